@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request Batching
+
+var (
+	ErrEmptyBatch    = errors.New("batch must hold at least one call")
+	ErrBatchTooLarge = errors.New("batch holds too many calls")
+	ErrNestedBatch   = errors.New("batches can't be nested")
+)
+
+// maxBatchSize is the most calls POST /batch accepts at once.
+const maxBatchSize = 1000
+
+// BatchCall is one API call inside a POST /batch request. IdempotencyKey is
+// sent as the call's Idempotency-Key header.
+type BatchCall struct {
+	Method         string
+	Path           string
+	Body           json.RawMessage `json:",omitempty"`
+	IdempotencyKey string          `json:",omitempty"`
+}
+
+// BatchResult is the answer to one BatchCall: the status and headers it
+// would have got on its own, such as Retry-After, with its JSON reply in
+// Body or, for statuses outside 2xx, its plain-text error in Error.
+type BatchResult struct {
+	Status int
+	Header http.Header     `json:",omitempty"`
+	Body   json.RawMessage `json:",omitempty"`
+	Error  string          `json:",omitempty"`
+}
+
+// err returns the result as a *StatusError if it failed.
+func (r BatchResult) err() error {
+	if r.Status >= 200 && r.Status <= 299 {
+		return nil
+	}
+	return &StatusError{Status: r.Status, Message: r.Error, RetryAfter: retryAfter(r.Header.Get("Retry-After"))}
+}
+
+// batch serves POST /batch by handing the calls to handler one after
+// another, in the order they were given, and answering with their results
+// in that order. Each call is handled as if it had been sent alone,
+// backpressure and idempotency keys included, so one failing doesn't fail
+// the others, and a call sees what the calls before it did.
+func (s *Server) batch(handler http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var calls []BatchCall
+		if !decode(w, r, &calls) {
+			return
+		}
+		switch {
+		case len(calls) == 0:
+			writeError(w, ErrEmptyBatch)
+			return
+		case len(calls) > maxBatchSize:
+			writeError(w, ErrBatchTooLarge)
+			return
+		}
+		results := make([]BatchResult, len(calls))
+		for i, call := range calls {
+			if strings.HasPrefix(call.Path, "/batch") {
+				results[i] = BatchResult{Status: statusFor(ErrNestedBatch), Error: ErrNestedBatch.Error()}
+				continue
+			}
+			request, err := http.NewRequestWithContext(r.Context(), call.Method, call.Path, bytes.NewReader(call.Body))
+			if err != nil {
+				results[i] = BatchResult{Status: http.StatusBadRequest, Error: err.Error()}
+				continue
+			}
+			request.Header.Set("User-Agent", r.UserAgent())
+			if authorization := r.Header.Get("Authorization"); authorization != "" {
+				request.Header.Set("Authorization", authorization)
+			}
+			if len(call.Body) > 0 {
+				request.Header.Set("Content-Type", "application/json")
+			}
+			if call.IdempotencyKey != "" {
+				request.Header.Set(idempotencyKeyHeader, call.IdempotencyKey)
+			}
+			recorder := &batchRecorder{header: http.Header{}, status: http.StatusOK}
+			handler.ServeHTTP(recorder, request)
+			results[i] = recorder.result()
+		}
+		writeJSON(w, http.StatusOK, results)
+	}
+}
+
+// batchRecorder collects one batched call's response.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *batchRecorder) Header() http.Header { return r.header }
+
+func (r *batchRecorder) WriteHeader(status int) { r.status = status }
+
+func (r *batchRecorder) Write(p []byte) (int, error) { return r.body.Write(p) }
+
+// result is the recorded response as a BatchResult. Its content type goes,
+// as the result carries the body itself.
+func (r *batchRecorder) result() BatchResult {
+	r.header.Del("Content-Type")
+	r.header.Del("X-Content-Type-Options")
+	result := BatchResult{Status: r.status}
+	if len(r.header) > 0 {
+		result.Header = r.header
+	}
+	body := bytes.TrimSpace(r.body.Bytes())
+	switch {
+	case r.status < 200 || r.status > 299:
+		result.Error = string(body)
+	case len(body) > 0:
+		result.Body = json.RawMessage(body)
+	}
+	return result
+}
+
+// BatchOptions sets how a batching Client groups its calls: a batch is sent
+// once it holds Size calls, or FlushInterval after its first call, whichever
+// comes first.
+type BatchOptions struct {
+	Size          int
+	FlushInterval time.Duration
+}
+
+var DefaultBatchOptions = BatchOptions{Size: 32, FlushInterval: time.Millisecond}
+
+// batcher groups a Client's calls into POST /batch requests.
+type batcher struct {
+	client  *Client
+	options BatchOptions
+	mutex   sync.Mutex
+	pending []pendingCall
+	timer   *time.Timer
+	sent    sync.WaitGroup
+}
+
+type pendingCall struct {
+	call BatchCall
+	done chan batchOutcome
+}
+
+type batchOutcome struct {
+	result BatchResult
+	err    error
+}
+
+// EnableBatching makes c send its calls in batches through POST /batch, so
+// many goroutines sharing c pay for one round trip between them. Every call
+// still blocks until its own result is back. It must be called before c is
+// shared between goroutines, and Close sends whatever is still pending.
+func (c *Client) EnableBatching(options BatchOptions) {
+	c.batcher = &batcher{client: c, options: options}
+}
+
+// Close sends any calls waiting to be batched and waits for their results.
+// It does nothing for a client that doesn't batch.
+func (c *Client) Close() {
+	if c.batcher != nil {
+		c.batcher.flush()
+		c.batcher.sent.Wait()
+	}
+}
+
+// call queues one call and waits for its result.
+func (b *batcher) call(call BatchCall) (BatchResult, error) {
+	done := make(chan batchOutcome, 1)
+	b.mutex.Lock()
+	b.pending = append(b.pending, pendingCall{call: call, done: done})
+	switch {
+	case len(b.pending) >= b.options.Size:
+		b.sendLocked()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.options.FlushInterval, b.flush)
+	}
+	b.mutex.Unlock()
+	outcome := <-done
+	return outcome.result, outcome.err
+}
+
+func (b *batcher) flush() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.pending) > 0 {
+		b.sendLocked()
+	}
+}
+
+// sendLocked sends the pending calls as one batch in the background.
+// Callers must hold b.mutex.
+func (b *batcher) sendLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	b.sent.Add(1)
+	go func() {
+		defer b.sent.Done()
+		calls := make([]BatchCall, len(batch))
+		for i, pending := range batch {
+			calls[i] = pending.call
+		}
+		var results []BatchResult
+		err := b.client.send(http.MethodPost, "/batch", calls, &results)
+		if err == nil && len(results) != len(batch) {
+			err = errors.New("batch answered with the wrong number of results")
+		}
+		for i, pending := range batch {
+			if err != nil {
+				pending.done <- batchOutcome{err: err}
+			} else {
+				pending.done <- batchOutcome{result: results[i]}
+			}
+		}
+	}()
+}
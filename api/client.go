@@ -0,0 +1,438 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Client SDK
+
+// DefaultClientTimeout bounds each request a client made by NewClient sends,
+// including reading the reply.
+const DefaultClientTimeout = 10 * time.Second
+
+// RetryOptions says how a Client retries failed requests. Attempts counts
+// the first try, so 1 turns retries off. The wait before each retry starts
+// at Backoff and doubles up to MaxBackoff, unless the server asked for a
+// longer one with Retry-After; a request the server asks to hold off for
+// longer than MaxBackoff, such as one over a daily quota, isn't retried.
+// With IdempotencyKeys, each POST and DELETE carries a fresh
+// Idempotency-Key so it can be retried after a lost reply without being
+// applied twice.
+type RetryOptions struct {
+	Attempts        int
+	Backoff         time.Duration
+	MaxBackoff      time.Duration
+	IdempotencyKeys bool
+}
+
+// DefaultRetryOptions are the retry options NewClient starts with.
+var DefaultRetryOptions = RetryOptions{Attempts: 4, Backoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second, IdempotencyKeys: true}
+
+// StatusError is a request the server refused: its status code, the
+// plain-text error it sent and how long its Retry-After header asked the
+// client to wait, if it sent one.
+type StatusError struct {
+	Status     int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", err.Status, http.StatusText(err.Status), err.Message)
+}
+
+// Client calls the REST API served by Handler. UserAgent is sent with every
+// request so servers can tell clients, such as bots, apart, and Token, if
+// set, as the bearer token the server meters requests by. Requests are
+// retried as Retry says. A Client may be shared between goroutines; set its
+// fields before it is.
+type Client struct {
+	BaseURL   string
+	UserAgent string
+	Token     string
+	HTTP      *http.Client
+	Retry     RetryOptions
+	batcher   *batcher
+}
+
+// NewClient returns a client for the API at baseURL, such as
+// "http://localhost:8080", whose requests time out after
+// DefaultClientTimeout and are retried with DefaultRetryOptions.
+func NewClient(baseURL, userAgent string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), UserAgent: userAgent, HTTP: &http.Client{Timeout: DefaultClientTimeout}, Retry: DefaultRetryOptions}
+}
+
+// RegisterUser creates a user.
+func (c *Client) RegisterUser(username string) (engine.UserProfile, error) {
+	var profile engine.UserProfile
+	err := c.do(http.MethodPost, "/users", struct{ Username string }{username}, &profile)
+	return profile, err
+}
+
+// GetUser returns a user's profile.
+func (c *Client) GetUser(username string) (engine.UserProfile, error) {
+	var profile engine.UserProfile
+	err := c.do(http.MethodGet, "/users/"+url.PathEscape(username), nil, &profile)
+	return profile, err
+}
+
+// GetFeed returns the user's feed in the given order: new, hot,
+// personalized, best, karma_weighted, controversial, or "" for hot.
+func (c *Client) GetFeed(username, sort string) ([]engine.FeedItem, error) {
+	path := "/users/" + url.PathEscape(username) + "/feed"
+	if sort != "" {
+		path += "?sort=" + url.QueryEscape(sort)
+	}
+	var feed []engine.FeedItem
+	err := c.do(http.MethodGet, path, nil, &feed)
+	return feed, err
+}
+
+// GetMessages returns the direct messages in the user's inbox.
+func (c *Client) GetMessages(username string) ([]Message, error) {
+	var messages []Message
+	err := c.do(http.MethodGet, "/users/"+url.PathEscape(username)+"/messages", nil, &messages)
+	return messages, err
+}
+
+// GetMessageRequests returns the message requests waiting for the user,
+// with the messages each holds.
+func (c *Client) GetMessageRequests(username string) ([]MessageRequest, error) {
+	var requests []MessageRequest
+	err := c.do(http.MethodGet, "/users/"+url.PathEscape(username)+"/message-requests", nil, &requests)
+	return requests, err
+}
+
+// AcceptMessageRequest moves from's messages to the user into their inbox.
+func (c *Client) AcceptMessageRequest(username, from string) error {
+	return c.do(http.MethodPost, "/users/"+url.PathEscape(username)+"/message-requests/"+url.PathEscape(from)+"/accept", nil, nil)
+}
+
+// DeclineMessageRequest drops from's message request to the user.
+func (c *Client) DeclineMessageRequest(username, from string) error {
+	return c.do(http.MethodPost, "/users/"+url.PathEscape(username)+"/message-requests/"+url.PathEscape(from)+"/decline", nil, nil)
+}
+
+// FollowUser makes follower follow the user named target.
+func (c *Client) FollowUser(follower, target string) error {
+	return c.do(http.MethodPost, "/users/"+url.PathEscape(target)+"/followers", struct{ User string }{follower}, nil)
+}
+
+// UnfollowUser makes follower stop following the user named target.
+func (c *Client) UnfollowUser(follower, target string) error {
+	return c.do(http.MethodDelete, "/users/"+url.PathEscape(target)+"/followers/"+url.PathEscape(follower), nil, nil)
+}
+
+// CreateSubReddit creates a subreddit.
+func (c *Client) CreateSubReddit(name string) (SubReddit, error) {
+	var subReddit SubReddit
+	err := c.do(http.MethodPost, "/subreddits", struct{ Name string }{name}, &subReddit)
+	return subReddit, err
+}
+
+// JoinSubReddit subscribes user to the subreddit.
+func (c *Client) JoinSubReddit(user, subReddit string) error {
+	return c.do(http.MethodPost, "/subreddits/"+url.PathEscape(subReddit)+"/members", struct{ User string }{user}, nil)
+}
+
+// LeaveSubReddit unsubscribes user from the subreddit.
+func (c *Client) LeaveSubReddit(user, subReddit string) error {
+	return c.do(http.MethodDelete, "/subreddits/"+url.PathEscape(subReddit)+"/members/"+url.PathEscape(user), nil, nil)
+}
+
+// GetSubRedditPosts returns the subreddit's listed posts as user sees them,
+// each with its whole thread.
+func (c *Client) GetSubRedditPosts(user, subReddit string) ([]engine.Thread, error) {
+	var threads []engine.Thread
+	err := c.do(http.MethodGet, "/subreddits/"+url.PathEscape(subReddit)+"/posts?user="+url.QueryEscape(user), nil, &threads)
+	return threads, err
+}
+
+// GetSubRedditStats returns how many users posted, commented and voted in
+// the subreddit over the last window, or all time if window is 0.
+func (c *Client) GetSubRedditStats(subReddit string, window time.Duration) (engine.EngagementStats, error) {
+	path := "/subreddits/" + url.PathEscape(subReddit) + "/stats"
+	if window > 0 {
+		path += "?window=" + window.String()
+	}
+	var stats engine.EngagementStats
+	err := c.do(http.MethodGet, path, nil, &stats)
+	return stats, err
+}
+
+// CreatePost submits a text post as user.
+func (c *Client) CreatePost(user, subReddit, content string) (engine.Thread, error) {
+	var thread engine.Thread
+	err := c.do(http.MethodPost, "/subreddits/"+url.PathEscape(subReddit)+"/posts", struct{ User, Content string }{user, content}, &thread)
+	return thread, err
+}
+
+// CreateLinkPost submits a post titled title linking to link as user.
+func (c *Client) CreateLinkPost(user, subReddit, title, link string) (engine.Thread, error) {
+	var thread engine.Thread
+	err := c.do(http.MethodPost, "/subreddits/"+url.PathEscape(subReddit)+"/posts", struct{ User, Content, URL string }{user, title, link}, &thread)
+	return thread, err
+}
+
+// GetThread returns a post and its whole comment tree.
+func (c *Client) GetThread(postID int64) (engine.Thread, error) {
+	var thread engine.Thread
+	err := c.do(http.MethodGet, "/posts/"+strconv.FormatInt(postID, 10), nil, &thread)
+	return thread, err
+}
+
+// GetThreadSummary returns a summary of a post's thread.
+func (c *Client) GetThreadSummary(postID int64) (engine.ThreadSummary, error) {
+	var summary engine.ThreadSummary
+	err := c.do(http.MethodGet, "/posts/"+strconv.FormatInt(postID, 10)+"/summary", nil, &summary)
+	return summary, err
+}
+
+// GetComment returns one comment.
+func (c *Client) GetComment(commentID int64) (Comment, error) {
+	var comment Comment
+	err := c.do(http.MethodGet, "/comments/"+strconv.FormatInt(commentID, 10), nil, &comment)
+	return comment, err
+}
+
+// CommentPost comments on a post as user.
+func (c *Client) CommentPost(user string, postID int64, content string) (Comment, error) {
+	var comment Comment
+	err := c.do(http.MethodPost, "/posts/"+strconv.FormatInt(postID, 10)+"/comments", struct{ User, Content string }{user, content}, &comment)
+	return comment, err
+}
+
+// Reply replies to a comment as user.
+func (c *Client) Reply(user string, commentID int64, content string) (Comment, error) {
+	var comment Comment
+	err := c.do(http.MethodPost, "/comments/"+strconv.FormatInt(commentID, 10)+"/replies", struct{ User, Content string }{user, content}, &comment)
+	return comment, err
+}
+
+// VotePost sets user's vote on a post: 1, -1, or 0 to withdraw it.
+func (c *Client) VotePost(user string, postID int64, direction int) (Vote, error) {
+	return c.vote("/posts/"+strconv.FormatInt(postID, 10)+"/votes", user, direction)
+}
+
+// VoteComment sets user's vote on a comment: 1, -1, or 0 to withdraw it.
+func (c *Client) VoteComment(user string, commentID int64, direction int) (Vote, error) {
+	return c.vote("/comments/"+strconv.FormatInt(commentID, 10)+"/votes", user, direction)
+}
+
+func (c *Client) vote(path, user string, direction int) (Vote, error) {
+	var vote Vote
+	err := c.do(http.MethodPost, path, struct {
+		User      string
+		Direction int
+	}{user, direction}, &vote)
+	return vote, err
+}
+
+// SendMessage sends a direct message.
+func (c *Client) SendMessage(from, to, content string) error {
+	return c.do(http.MethodPost, "/messages", struct{ From, To, Content string }{from, to, content}, nil)
+}
+
+// Events returns up to limit events logged after the one numbered after,
+// oldest first. Following the site means calling it again with the Seq of
+// the last event returned.
+func (c *Client) Events(after, limit int) ([]engine.Event, error) {
+	var events []engine.Event
+	err := c.do(http.MethodGet, fmt.Sprintf("/events?after=%d&limit=%d", after, limit), nil, &events)
+	return events, err
+}
+
+// Karma returns the user's karma as the server and its shared store count
+// it.
+func (c *Client) Karma(username string) (Karma, error) {
+	var karma Karma
+	err := c.do(http.MethodGet, "/users/"+url.PathEscape(username)+"/karma", nil, &karma)
+	return karma, err
+}
+
+// Usage returns the requests the server has counted against c's token.
+func (c *Client) Usage() (Usage, error) {
+	var usage Usage
+	err := c.do(http.MethodGet, "/usage", nil, &usage)
+	return usage, err
+}
+
+// do sends body as JSON, if it isn't nil, and decodes the reply into reply,
+// if that isn't nil. Replies outside 2xx come back as a *StatusError. A
+// batching client queues the call for the next batch instead of sending it
+// on its own, and retries it in a later batch as c.Retry allows if its own
+// result failed.
+func (c *Client) do(method, path string, body, reply interface{}) error {
+	if c.batcher == nil {
+		return c.send(method, path, body, reply)
+	}
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	call := BatchCall{Method: method, Path: path, Body: encoded, IdempotencyKey: c.idempotencyKey(method)}
+	var result BatchResult
+	err := c.retrying(idempotent(method, call.IdempotencyKey), func() error {
+		var err error
+		if result, err = c.batcher.call(call); err != nil {
+			return batchError{err}
+		}
+		return result.err()
+	})
+	var failed batchError
+	if errors.As(err, &failed) {
+		return failed.err
+	}
+	if err != nil || reply == nil || len(result.Body) == 0 {
+		return err
+	}
+	return json.Unmarshal(result.Body, reply)
+}
+
+// batchError is a batch that failed as a whole. send has already retried
+// it, so do doesn't again.
+type batchError struct{ err error }
+
+func (e batchError) Error() string { return e.err.Error() }
+
+// send makes the HTTP request for do, retrying it as c.Retry allows. POSTs,
+// batches included, get an Idempotency-Key, which every retry repeats.
+func (c *Client) send(method, path string, body, reply interface{}) error {
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	key := c.idempotencyKey(method)
+	return c.retrying(idempotent(method, key), func() error {
+		return c.sendOnce(method, path, body != nil, encoded, key, reply)
+	})
+}
+
+// idempotencyKey returns a fresh Idempotency-Key for a request sent with
+// method, or "" if it needs none.
+func (c *Client) idempotencyKey(method string) string {
+	if c.Retry.IdempotencyKeys && c.Retry.Attempts > 1 && (method == http.MethodPost || method == http.MethodDelete) {
+		return newIdempotencyKey()
+	}
+	return ""
+}
+
+// idempotent reports whether a request may be applied more than once to the
+// same effect.
+func idempotent(method, key string) bool {
+	return key != "" || method == http.MethodGet || method == http.MethodDelete
+}
+
+// retrying makes attempt until it succeeds, fails in a way retryWait won't
+// retry, or c.Retry.Attempts are used up, backing off between tries.
+func (c *Client) retrying(idempotent bool, attempt func() error) error {
+	backoff := c.Retry.Backoff
+	for tries := 1; ; tries++ {
+		err := attempt()
+		wait, retry := c.retryWait(err, idempotent, backoff)
+		if !retry || tries >= c.Retry.Attempts {
+			return err
+		}
+		time.Sleep(wait)
+		backoff = min(2*backoff, c.Retry.MaxBackoff)
+	}
+}
+
+// retryWait says whether a request that failed with err may be sent again,
+// and after how long. Requests refused with 429 or 503 were never handled,
+// so they are always retried, after at least the server's Retry-After. A
+// request lost in transit or at a gateway may already have been applied,
+// so it is only retried if it is idempotent.
+func (c *Client) retryWait(err error, idempotent bool, backoff time.Duration) (time.Duration, bool) {
+	var refused *StatusError
+	var lost *url.Error
+	switch {
+	case errors.As(err, &refused):
+		switch refused.Status {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return max(refused.RetryAfter, backoff), refused.RetryAfter <= c.Retry.MaxBackoff
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return backoff, idempotent
+		}
+	case errors.As(err, &lost):
+		return backoff, idempotent
+	}
+	return 0, false
+}
+
+// sendOnce makes one HTTP request for send.
+func (c *Client) sendOnce(method, path string, hasBody bool, encoded []byte, key string, reply interface{}) error {
+	var payload io.Reader
+	if hasBody {
+		payload = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequest(method, c.BaseURL+path, payload)
+	if err != nil {
+		return err
+	}
+	if hasBody {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.UserAgent != "" {
+		request.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if key != "" {
+		request.Header.Set(idempotencyKeyHeader, key)
+	}
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(response.Body)
+		return &StatusError{Status: response.StatusCode, Message: strings.TrimSpace(string(message)), RetryAfter: retryAfter(response.Header.Get("Retry-After"))}
+	}
+	if reply == nil {
+		io.Copy(io.Discard, response.Body)
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(reply)
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date, into how long to wait from now. It is 0 if the header is missing
+// or malformed.
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// newIdempotencyKey returns a random Idempotency-Key.
+func newIdempotencyKey() string {
+	var key [16]byte
+	rand.Read(key[:])
+	return hex.EncodeToString(key[:])
+}
@@ -0,0 +1,5 @@
+// Package api serves an engine.Engine over HTTP as a JSON REST API, so the
+// engine can back a real client instead of only the simulator. Requests act
+// as the user named in their body or path; there is no authentication, so
+// the API is meant to sit behind one. Client is a Go SDK for it.
+package api
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Idempotent Requests
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+
+	// DefaultIdempotencyTTL is how long servers remember idempotent
+	// responses.
+	DefaultIdempotencyTTL = 24 * time.Hour
+)
+
+// IdempotencyStore remembers the responses to mutating requests sent with an
+// Idempotency-Key header, so a client that retries after a timeout gets the
+// original response back instead of voting or posting twice. Keys belong to
+// the API token that sent them and expire after TTL. A retry that arrives
+// while the original is still being handled is rejected with 409, and
+// reusing a key for a different request with 422.
+type IdempotencyStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	now      func() time.Time
+	entries  map[string]*idempotentResponse
+	swept    time.Time
+	replayed int
+	expired  int
+}
+
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	expires     time.Time
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+// NewIdempotencyStore returns a store that keeps responses for ttl.
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{ttl: ttl, now: time.Now, entries: make(map[string]*idempotentResponse)}
+}
+
+// Middleware applies the store to every POST and DELETE request that
+// carries a key. Other requests pass straight through. Only successful
+// responses are kept; a failed request releases its key so the client can
+// retry it, as does one whose handler panics.
+func (s *IdempotencyStore) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodDelete) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "idempotency key is too long", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		key = token(r) + " " + key
+		fingerprint := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+
+		entry, status := s.claim(key, fingerprint)
+		switch status {
+		case http.StatusOK:
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(idempotencyReplayedHeader, "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		case http.StatusConflict:
+			http.Error(w, "a request with this idempotency key is in progress", http.StatusConflict)
+			return
+		case http.StatusUnprocessableEntity:
+			http.Error(w, "idempotency key was used for a different request", http.StatusUnprocessableEntity)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		finished := false
+		defer func() {
+			if !finished {
+				s.release(key)
+			}
+		}()
+		handler.ServeHTTP(recorder, r)
+		s.finish(key, recorder)
+		finished = true
+	})
+}
+
+// claim looks key up. It returns the stored response with 200 if the request
+// is a replay, 409 or 422 if it must be rejected, and 0 after reserving the
+// key for a new request.
+func (s *IdempotencyStore) claim(key string, fingerprint [sha256.Size]byte) (*idempotentResponse, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	if entry, exists := s.entries[key]; exists && now.Before(entry.expires) {
+		switch {
+		case entry.fingerprint != fingerprint:
+			return nil, http.StatusUnprocessableEntity
+		case !entry.done:
+			return nil, http.StatusConflict
+		}
+		s.replayed++
+		return entry, http.StatusOK
+	}
+	s.entries[key] = &idempotentResponse{fingerprint: fingerprint, expires: now.Add(s.ttl)}
+	return nil, 0
+}
+
+// Replays returns how many retried requests were answered from the store.
+func (s *IdempotencyStore) Replays() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replayed
+}
+
+func (s *IdempotencyStore) finish(key string, recorder *responseRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if recorder.status >= 300 {
+		delete(s.entries, key)
+		return
+	}
+	entry := s.entries[key]
+	entry.done = true
+	entry.status = recorder.status
+	entry.header = recorder.Header().Clone()
+	entry.body = recorder.body.Bytes()
+}
+
+// release forgets the key of a request that ended without a response to
+// keep.
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// sweep drops expired keys at most once per TTL, including any still marked
+// in progress. Callers must hold s.mu.
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < s.ttl {
+		return
+	}
+	s.swept = now
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+			s.expired++
+		}
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
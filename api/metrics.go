@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Prometheus Metrics
+
+// MetricsCollector writes more metrics in the Prometheus text format after
+// the engine's own.
+type MetricsCollector func(w io.Writer) error
+
+// MetricsHandler serves e's counters in the Prometheus text format, followed
+// by whatever the collectors write, so Prometheus can scrape a long-running
+// simulation or server.
+func MetricsHandler(e *engine.Engine, collectors ...MetricsCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		err := e.WriteMetrics(&b)
+		for _, collect := range collectors {
+			if err == nil {
+				err = collect(&b)
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(b.Bytes())
+	})
+}
+
+// timed records how long each request takes, by the route that served it.
+func (s *Server) timed(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		began := time.Now()
+		handler.ServeHTTP(w, r)
+		if r.Pattern == "" {
+			return
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		histogram := s.latencies[r.Pattern]
+		if histogram == nil {
+			histogram = &engine.LatencyHistogram{}
+			s.latencies[r.Pattern] = histogram
+		}
+		histogram.Record(time.Since(began))
+	})
+}
+
+// writeRequestMetrics writes the request latencies timed collected.
+func (s *Server) writeRequestMetrics(w io.Writer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return engine.WriteHistogramMetrics(w, "http_request_duration_seconds", "REST API request latency by route.", "route", s.latencies)
+}
@@ -0,0 +1,763 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// REST API
+
+var (
+	ErrUnknownUser      = errors.New("unknown user")
+	ErrEmptyName        = errors.New("subreddit name must not be empty")
+	ErrInvalidID        = errors.New("invalid ID")
+	ErrInvalidDirection = errors.New("direction must be 1, 0 or -1")
+	ErrUnknownSort      = errors.New("unknown feed sort")
+	ErrInvalidQuery     = errors.New("invalid query parameter")
+)
+
+// maxEventPage is the most events GET /events returns at once.
+const maxEventPage = 1000
+
+// feedSorts maps the sort query parameter of feed requests to engine sorts.
+var feedSorts = map[string]engine.FeedSort{
+	"new":            engine.SortNew,
+	"hot":            engine.SortHot,
+	"personalized":   engine.SortPersonalized,
+	"best":           engine.SortBest,
+	"karma_weighted": engine.SortKarmaWeighted,
+	"controversial":  engine.SortControversial,
+}
+
+// Comment is a comment as the API returns it. ParentID is 0 for top-level
+// comments.
+type Comment struct {
+	ID        int64
+	PostID    int64
+	ParentID  int64 `json:",omitempty"`
+	Permalink string
+	Author    string
+	Content   string
+	Votes     int
+	CreatedAt time.Time
+}
+
+// Message is a direct message as the API returns it.
+type Message struct {
+	From    string
+	To      string
+	Content string
+	SentAt  time.Time
+}
+
+// MessageRequest is a pending message request as the API returns it, with
+// the messages it holds.
+type MessageRequest struct {
+	From     string
+	SentAt   time.Time
+	Messages []Message
+}
+
+// Vote is the reply to a vote: the post or comment's score after it.
+type Vote struct {
+	ID    int64
+	Votes int
+}
+
+// SubReddit is a subreddit as the API returns it on creation.
+type SubReddit struct {
+	Name     string
+	Settings engine.SubRedditSettings
+}
+
+// Karma is a user's karma as this instance counts it and as the shared
+// store, which every instance sharing it adds to, counts it.
+type Karma struct {
+	Username string
+	Karma    int
+	Shared   int64
+	Store    string
+}
+
+// Server exposes one engine over HTTP. DailyQuota caps the requests each
+// API token may send per UTC day, 0 meaning unlimited. SharedFeeds serves
+// feeds through the engine's shared feed cache, so API instances sharing a
+// store reuse each other's feeds. Set both before the server starts
+// serving.
+type Server struct {
+	DailyQuota  int
+	SharedFeeds bool
+	engine      *engine.Engine
+	idempotency *IdempotencyStore
+	mutex       sync.Mutex
+	latencies   map[string]*engine.LatencyHistogram
+	usage       map[string]*tokenUsage
+}
+
+// NewServer returns a server for e.
+func NewServer(e *engine.Engine) *Server {
+	return &Server{engine: e, idempotency: NewIdempotencyStore(DefaultIdempotencyTTL), latencies: make(map[string]*engine.LatencyHistogram), usage: make(map[string]*tokenUsage)}
+}
+
+// Handler serves the REST API. Bodies are JSON objects with the fields
+// shown:
+//
+//	POST   /users                          {Username}
+//	GET    /users/{username}
+//	GET    /users/{username}/feed?sort=S   (new, hot, personalized, best, karma_weighted or controversial; hot by default)
+//	GET    /users/{username}/karma         (the user's karma here and in the shared store)
+//	GET    /users/{username}/messages
+//	GET    /users/{username}/message-requests
+//	POST   /users/{username}/message-requests/{from}/accept
+//	POST   /users/{username}/message-requests/{from}/decline
+//	POST   /users/{username}/followers     {User}
+//	DELETE /users/{username}/followers/{follower}
+//	POST   /subreddits                     {Name}
+//	POST   /subreddits/{name}/members      {User}
+//	DELETE /subreddits/{name}/members/{username}
+//	GET    /subreddits/{name}/posts?user=U
+//	GET    /subreddits/{name}/stats?window=W   (distinct posters, commenters and voters over the last W, such as 24h; all time by default)
+//	POST   /subreddits/{name}/posts        {User, Content, URL}  (URL makes a link post titled Content)
+//	GET    /posts/{id}                     (the whole thread)
+//	GET    /posts/{id}/summary             (a summary of the thread, cached until it gains enough new comments)
+//	POST   /posts/{id}/comments            {User, Content}
+//	POST   /posts/{id}/votes               {User, Direction}  (Direction 0 withdraws the user's vote)
+//	GET    /comments/{id}
+//	POST   /comments/{id}/replies          {User, Content}
+//	POST   /comments/{id}/votes            {User, Direction}  (Direction 0 withdraws the user's vote)
+//	POST   /messages                       {From, To, Content}
+//	GET    /events?after=N&limit=L         (up to L events logged after event N, oldest first, and never more than 1000)
+//	POST   /batch                          [{Method, Path, Body, IdempotencyKey}, ...]  (up to 1000 of the calls above, run in order and answered with [{Status, Header, Body, Error}, ...])
+//	GET    /metrics                        (engine counters and request latency by route, in the Prometheus text format)
+//	GET    /usage                          (the calling token's request counts, error rate and remaining daily quota)
+//
+// Errors are plain text with a status matching the engine's sentinel
+// error: 404 for unknown users and content, 403 when the user may not act,
+// 409 for taken names, repeated or missing votes and follows, 501 when no
+// summarizer is configured, 429 for tokens over their daily quota, 502
+// when the shared store fails, and 400 otherwise.
+// Requests that change state answer 503 with a Retry-After header while the
+// engine signals backpressure.
+//
+// POSTs and DELETEs may carry an Idempotency-Key header: a retry with
+// the same key, token and request within a day gets the original response,
+// marked with an Idempotent-Replayed header, rather than being applied
+// again.
+//
+// Clients identify themselves with an "Authorization: Bearer <token>"
+// header; requests without one share the token "anonymous". Each token's
+// requests are counted and limited to DailyQuota.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users", s.mutating(s.registerUser))
+	mux.HandleFunc("GET /users/{username}", s.getUser)
+	mux.HandleFunc("GET /users/{username}/feed", s.getFeed)
+	mux.HandleFunc("GET /users/{username}/karma", s.getKarma)
+	mux.HandleFunc("GET /users/{username}/messages", s.getMessages)
+	mux.HandleFunc("GET /users/{username}/message-requests", s.getMessageRequests)
+	mux.HandleFunc("POST /users/{username}/message-requests/{from}/accept", s.mutating(s.settleMessageRequest(s.engine.AcceptDMRequestContext)))
+	mux.HandleFunc("POST /users/{username}/message-requests/{from}/decline", s.mutating(s.settleMessageRequest(s.engine.DeclineDMRequestContext)))
+	mux.HandleFunc("POST /users/{username}/followers", s.mutating(s.followUser))
+	mux.HandleFunc("DELETE /users/{username}/followers/{follower}", s.mutating(s.unfollowUser))
+	mux.HandleFunc("POST /subreddits", s.mutating(s.createSubReddit))
+	mux.HandleFunc("POST /subreddits/{name}/members", s.mutating(s.joinSubReddit))
+	mux.HandleFunc("DELETE /subreddits/{name}/members/{username}", s.mutating(s.leaveSubReddit))
+	mux.HandleFunc("GET /subreddits/{name}/posts", s.getSubRedditPosts)
+	mux.HandleFunc("GET /subreddits/{name}/stats", s.getSubRedditStats)
+	mux.HandleFunc("POST /subreddits/{name}/posts", s.mutating(s.createPost))
+	mux.HandleFunc("GET /posts/{id}", s.getPost)
+	mux.HandleFunc("GET /posts/{id}/summary", s.getPostSummary)
+	mux.HandleFunc("POST /posts/{id}/comments", s.mutating(s.commentPost))
+	mux.HandleFunc("POST /posts/{id}/votes", s.mutating(s.votePost))
+	mux.HandleFunc("GET /comments/{id}", s.getComment)
+	mux.HandleFunc("POST /comments/{id}/replies", s.mutating(s.replyToComment))
+	mux.HandleFunc("POST /comments/{id}/votes", s.mutating(s.voteComment))
+	mux.HandleFunc("POST /messages", s.mutating(s.sendMessage))
+	mux.HandleFunc("GET /events", s.getEvents)
+	mux.HandleFunc("GET /usage", s.getUsage)
+	mux.Handle("GET /metrics", MetricsHandler(s.engine, s.writeRequestMetrics))
+	metered := s.metered(mux)
+	mux.HandleFunc("POST /batch", s.mutating(s.batch(s.timed(metered))))
+	return s.timed(metered)
+}
+
+// mutating sheds requests that would change state while the engine signals
+// backpressure, and answers requests retried with the same Idempotency-Key
+// from the idempotency store, even while shedding.
+func (s *Server) mutating(handler http.HandlerFunc) http.HandlerFunc {
+	return s.idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var overloaded *engine.BackpressureError
+		if errors.As(s.engine.Backpressure(), &overloaded) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(overloaded.RetryAfter.Seconds()))))
+			http.Error(w, overloaded.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	})).ServeHTTP
+}
+
+func (s *Server) registerUser(w http.ResponseWriter, r *http.Request) {
+	var body struct{ Username string }
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Username == "" {
+		writeError(w, engine.ErrInvalidUsername)
+		return
+	}
+	user, err := s.engine.RegisterUserContext(r.Context(), body.Username)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.engine.GetUserProfile(user))
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	user, err := s.user(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.engine.GetUserProfile(user))
+}
+
+func (s *Server) getFeed(w http.ResponseWriter, r *http.Request) {
+	user, err := s.user(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	order := engine.SortHot
+	if name := r.URL.Query().Get("sort"); name != "" {
+		var known bool
+		if order, known = feedSorts[name]; !known {
+			writeError(w, ErrUnknownSort)
+			return
+		}
+	}
+	if s.SharedFeeds {
+		writeJSON(w, http.StatusOK, s.engine.CachedFeedItems(user, order))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.engine.GetFeedItems(user, order))
+}
+
+func (s *Server) getKarma(w http.ResponseWriter, r *http.Request) {
+	user, err := s.user(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	shared, err := s.engine.SharedKarma(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	profile := s.engine.GetUserProfile(user)
+	s.engine.Mutex.RLock()
+	store := s.engine.Shared.Name()
+	s.engine.Mutex.RUnlock()
+	writeJSON(w, http.StatusOK, Karma{Username: profile.Username, Karma: profile.Karma, Shared: shared, Store: store})
+}
+
+func (s *Server) getMessages(w http.ResponseWriter, r *http.Request) {
+	user, err := s.user(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	inbox := s.engine.RetrieveMessages(user)
+	s.engine.Mutex.RLock()
+	messages := make([]Message, 0, len(inbox))
+	for _, message := range inbox {
+		messages = append(messages, Message{From: message.From.Username, To: message.To.Username, Content: message.Content, SentAt: message.SentAt})
+	}
+	s.engine.Mutex.RUnlock()
+	writeJSON(w, http.StatusOK, messages)
+}
+
+func (s *Server) getMessageRequests(w http.ResponseWriter, r *http.Request) {
+	user, err := s.user(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pending := s.engine.GetDMRequests(user)
+	held := s.engine.GetRequestFolder(user)
+	s.engine.Mutex.RLock()
+	requests := make([]MessageRequest, 0, len(pending))
+	for _, request := range pending {
+		messages := []Message{}
+		for _, message := range held {
+			if message.From == request.From {
+				messages = append(messages, Message{From: message.From.Username, To: message.To.Username, Content: message.Content, SentAt: message.SentAt})
+			}
+		}
+		requests = append(requests, MessageRequest{From: request.From.Username, SentAt: request.SentAt, Messages: messages})
+	}
+	s.engine.Mutex.RUnlock()
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// settleMessageRequest accepts or declines the request from {from} to
+// {username}.
+func (s *Server) settleMessageRequest(settle func(ctx context.Context, user, from *engine.User) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.user(r.PathValue("username"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		from, err := s.user(r.PathValue("from"))
+		if err == nil {
+			err = settle(r.Context(), user, from)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) followUser(w http.ResponseWriter, r *http.Request) {
+	var body struct{ User string }
+	if !decode(w, r, &body) {
+		return
+	}
+	target, err := s.user(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	follower, err := s.user(body.User)
+	if err == nil {
+		err = s.engine.FollowUserContext(r.Context(), follower, target)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	target, err := s.user(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	follower, err := s.user(r.PathValue("follower"))
+	if err == nil {
+		err = s.engine.UnfollowUserContext(r.Context(), follower, target)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createSubReddit(w http.ResponseWriter, r *http.Request) {
+	var body struct{ Name string }
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Name == "" {
+		writeError(w, ErrEmptyName)
+		return
+	}
+	if _, err := s.engine.CreateSubRedditContext(r.Context(), body.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	settings, _ := s.engine.GetSubRedditSettings(body.Name)
+	writeJSON(w, http.StatusCreated, SubReddit{Name: body.Name, Settings: settings})
+}
+
+func (s *Server) joinSubReddit(w http.ResponseWriter, r *http.Request) {
+	var body struct{ User string }
+	if !decode(w, r, &body) {
+		return
+	}
+	name := r.PathValue("name")
+	user, err := s.user(body.User)
+	if err == nil {
+		err = s.engine.JoinSubRedditContext(r.Context(), user, name)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) leaveSubReddit(w http.ResponseWriter, r *http.Request) {
+	user, err := s.user(r.PathValue("username"))
+	if err == nil {
+		err = s.engine.LeaveSubRedditContext(r.Context(), user, r.PathValue("name"))
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getSubRedditPosts(w http.ResponseWriter, r *http.Request) {
+	user, err := s.user(r.URL.Query().Get("user"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	posts, err := s.engine.GetSubRedditFeed(user, r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	threads := make([]json.RawMessage, 0, len(posts))
+	for _, post := range posts {
+		if thread, err := s.engine.ExportThread(post.ID, engine.ThreadJSON); err == nil {
+			threads = append(threads, thread)
+		}
+	}
+	writeJSON(w, http.StatusOK, threads)
+}
+
+func (s *Server) getSubRedditStats(w http.ResponseWriter, r *http.Request) {
+	var window time.Duration
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			writeError(w, ErrInvalidQuery)
+			return
+		}
+		window = parsed
+	}
+	stats, err := s.engine.GetEngagementStats(r.PathValue("name"), window)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) createPost(w http.ResponseWriter, r *http.Request) {
+	var body struct{ User, Content, URL string }
+	if !decode(w, r, &body) {
+		return
+	}
+	user, err := s.user(body.User)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	name := r.PathValue("name")
+	var post *engine.Post
+	if body.URL != "" {
+		post, err = s.engine.CreateLinkPostContext(r.Context(), user, name, body.Content, body.URL)
+	} else {
+		post, err = s.engine.CreatePostContext(r.Context(), user, name, body.Content)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeThread(w, http.StatusCreated, post.ID)
+}
+
+func (s *Server) getPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, ErrInvalidID)
+		return
+	}
+	s.writeThread(w, http.StatusOK, id)
+}
+
+func (s *Server) getPostSummary(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, ErrInvalidID)
+		return
+	}
+	summary, err := s.engine.SummarizeThread(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) writeThread(w http.ResponseWriter, status int, postID int64) {
+	thread, err := s.engine.ExportThread(postID, engine.ThreadJSON)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(thread)
+}
+
+func (s *Server) commentPost(w http.ResponseWriter, r *http.Request) {
+	var body struct{ User, Content string }
+	if !decode(w, r, &body) {
+		return
+	}
+	user, err := s.user(body.User)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	post, err := s.post(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	comment, err := s.engine.CommentPostContext(r.Context(), user, post, body.Content)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeComment(w, http.StatusCreated, comment, 0)
+}
+
+func (s *Server) replyToComment(w http.ResponseWriter, r *http.Request) {
+	var body struct{ User, Content string }
+	if !decode(w, r, &body) {
+		return
+	}
+	user, err := s.user(body.User)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	parent, err := s.comment(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	reply, err := s.engine.AddReplyToCommentContext(r.Context(), user, parent, body.Content)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeComment(w, http.StatusCreated, reply, parent.ID)
+}
+
+func (s *Server) getComment(w http.ResponseWriter, r *http.Request) {
+	comment, err := s.comment(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.engine.Mutex.RLock()
+	parentID := s.engine.CommentParents[comment.ID]
+	s.engine.Mutex.RUnlock()
+	s.writeComment(w, http.StatusOK, comment, parentID)
+}
+
+func (s *Server) writeComment(w http.ResponseWriter, status int, comment *engine.Comment, parentID int64) {
+	s.engine.Mutex.RLock()
+	reply := Comment{
+		ID:        comment.ID,
+		PostID:    comment.PostID,
+		ParentID:  parentID,
+		Permalink: engine.CommentPermalink(comment),
+		Author:    comment.Author.Username,
+		Content:   comment.Content(),
+		Votes:     comment.Votes,
+		CreatedAt: comment.CreatedAt,
+	}
+	s.engine.Mutex.RUnlock()
+	writeJSON(w, status, reply)
+}
+
+func (s *Server) votePost(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		User      string
+		Direction int
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	user, err := s.user(body.User)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	post, err := s.post(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Direction < -1 || body.Direction > 1 {
+		writeError(w, ErrInvalidDirection)
+		return
+	}
+	if body.Direction == 0 {
+		err = s.engine.UnvotePostContext(r.Context(), user, post)
+	} else {
+		err = s.engine.CastPostVoteContext(r.Context(), user, post, body.Direction, "")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.engine.Mutex.RLock()
+	vote := Vote{ID: post.ID, Votes: post.Votes}
+	s.engine.Mutex.RUnlock()
+	writeJSON(w, http.StatusOK, vote)
+}
+
+func (s *Server) voteComment(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		User      string
+		Direction int
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	user, err := s.user(body.User)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	comment, err := s.comment(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	switch body.Direction {
+	case 1:
+		err = s.engine.UpvoteCommentContext(r.Context(), user, comment)
+	case -1:
+		err = s.engine.DownvoteCommentContext(r.Context(), user, comment)
+	case 0:
+		err = s.engine.UnvoteCommentContext(r.Context(), user, comment)
+	default:
+		err = ErrInvalidDirection
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.engine.Mutex.RLock()
+	vote := Vote{ID: comment.ID, Votes: comment.Votes}
+	s.engine.Mutex.RUnlock()
+	writeJSON(w, http.StatusOK, vote)
+}
+
+// sendMessage answers 202 because where the message went isn't reported:
+// the engine may file it as spam or among the recipient's message requests.
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request) {
+	var body struct{ From, To, Content string }
+	if !decode(w, r, &body) {
+		return
+	}
+	from, err := s.user(body.From)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	to, err := s.user(body.To)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.engine.SendDirectMessageContext(r.Context(), from, to, body.Content); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) getEvents(w http.ResponseWriter, r *http.Request) {
+	after, limit := 0, maxEventPage
+	query := r.URL.Query()
+	for name, value := range map[string]*int{"after": &after, "limit": &limit} {
+		if raw := query.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				writeError(w, ErrInvalidQuery)
+				return
+			}
+			*value = n
+		}
+	}
+	events := s.engine.EventsSince(after, min(max(limit, 1), maxEventPage))
+	if events == nil {
+		events = []engine.Event{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) user(username string) (*engine.User, error) {
+	user := s.engine.GetUserByUsername(username)
+	if user == nil {
+		return nil, ErrUnknownUser
+	}
+	return user, nil
+}
+
+func (s *Server) post(id string) (*engine.Post, error) {
+	postID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	return s.engine.GetPost(postID)
+}
+
+func (s *Server) comment(id string) (*engine.Comment, error) {
+	commentID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	return s.engine.GetComment(commentID)
+}
+
+// decode reads a JSON body into v, answering 400 itself if it can't.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// statusFor maps engine and API errors to HTTP statuses.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownUser), errors.Is(err, engine.ErrSubRedditNotFound), errors.Is(err, engine.ErrPostNotFound), errors.Is(err, engine.ErrCommentNotFound), errors.Is(err, engine.ErrNoDMRequest):
+		return http.StatusNotFound
+	case errors.Is(err, engine.ErrQuotaExceeded), errors.Is(err, engine.ErrUserSuspended), errors.Is(err, engine.ErrBannedFromSubReddit), errors.Is(err, engine.ErrInsufficientKarma), errors.Is(err, engine.ErrNotModerator), errors.Is(err, engine.ErrDMRequestDeclined), errors.Is(err, engine.ErrAgeRestricted):
+		return http.StatusForbidden
+	case errors.Is(err, engine.ErrUsernameTaken), errors.Is(err, engine.ErrSubRedditExists), errors.Is(err, engine.ErrDuplicateURL), errors.Is(err, engine.ErrAlreadyVoted), errors.Is(err, engine.ErrNotVoted), errors.Is(err, engine.ErrAlreadyFollowing), errors.Is(err, engine.ErrNotFollowing):
+		return http.StatusConflict
+	case errors.Is(err, ErrDailyQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, engine.ErrNoSummarizer):
+		return http.StatusNotImplemented
+	}
+	return http.StatusBadRequest
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), statusFor(err))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API Usage and Quotas
+
+var ErrDailyQuotaExceeded = errors.New("daily request quota exceeded")
+
+// anonymousToken stands for requests sent without a token in usage reports.
+const anonymousToken = "anonymous"
+
+// Usage is what one API token has sent: Requests served, how many of them
+// failed and how many were refused for exceeding the daily quota. Today
+// counts requests since the start of the current UTC day, when Remaining of
+// Quota are left until ResetsAt; Quota is 0 when requests are unlimited.
+type Usage struct {
+	Token     string
+	Requests  int
+	Errors    int
+	ErrorRate float64
+	Rejected  int
+	Today     int
+	Quota     int
+	Remaining int
+	ResetsAt  time.Time `json:",omitzero"`
+	LastSeen  time.Time `json:",omitzero"`
+}
+
+type tokenUsage struct {
+	day      time.Time
+	today    int
+	requests int
+	errors   int
+	rejected int
+	lastSeen time.Time
+}
+
+// token is the API token a request carries as "Authorization: Bearer
+// <token>", or anonymousToken.
+func token(r *http.Request) string {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found && token != "" {
+		return token
+	}
+	return anonymousToken
+}
+
+// usageWriter remembers the status a request was answered with.
+type usageWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *usageWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// metered counts every request against its token and refuses those over
+// the token's daily quota with 429, a Retry-After header until the next UTC
+// day and X-RateLimit headers. The POST /batch envelope isn't counted, but
+// each call in it is, and GET /usage is always served so a client can see
+// why it was refused.
+func (s *Server) metered(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		token, now := token(r), s.engine.Clock.Now()
+		exempt := r.Method == http.MethodGet && r.URL.Path == "/usage"
+
+		s.mutex.Lock()
+		usage := s.usage[token]
+		if usage == nil {
+			usage = &tokenUsage{}
+			s.usage[token] = usage
+		}
+		if day := utcDay(now); !usage.day.Equal(day) {
+			usage.day, usage.today = day, 0
+		}
+		usage.lastSeen = now
+		quota := s.DailyQuota
+		if quota > 0 && usage.today >= quota && !exempt {
+			usage.rejected++
+			resetsAt := usage.day.Add(24 * time.Hour)
+			s.mutex.Unlock()
+			w.Header().Set("Retry-After", strconv.Itoa(int(resetsAt.Sub(now).Seconds())+1))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(quota))
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetsAt.Unix(), 10))
+			writeError(w, fmt.Errorf("%w: token %s may send %d requests per day, resets at %s", ErrDailyQuotaExceeded, token, quota, resetsAt.Format(time.RFC3339)))
+			return
+		}
+		usage.requests++
+		if !exempt {
+			usage.today++
+		}
+		if quota > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(quota))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(quota-usage.today))
+		}
+		s.mutex.Unlock()
+
+		recorder := &usageWriter{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+		if recorder.status >= http.StatusBadRequest {
+			s.mutex.Lock()
+			usage.errors++
+			s.mutex.Unlock()
+		}
+	})
+}
+
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// report turns a token's counters into its Usage. Callers must hold
+// s.mutex.
+func (s *Server) report(token string, usage *tokenUsage) Usage {
+	report := Usage{Token: token, Requests: usage.requests, Errors: usage.errors, Rejected: usage.rejected, Today: usage.today, Quota: s.DailyQuota, LastSeen: usage.lastSeen}
+	if usage.requests > 0 {
+		report.ErrorRate = float64(usage.errors) / float64(usage.requests)
+	}
+	if s.DailyQuota > 0 {
+		report.Remaining = max(s.DailyQuota-usage.today, 0)
+		report.ResetsAt = usage.day.Add(24 * time.Hour)
+	}
+	return report
+}
+
+// Usage returns what every token has sent, busiest first.
+func (s *Server) Usage() []Usage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	usages := make([]Usage, 0, len(s.usage))
+	for token, usage := range s.usage {
+		usages = append(usages, s.report(token, usage))
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Requests != usages[j].Requests {
+			return usages[i].Requests > usages[j].Requests
+		}
+		return usages[i].Token < usages[j].Token
+	})
+	return usages
+}
+
+// getUsage answers with the calling token's usage.
+func (s *Server) getUsage(w http.ResponseWriter, r *http.Request) {
+	token := token(r)
+	s.mutex.Lock()
+	usage := s.report(token, s.usage[token])
+	s.mutex.Unlock()
+	writeJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Result Comparison
+
+// MetricDiff compares the mean of one exported metric between a baseline
+// and a candidate. PercentChange is nil when the baseline is zero. PValue
+// comes from Welch's t-test; it is nil, and the difference is never
+// significant, unless both sides have at least two runs.
+type MetricDiff struct {
+	Name          string
+	Baseline      float64
+	Candidate     float64
+	PercentChange *float64 `json:",omitempty"`
+	PValue        *float64 `json:",omitempty"`
+	Significant   bool
+}
+
+// Comparison is the outcome of comparing baseline runs with candidate runs.
+type Comparison struct {
+	BaselineRuns  int
+	CandidateRuns int
+	Alpha         float64
+	Metrics       []MetricDiff
+}
+
+// loadExportedRuns reads every ExportJSON document in r, flattened to
+// metric name and value. Concatenating the exports of repeated seeds into
+// one file gives compare the samples it needs for significance testing.
+func loadExportedRuns(r io.Reader) ([]map[string]float64, error) {
+	var runs []map[string]float64
+	decoder := json.NewDecoder(r)
+	for {
+		var document map[string]interface{}
+		if err := decoder.Decode(&document); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		// Time series are too long to diff point by point
+		delete(document, "SubRedditTimeSeries")
+		run := make(map[string]float64)
+		flattenMetrics("", document, run)
+		runs = append(runs, run)
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no exported results")
+	}
+	return runs, nil
+}
+
+func flattenMetrics(prefix string, value interface{}, metrics map[string]float64) {
+	switch value := value.(type) {
+	case float64:
+		metrics[prefix] = value
+	case map[string]interface{}:
+		for key, child := range value {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenMetrics(name, child, metrics)
+		}
+	}
+}
+
+// CompareRuns diffs every metric present in either side. A metric missing
+// from a run counts as zero there, as ExportJSON omits empty breakdowns.
+func CompareRuns(baseline, candidate []map[string]float64, alpha float64) Comparison {
+	names := make(map[string]bool)
+	for _, runs := range [][]map[string]float64{baseline, candidate} {
+		for _, run := range runs {
+			for name := range run {
+				names[name] = true
+			}
+		}
+	}
+	comparison := Comparison{BaselineRuns: len(baseline), CandidateRuns: len(candidate), Alpha: alpha}
+	for name := range names {
+		before, after := metricSamples(baseline, name), metricSamples(candidate, name)
+		diff := MetricDiff{Name: name, Baseline: mean(before), Candidate: mean(after)}
+		if diff.Baseline != 0 {
+			change := (diff.Candidate - diff.Baseline) / math.Abs(diff.Baseline) * 100
+			diff.PercentChange = &change
+		}
+		if p, tested := welchPValue(before, after); tested {
+			diff.PValue = &p
+			diff.Significant = p < alpha
+		}
+		comparison.Metrics = append(comparison.Metrics, diff)
+	}
+	sort.Slice(comparison.Metrics, func(i, j int) bool { return comparison.Metrics[i].Name < comparison.Metrics[j].Name })
+	return comparison
+}
+
+func metricSamples(runs []map[string]float64, name string) []float64 {
+	samples := make([]float64, len(runs))
+	for i, run := range runs {
+		samples[i] = run[name]
+	}
+	return samples
+}
+
+func mean(samples []float64) float64 {
+	sum := 0.0
+	for _, sample := range samples {
+		sum += sample
+	}
+	return sum / float64(len(samples))
+}
+
+func variance(samples []float64, mean float64) float64 {
+	sum := 0.0
+	for _, sample := range samples {
+		sum += (sample - mean) * (sample - mean)
+	}
+	return sum / float64(len(samples)-1)
+}
+
+// welchPValue is the two-sided p-value of Welch's t-test for a difference
+// in means, or false when either side has fewer than two samples. When both
+// sides are constant it is 1 if they agree and 0 otherwise.
+func welchPValue(a, b []float64) (float64, bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	meanA, meanB := mean(a), mean(b)
+	seA, seB := variance(a, meanA)/float64(len(a)), variance(b, meanB)/float64(len(b))
+	if seA+seB == 0 {
+		if meanA == meanB {
+			return 1, true
+		}
+		return 0, true
+	}
+	t := (meanB - meanA) / math.Sqrt(seA+seB)
+	df := (seA + seB) * (seA + seB) / (seA*seA/float64(len(a)-1) + seB*seB/float64(len(b)-1))
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t)), true
+}
+
+// regularizedIncompleteBeta evaluates I_x(a, b) with the continued fraction
+// from Numerical Recipes, which converges quickly for the a, b and x the
+// t-distribution needs.
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	lgammaAB, _ := math.Lgamma(a + b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+	}
+	return front * betaContinuedFraction(a, b, x) / a
+}
+
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	result := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		for _, numerator := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + numerator*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + numerator/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			result *= d * c
+		}
+		if math.Abs(d*c-1) < epsilon {
+			break
+		}
+	}
+	return result
+}
+
+func printComparison(comparison Comparison) {
+	fmt.Printf("Baseline runs: %d, Candidate runs: %d, Significance level: %.2f\n", comparison.BaselineRuns, comparison.CandidateRuns, comparison.Alpha)
+	fmt.Printf("%-32s %14s %14s %10s %8s\n", "Metric", "Baseline", "Candidate", "Change", "p")
+	significant := 0
+	for _, diff := range comparison.Metrics {
+		change, p, marker := "-", "-", ""
+		if diff.PercentChange != nil {
+			change = fmt.Sprintf("%.1f%%", *diff.PercentChange)
+		}
+		if diff.PValue != nil {
+			p = fmt.Sprintf("%.3f", *diff.PValue)
+		}
+		if diff.Significant {
+			marker = " *"
+			significant++
+		}
+		fmt.Printf("%-32s %14.2f %14.2f %10s %8s%s\n", diff.Name, diff.Baseline, diff.Candidate, change, p, marker)
+	}
+	fmt.Printf("Significant differences: %d of %d metrics\n", significant, len(comparison.Metrics))
+}
+
+// runCompare implements "compare [-alpha A] [-json] <baseline> <candidate>".
+func runCompare(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	alpha := flags.Float64("alpha", 0.05, "p-value below which a difference counts as significant")
+	asJSON := flags.Bool("json", false, "print the comparison as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: compare [-alpha A] [-json] <baseline-export> <candidate-export>")
+	}
+	var sides [2][]map[string]float64
+	for i, path := range flags.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		runs, err := loadExportedRuns(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		sides[i] = runs
+	}
+	comparison := CompareRuns(sides[0], sides[1], *alpha)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(comparison)
+	}
+	printComparison(comparison)
+	return nil
+}
@@ -0,0 +1,767 @@
+// Command redditclone simulates a Reddit-like site on the engine package and
+// prints a report of the run. "redditclone replay <event-log>" and
+// "redditclone compare" work on the files earlier runs exported.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/api"
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+	"github.com/sahasgundapaneni/reddit-clone/simulator"
+)
+
+// Simulation Binary
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		if err := runCompare(os.Args[2:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	targetRate := flag.Float64("target-rate", 0, "run throughput target mode at this many actions/sec after the simulation")
+	targetDuration := flag.Duration("target-duration", 10*time.Second, "how long to hold the throughput target")
+	coldStorePath := flag.String("cold-store", "", "archive old posts to this file after the simulation")
+	coldAfter := flag.Duration("cold-after", 0, "minimum post age before it is moved to cold storage")
+	sampleInterval := flag.Duration("sample-interval", time.Millisecond, "how often to sample per-subreddit statistics")
+	exportPath := flag.String("export", "", "write metrics and time series as JSON to this file")
+	feedWorkers := flag.Int("feed-workers", 4, "number of workers building feeds for the report")
+	feedTimeout := flag.Duration("feed-timeout", 100*time.Millisecond, "per-request timeout for feed generation")
+	redisAddr := flag.String("redis", "", "share hot counters and the feed cache through Redis at this address")
+	verify := flag.Bool("verify", false, "check the engine's object graph for corruption at the end of the simulation")
+	chaosMode := flag.Bool("chaos", false, "inject lock delays, dropped hook deliveries and worker crashes, then check invariants and that concurrent actors saw operations atomically")
+	eventLogPath := flag.String("event-log", "", "write the event log as JSON lines to this file, for use with replay")
+	takeoutUser := flag.String("takeout-user", "", "export this user's data as a zip archive to -takeout")
+	takeoutPath := flag.String("takeout", "takeout.zip", "destination for the -takeout-user archive")
+	worldPath := flag.String("world", "", "load subreddits, seed users and moderators from this JSON world definition")
+	voteQueueLimit := flag.Int("vote-queue-limit", engine.DefaultBackpressureLimits.VoteQueue, "signal backpressure while a vote stream has more than this many unacknowledged deltas (0 disables)")
+	notificationQueueLimit := flag.Int("notification-queue-limit", engine.DefaultBackpressureLimits.NotificationQueue, "signal backpressure while more than this many notifications await disconnected users (0 disables)")
+	rankingWorkers := flag.Int("ranking-workers", engine.DefaultRankingPoolOptions.Workers, "precompute feed ranking scores on this many workers after votes (0 ranks on the read path)")
+	voteWebhook := flag.String("vote-webhook", "", "POST batched vote deltas as JSON to this URL")
+	tenantCount := flag.Int("tenants", 0, "also simulate this many isolated tenant sites in parallel")
+	brigadeExperiment := flag.Bool("brigade-experiment", false, "downvote-brigade rising posts on a fresh simulation, report how each ranking resists, and exit")
+	onboardingExperiment := flag.Bool("onboarding-experiment", false, "onboard cohorts of new users with subreddit slates of varying quality, report their activity and retention, and exit")
+	capacityPlan := flag.Bool("capacity-plan", false, "ramp simulated users stepwise until a threshold is exceeded, report, and exit")
+	planP99 := flag.Duration("plan-p99", time.Millisecond, "capacity plan p99 action latency threshold")
+	planErrorRate := flag.Float64("plan-error-rate", 0.05, "capacity plan error rate threshold")
+	timeScale := flag.Duration("time-scale", 0, "let this much simulated time pass for every real second on top of the simulator's own steps, e.g. 1h, so time decay, archiving and scheduled jobs play out in seconds (0 keeps the clock still between steps)")
+	decayAfter := flag.Duration("vote-decay-after", 0, "votes on posts older than this start counting less toward karma and hot score")
+	decayZero := flag.Duration("vote-decay-zero", 0, "votes on posts older than this count for nothing (0 disables decay)")
+	threadExportPath := flag.String("export-thread", "", "write the busiest thread to this file instead of the report (JSON if it ends in .json, else Markdown)")
+	idOffset := flag.Int64("id-offset", 0, "start user, post and comment IDs after this value, to keep engines sharing a store from colliding")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "export engine and admin API spans, or with -serve engine and REST API spans, to this OTLP/HTTP collector, e.g. http://localhost:4318")
+	adminAddr := flag.String("admin-addr", "", "serve the simulator control API (pause, resume, rate, users, inject) on this address")
+	metricsAddr := flag.String("metrics-addr", "", "serve engine counters and action latency histograms in the Prometheus format at /metrics on this address while simulating (-serve exposes them on its own address)")
+	startPaused := flag.Bool("paused", false, "with -admin-addr, wait for POST /resume before simulating")
+	usersPerSecond := flag.Float64("users-per-second", 0, "with -admin-addr, limit new users to this wall-clock rate (0 is unthrottled)")
+	regionSamples := flag.Int("regions", 0, "assign users and subreddits to regions and sample this many regional actions")
+	botRounds := flag.Int("bots", 0, "after visits, run this many rounds of activity answered by scripted bots using the API client, and report the load they add")
+	remoteLoad := flag.Duration("remote-load", 0, "after the bots, load the engine through its REST API as a remote simulator would, for this long unbatched and then as long batched, and compare their throughput (0 skips it)")
+	remoteClients := flag.Int("remote-clients", simulator.DefaultRemoteLoadConfig.Clients, "with -remote-load, how many simulated users call the API at once")
+	remoteLatency := flag.Duration("remote-latency", simulator.DefaultRemoteLoadConfig.Latency, "with -remote-load, network latency added to every round trip")
+	batchSize := flag.Int("batch-size", simulator.DefaultRemoteLoadConfig.Batch.Size, "with -remote-load, the most calls sent in one batch")
+	batchFlush := flag.Duration("batch-flush", simulator.DefaultRemoteLoadConfig.Batch.FlushInterval, "with -remote-load, how long a batch waits to fill before it is sent")
+	actorDuration := flag.Duration("actors", simulator.DefaultSimulationConfig.Duration, "after sign-up, run every user as its own goroutine taking random actions for this long, and report throughput under that concurrent load (0 skips it)")
+	duration := flag.Duration("duration", simulator.DefaultSimulationConfig.Duration, "same as -actors")
+	simConfigPath := flag.String("sim-config", "", "read the run's size, duration, seed and activity probabilities from this TOML file; flags given explicitly override it")
+	numUsers := flag.Int("users", simulator.DefaultSimulationConfig.Users, "how many users sign up during the simulation")
+	numSubReddits := flag.Int("subreddits", simulator.DefaultSimulationConfig.SubReddits, "how many subreddits the generated world has, when no -world is given")
+	seed := flag.Int64("seed", 0, "seed the engine's random source with this value, printed in the report so a run can be repeated (0 seeds from the clock); the same seed only gives the same simulation with -duration 0, since timed runs depend on how the concurrent actors are scheduled")
+	zipfExponent := flag.Float64("zipf", simulator.DefaultActivity.ZipfExponent, "exponent of the Zipf law by which subreddit popularity falls off with rank when users pick subreddits to join, post in and browse (greater than 1)")
+	megathreadDuration := flag.Duration("megathread", 0, "after the actors, run a live megathread in the largest subreddit for this long, with every member commenting on it at once (0 skips it)")
+	megathreadWatchers := flag.Int("megathread-watchers", 50, "with -megathread, how many watchers stream the thread's new comments")
+	discoveryShare := flag.Float64("discovery", 0, "after the promotions, give this share of feed slots to hot posts from subreddits users don't follow, with the rest after their own posts, and report how many of those subreddits they join (0 skips it)")
+	visitDays := flag.Int("visit-days", 0, "after sign-up, simulate this many days of return visits following each persona's daily rhythm")
+	serveAddr := flag.String("serve", "", "serve the engine as a REST API on this address instead of simulating, starting from -world if given")
+	configPath := flag.String("config", "", "apply engine settings (limits, karma policy, ranking, discovery slots) from this JSON file, rereading it on SIGHUP or POST /config/reload")
+	loadPath := flag.String("load", "", "resume from an engine snapshot written by -save instead of loading a world")
+	savePath := flag.String("save", "", "write an engine snapshot to this file at the end of the run, or when -serve is interrupted")
+	storePath := flag.String("store", "", "checkpoint engine state to this file store, resuming from it if it has any and syncing it at the end of the run (and every -store-interval while serving); state is still held in memory")
+	dailyQuota := flag.Int("daily-quota", 0, "with -serve, refuse API tokens more than this many requests per UTC day (0 is unlimited)")
+	storeInterval := flag.Duration("store-interval", 30*time.Second, "with -serve and -store, how often to sync the store")
+	codecName := flag.String("codec", "json", "encoding of -save and -load snapshots and -store records: json, gob or msgpack")
+	benchmarkCodecs := flag.Bool("benchmark-codecs", false, "time every codec encoding and decoding the final state's snapshot (go test -bench=Codec ./engine benchmarks them on a fixture)")
+	heatmapPath := flag.String("heatmap", "", "write activity heatmaps by day of week and hour as JSON to this file")
+	sitePath := flag.String("export-site", "", "write the final state as a browsable static HTML site (subreddits, posts with their comment trees, user profiles) to this directory")
+	logLevel := flag.String("log-level", "info", "log only at this level and above: debug (every action with its user, subreddit and latency), info, warn or error")
+	logFormat := flag.String("log-format", "text", "format of the logs written to stderr: text or json")
+	quiet := flag.Bool("quiet", false, "log only errors and skip the report unless -report names a file, for benchmarking")
+	reportPath := flag.String("report", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	logger, err := newLogger(os.Stderr, *logLevel, *logFormat, *quiet)
+	if err != nil {
+		fatal(err)
+	}
+	slog.SetDefault(logger)
+	var out io.Writer = os.Stdout
+	if *quiet {
+		out = io.Discard
+	}
+	if *reportPath != "" {
+		file, err := os.Create(*reportPath)
+		if err != nil {
+			fatal(err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	config := simulator.DefaultSimulationConfig
+	if *simConfigPath != "" {
+		loaded, err := simulator.LoadSimulationConfig(*simConfigPath)
+		if err != nil {
+			fatal(err)
+		}
+		config = loaded
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "actors":
+			config.Duration = *actorDuration
+		case "duration":
+			config.Duration = *duration
+		case "users":
+			config.Users = *numUsers
+		case "subreddits":
+			config.SubReddits = *numSubReddits
+		case "seed":
+			config.Seed = *seed
+		case "zipf":
+			config.Activity.ZipfExponent = *zipfExponent
+		}
+	})
+	if err := config.Validate(); err != nil {
+		fatal(err)
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	codec, err := engine.CodecByName(*codecName)
+	if err != nil {
+		fatal(err)
+	}
+	if *brigadeExperiment {
+		brigade := simulator.DefaultBrigadeConfig
+		brigade.Seed = config.Seed
+		simulator.PrintBrigadeReport(out, simulator.RunBrigadeExperiment(brigade))
+		return
+	}
+	if *onboardingExperiment {
+		onboarding := simulator.DefaultOnboardingConfig
+		onboarding.Seed = config.Seed
+		simulator.PrintOnboardingReport(out, onboarding, simulator.RunOnboardingExperiment(onboarding))
+		return
+	}
+	if *capacityPlan {
+		simulator.PrintCapacityPlan(out, simulator.RunCapacityPlan(simulator.CapacityThresholds{P99: *planP99, ErrorRate: *planErrorRate}, simulator.CapacitySteps, config.Seed))
+		return
+	}
+	if *serveAddr != "" {
+		if err := serve(*serveAddr, *worldPath, *configPath, *loadPath, *savePath, *storePath, *redisAddr, *otlpEndpoint, *storeInterval, *dailyQuota, codec); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	e := engine.New()
+	clock := engine.NewSimClock(time.Now())
+	e.Clock = clock
+	e.Rand = engine.NewRand(config.Seed)
+	e.Logger = logger
+	e.SetCodec(codec)
+	e.SetVoteDecay(engine.VoteDecay{FullWeightAge: *decayAfter, ZeroWeightAge: *decayZero})
+	e.SetBackpressureLimits(engine.BackpressureLimits{VoteQueue: *voteQueueLimit, NotificationQueue: *notificationQueueLimit, HookFill: engine.DefaultBackpressureLimits.HookFill})
+	if err := e.SetIDOffset(*idOffset); err != nil {
+		fatal(err)
+	}
+	if *configPath != "" {
+		stopWatching, err := watchConfig(e, *configPath)
+		if err != nil {
+			fatal(err)
+		}
+		defer stopWatching()
+	}
+
+	// Simulate users and subreddits
+	if *redisAddr != "" {
+		if closeRedis := shareThroughRedis(e, *redisAddr); closeRedis != nil {
+			defer closeRedis()
+		}
+	}
+	var rankingPool *engine.RankingPool
+	if *rankingWorkers > 0 {
+		options := engine.DefaultRankingPoolOptions
+		options.Workers = *rankingWorkers
+		rankingPool = engine.NewRankingPool(options)
+		e.EnableRankingPool(rankingPool)
+		defer rankingPool.Close()
+	}
+	var tracer *engine.Tracer
+	if *otlpEndpoint != "" {
+		tracer = engine.NewTracer(*otlpEndpoint)
+		e.EnableTracing(tracer)
+	}
+	var chaos *engine.Chaos
+	var hookEvents int64
+	if *chaosMode {
+		chaos = e.EnableChaos(engine.DefaultChaosConfig)
+		e.AddEventHook(func(engine.Event) { atomic.AddInt64(&hookEvents, 1) }, 1024)
+	}
+	world := simulator.GeneratedWorld(config.SubReddits, e.Rand)
+	if *worldPath != "" {
+		loaded, err := engine.LoadWorldDefinition(*worldPath)
+		if err != nil {
+			fatal(err)
+		}
+		world = loaded
+	}
+	if *storePath != "" {
+		store, err := openStore(e, *storePath, *loadPath == "")
+		if err != nil {
+			fatal(err)
+		}
+		defer store.Close()
+	}
+	switch {
+	case *loadPath != "":
+		if err := loadSnapshot(e, *loadPath); err != nil {
+			fatal(err)
+		}
+		world = snapshotWorld(e)
+	case len(e.Users) > 0:
+		logger.Info("resumed from the store", "path", *storePath, "users", len(e.Users), "subreddits", len(e.SubReddits))
+		world = snapshotWorld(e)
+	default:
+		if err := e.LoadWorld(world); err != nil {
+			fatal(err)
+		}
+	}
+	if len(world.SubReddits) == 0 {
+		// A snapshot or store may hold no subreddits, but the simulation
+		// needs one to post in.
+		fatal(engine.ErrEmptyWorld)
+	}
+	voteStream := e.SubscribeVotes(engine.DefaultVoteStreamOptions)
+	ExternalScores := simulator.ConsumeVoteScores(voteStream)
+	if *voteWebhook != "" {
+		webhookStream := e.SubscribeVotes(engine.DefaultVoteStreamOptions)
+		go engine.DeliverVoteWebhook(webhookStream, *voteWebhook, &http.Client{Timeout: time.Second})
+		defer webhookStream.Close()
+	}
+	stopSampler := e.StartSubRedditSampler(*sampleInterval)
+	stopScheduler := func() {}
+	if *timeScale > 0 {
+		clock.Run(timeScale.Seconds())
+		stopScheduler = e.StartScheduler(100 * time.Millisecond)
+	}
+	results := &simulator.ActionResults{Logger: logger}
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", api.MetricsHandler(e, results.WriteMetrics))
+		server := &http.Server{Addr: *metricsAddr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Error("metrics endpoint stopped", "addr", *metricsAddr, "err", err)
+			}
+		}()
+		defer server.Close()
+	}
+	var control *simulator.SimControl
+	if *adminAddr != "" {
+		control = simulator.NewSimControl(e)
+		if *startPaused {
+			control.Pause(context.Background())
+		}
+		if *usersPerSecond > 0 {
+			control.SetRate(context.Background(), *usersPerSecond)
+		}
+		if *configPath != "" {
+			control.SetConfigFile(*configPath)
+		}
+		var handler http.Handler = control.Handler()
+		if tracer != nil {
+			handler = tracer.Middleware(handler)
+		}
+		server := &http.Server{Addr: *adminAddr, Handler: handler}
+		go func() {
+			if err := server.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Error("admin endpoint stopped", "addr", *adminAddr, "err", err)
+			}
+		}()
+		defer server.Close()
+	}
+	simStart := e.Clock.Now()
+	simulator.SimulateUsers(e, config.Users, world.SubRedditNames(), config.Activity, results, control)
+	conversations := simulator.SimulateConversations(e, world.SubRedditNames(), 5, 6, results)
+	actors := simulator.SimulateActors(e, config.Duration, world.SubRedditNames(), config.Activity, results, chaos != nil)
+	var megathread simulator.MegathreadReport
+	if *megathreadDuration > 0 {
+		if megathread, err = simulator.SimulateMegathread(e, simulator.LargestSubReddit(e), *megathreadWatchers, *megathreadDuration, results); err != nil {
+			logger.Warn("megathread skipped", "err", err)
+		}
+	}
+	simulator.SimulateBanAppeals(e, world.SubRedditNames(), 0.5, results)
+	visits := simulator.SimulateVisits(e, *visitDays, results)
+	bots := simulator.SimulateBots(e, simulator.DefaultBots(), *botRounds, world.SubRedditNames(), results)
+	var remote simulator.RemoteLoadReport
+	if *remoteLoad > 0 {
+		remote = simulator.RunRemoteLoadTest(e, world.SubRedditNames(), simulator.RemoteLoadConfig{
+			Clients:  *remoteClients,
+			Duration: *remoteLoad,
+			Batch:    api.BatchOptions{Size: *batchSize, FlushInterval: *batchFlush},
+			Latency:  *remoteLatency,
+		})
+	}
+	stopSampler()
+	stopScheduler()
+	clock.Run(0)
+	e.LiftExpiredSuspensions()
+	e.LiftExpiredBans()
+	e.SampleSubReddits()
+	if *coldStorePath != "" {
+		if err := e.EnableColdStorage(*coldStorePath); err != nil {
+			logger.Warn("cold storage disabled", "path", *coldStorePath, "err", err)
+		} else {
+			defer e.ColdStore.Close()
+		}
+	}
+
+	// Throughput is measured under concurrent load when the actors ran; the
+	// sequential phases only ever drive one user at a time
+	throughput := actors.Throughput
+	if actors.Actors == 0 {
+		throughput = float64(e.TotalActions) / time.Since(e.StartTime).Seconds()
+	}
+
+	report := &reporter{w: out}
+	report.summary(e, config.Seed, throughput, simStart)
+	if e.ColdStore != nil {
+		archived, err := e.ArchiveOldPosts(*coldAfter)
+		if err != nil {
+			logger.Error("archiving old posts failed", "err", err)
+		}
+		report.archive(e, archived)
+	}
+
+	report.phases(conversations, actors, megathread, visits, bots, remote, results)
+	simulator.PrintPromotionStats(out, simulator.SimulatePromotions(e, 3))
+	if *discoveryShare > 0 {
+		if discovery, err := simulator.SimulateDiscovery(e, *discoveryShare, 3); err != nil {
+			logger.Warn("discovery skipped", "err", err)
+		} else {
+			simulator.PrintDiscoveryReport(out, discovery)
+		}
+	}
+	report.achievements(e)
+	report.subReddits(e, world, config.Activity.ZipfExponent)
+
+	// Build every user's feed through the worker pool
+	pool := engine.NewFeedPool(e, *feedWorkers, len(e.Users))
+	feeds := make(map[int64][]*engine.Post, len(e.Users))
+	var feedsMu sync.Mutex
+	var feedsWG sync.WaitGroup
+	for _, user := range e.Users {
+		feedsWG.Add(1)
+		go func(user *engine.User) {
+			defer feedsWG.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), *feedTimeout)
+			defer cancel()
+			if feed, err := pool.GetFeed(ctx, user, engine.SortHot); err == nil {
+				feedsMu.Lock()
+				feeds[user.ID] = feed
+				feedsMu.Unlock()
+			}
+		}(user)
+	}
+	feedsWG.Wait()
+	pool.Close()
+	report.pools(pool.Stats(), rankingPool)
+
+	// Display Random User Feed
+	randomUser := e.Users[simulator.RandomUserID(e)]
+	e.CachedFeedItems(randomUser, engine.SortHot)
+	e.CachedFeedItems(randomUser, engine.SortHot)
+	e.FlushShared()
+	report.feed(e, randomUser, feeds[randomUser.ID])
+
+	// Compare hot and personalized ranking
+	report.personalization(e, simulator.EvaluatePersonalization(e, 50, 10))
+
+	// Display the Busiest Thread
+	if id := e.BusiestPostID(); id != 0 {
+		format := engine.ThreadMarkdown
+		if strings.HasSuffix(*threadExportPath, ".json") {
+			format = engine.ThreadJSON
+		}
+		if thread, err := e.ExportThread(id, format); err == nil {
+			if *threadExportPath != "" {
+				if err := os.WriteFile(*threadExportPath, thread, 0o644); err != nil {
+					logger.Error("thread export failed", "err", err)
+				}
+			} else if markdown, err := e.ExportThread(id, engine.ThreadMarkdown); err == nil {
+				report.thread(markdown)
+			}
+		}
+		if summary, err := e.SummarizeThread(id); err == nil {
+			report.threadSummary(summary, e.GetSummaryStats())
+		}
+	}
+
+	report.messages(e)
+
+	// Broadcast an announcement to measure fan-out
+	stats, err := e.Broadcast(e.Users[e.IDOffset+1], "Thanks for taking part in the simulation!")
+	report.broadcast(e, stats, err)
+
+	if *regionSamples > 0 {
+		model := engine.NewLatencyModel(engine.DefaultRegions, e.Rand)
+		e.AssignRegions(model)
+		simulator.PrintRegionalLatencyReport(out, simulator.SimulateRegionalLatency(e, model, *regionSamples))
+	}
+
+	if *tenantCount > 0 {
+		simulator.PrintTenantMetrics(out, simulator.RunTenants(*tenantCount, config.Users, config.SubReddits, config.Seed))
+	}
+
+	if *targetRate > 0 {
+		simulator.PrintThroughputTargetResult(out, simulator.RunThroughputTarget(e, *targetRate, *targetDuration))
+	}
+
+	if chaos != nil {
+		simulator.RunThroughputTarget(e, 20000, time.Second)
+		e.CloseEventHooks()
+		report.chaos(e, chaos, atomic.LoadInt64(&hookEvents))
+		if report.violations("Invariant violations:", e.CheckInvariants()) {
+			fatal(errors.New("invariants violated"))
+		}
+		report.println("All invariants hold.")
+		if report.violations("Happens-before violations:", e.CheckHappensBefore(actors.Sessions)) {
+			fatal(errors.New("happens-before violated"))
+		}
+		observations := 0
+		for _, session := range actors.Sessions {
+			observations += len(session)
+		}
+		report.printf("Operations appeared atomic to %d actors across %d observations.\n", len(actors.Sessions), observations)
+	}
+
+	if tracer != nil {
+		tracer.Close()
+		report.printf("\nTracing: %d spans exported, %d dropped, %d failed exports\n", tracer.Exported, tracer.Dropped, tracer.Failed)
+	}
+
+	if *verify {
+		report.println("\nIntegrity Check:")
+		var integrity *engine.IntegrityError
+		if err := e.Verify(); errors.As(err, &integrity) {
+			report.violations("Integrity violations:", integrity.Violations)
+			fatal(err)
+		}
+		report.println("Object graph verified.")
+		if report.ageGating(simulator.CheckAgeGating(e)) {
+			fatal(errors.New("NSFW content reached minors"))
+		}
+	}
+
+	drained := voteStream.WaitAcked(time.Second)
+	voteStream.Close()
+	simulator.PrintVoteStreamReport(out, voteStream.Stats(), drained, simulator.MismatchedPostScores(e, ExternalScores))
+
+	if *exportPath != "" {
+		if err := writeFile(*exportPath, e.ExportJSON); err != nil {
+			logger.Error("export failed", "err", err)
+		}
+	}
+	if *eventLogPath != "" {
+		if err := writeFile(*eventLogPath, e.SaveEventLog); err != nil {
+			logger.Error("saving the event log failed", "err", err)
+		}
+	}
+	if *heatmapPath != "" {
+		if err := writeFile(*heatmapPath, e.ExportActivityHeatmaps); err != nil {
+			logger.Error("heatmap export failed", "err", err)
+		}
+	}
+	if *sitePath != "" {
+		if stats, err := e.ExportSite(*sitePath); err != nil {
+			logger.Error("site export failed", "err", err)
+		} else {
+			logger.Info("site exported", "subreddits", stats.SubReddits, "posts", stats.Posts, "users", stats.Users, "dir", *sitePath)
+		}
+	}
+	if *benchmarkCodecs {
+		report.codecs(runCodecBenchmarks(e))
+	}
+	if *savePath != "" {
+		if err := writeFile(*savePath, e.Save); err != nil {
+			logger.Error("saving the snapshot failed", "err", err)
+		}
+	}
+	if *storePath != "" {
+		if err := e.Sync(); err != nil {
+			logger.Error("syncing the store failed", "err", err)
+		} else {
+			stats := e.Store.(*engine.FileStore).Stats()
+			logger.Info("store synced", "path", *storePath, "records", stats.Records, "written", stats.Writes, "unchanged", stats.Unchanged, "bytes", stats.Bytes, "garbage", stats.Garbage)
+		}
+	}
+	if *takeoutUser != "" {
+		if user := e.GetUserByUsername(*takeoutUser); user == nil {
+			logger.Error("takeout failed: unknown user", "user", *takeoutUser)
+		} else if err := writeFile(*takeoutPath, func(w io.Writer) error { return e.ExportUserData(user, w) }); err != nil {
+			logger.Error("takeout failed", "err", err)
+		}
+	}
+}
+
+// fatal logs err and exits.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+// newLogger returns a logger writing to w at level and above as text or
+// JSON; quiet raises the level to errors only.
+func newLogger(w io.Writer, level, format string, quiet bool) (*slog.Logger, error) {
+	var options slog.HandlerOptions
+	var minimum slog.Level
+	if err := minimum.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("-log-level: %w", err)
+	}
+	if quiet {
+		minimum = max(minimum, slog.LevelError)
+	}
+	options.Level = minimum
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, &options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, &options)), nil
+	}
+	return nil, fmt.Errorf("-log-format: unknown format %q", format)
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadSnapshot restores the engine snapshot at path into e.
+func loadSnapshot(e *engine.Engine, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := e.Load(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// openStore opens the file store at path as e's store and, if resume is
+// set, restores whatever was last synced to it.
+func openStore(e *engine.Engine, path string, resume bool) (*engine.FileStore, error) {
+	store, err := engine.OpenFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+	e.SetStore(store)
+	if resume {
+		if err := e.LoadFromStore(); err != nil && !errors.Is(err, engine.ErrStoreEmpty) {
+			store.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return store, nil
+}
+
+// snapshotWorld describes the subreddits of a loaded snapshot as a world, in
+// name order, for the simulation to run on.
+func snapshotWorld(e *engine.Engine) *engine.WorldDefinition {
+	world := &engine.WorldDefinition{}
+	for name := range e.SubReddits {
+		world.SubReddits = append(world.SubReddits, engine.WorldSubReddit{Name: name})
+	}
+	sort.Slice(world.SubReddits, func(i, j int) bool { return world.SubReddits[i].Name < world.SubReddits[j].Name })
+	return world
+}
+
+// serve runs the REST API on a real-clock engine, starting from the snapshot
+// at loadPath, else from what was last synced to the store at storePath,
+// else from the world at worldPath, until the server fails. With a
+// storePath, the store is synced every storeInterval; with a savePath or
+// storePath, an interrupt stops the server and saves a snapshot or syncs the
+// store. Each API token may send dailyQuota requests a day, and what every
+// token sent is logged when the server stops. Snapshots and store records
+// are encoded with codec. With a redisAddr, counters and feeds are shared
+// through Redis with every other server using it. With an otlpEndpoint,
+// every request and the engine actions it causes are traced there.
+func serve(addr, worldPath, configPath, loadPath, savePath, storePath, redisAddr, otlpEndpoint string, storeInterval time.Duration, dailyQuota int, codec engine.Codec) error {
+	e := engine.New()
+	e.SetCodec(codec)
+	var tracer *engine.Tracer
+	if otlpEndpoint != "" {
+		tracer = engine.NewTracer(otlpEndpoint)
+		defer tracer.Close()
+		e.EnableTracing(tracer)
+	}
+	shared := false
+	if redisAddr != "" {
+		if closeRedis := shareThroughRedis(e, redisAddr); closeRedis != nil {
+			defer closeRedis()
+			shared = true
+		}
+	}
+	if configPath != "" {
+		stopWatching, err := watchConfig(e, configPath)
+		if err != nil {
+			return err
+		}
+		defer stopWatching()
+	}
+	if storePath != "" {
+		store, err := openStore(e, storePath, loadPath == "")
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+	if loadPath != "" {
+		if err := loadSnapshot(e, loadPath); err != nil {
+			return err
+		}
+	} else if worldPath != "" && len(e.Users) == 0 {
+		world, err := engine.LoadWorldDefinition(worldPath)
+		if err != nil {
+			return err
+		}
+		if err := e.LoadWorld(world); err != nil {
+			return err
+		}
+	}
+	apiServer := api.NewServer(e)
+	apiServer.DailyQuota = dailyQuota
+	apiServer.SharedFeeds = shared
+	handler := apiServer.Handler()
+	if tracer != nil {
+		handler = tracer.Middleware(handler)
+	}
+	server := &http.Server{Addr: addr, Handler: handler}
+	if savePath != "" || storePath != "" {
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupts)
+		go func() {
+			<-interrupts
+			server.Shutdown(context.Background())
+		}()
+	}
+	if storePath != "" {
+		ticker := time.NewTicker(storeInterval)
+		defer ticker.Stop()
+		go func() {
+			for range ticker.C {
+				if err := e.Sync(); err != nil {
+					e.Logger.Error("syncing the store failed", "err", err)
+				}
+			}
+		}()
+	}
+	e.Logger.Info("serving the REST API", "addr", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	for _, usage := range apiServer.Usage() {
+		e.Logger.Info("api usage", "token", usage.Token, "requests", usage.Requests, "errors", usage.Errors, "error_rate", usage.ErrorRate, "rejected", usage.Rejected)
+	}
+	if storePath != "" {
+		if err := e.Sync(); err != nil {
+			return err
+		}
+		e.Logger.Info("synced the store", "path", storePath)
+	}
+	if savePath != "" {
+		if err := writeFile(savePath, e.Save); err != nil {
+			return err
+		}
+		e.Logger.Info("saved snapshot", "path", savePath)
+	}
+	return nil
+}
+
+// shareThroughRedis makes e share its counters and feed cache through the
+// Redis server at addr, keeping the in-memory store if Redis can't be
+// reached. The returned function sends what is still queued and closes the
+// connection; it is nil if Redis wasn't used.
+func shareThroughRedis(e *engine.Engine, addr string) func() {
+	store, err := engine.DialRedisStore(addr, "redditclone:", time.Second)
+	if err != nil {
+		e.Logger.Warn("Redis unavailable, using the in-memory shared store", "addr", addr, "err", err)
+		return nil
+	}
+	e.SetSharedStore(store)
+	return func() {
+		e.FlushShared()
+		store.Close()
+	}
+}
+
+// watchConfig applies the engine configuration file at path, then reapplies
+// it each time the process receives SIGHUP until stop is called.
+func watchConfig(e *engine.Engine, path string) (stop func(), err error) {
+	if _, err := e.ReloadConfig(path); err != nil {
+		return nil, err
+	}
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hangups:
+				changes, err := e.ReloadConfig(path)
+				if err != nil {
+					e.Logger.Error("config reload failed", "path", path, "err", err)
+					continue
+				}
+				e.Logger.Info("config reloaded", "path", path, "changed", len(changes))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hangups)
+		close(done)
+	}, nil
+}
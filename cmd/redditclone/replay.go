@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Replay Subcommand
+
+func printReplayMetrics(m engine.ReplayMetrics) {
+	fmt.Println("Replay Complete. Metrics:")
+	fmt.Printf("Events: %d\n", m.Events)
+	fmt.Printf("Users: %d\n", m.Users)
+	fmt.Printf("SubReddits: %d\n", m.SubReddits)
+	fmt.Printf("Total Posts: %d\n", m.TotalPosts)
+	fmt.Printf("Total Votes: %d\n", m.TotalVotes)
+	fmt.Printf("Total Comments: %d\n", m.TotalComments)
+	fmt.Printf("Total Messages: %d\n", m.TotalMessages)
+	fmt.Printf("Total Actions: %d\n", m.TotalActions)
+	fmt.Printf("Simulated Time: %v\n", m.SimulatedTime)
+
+	fmt.Println("Action Breakdown:")
+	for action, count := range m.ActionBreakdown {
+		fmt.Printf("%s: %d\n", action, count)
+	}
+
+	fmt.Println("\nSubReddit Metrics (Zipf Distribution Impact):")
+	names := make([]string, 0, len(m.SubRedditMembers))
+	for name := range m.SubRedditMembers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return m.SubRedditMembers[names[i]] > m.SubRedditMembers[names[j]]
+	})
+	for i, name := range names {
+		fmt.Printf("%d. %s - Members: %d, Posts: %d\n", i+1, name, m.SubRedditMembers[name], m.SubRedditPosts[name])
+	}
+}
+
+// runReplay implements the replay subcommand: replay <event-log>.
+func runReplay(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: replay <event-log>")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	events, err := engine.LoadEventLog(file)
+	if err != nil {
+		return err
+	}
+	printReplayMetrics(engine.ReplayEvents(events))
+	return nil
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+	"github.com/sahasgundapaneni/reddit-clone/simulator"
+)
+
+// Run Report
+
+// reportListLimit is how many entries the report lists for a feed or the
+// direct messages; concurrent actors can leave thousands.
+const reportListLimit = 20
+
+// reporter writes the final report of a simulation run to w. main calls its
+// methods in report order as each phase of the run finishes, so every
+// section shows the engine as that phase left it.
+type reporter struct {
+	w io.Writer
+}
+
+func (r *reporter) printf(format string, args ...any) {
+	fmt.Fprintf(r.w, format, args...)
+}
+
+func (r *reporter) println(args ...any) {
+	fmt.Fprintln(r.w, args...)
+}
+
+// more notes how many of total entries the report left out.
+func (r *reporter) more(total int) {
+	if total > reportListLimit {
+		r.printf("... and %d more\n", total-reportListLimit)
+	}
+}
+
+// summary writes the site-wide totals from simStart to now.
+func (r *reporter) summary(e *engine.Engine, seed int64, throughput float64, simStart time.Time) {
+	r.println("Simulation Complete. Metrics:")
+	r.printf("Seed: %d\n", seed)
+	r.printf("Users: %d\n", len(e.Users))
+	r.printf("SubReddits: %d\n", len(e.SubReddits))
+	r.printf("Total Posts: %d\n", e.TotalPosts)
+	r.printf("Total Votes: %d\n", e.TotalVotes)
+	r.printf("Total Comments: %d\n", e.TotalComments)
+	r.printf("Total Messages: %d\n", e.TotalMessages)
+	r.printf("Total Actions: %d\n", e.TotalActions)
+	r.printf("Throughput (actions/sec): %.2f\n", throughput)
+	r.printf("Simulated Time: %v\n", e.Clock.Now().Sub(simStart).Round(time.Second))
+	r.printf("Disconnected Users: %d\n", e.DisconnectedUsers)
+	r.printf("Events Logged: %d\n", len(e.Events))
+	r.printf("Suspensions: %d (blocked actions: %d, audit entries: %d)\n", e.TotalSuspensions, e.BlockedActions, len(e.AuditLog))
+	r.printf("Rejected Crossposts: %d\n", e.RejectedCrossposts)
+	r.printf("Posts Blocked by Karma Requirements: %d\n", e.KarmaGatedPosts)
+	r.printf("NSFW Impressions Withheld from Minors: %d\n", atomic.LoadInt64(&e.AgeGatedImpressions))
+	r.printf("Churned Users: %d\n", e.ChurnedUsers)
+	r.printf("Duplicate Link Submissions: %d\n", e.DedupHits)
+	r.printf("Hidden Content: %d posts and %d comments removed by moderators, %d posts and %d comments deleted by authors\n", len(e.RemovedPosts), len(e.RemovedComments), len(e.DeletedPosts), len(e.DeletedComments))
+	simulator.PrintBackpressureChart(r.w, e, simStart, e.Clock.Now())
+	simulator.PrintDefaultSubReddits(r.w, e)
+	r.printf("Media Posts: %d (galleries: %d, attachments: %d)\n", e.AttachmentPosts, e.GalleryPosts, e.TotalAttachments)
+	r.printf("Comment Reactions: %d %v\n", e.TotalReactions, e.GetReactionTotals())
+	r.printf("Merged Accounts: %d\n", e.MergedAccounts)
+	r.printf("Stickied Comments: %d\n", len(e.StickyComments))
+	r.printf("Decayed Votes: %d (karma withheld: %.1f)\n", e.DecayedVotes, e.WithheldKarma)
+	if e.ConfigReloads > 0 {
+		r.printf("Config Reloads: %d\n", e.ConfigReloads)
+	}
+	if rejects := e.GetValidationRejects(); len(rejects) > 0 {
+		reasons := make([]string, 0, len(rejects))
+		for reason := range rejects {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		r.println("Validation Rejects:")
+		for _, reason := range reasons {
+			r.printf("  %s: %d\n", reason, rejects[reason])
+		}
+	}
+	r.printf("Content Policy Removals: %d (subreddit bans: %d)\n", e.TotalPolicyViolations, e.TotalSubRedditBans)
+	var appeals engine.AppealStats
+	for name := range e.SubReddits {
+		stats, _ := e.GetAppealStats(name)
+		appeals.Filed += stats.Filed
+		appeals.Pending += stats.Pending
+		appeals.Accepted += stats.Accepted
+		appeals.Denied += stats.Denied
+	}
+	r.printf("Ban Appeals: %d filed, %d pending, %d accepted, %d denied\n", appeals.Filed, appeals.Pending, appeals.Accepted, appeals.Denied)
+	r.printf("Comment Edits: %d (marked edited: %d, edited-content rate: %.2f%%)\n", e.TotalCommentEdits, len(e.EditedComments), e.EditedContentRate()*100)
+	collapse := e.GetCollapseStats()
+	r.printf("Collapsed Comments: %d in %d chains below score %d (collapses: %d, uncollapses: %d)\n", collapse.Comments, collapse.Chains, collapse.Threshold, collapse.Collapses, collapse.Uncollapses)
+	r.printf("Comment Arena: %d slabs, %.1f KB of comment text, %.1f KB superseded by edits\n", e.CommentArena.Slabs, float64(e.CommentArena.Bytes)/1024, float64(e.CommentArena.Wasted)/1024)
+	r.printf("Vote Anomalies: %d\n", len(e.GetAnomalies()))
+	r.printf("Translations: %d cached, %d hits, %d misses\n", len(e.TranslationCache), e.TranslationHits, e.TranslationMisses)
+}
+
+// archive writes how many posts went to cold storage, with the first of
+// them as a sample.
+func (r *reporter) archive(e *engine.Engine, archived int) {
+	r.printf("Archived Posts: %d\n", archived)
+	for id := e.IDOffset + 1; id < e.PostID && archived > 0; id++ {
+		if post, subRedditName, err := e.GetArchivedPost(id); err == nil {
+			r.printf("Sample Archived Post %d in %s: %s (%d comments)\n", post.ID, subRedditName, post.Content, len(post.Comments))
+			break
+		}
+	}
+}
+
+// phases writes the report of every simulation phase that ran, then the
+// outcome and latency of all their actions.
+func (r *reporter) phases(conversations simulator.ConversationStats, actors simulator.ActorReport, megathread simulator.MegathreadReport, visits simulator.VisitReport, bots simulator.BotReport, remote simulator.RemoteLoadReport, results *simulator.ActionResults) {
+	simulator.PrintConversationStats(r.w, conversations)
+	if actors.Actors > 0 {
+		simulator.PrintActorReport(r.w, actors)
+	}
+	if megathread.PostID != 0 {
+		simulator.PrintMegathreadReport(r.w, megathread)
+	}
+	if visits.Days > 0 {
+		simulator.PrintVisitReport(r.w, visits)
+	}
+	if bots.Rounds > 0 {
+		simulator.PrintBotReport(r.w, bots)
+	}
+	if remote.Config.Duration > 0 {
+		simulator.PrintRemoteLoadReport(r.w, remote)
+	}
+	simulator.PrintActionResults(r.w, results)
+	simulator.PrintLatencyReport(r.w, results)
+}
+
+// achievements writes how often each milestone was reached and how many
+// users hold each trophy.
+func (r *reporter) achievements(e *engine.Engine) {
+	r.println("\nMilestones:")
+	for _, milestone := range e.GetMilestoneCounts() {
+		r.printf("%s: %d\n", milestone.Kind, milestone.Count)
+	}
+
+	r.println("\nTrophies:")
+	r.printf("%-36s %8s %8s\n", "Trophy", "Users", "Share")
+	for _, trophy := range e.GetTrophyDistribution() {
+		r.printf("%-36s %8d %7.1f%%\n", trophy.Name, trophy.Users, trophy.Share*100)
+	}
+}
+
+// subReddits writes the action breakdown, how closely subreddit popularity
+// followed the Zipf law with zipfExponent, and each subreddit's engagement,
+// traffic, moderation queues and rule violations.
+func (r *reporter) subReddits(e *engine.Engine, world *engine.WorldDefinition, zipfExponent float64) {
+	// Display Action Breakdown
+	r.println("Action Breakdown:")
+	for action, count := range e.ActionBreakdown {
+		r.printf("%s: %d\n", action, count)
+	}
+
+	// Display Subreddit Metrics
+	r.println("\nSubReddit Metrics (Zipf Distribution Impact):")
+	fit := simulator.FitSubRedditPopularity(e)
+	r.printf("Zipf exponent: %.2f configured, fitted %.2f to organic memberships and %.2f to posts\n", zipfExponent, fit.Members, fit.Posts)
+	type SubRedditStats struct {
+		Name       string
+		Engagement engine.EngagementStats
+		PostCount  int
+		Topics     []string
+		OnTopic    float64
+	}
+	subredditStats := make([]SubRedditStats, 0, len(e.SubReddits))
+	for name, subreddit := range e.SubReddits {
+		engagement, _ := e.GetEngagementStats(name, 0)
+		stats := SubRedditStats{
+			Name:       name,
+			Engagement: engagement,
+			PostCount:  len(subreddit.Posts),
+			Topics:     subreddit.Topics,
+			OnTopic:    simulator.OnTopicShare(subreddit),
+		}
+		subredditStats = append(subredditStats, stats)
+	}
+
+	// Rank by who takes part rather than who subscribed
+	sort.Slice(subredditStats, func(i, j int) bool {
+		if a, b := subredditStats[i].Engagement.Contributors, subredditStats[j].Engagement.Contributors; a != b {
+			return a > b
+		}
+		return subredditStats[i].Name < subredditStats[j].Name
+	})
+
+	for i, stats := range subredditStats {
+		engagement := stats.Engagement
+		r.printf("%d. %s - Contributors: %d (posters %d, commenters %d, voters %d), Lurkers: %d of %d members, Contributor ratio: %.2f, Posts: %d, Topics: %v, On-topic members: %.0f%%\n", i+1, stats.Name, engagement.Contributors, engagement.Posters, engagement.Commenters, engagement.Voters, engagement.Lurkers, engagement.Members, engagement.ContributorRatio, stats.PostCount, stats.Topics, stats.OnTopic*100)
+	}
+
+	// Display Traffic for the Largest Subreddits
+	r.println("\nSubReddit Traffic (most contributors, 3):")
+	for _, stats := range subredditStats[:min(3, len(subredditStats))] {
+		days, _ := e.GetTrafficStats(stats.Name)
+		for _, day := range days {
+			r.printf("%s %s - Uniques: %d, Pageviews: %d, Subscriptions: +%d/-%d, Posters: %d, Commenters: %d, Voters: %d\n", stats.Name, day.Date, day.Uniques, day.Pageviews, day.Subscriptions, day.Unsubscriptions, day.Posters, day.Commenters, day.Voters)
+		}
+	}
+
+	// Display Pre-moderation Queues
+	r.println("\nPre-moderated SubReddits:")
+	for _, name := range world.SubRedditNames() {
+		if settings, _ := e.GetSubRedditSettings(name); settings.RequireApproval {
+			stats, _ := e.GetApprovalStats(name)
+			r.printf("%s - Pending: %d, Approved: %d, Rejected: %d, Approval latency mean %v, p95 %v\n", name, stats.Pending, stats.Approved, stats.Rejected, stats.MeanLatency.Round(time.Second), stats.P95Latency)
+		}
+	}
+
+	// Display Posting Embargoes
+	r.println("\nPosting Embargoes:")
+	for _, name := range world.SubRedditNames() {
+		if status, _ := e.GetEmbargoStatus(name); status.Released > 0 || status.Queued > 0 {
+			r.printf("%s - Released: %d, Still queued: %d\n", name, status.Released, status.Queued)
+		}
+	}
+	r.printf("Scheduled jobs run: %v\n", e.ScheduledRuns)
+
+	// Display Rule Violations
+	r.println("\nRule Violations:")
+	violations := make(map[string]int)
+	for name := range e.SubReddits {
+		stats, _ := e.GetRuleViolationStats(name)
+		for _, stat := range stats {
+			violations[stat.Rule.Title] += stat.Violations
+		}
+	}
+	for _, rule := range simulator.DefaultRules {
+		r.printf("%s: %d\n", rule.Title, violations[rule.Title])
+	}
+}
+
+// pools writes how the feed pool and, if there is one, the ranking pool
+// kept up.
+func (r *reporter) pools(feedPool engine.FeedPoolStats, rankingPool *engine.RankingPool) {
+	r.println("\nFeed Generation Pool:")
+	r.printf("Workers: %d, Requests: %d, Completed: %d, Timed out: %d\n", feedPool.Workers, feedPool.Submitted, feedPool.Completed, feedPool.TimedOut)
+	r.printf("Peak queue depth: %d, Utilization: %.1f%%\n", feedPool.PeakQueue, feedPool.Utilization*100)
+	if rankingPool != nil {
+		ranking := rankingPool.Stats()
+		r.println("\nRanking Compute Pool:")
+		r.printf("Workers: %d, Computed: %d, Coalesced: %d, Dropped: %d\n", ranking.Workers, ranking.Computed, ranking.Coalesced, ranking.Dropped)
+		r.printf("Cache hits: %d, Misses: %d (stale recomputes: %d)\n", ranking.Hits, ranking.Misses, ranking.StaleRecomputes)
+		r.printf("Stale hits: %d, Mean staleness: %v, Max staleness: %v\n", ranking.StaleHits, ranking.MeanStaleness, ranking.MaxStaleness)
+	}
+}
+
+// feed writes user's feed and their karma as the shared store counts it.
+func (r *reporter) feed(e *engine.Engine, user *engine.User, feed []*engine.Post) {
+	r.println("\nFeed for a Random User:")
+	for _, post := range feed[:min(len(feed), reportListLimit)] {
+		r.printf("Post ID %d (%s) by %s: %s\n", post.ID, engine.PostPermalink(post), engine.DisplayedName(post.Author), post.Content)
+	}
+	r.more(len(feed))
+	karma, _ := e.SharedKarma(user)
+	r.printf("Shared store: %s, karma counter: %d (post %d, comment %d), feed cache hits/misses: %d/%d, errors: %d\n", e.Shared.Name(), karma, user.PostKarma, user.CommentKarma, e.FeedCacheHits, e.FeedCacheMisses, e.SharedStoreErrors)
+}
+
+// personalization compares hot and personalized ranking, then how well
+// each feed sort retains users.
+func (r *reporter) personalization(e *engine.Engine, engagement simulator.EngagementReport) {
+	r.println("\nFeed Personalization (simulated engagement, top 10):")
+	r.printf("Users sampled: %d\n", engagement.UsersSampled)
+	r.printf("Hot: %.2f, Personalized: %.2f\n", engagement.HotEngagement, engagement.PersonalizedEngagement)
+
+	simulator.PrintRetentionCurves(r.w, e, 10)
+}
+
+// thread writes the busiest thread as Markdown.
+func (r *reporter) thread(markdown []byte) {
+	r.println("\nSample Thread:")
+	r.w.Write(markdown)
+}
+
+// threadSummary writes the busiest thread's summary and how often cached
+// summaries were reused.
+func (r *reporter) threadSummary(summary engine.ThreadSummary, stats engine.SummaryStats) {
+	r.printf("\nThread Summary (%d comments):\n%s\n", summary.Comments, summary.Summary)
+	r.printf("Thread Summaries: %d cached, %d hits, %d misses (%d invalidated by new activity)\n", stats.Cached, stats.Hits, stats.Misses, stats.Invalidations)
+}
+
+// messages writes the direct messages and how the inbox and spam filter
+// handled them.
+func (r *reporter) messages(e *engine.Engine) {
+	r.println("\nDirect Messages:")
+	for _, message := range e.Messages[:min(len(e.Messages), reportListLimit)] {
+		r.printf("From %s to %s: %s\n", message.From.Username, message.To.Username, message.Content)
+	}
+	r.more(len(e.Messages))
+
+	rates, accuracy := e.GetInboxRates(), e.GetSpamAccuracy()
+	r.printf("Inbox Rates: %d delivered, %d filed as spam, %.2f per recipient, peak %d in one hour (%s)\n", rates.Inbox, rates.Spam, rates.MeanPerRecipient, rates.PeakHourly, rates.PeakUser)
+	requests := e.GetDMRequestStats()
+	r.printf("DM Requests: %d pending (%d messages held), %d accepted (%d automatically), %d declined, %d messages dropped\n", requests.Pending, rates.Requests, requests.Accepted, requests.AutoAccepted, requests.Declined, requests.Dropped)
+	r.printf("Spam Classification: accuracy %.1f%%, precision %.1f%%, recall %.1f%%\n", accuracy.Accuracy()*100, accuracy.Precision()*100, accuracy.Recall()*100)
+}
+
+// broadcast writes the announcement's fan-out, unless it failed, and the
+// reply notifications sent and muted over the run.
+func (r *reporter) broadcast(e *engine.Engine, stats engine.BroadcastStats, err error) {
+	r.println("\nBroadcast Announcement:")
+	if err != nil {
+		r.printf("Broadcast failed: %v\n", err)
+	} else {
+		r.printf("Recipients: %d, Delivered: %d, Queued: %d, Fan-out time: %v\n", stats.Recipients, stats.Delivered, stats.Queued, stats.Duration)
+	}
+	muted := e.GetMutedNotifications()
+	r.printf("Reply Notifications: %d sent, muted by thread: %d, post: %d, subreddit: %d\n", e.ReplyNotifications, muted[engine.MuteThread], muted[engine.MutePost], muted[engine.MuteSubReddit])
+}
+
+// chaos writes the faults injected and how each event hook fared, given
+// the events the run's own hook observed.
+func (r *reporter) chaos(e *engine.Engine, chaos *engine.Chaos, observed int64) {
+	r.println("\nChaos Mode:")
+	r.printf("Lock delays: %d, Dropped hook deliveries: %d, Killed workers: %d\n", chaos.LockDelays, chaos.DroppedHooks, chaos.KilledWorkers)
+	for i, stats := range e.HookStats() {
+		r.printf("Hook %d: emitted %d, delivered %d, dropped %d, restarts %d (observed %d)\n", i, stats.Emitted, stats.Delivered, stats.Dropped, stats.Restarts, observed)
+	}
+}
+
+// violations writes the violations a check found under title and reports
+// whether there were any.
+func (r *reporter) violations(title string, violations []string) bool {
+	if len(violations) == 0 {
+		return false
+	}
+	r.println(title)
+	for _, violation := range violations {
+		r.printf("  %s\n", violation)
+	}
+	return true
+}
+
+// ageGating writes how many minors' listings were checked and any NSFW
+// content that reached them, and reports whether any did.
+func (r *reporter) ageGating(gating simulator.AgeGatingReport) bool {
+	r.printf("Age gating checked for %d minors across %d feeds and %d recommendations.\n", gating.Minors, gating.Feeds, gating.Recommendations)
+	for _, leak := range gating.Leaks {
+		r.printf("  %s\n", leak)
+	}
+	return len(gating.Leaks) > 0
+}
+
+// codecRounds is how many times each codec encodes and decodes the
+// snapshot, averaged in its report.
+const codecRounds = 5
+
+// codecBenchmark is how one codec did on a snapshot: the encoded size and
+// the mean time to encode and decode it once. Failed is set if the codec
+// couldn't encode or decode it.
+type codecBenchmark struct {
+	codec          string
+	bytes          int
+	encode, decode time.Duration
+	failed         bool
+}
+
+// runCodecBenchmarks times every codec encoding and decoding e's snapshot
+// codecRounds times.
+func runCodecBenchmarks(e *engine.Engine) []codecBenchmark {
+	benchmarks := make([]codecBenchmark, 0, len(engine.Codecs()))
+	for _, codec := range engine.Codecs() {
+		benchmarks = append(benchmarks, benchmarkCodec(e, codec))
+	}
+	return benchmarks
+}
+
+func benchmarkCodec(e *engine.Engine, codec engine.Codec) codecBenchmark {
+	benchmark := codecBenchmark{codec: codec.Name()}
+	var data []byte
+	var err error
+	start := time.Now()
+	for i := 0; i < codecRounds && err == nil; i++ {
+		data, err = e.EncodeSnapshot(codec)
+	}
+	benchmark.encode = time.Since(start) / codecRounds
+	start = time.Now()
+	for i := 0; i < codecRounds && err == nil; i++ {
+		err = engine.DecodeSnapshot(codec, data)
+	}
+	benchmark.decode = time.Since(start) / codecRounds
+	benchmark.bytes, benchmark.failed = len(data), err != nil
+	return benchmark
+}
+
+// codecs writes each codec's snapshot size and encode and decode times,
+// with the size relative to the first codec's.
+func (r *reporter) codecs(benchmarks []codecBenchmark) {
+	r.println("\nCodecs (snapshot of the final state):")
+	base := benchmarks[0]
+	for _, benchmark := range benchmarks {
+		if benchmark.failed {
+			r.printf("%-8s failed\n", benchmark.codec)
+			continue
+		}
+		r.printf("%-8s %10d bytes (%3.0f%%)  encode %-12v decode %v\n", benchmark.codec, benchmark.bytes,
+			100*float64(benchmark.bytes)/float64(max(base.bytes, 1)), benchmark.encode, benchmark.decode)
+	}
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Event Log
+
+// Event is one entry of the engine's event log. TargetID is the post,
+// comment or user acted on, or the argument of a control action.
+type Event struct {
+	Seq       int
+	Time      time.Time
+	Type      string
+	UserID    int64
+	SubReddit string
+	TargetID  int64
+}
+
+// recordEvent appends to the event log. Callers must hold e.Mutex.
+func (e *Engine) recordEvent(eventType string, userID int64, subRedditName string, targetID int64) {
+	e.EventSeq++
+	event := Event{
+		Seq:       e.EventSeq,
+		Time:      e.Clock.Now(),
+		Type:      eventType,
+		UserID:    userID,
+		SubReddit: subRedditName,
+		TargetID:  targetID,
+	}
+	e.Events = append(e.Events, event)
+	e.recordActivity(event)
+	e.dispatchEvent(event)
+	e.traceEvent(event)
+	e.logEvent(event)
+}
+
+// EventsSince returns up to limit events logged after the one numbered seq,
+// oldest first, for clients that follow the site by polling. A limit of 0
+// or less returns them all.
+func (e *Engine) EventsSince(seq, limit int) []Event {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	start := sort.Search(len(e.Events), func(i int) bool { return e.Events[i].Seq > seq })
+	end := len(e.Events)
+	if limit > 0 {
+		end = min(end, start+limit)
+	}
+	return append([]Event(nil), e.Events[start:end]...)
+}
+
+// Custom Actions
+
+var (
+	ErrActionExists  = errors.New("action already registered")
+	ErrUnknownAction = errors.New("unknown action")
+)
+
+// ActionHandler implements a custom action. It runs with e.Mutex held for
+// writing, so it may touch engine state directly but must not call other
+// locking Engine methods.
+type ActionHandler func(e *Engine, user *User, args map[string]interface{}) error
+
+// RegisterAction makes handler available to PerformAction under name, and
+// counts it in the action breakdown.
+func (e *Engine) RegisterAction(name string, handler ActionHandler) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if _, exists := e.CustomActions[name]; exists {
+		return ErrActionExists
+	}
+	if _, exists := e.ActionBreakdown[name]; exists {
+		return ErrActionExists
+	}
+	e.CustomActions[name] = handler
+	e.ActionBreakdown[name] = 0
+	return nil
+}
+
+// PerformAction runs the custom action registered as name on behalf of the
+// user.
+func (e *Engine) PerformAction(user *User, name string, args map[string]interface{}) error {
+	return e.PerformActionContext(context.Background(), user, name, args)
+}
+
+// PerformActionContext is PerformAction, traced as a child of the span in ctx.
+func (e *Engine) PerformActionContext(ctx context.Context, user *User, name string, args map[string]interface{}) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	handler, exists := e.CustomActions[name]
+	if !exists {
+		return ErrUnknownAction
+	}
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	if err := handler(e, user, args); err != nil {
+		return err
+	}
+	e.ActionBreakdown[name]++
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent(name, user.ID, "", 0)
+	return nil
+}
+
+// AwardAction is an ActionHandler that gives the author of args["post"] a
+// karma bonus. The simulator registers it as "award".
+func AwardAction(e *Engine, user *User, args map[string]interface{}) error {
+	post, ok := args["post"].(*Post)
+	if !ok || post == nil {
+		return errors.New("award: missing post")
+	}
+	if post.Author == user {
+		return errors.New("award: cannot award own post")
+	}
+	post.Author.PostKarma += 10
+	e.checkKarmaMilestones(post.Author)
+	e.bumpShared(UserKarmaKey(post.Author.ID), 10)
+	return nil
+}
+
+// RecordControl logs an operator action. value carries the action's
+// argument, such as the new rate or the number of users added.
+func (e *Engine) RecordControl(action string, value int64) {
+	e.RecordControlContext(context.Background(), action, value)
+}
+
+// RecordControlContext is RecordControl, traced as a child of the span in ctx.
+func (e *Engine) RecordControlContext(ctx context.Context, action string, value int64) {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	e.recordEvent(action, 0, "", value)
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Activity Heatmaps
+
+// ActivityHeatmap counts actions by day of week and hour of day, indexed
+// [time.Weekday][hour] in the engine clock's location. It encodes as a 7x24
+// JSON matrix, Sunday first. Buckets follow e.Clock, so only a SimClock
+// advanced over several days spreads activity across the matrix.
+type ActivityHeatmap [7][24]int
+
+// ActivityHeatmaps is the export written by ExportActivityHeatmaps: the whole
+// site, each simulated persona and each user, keyed by username. Merged
+// accounts are folded into the account they were merged into.
+type ActivityHeatmaps struct {
+	Site     ActivityHeatmap
+	Personas map[string]ActivityHeatmap
+	Users    map[string]ActivityHeatmap
+}
+
+func (h *ActivityHeatmap) add(other ActivityHeatmap) {
+	for day := range h {
+		for hour := range h[day] {
+			h[day][hour] += other[day][hour]
+		}
+	}
+}
+
+// Total is the number of actions counted.
+func (h ActivityHeatmap) Total() int {
+	total := 0
+	for day := range h {
+		for _, count := range h[day] {
+			total += count
+		}
+	}
+	return total
+}
+
+// Peak returns the busiest day and hour. Ties go to the earliest bucket.
+func (h ActivityHeatmap) Peak() (time.Weekday, int) {
+	peakDay, peakHour := time.Sunday, 0
+	for day := range h {
+		for hour, count := range h[day] {
+			if count > h[peakDay][peakHour] {
+				peakDay, peakHour = time.Weekday(day), hour
+			}
+		}
+	}
+	return peakDay, peakHour
+}
+
+// recordActivity counts event in its user's heatmap. Bookkeeping events and
+// events without an acting user are skipped. Callers must hold e.Mutex.
+func (e *Engine) recordActivity(event Event) {
+	if nonActionEvents[event.Type] {
+		return
+	}
+	user, exists := e.Users[event.UserID]
+	if !exists {
+		return
+	}
+	user.Activity[event.Time.Weekday()][event.Time.Hour()]++
+}
+
+// GetActivityHeatmap returns the user's activity by day of week and hour.
+func (e *Engine) GetActivityHeatmap(user *User) ActivityHeatmap {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return user.Activity
+}
+
+// GetActivityHeatmaps aggregates every user's activity for the site, by
+// persona and by user. Users without a persona are left out of Personas.
+func (e *Engine) GetActivityHeatmaps() ActivityHeatmaps {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	heatmaps := ActivityHeatmaps{Personas: make(map[string]ActivityHeatmap), Users: make(map[string]ActivityHeatmap)}
+	for _, user := range e.Users {
+		if user.MergedInto != 0 {
+			continue
+		}
+		heatmaps.Site.add(user.Activity)
+		heatmaps.Users[user.Username] = user.Activity
+		if user.Persona != "" {
+			persona := heatmaps.Personas[user.Persona]
+			persona.add(user.Activity)
+			heatmaps.Personas[user.Persona] = persona
+		}
+	}
+	return heatmaps
+}
+
+// ExportActivityHeatmaps writes GetActivityHeatmaps as indented JSON.
+func (e *Engine) ExportActivityHeatmaps(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e.GetActivityHeatmaps())
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Age Gating
+
+var (
+	ErrInvalidAge    = errors.New("invalid age")
+	ErrAgeRestricted = errors.New("age restricted")
+)
+
+// AdultAge is the age at which users may see NSFW subreddits and posts.
+const AdultAge = 18
+
+// maxAge bounds the ages SetUserAge accepts.
+const maxAge = 150
+
+// SetUserAge records the user's age in years. Users whose age isn't known,
+// or is under AdultAge, are never shown NSFW content.
+func (e *Engine) SetUserAge(user *User, age int) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if age <= 0 || age > maxAge {
+		return ErrInvalidAge
+	}
+	user.Age = age
+	e.recordEvent("set_age", user.ID, "", int64(age))
+	return nil
+}
+
+// SetPostNSFW marks or unmarks post as NSFW. Only its author or a moderator
+// of its subreddit may change it.
+func (e *Engine) SetPostNSFW(user *User, post *Post, nsfw bool) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if post.Author != user && !e.isModerator(user, subReddit) {
+		return ErrNotModerator
+	}
+	post.NSFW = nsfw
+	if nsfw {
+		e.recordEvent("mark_nsfw", user.ID, post.SubReddit, post.ID)
+	} else {
+		e.recordEvent("unmark_nsfw", user.ID, post.SubReddit, post.ID)
+	}
+	return nil
+}
+
+// minor reports whether user is too young, or of unknown age, to be shown
+// NSFW content.
+func minor(user *User) bool {
+	return user.Age < AdultAge
+}
+
+// isNSFW reports whether post is NSFW itself or in an NSFW subreddit.
+// Callers must hold e.Mutex.
+func (e *Engine) isNSFW(post *Post) bool {
+	if post.NSFW {
+		return true
+	}
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	return exists && subReddit.Settings.NSFW
+}
+
+// ageGated reports whether post must be withheld from user, counting it as
+// a filtered impression if so. Listings are served under the read lock, so
+// the count is kept atomically. Callers must hold e.Mutex.
+func (e *Engine) ageGated(user *User, post *Post) bool {
+	if !minor(user) || !e.isNSFW(post) {
+		return false
+	}
+	atomic.AddInt64(&e.AgeGatedImpressions, 1)
+	return true
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestMinorsDontSeeNSFW checks that NSFW posts and subreddits are kept from
+// minors and from users whose age isn't known, in feeds, recommendations
+// and joins, and not from adults.
+func TestMinorsDontSeeNSFW(t *testing.T) {
+	e, author, unknown := newTestSite(t)
+	adult, err := e.RegisterUser("adult")
+	if err != nil {
+		t.Fatal(err)
+	}
+	minorUser, err := e.RegisterUser("minor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newcomer, err := e.RegisterUser("newcomer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for user, age := range map[*User]int{adult: 30, minorUser: 15, newcomer: 30} {
+		if err := e.SetUserAge(user, age); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, user := range []*User{adult, minorUser} {
+		if err := e.JoinSubReddit(user, "news"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := e.CreateSubReddit("afterdark"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetSubRedditSettings("afterdark", SubRedditSettings{NSFW: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []*User{minorUser, unknown} {
+		if err := e.JoinSubReddit(user, "afterdark"); !errors.Is(err, ErrAgeRestricted) {
+			t.Errorf("%s joining an NSFW subreddit: %v, want ErrAgeRestricted", user.Username, err)
+		}
+	}
+	if err := e.JoinSubReddit(adult, "afterdark"); err != nil {
+		t.Fatal(err)
+	}
+
+	safe, err := e.CreatePost(author, "news", "Safe for work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flagged, err := e.CreatePost(author, "news", "Not safe for work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetPostNSFW(author, flagged, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.CreatePost(adult, "afterdark", "Also not safe for work"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []*User{minorUser, unknown} {
+		if feed := e.GetUserFeed(user); len(feed) != 1 || feed[0] != safe {
+			t.Errorf("%s's feed has %d posts, want only post %d", user.Username, len(feed), safe.ID)
+		}
+		for _, recommendation := range e.RecommendSubReddits(user, len(e.SubReddits)) {
+			if recommendation.SubReddit == "afterdark" {
+				t.Errorf("%s was recommended an NSFW subreddit", user.Username)
+			}
+		}
+	}
+	if feed := e.GetUserFeed(adult); len(feed) != 3 {
+		t.Errorf("adult's feed has %d posts, want 3", len(feed))
+	}
+	recommended := false
+	for _, recommendation := range e.RecommendSubReddits(newcomer, len(e.SubReddits)) {
+		recommended = recommended || recommendation.SubReddit == "afterdark"
+	}
+	if !recommended {
+		t.Error("an adult wasn't recommended the NSFW subreddit")
+	}
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Allocation budgets for the hot paths. Posts and comments are stored by
+// pointer and feeds are sized before they are filled, so none of these
+// should grow with the size of the site; the budgets leave a little room
+// for amortized slice and map growth.
+const (
+	createPostAllocs  = 6
+	commentPostAllocs = 8
+	userFeedAllocs    = 1
+)
+
+// allocRuns is how many times each hot path is measured.
+const allocRuns = 200
+
+// distinctContents returns n different post or comment bodies, made ahead
+// of time so building them isn't counted, and so none is rejected as a
+// duplicate.
+func distinctContents(n int) []string {
+	contents := make([]string, n)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("Content number %d", i)
+	}
+	return contents
+}
+
+func TestCreatePostAllocs(t *testing.T) {
+	e, author, _ := newTestSite(t)
+	contents := distinctContents(allocRuns + 1)
+	i := 0
+	allocs := testing.AllocsPerRun(allocRuns, func() {
+		if _, err := e.CreatePost(author, "news", contents[i]); err != nil {
+			t.Fatal(err)
+		}
+		i++
+	})
+	if allocs > createPostAllocs {
+		t.Errorf("CreatePost allocates %.1f times per call, want at most %d", allocs, createPostAllocs)
+	}
+}
+
+func TestCommentPostAllocs(t *testing.T) {
+	e, author, voter := newTestSite(t)
+	post, err := e.CreatePost(author, "news", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := distinctContents(allocRuns + 1)
+	i := 0
+	allocs := testing.AllocsPerRun(allocRuns, func() {
+		if _, err := e.CommentPost(voter, post, contents[i]); err != nil {
+			t.Fatal(err)
+		}
+		i++
+	})
+	if allocs > commentPostAllocs {
+		t.Errorf("CommentPost allocates %.1f times per call, want at most %d", allocs, commentPostAllocs)
+	}
+}
+
+func TestGetUserFeedAllocs(t *testing.T) {
+	e, author, voter := newTestSite(t)
+	for _, content := range distinctContents(500) {
+		if _, err := e.CreatePost(author, "news", content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	allocs := testing.AllocsPerRun(allocRuns, func() {
+		if feed := e.GetUserFeed(voter); len(feed) != 500 {
+			t.Fatalf("feed has %d posts, want 500", len(feed))
+		}
+	})
+	if allocs > userFeedAllocs {
+		t.Errorf("GetUserFeed allocates %.1f times per call, want at most %d", allocs, userFeedAllocs)
+	}
+}
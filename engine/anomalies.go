@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// Vote Velocity Anomalies
+
+const (
+	velocityWindow     = time.Minute
+	anomalyStdDevs     = 3.0
+	anomalyMinBuckets  = 5
+	anomalyMinVelocity = 5
+)
+
+// Anomaly is a post whose vote velocity stood out from its subreddit's.
+type Anomaly struct {
+	PostID     int64
+	SubReddit  string
+	Velocity   float64
+	Mean       float64
+	StdDev     float64
+	DetectedAt time.Time
+}
+
+type postVelocity struct {
+	bucketStart time.Time
+	count       int
+	alerted     bool
+}
+
+// velocityStats summarizes per-window vote counts across all posts of a
+// subreddit. Sums are updated in place as buckets fill, so the norm never
+// needs a rescan.
+type velocityStats struct {
+	buckets int
+	sum     float64
+	sumSq   float64
+}
+
+func (s *velocityStats) meanStdDev() (float64, float64) {
+	if s.buckets == 0 {
+		return 0, 0
+	}
+	mean := s.sum / float64(s.buckets)
+	variance := s.sumSq/float64(s.buckets) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return mean, math.Sqrt(variance)
+}
+
+// trackVoteVelocity counts a vote on post in its current window and raises
+// an anomaly the first time the window's velocity is well above the
+// subreddit's norm. Callers must hold e.Mutex.
+func (e *Engine) trackVoteVelocity(post *Post) {
+	now := e.Clock.Now()
+	stats, exists := e.velocityStats[post.SubReddit]
+	if !exists {
+		stats = &velocityStats{}
+		e.velocityStats[post.SubReddit] = stats
+	}
+	velocity, exists := e.postVelocity[post.ID]
+	if !exists || now.Sub(velocity.bucketStart) >= velocityWindow {
+		velocity = &postVelocity{bucketStart: now}
+		e.postVelocity[post.ID] = velocity
+		stats.buckets++
+	}
+	c := float64(velocity.count)
+	stats.sum++
+	stats.sumSq += 2*c + 1
+	velocity.count++
+
+	if velocity.alerted || velocity.count < anomalyMinVelocity || stats.buckets < anomalyMinBuckets {
+		return
+	}
+	rate := float64(velocity.count) / velocityWindow.Minutes()
+	mean, stdDev := stats.meanStdDev()
+	if rate > mean+anomalyStdDevs*stdDev {
+		velocity.alerted = true
+		e.Anomalies = append(e.Anomalies, Anomaly{
+			PostID:     post.ID,
+			SubReddit:  post.SubReddit,
+			Velocity:   rate,
+			Mean:       mean,
+			StdDev:     stdDev,
+			DetectedAt: now,
+		})
+		e.recordEvent("vote_anomaly", 0, post.SubReddit, post.ID)
+	}
+}
+
+// GetAnomalies returns the anomalies detected so far, oldest first.
+func (e *Engine) GetAnomalies() []Anomaly {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return append([]Anomaly(nil), e.Anomalies...)
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newVotingSite returns newTestSite's engine on a simulated clock, its
+// author, posts by the author and users voters to vote on them.
+func newVotingSite(t *testing.T, posts, voters int) (*Engine, *SimClock, []*Post, []*User) {
+	t.Helper()
+	e, author, _ := newTestSite(t)
+	clock := NewSimClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	e.Clock = clock
+	var listed []*Post
+	for i := 0; i < posts; i++ {
+		post, err := e.CreatePost(author, "news", fmt.Sprintf("Post %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed = append(listed, post)
+	}
+	var users []*User
+	for i := 0; i < voters; i++ {
+		user, err := e.RegisterUser(fmt.Sprintf("voter%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	return e, clock, listed, users
+}
+
+// voteSteadily gives each post one to six votes a minute for minutes
+// minutes, the kind of traffic the detector should leave alone even when a
+// post passes anomalyMinVelocity.
+func voteSteadily(t *testing.T, e *Engine, clock *SimClock, posts []*Post, voters []*User, minutes int) {
+	t.Helper()
+	next := make(map[*Post]int)
+	for minute := 0; minute < minutes; minute++ {
+		for i, post := range posts {
+			for v := 0; v <= (minute+i)%6; v++ {
+				if err := e.UpvotePost(voters[next[post]], post); err != nil {
+					t.Fatal(err)
+				}
+				next[post]++
+			}
+		}
+		clock.Advance(velocityWindow)
+	}
+}
+
+func TestSteadyVotesAreNotAnomalies(t *testing.T) {
+	e, clock, posts, voters := newVotingSite(t, 10, 40)
+	voteSteadily(t, e, clock, posts, voters, 10)
+	if anomalies := e.GetAnomalies(); len(anomalies) != 0 {
+		t.Errorf("steady voting raised %d anomalies, first on post %d", len(anomalies), anomalies[0].PostID)
+	}
+}
+
+func TestVoteBurstIsAnomaly(t *testing.T) {
+	e, clock, posts, voters := newVotingSite(t, 11, 40)
+	voteSteadily(t, e, clock, posts[:10], voters, 10)
+	target := posts[10]
+	for _, voter := range voters[:25] {
+		if err := e.UpvotePost(voter, target); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+	}
+	anomalies := e.GetAnomalies()
+	if len(anomalies) != 1 || anomalies[0].PostID != target.ID {
+		t.Fatalf("got anomalies %+v, want one on post %d", anomalies, target.ID)
+	}
+	if anomalies[0].Velocity <= anomalies[0].Mean {
+		t.Errorf("burst velocity %.1f isn't above the mean %.1f", anomalies[0].Velocity, anomalies[0].Mean)
+	}
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Post Attachments and Galleries
+
+// AttachmentType is the kind of media an attachment holds.
+type AttachmentType string
+
+const (
+	AttachmentImage AttachmentType = "image"
+	AttachmentGIF   AttachmentType = "gif"
+	AttachmentVideo AttachmentType = "video"
+)
+
+const (
+	maxAttachments   = 20
+	maxCaptionLength = 180
+)
+
+var (
+	ErrNoAttachments       = errors.New("attachment post has no attachments")
+	ErrTooManyAttachments  = errors.New("too many attachments")
+	ErrUnknownAttachment   = errors.New("unknown attachment type")
+	ErrInvalidAttachment   = errors.New("attachment reference must be an http or https URL")
+	ErrVideoNotInGallery   = errors.New("videos can't be part of a gallery")
+	ErrDuplicateAttachment = errors.New("attachment appears twice")
+)
+
+// Attachment is one piece of media on a post, in display order. Reference
+// is where the media is hosted; Caption is optional.
+type Attachment struct {
+	Type      AttachmentType
+	Reference string
+	Caption   string `json:",omitempty"`
+}
+
+// AttachmentError reports which attachment was rejected. It matches the
+// underlying error with errors.Is.
+type AttachmentError struct {
+	Index int
+	Err   error
+}
+
+func (err *AttachmentError) Error() string {
+	return fmt.Sprintf("attachment %d: %v", err.Index+1, err.Err)
+}
+
+func (err *AttachmentError) Unwrap() error {
+	return err.Err
+}
+
+// IsGallery reports whether the post is a gallery: more than one image or
+// GIF shown as a swipeable set.
+func (post *Post) IsGallery() bool {
+	return len(post.Attachments) > 1
+}
+
+// CreateAttachmentPost submits a post made of media. One attachment of any
+// type makes a media post; several make a gallery, which may only hold
+// images and GIFs. The title is validated like a link post's.
+func (e *Engine) CreateAttachmentPost(user *User, subRedditName, title string, attachments []Attachment) (*Post, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if e.isBanned(user, subReddit) {
+		return nil, ErrBannedFromSubReddit
+	}
+	if err := e.checkPostKarma(user, subReddit); err != nil {
+		return nil, err
+	}
+	if e.overQuota(e.Quota.MaxPosts, e.TotalPosts) {
+		return nil, ErrQuotaExceeded
+	}
+	title, err := e.validateContent("title", title, maxTitleLength)
+	if err != nil {
+		return nil, err
+	}
+	validated, err := e.validateAttachments(attachments)
+	if err != nil {
+		return nil, err
+	}
+	post := e.insertPost(subReddit, Post{Author: user, Content: title, Attachments: validated}, "post")
+	e.AttachmentPosts++
+	e.TotalAttachments += len(validated)
+	if post.IsGallery() {
+		e.GalleryPosts++
+	}
+	return post, nil
+}
+
+// validateAttachments checks and copies attachments, cleaning captions the
+// way validateContent cleans other text. Callers must hold e.Mutex.
+func (e *Engine) validateAttachments(attachments []Attachment) ([]Attachment, error) {
+	if len(attachments) == 0 {
+		return nil, ErrNoAttachments
+	}
+	if len(attachments) > maxAttachments {
+		return nil, ErrTooManyAttachments
+	}
+	validated := make([]Attachment, len(attachments))
+	seen := make(map[string]bool, len(attachments))
+	for i, attachment := range attachments {
+		var reason error
+		switch attachment.Type {
+		case AttachmentImage, AttachmentGIF:
+		case AttachmentVideo:
+			if len(attachments) > 1 {
+				reason = ErrVideoNotInGallery
+			}
+		default:
+			reason = ErrUnknownAttachment
+		}
+		if parsed, err := url.Parse(attachment.Reference); reason == nil && (err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https")) {
+			reason = ErrInvalidAttachment
+		}
+		if reason == nil && seen[attachment.Reference] {
+			reason = ErrDuplicateAttachment
+		}
+		if reason != nil {
+			e.ValidationRejects["attachment: "+reason.Error()]++
+			return nil, &AttachmentError{Index: i, Err: reason}
+		}
+		seen[attachment.Reference] = true
+		if attachment.Caption != "" {
+			caption, err := e.validateContent("caption", attachment.Caption, maxCaptionLength)
+			if err != nil {
+				return nil, &AttachmentError{Index: i, Err: err}
+			}
+			attachment.Caption = caption
+		}
+		validated[i] = attachment
+	}
+	return validated, nil
+}
+
+func appendColdAttachments(buf []byte, attachments []Attachment) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(attachments)))
+	for _, attachment := range attachments {
+		buf = appendColdString(buf, string(attachment.Type))
+		buf = appendColdString(buf, attachment.Reference)
+		buf = appendColdString(buf, attachment.Caption)
+	}
+	return buf
+}
+
+func decodeColdAttachments(r *coldReader) []Attachment {
+	count := int(r.uvarint())
+	if count == 0 {
+		return nil
+	}
+	attachments := make([]Attachment, count)
+	for i := range attachments {
+		attachments[i] = Attachment{Type: AttachmentType(r.string()), Reference: r.string(), Caption: r.string()}
+	}
+	return attachments
+}
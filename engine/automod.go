@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Content Policy (AutoMod)
+
+var ErrBannedFromSubReddit = errors.New("user is banned from this subreddit")
+
+// ContentPolicy is evaluated against every post and comment submitted to a
+// subreddit. Offending content is removed as soon as it is created. With
+// WarningsBeforeBan set, each removal warns the author and the last warning
+// bans them from the subreddit for BanDuration (or permanently if zero).
+type ContentPolicy struct {
+	BannedWords       []string
+	MaxLength         int
+	WarningsBeforeBan int
+	BanDuration       time.Duration
+}
+
+const (
+	violationBannedWord = "banned_word"
+	violationTooLong    = "too_long"
+)
+
+// SetContentPolicy replaces the subreddit's content policy. Only its
+// moderators may set it.
+func (e *Engine) SetContentPolicy(mod *User, subRedditName string, policy ContentPolicy) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if !e.isModerator(mod, subReddit) {
+		return ErrNotModerator
+	}
+	setContentPolicy(subReddit, policy)
+	e.recordModAction(subReddit, mod, "set_content_policy", 0, 0)
+	return nil
+}
+
+// setContentPolicy installs policy with its banned words normalized to
+// lowercase. Callers must hold e.Mutex.
+func setContentPolicy(subReddit *SubReddit, policy ContentPolicy) {
+	policy.BannedWords = append([]string(nil), policy.BannedWords...)
+	subReddit.bannedWords = make(map[string]bool, len(policy.BannedWords))
+	for i, word := range policy.BannedWords {
+		policy.BannedWords[i] = strings.ToLower(word)
+		subReddit.bannedWords[policy.BannedWords[i]] = true
+	}
+	subReddit.Policy = policy
+}
+
+// policyViolation returns why content breaks the subreddit's policy, or "".
+func policyViolation(subReddit *SubReddit, content string) string {
+	if max := subReddit.Policy.MaxLength; max > 0 && len(content) > max {
+		return violationTooLong
+	}
+	if len(subReddit.bannedWords) > 0 {
+		words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if subReddit.bannedWords[word] {
+				return violationBannedWord
+			}
+		}
+	}
+	return ""
+}
+
+// enforcePostPolicy removes a just-created or edited post that breaks the
+// subreddit's policy. Callers must hold e.Mutex.
+func (e *Engine) enforcePostPolicy(subReddit *SubReddit, post *Post) {
+	reason := policyViolation(subReddit, post.Content)
+	if reason == "" {
+		return
+	}
+	post.Removed = true
+	e.RemovedPosts[post.ID] = 0
+	e.autoModRemove(subReddit, post.Author, "automod_remove_post", post.ID, reason)
+}
+
+// enforceCommentPolicy is enforcePostPolicy for comments. Callers must hold
+// e.Mutex.
+func (e *Engine) enforceCommentPolicy(subReddit *SubReddit, comment *Comment) {
+	reason := policyViolation(subReddit, comment.Content())
+	if reason == "" {
+		return
+	}
+	comment.Removed = true
+	e.RemovedComments[comment.ID] = 0
+	e.dropSticky(comment)
+	e.autoModRemove(subReddit, comment.Author, "automod_remove_comment", comment.ID, reason)
+}
+
+func (e *Engine) autoModRemove(subReddit *SubReddit, author *User, action string, targetID int64, reason string) {
+	subReddit.PolicyViolations[reason]++
+	e.TotalPolicyViolations++
+	subReddit.ModLog = append(subReddit.ModLog, ModLogEntry{Time: e.Clock.Now(), Action: action, TargetID: targetID})
+	e.recordEvent(action, 0, subReddit.Name, targetID)
+
+	limit := subReddit.Policy.WarningsBeforeBan
+	if limit <= 0 {
+		return
+	}
+	subReddit.Warnings[author.ID]++
+	warnings := subReddit.Warnings[author.ID]
+	if warnings < limit {
+		e.notify(author, "automod_warning", fmt.Sprintf("Your submission to %s was removed (%s). Warning %d of %d.", subReddit.Name, reason, warnings, limit))
+		return
+	}
+	until := time.Time{}
+	if subReddit.Policy.BanDuration > 0 {
+		until = e.Clock.Now().Add(subReddit.Policy.BanDuration)
+	}
+	e.ban(subReddit, author.ID, until)
+	delete(subReddit.Warnings, author.ID)
+	e.TotalSubRedditBans++
+	e.notify(author, "subreddit_ban", fmt.Sprintf("You have been banned from %s after %d policy warnings.", subReddit.Name, limit))
+	subReddit.ModLog = append(subReddit.ModLog, ModLogEntry{Time: e.Clock.Now(), Action: "automod_ban", TargetID: author.ID})
+	e.recordEvent("automod_ban", author.ID, subReddit.Name, 0)
+}
+
+// ban bans the user numbered userID from subReddit until the given time,
+// zero meaning for good. Each ban gets a new ID in subReddit.BanIDs, so two
+// bans ending at the same time, such as two permanent ones, stay apart.
+// Callers must hold e.Mutex.
+func (e *Engine) ban(subReddit *SubReddit, userID int64, until time.Time) {
+	e.BanID++
+	subReddit.Banned[userID] = until
+	subReddit.BanIDs[userID] = e.BanID
+}
+
+// unban lifts the user's ban from subReddit. Callers must hold e.Mutex.
+func (e *Engine) unban(subReddit *SubReddit, userID int64) {
+	delete(subReddit.Banned, userID)
+	delete(subReddit.BanIDs, userID)
+}
+
+// isBanned reports whether user is banned from subReddit. An expired ban
+// no longer counts but stays in Banned until LiftExpiredBans sweeps it, so
+// this only reads and holding e.Mutex's read lock is enough.
+func (e *Engine) isBanned(user *User, subReddit *SubReddit) bool {
+	until, banned := subReddit.Banned[user.ID]
+	return banned && (until.IsZero() || e.Clock.Now().Before(until))
+}
+
+// LiftExpiredBans removes every expired ban from every subreddit and
+// returns how many it removed.
+func (e *Engine) LiftExpiredBans() int {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	now := e.Clock.Now()
+	lifted := 0
+	for _, subReddit := range e.SubReddits {
+		for id, until := range subReddit.Banned {
+			if !until.IsZero() && !now.Before(until) {
+				e.unban(subReddit, id)
+				lifted++
+			}
+		}
+	}
+	return lifted
+}
+
+// IsBanned reports whether the user is currently banned from the
+// subreddit.
+func (e *Engine) IsBanned(user *User, subRedditName string) bool {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	return exists && e.isBanned(user, subReddit)
+}
+
+// GetPolicyViolations returns removal counts by violation reason.
+func (e *Engine) GetPolicyViolations(subRedditName string) (map[string]int, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	counts := make(map[string]int, len(subReddit.PolicyViolations))
+	for reason, count := range subReddit.PolicyViolations {
+		counts[reason] = count
+	}
+	return counts, nil
+}
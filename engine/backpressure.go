@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Backpressure
+
+const (
+	QueueVotes         = "votes"
+	QueueNotifications = "notifications"
+	QueueHooks         = "hooks"
+
+	backpressureRetryAfter = 5 * time.Millisecond
+)
+
+var ErrBackpressure = errors.New("engine is overloaded, slow down")
+
+// BackpressureLimits are soft limits on the engine's internal queues: the
+// most vote deltas any vote stream has waiting for acknowledgement, the
+// notifications held for disconnected users, and the share of any event
+// hook's buffer in use. Zero disables a limit.
+type BackpressureLimits struct {
+	VoteQueue         int
+	NotificationQueue int
+	HookFill          float64
+}
+
+var DefaultBackpressureLimits = BackpressureLimits{VoteQueue: 4096, NotificationQueue: 10000, HookFill: 0.8}
+
+// BackpressureError reports the first queue found over its soft limit.
+// Clients should wait RetryAfter before trying again. It matches
+// ErrBackpressure with errors.Is.
+type BackpressureError struct {
+	Queue      string
+	Depth      int
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (err *BackpressureError) Error() string {
+	return fmt.Sprintf("%v: %s queue at %d (limit %d)", ErrBackpressure, err.Queue, err.Depth, err.Limit)
+}
+
+func (err *BackpressureError) Unwrap() error {
+	return ErrBackpressure
+}
+
+// BackpressureActivation is a queue crossing its soft limit.
+type BackpressureActivation struct {
+	Time  time.Time
+	Queue string
+	Depth int
+}
+
+// SetBackpressureLimits replaces the soft limits Backpressure checks.
+func (e *Engine) SetBackpressureLimits(limits BackpressureLimits) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.BackpressureLimits = limits
+}
+
+// Backpressure returns a *BackpressureError while any queue is over its soft
+// limit and nil otherwise. Each time a queue goes over its limit, having been
+// under it at the previous check, is logged as an activation.
+func (e *Engine) Backpressure() error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	var first *BackpressureError
+	check := func(queue string, depth, limit int) {
+		over := limit > 0 && depth > limit
+		if over && !e.backpressured[queue] {
+			e.BackpressureActivations = append(e.BackpressureActivations, BackpressureActivation{Time: e.Clock.Now(), Queue: queue, Depth: depth})
+		}
+		e.backpressured[queue] = over
+		if over && first == nil {
+			first = &BackpressureError{Queue: queue, Depth: depth, Limit: limit, RetryAfter: backpressureRetryAfter}
+		}
+	}
+	limits := e.BackpressureLimits
+	check(QueueVotes, e.voteQueueDepth(), limits.VoteQueue)
+	check(QueueNotifications, e.notificationBacklog, limits.NotificationQueue)
+	depth, capacity := e.fullestHook()
+	check(QueueHooks, depth, int(limits.HookFill*float64(capacity)))
+	if first == nil {
+		return nil
+	}
+	e.BackpressureSignals++
+	return first
+}
+
+// voteQueueDepth is the most deltas any vote stream has pending. Callers must
+// hold e.Mutex.
+func (e *Engine) voteQueueDepth() int {
+	depth := 0
+	for _, stream := range e.voteStreams {
+		stream.mu.Lock()
+		depth = max(depth, len(stream.pending))
+		stream.mu.Unlock()
+	}
+	return depth
+}
+
+// fullestHook returns the queue length and capacity of the event hook whose
+// buffer is fullest. Callers must hold e.Mutex.
+func (e *Engine) fullestHook() (depth, capacity int) {
+	for _, hook := range e.hooks {
+		if cap(hook.events) > 0 && (capacity == 0 || len(hook.events)*capacity > depth*cap(hook.events)) {
+			depth, capacity = len(hook.events), cap(hook.events)
+		}
+	}
+	return depth, capacity
+}
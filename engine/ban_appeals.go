@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ban Appeals
+
+var (
+	ErrNotBanned       = errors.New("user is not banned from this subreddit")
+	ErrAlreadyAppealed = errors.New("ban has already been appealed")
+	ErrAppealDecided   = errors.New("appeal has already been decided")
+)
+
+// AppealStatus is where a ban appeal stands.
+type AppealStatus string
+
+const (
+	AppealPending  AppealStatus = "pending"
+	AppealAccepted AppealStatus = "accepted"
+	AppealDenied   AppealStatus = "denied"
+)
+
+// BanAppeal is a banned user's request to have the ban numbered BanID in
+// SubReddit lifted. BannedUntil is the ban's expiry, zero for a permanent
+// ban.
+// ModeratorID and DecidedAt are set once a moderator accepts or denies it,
+// and Reason says why a denial was given.
+type BanAppeal struct {
+	SubReddit   string
+	UserID      int64
+	Message     string
+	BanID       int64
+	BannedUntil time.Time
+	Status      AppealStatus
+	FiledAt     time.Time
+	DecidedAt   time.Time
+	ModeratorID int64
+	Reason      string
+}
+
+// AppealStats counts a subreddit's ban appeals by outcome. AcceptRate is
+// the share of decided appeals that were accepted, and MeanLatency the mean
+// time from filing to decision.
+type AppealStats struct {
+	Filed       int
+	Pending     int
+	Accepted    int
+	Denied      int
+	AcceptRate  float64
+	MeanLatency time.Duration
+}
+
+// FileBanAppeal appeals the user's ban from the subreddit with message. Each
+// ban may be appealed once; the appeal waits in the subreddit's appeal queue
+// and its moderators are notified.
+func (e *Engine) FileBanAppeal(user *User, subRedditName, message string) (*BanAppeal, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	if !e.isBanned(user, subReddit) {
+		return nil, ErrNotBanned
+	}
+	banID := subReddit.BanIDs[user.ID]
+	for _, appeal := range subReddit.Appeals {
+		if appeal.UserID == user.ID && appeal.BanID == banID {
+			return nil, ErrAlreadyAppealed
+		}
+	}
+	appeal := &BanAppeal{SubReddit: subReddit.Name, UserID: user.ID, Message: message, BanID: banID, BannedUntil: subReddit.Banned[user.ID], Status: AppealPending, FiledAt: e.Clock.Now()}
+	subReddit.Appeals = append(subReddit.Appeals, appeal)
+	for _, mod := range subReddit.Moderators {
+		e.notify(mod, "ban_appeal", fmt.Sprintf("%s appealed their ban from %s.", user.Username, subReddit.Name))
+	}
+	e.recordEvent("file_ban_appeal", user.ID, subReddit.Name, 0)
+	return appeal, nil
+}
+
+// GetAppealQueue returns the subreddit's pending ban appeals, oldest first.
+func (e *Engine) GetAppealQueue(mod *User, subRedditName string) ([]*BanAppeal, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if !e.isModerator(mod, subReddit) {
+		return nil, ErrNotModerator
+	}
+	var queue []*BanAppeal
+	for _, appeal := range subReddit.Appeals {
+		if appeal.Status == AppealPending {
+			queue = append(queue, appeal)
+		}
+	}
+	return queue, nil
+}
+
+// AcceptBanAppeal lifts the appealed ban, unless it has already expired or
+// been replaced by a newer one, and tells the user.
+func (e *Engine) AcceptBanAppeal(mod *User, appeal *BanAppeal) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, err := e.checkAppealDecision(mod, appeal)
+	if err != nil {
+		return err
+	}
+	if _, banned := subReddit.Banned[appeal.UserID]; banned && subReddit.BanIDs[appeal.UserID] == appeal.BanID {
+		e.unban(subReddit, appeal.UserID)
+	}
+	e.decideAppeal(subReddit, mod, appeal, AppealAccepted, "")
+	if user := e.Users[appeal.UserID]; user != nil {
+		e.notify(user, "ban_appeal", fmt.Sprintf("Your appeal was accepted and your ban from %s has been lifted.", subReddit.Name))
+	}
+	return nil
+}
+
+// DenyBanAppeal records the denial and why, leaving the ban in place, and
+// tells the user.
+func (e *Engine) DenyBanAppeal(mod *User, appeal *BanAppeal, reason string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, err := e.checkAppealDecision(mod, appeal)
+	if err != nil {
+		return err
+	}
+	e.decideAppeal(subReddit, mod, appeal, AppealDenied, reason)
+	if user := e.Users[appeal.UserID]; user != nil {
+		e.notify(user, "ban_appeal", fmt.Sprintf("Your appeal of your ban from %s was denied: %s", subReddit.Name, reason))
+	}
+	return nil
+}
+
+// checkAppealDecision checks that mod may decide appeal. Callers must hold
+// e.Mutex.
+func (e *Engine) checkAppealDecision(mod *User, appeal *BanAppeal) (*SubReddit, error) {
+	subReddit, exists := e.SubReddits[appeal.SubReddit]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if e.isSuspended(mod) {
+		return nil, ErrUserSuspended
+	}
+	if !e.isModerator(mod, subReddit) {
+		return nil, ErrNotModerator
+	}
+	if appeal.Status != AppealPending {
+		return nil, ErrAppealDecided
+	}
+	return subReddit, nil
+}
+
+// decideAppeal settles appeal and logs the decision. Callers must hold
+// e.Mutex.
+func (e *Engine) decideAppeal(subReddit *SubReddit, mod *User, appeal *BanAppeal, status AppealStatus, reason string) {
+	appeal.Status = status
+	appeal.DecidedAt = e.Clock.Now()
+	appeal.ModeratorID = mod.ID
+	appeal.Reason = reason
+	action := "accept_ban_appeal"
+	if status == AppealDenied {
+		action = "deny_ban_appeal"
+	}
+	e.recordModAction(subReddit, mod, action, appeal.UserID, 0)
+	e.recordEvent(action, mod.ID, subReddit.Name, appeal.UserID)
+}
+
+// GetAppealStats reports on the subreddit's ban appeals.
+func (e *Engine) GetAppealStats(subRedditName string) (AppealStats, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return AppealStats{}, ErrSubRedditNotFound
+	}
+	stats := AppealStats{Filed: len(subReddit.Appeals)}
+	var latency time.Duration
+	for _, appeal := range subReddit.Appeals {
+		switch appeal.Status {
+		case AppealPending:
+			stats.Pending++
+			continue
+		case AppealAccepted:
+			stats.Accepted++
+		case AppealDenied:
+			stats.Denied++
+		}
+		latency += appeal.DecidedAt.Sub(appeal.FiledAt)
+	}
+	if decided := stats.Accepted + stats.Denied; decided > 0 {
+		stats.AcceptRate = float64(stats.Accepted) / float64(decided)
+		stats.MeanLatency = latency / time.Duration(decided)
+	}
+	return stats, nil
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Chaos Mode
+
+// ChaosConfig sets how often each kind of fault is injected. Rates are
+// probabilities per opportunity.
+type ChaosConfig struct {
+	LockDelayRate  float64
+	MaxLockDelay   time.Duration
+	HookDropRate   float64
+	WorkerKillRate float64
+}
+
+// Chaos injects faults into engine internals. A nil *Chaos injects nothing.
+type Chaos struct {
+	ChaosConfig
+	// rng is the chaos mode's own generator: faults are drawn from whichever
+	// goroutine takes the lock, which would reorder the engine's draws.
+	rng           *rand.Rand
+	LockDelays    int64
+	DroppedHooks  int64
+	KilledWorkers int64
+}
+
+var errWorkerKilled = errors.New("chaos: worker killed")
+
+func (c *Chaos) maybeDelayLock() {
+	if c == nil || c.rng.Float64() >= c.LockDelayRate {
+		return
+	}
+	atomic.AddInt64(&c.LockDelays, 1)
+	time.Sleep(time.Duration(c.rng.Int63n(int64(c.MaxLockDelay) + 1)))
+}
+
+func (c *Chaos) shouldDropHook() bool {
+	if c == nil || c.rng.Float64() >= c.HookDropRate {
+		return false
+	}
+	atomic.AddInt64(&c.DroppedHooks, 1)
+	return true
+}
+
+// maybeKillWorker panics with errWorkerKilled; worker supervisors recover it
+// and restart the worker.
+func (c *Chaos) maybeKillWorker() {
+	if c == nil || c.rng.Float64() >= c.WorkerKillRate {
+		return
+	}
+	atomic.AddInt64(&c.KilledWorkers, 1)
+	panic(errWorkerKilled)
+}
+
+// EngineMutex is the engine's lock. It behaves as a sync.RWMutex but gives
+// chaos mode a place to delay acquisition and tracing a place to note when
+// the current writer acquired it and which span it acts for. Only writers
+// are traced, as only they record events.
+type EngineMutex struct {
+	sync.RWMutex
+	chaos    *Chaos
+	tracer   *Tracer
+	acquired time.Time
+	parent   SpanContext
+}
+
+func (m *EngineMutex) Lock() {
+	m.chaos.maybeDelayLock()
+	m.RWMutex.Lock()
+	if m.tracer != nil {
+		m.acquired, m.parent = time.Now(), SpanContext{}
+	}
+}
+
+// lockContext is Lock for a writer acting for the span in ctx, which the
+// spans of the events it records become children of.
+func (m *EngineMutex) lockContext(ctx context.Context) {
+	m.Lock()
+	if m.tracer != nil {
+		m.parent = SpanFromContext(ctx)
+	}
+}
+
+func (m *EngineMutex) RLock() {
+	m.chaos.maybeDelayLock()
+	m.RWMutex.RLock()
+}
+
+// EnableChaos turns on fault injection. It must be called before the engine
+// is shared between goroutines.
+func (e *Engine) EnableChaos(config ChaosConfig) *Chaos {
+	chaos := &Chaos{ChaosConfig: config, rng: NewRand(e.Rand.Int63())}
+	e.Mutex.chaos = chaos
+	return chaos
+}
+
+func (e *Engine) chaos() *Chaos {
+	return e.Mutex.chaos
+}
+
+var DefaultChaosConfig = ChaosConfig{
+	LockDelayRate:  0.01,
+	MaxLockDelay:   200 * time.Microsecond,
+	HookDropRate:   0.02,
+	WorkerKillRate: 0.01,
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock
+
+// Clock tells the engine the time. Tests and simulations use a SimClock.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// SimClock only moves when advanced, letting the simulator compress long
+// stretches of simulated time into a short run. Once Run, it also moves by
+// itself, faster than real time, between advances.
+type SimClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	rate   float64
+	anchor time.Time
+}
+
+// NewSimClock returns a SimClock stopped at start.
+func NewSimClock(start time.Time) *SimClock {
+	return &SimClock{now: start}
+}
+
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nowLocked()
+}
+
+// nowLocked is the clock's time, counting how far it has run since it last
+// changed. Callers must hold c.mu.
+func (c *SimClock) nowLocked() time.Time {
+	if c.rate == 0 {
+		return c.now
+	}
+	return c.now.Add(time.Duration(float64(time.Since(c.anchor)) * c.rate))
+}
+
+// Run makes the clock pass rate simulated seconds for every real second, so
+// 3600 runs an hour of simulated time each second, on top of whatever
+// Advance adds. A rate of 0 stops it again.
+func (c *SimClock) Run(rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.nowLocked()
+	c.anchor = time.Now()
+	c.rate = rate
+}
+
+// Rate is the rate the clock was last Run at, 0 if it only moves when
+// advanced.
+func (c *SimClock) Rate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// Advance moves the clock forward by d.
+func (c *SimClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.nowLocked().Add(d)
+	c.anchor = time.Now()
+	c.mu.Unlock()
+}
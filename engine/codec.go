@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Storage Codecs
+
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// Codec turns the documents Save and Sync write into bytes and back. Save,
+// Load, Sync and LoadFromStore all encode with e.Codec, so state written
+// with one codec has to be read back with the same one. JSONCodec is the
+// default; GobCodec and MsgpackCodec trade readability for smaller, faster
+// snapshots. Codecs needing generated code, such as protobuf, can be
+// plugged in with SetCodec.
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes with encoding/json. Its snapshots can be read and
+// diffed by hand.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// GobCodec encodes with encoding/gob. Every record carries its own type
+// description, so it pays off most on large documents such as whole
+// snapshots. Gob leaves out empty slices, so they come back nil.
+type GobCodec struct{}
+
+func (GobCodec) Name() string { return "gob" }
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Codecs returns the codecs the engine ships with, default first.
+func Codecs() []Codec {
+	return []Codec{JSONCodec{}, GobCodec{}, MsgpackCodec{}}
+}
+
+// CodecByName returns the shipped codec called name.
+func CodecByName(name string) (Codec, error) {
+	for _, codec := range Codecs() {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownCodec, name)
+}
+
+// SetCodec replaces the codec Save, Load, Sync and LoadFromStore encode
+// with.
+func (e *Engine) SetCodec(codec Codec) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.Codec = codec
+}
+
+// EncodeSnapshot encodes the snapshot Save would write with codec, holding
+// the engine's read lock while it does, since the snapshot shares the
+// engine's maps.
+func (e *Engine) EncodeSnapshot(codec Codec) ([]byte, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	saved, err := e.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return codec.Marshal(saved)
+}
+
+// DecodeSnapshot decodes a snapshot EncodeSnapshot encoded with codec,
+// without restoring it into an engine.
+func DecodeSnapshot(codec Codec, data []byte) error {
+	var decoded snapshot
+	return codec.Unmarshal(data, &decoded)
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newCodecBenchmarkSite returns a site with a few hundred posts, each with
+// a vote and a short comment thread, to encode.
+func newCodecBenchmarkSite(b *testing.B) *Engine {
+	b.Helper()
+	e, author, voter := newTestSite(b)
+	for i := 0; i < 300; i++ {
+		post, err := e.CreatePost(author, "news", fmt.Sprintf("Post number %d", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := e.UpvotePost(voter, post); err != nil {
+			b.Fatal(err)
+		}
+		comment, err := e.CommentPost(voter, post, fmt.Sprintf("Comment on post %d", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := e.AddReplyToComment(author, comment, fmt.Sprintf("Reply on post %d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return e
+}
+
+func BenchmarkCodecEncode(b *testing.B) {
+	e := newCodecBenchmarkSite(b)
+	saved, err := e.snapshot()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, codec := range Codecs() {
+		b.Run(codec.Name(), func(b *testing.B) {
+			var data []byte
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if data, err = codec.Marshal(saved); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(data)), "bytes/snapshot")
+		})
+	}
+}
+
+func BenchmarkCodecDecode(b *testing.B) {
+	e := newCodecBenchmarkSite(b)
+	for _, codec := range Codecs() {
+		b.Run(codec.Name(), func(b *testing.B) {
+			data, err := e.EncodeSnapshot(codec)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := DecodeSnapshot(codec, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
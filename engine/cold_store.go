@@ -0,0 +1,352 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cold Storage
+
+var ErrPostNotArchived = errors.New("post not in cold storage")
+
+type coldRecord struct {
+	offset    int64
+	length    int
+	subReddit string
+}
+
+// ColdStore keeps archived posts in an append-only file. Records are encoded
+// with a compact varint layout and read back through a memory mapping that is
+// created lazily on first access and refreshed when the file has grown.
+type ColdStore struct {
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	mapped   []byte
+	index    map[int64]coldRecord
+	scratch  []byte
+	Archived int
+}
+
+// OpenColdStore creates the archive file at path, truncating any existing
+// one.
+func OpenColdStore(path string) (*ColdStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &ColdStore{file: file, index: make(map[int64]coldRecord)}, nil
+}
+
+func (cs *ColdStore) Close() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.mapped != nil {
+		unmapFile(cs.mapped)
+		cs.mapped = nil
+	}
+	return cs.file.Close()
+}
+
+// put appends post's record. Putting an archived post again replaces its
+// record; the old one stays in the file, unreferenced.
+func (cs *ColdStore) put(post *Post, subRedditName string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	buf := encodeColdPost(cs.scratch[:0], post)
+	cs.scratch = buf
+	if _, err := cs.file.WriteAt(buf, cs.size); err != nil {
+		return err
+	}
+	if _, archived := cs.index[post.ID]; !archived {
+		cs.Archived++
+	}
+	cs.index[post.ID] = coldRecord{offset: cs.size, length: len(buf), subReddit: subRedditName}
+	cs.size += int64(len(buf))
+	return nil
+}
+
+func (cs *ColdStore) get(id int64, users map[int64]*User) (*Post, string, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	record, exists := cs.index[id]
+	if !exists {
+		return nil, "", ErrPostNotArchived
+	}
+	if int64(len(cs.mapped)) < record.offset+int64(record.length) {
+		if cs.mapped != nil {
+			unmapFile(cs.mapped)
+			cs.mapped = nil
+		}
+		mapped, err := mapFile(cs.file, cs.size)
+		if err != nil {
+			return nil, "", err
+		}
+		cs.mapped = mapped
+	}
+	data := cs.mapped[record.offset : record.offset+int64(record.length)]
+	post := decodeColdPost(data, users)
+	post.SubReddit = record.subReddit
+	setColdCommentOrigin(post.Comments, post.ID, record.subReddit)
+	return post, record.subReddit, nil
+}
+
+// ids returns the IDs of every archived post, in ascending order.
+func (cs *ColdStore) ids() []int64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	ids := make([]int64, 0, len(cs.index))
+	for id := range cs.index {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// EnableColdStorage opens a cold store at path for ArchiveOldPosts to move
+// posts into.
+func (e *Engine) EnableColdStorage(path string) error {
+	store, err := OpenColdStore(path)
+	if err != nil {
+		return err
+	}
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.ColdStore = store
+	return nil
+}
+
+// ArchiveOldPosts moves posts created more than age ago out of their
+// subreddits and into the cold store, returning how many were moved. Posts
+// awaiting approval or an unlock stay hot.
+func (e *Engine) ArchiveOldPosts(age time.Duration) (int, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.ColdStore == nil {
+		return 0, nil
+	}
+	cutoff := e.Clock.Now().Add(-age)
+	moved := 0
+	for name, subReddit := range e.SubReddits {
+		hot := subReddit.Posts[:0]
+		for _, post := range subReddit.Posts {
+			if post.CreatedAt.Before(cutoff) && !post.Pending && !post.Embargoed {
+				if err := e.ColdStore.put(post, name); err != nil {
+					return moved, err
+				}
+				e.unindexPost(post)
+				moved++
+				continue
+			}
+			hot = append(hot, post)
+		}
+		clear(subReddit.Posts[len(hot):])
+		subReddit.Posts = hot
+	}
+	return moved, nil
+}
+
+// reassignArchived rewrites the archived posts and comments duplicate wrote
+// as primary's, returning how many of each moved. Callers must hold
+// e.Mutex.
+func (e *Engine) reassignArchived(primary, duplicate *User) (posts, comments int, err error) {
+	if e.ColdStore == nil {
+		return 0, 0, nil
+	}
+	for _, id := range e.ColdStore.ids() {
+		post, subRedditName, err := e.ColdStore.get(id, e.Users)
+		if err != nil {
+			return posts, comments, err
+		}
+		authored := post.Author == duplicate
+		if authored {
+			post.Author = primary
+			posts++
+		}
+		moved := reassignColdComments(post.Comments, primary, duplicate)
+		comments += moved
+		if !authored && moved == 0 {
+			continue
+		}
+		if err := e.ColdStore.put(post, subRedditName); err != nil {
+			return posts, comments, err
+		}
+	}
+	return posts, comments, nil
+}
+
+// reassignColdComments moves authorship of duplicate's comments in an
+// archived tree to primary. Archived comments keep no votes or reactions
+// by user, so there is nothing else to merge.
+func reassignColdComments(comments []*Comment, primary, duplicate *User) int {
+	moved := 0
+	for _, comment := range comments {
+		if comment.Author == duplicate {
+			comment.Author = primary
+			moved++
+		}
+		moved += reassignColdComments(comment.Replies, primary, duplicate)
+	}
+	return moved
+}
+
+// GetArchivedPost lazily loads a post from cold storage.
+func (e *Engine) GetArchivedPost(id int64) (*Post, string, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	if e.ColdStore == nil {
+		return nil, "", ErrPostNotArchived
+	}
+	return e.ColdStore.get(id, e.Users)
+}
+
+func encodeColdPost(buf []byte, post *Post) []byte {
+	buf = binary.AppendUvarint(buf, uint64(post.ID))
+	buf = binary.AppendUvarint(buf, uint64(post.Author.ID))
+	buf = binary.AppendVarint(buf, int64(post.Votes))
+	buf = binary.AppendUvarint(buf, math.Float64bits(post.WeightedVotes))
+	buf = binary.AppendVarint(buf, post.CreatedAt.UnixNano())
+	buf = appendColdString(buf, post.Content)
+	buf = appendColdString(buf, post.URL)
+	buf = appendColdAttachments(buf, post.Attachments)
+	return appendColdComments(buf, post.Comments)
+}
+
+func appendColdComments(buf []byte, comments []*Comment) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(comments)))
+	for _, comment := range comments {
+		buf = binary.AppendUvarint(buf, uint64(comment.ID))
+		buf = binary.AppendUvarint(buf, uint64(comment.Author.ID))
+		buf = binary.AppendVarint(buf, int64(comment.Votes))
+		buf = binary.AppendVarint(buf, comment.CreatedAt.UnixNano())
+		buf = binary.AppendVarint(buf, coldTime(comment.EditedAt))
+		buf = appendColdString(buf, comment.Content())
+		buf = appendColdReactions(buf, comment.Reactions)
+		buf = appendColdComments(buf, comment.Replies)
+	}
+	return buf
+}
+
+// appendColdReactions writes tallies sorted by emoji so encoding is stable.
+func appendColdReactions(buf []byte, reactions map[string]int) []byte {
+	emojis := make([]string, 0, len(reactions))
+	for emoji := range reactions {
+		emojis = append(emojis, emoji)
+	}
+	sort.Strings(emojis)
+	buf = binary.AppendUvarint(buf, uint64(len(emojis)))
+	for _, emoji := range emojis {
+		buf = appendColdString(buf, emoji)
+		buf = binary.AppendUvarint(buf, uint64(reactions[emoji]))
+	}
+	return buf
+}
+
+func decodeColdReactions(r *coldReader) map[string]int {
+	count := int(r.uvarint())
+	if count == 0 {
+		return nil
+	}
+	reactions := make(map[string]int, count)
+	for i := 0; i < count; i++ {
+		emoji := r.string()
+		reactions[emoji] = int(r.uvarint())
+	}
+	return reactions
+}
+
+// coldTime encodes the zero time as 0 rather than its (negative) UnixNano.
+func coldTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func appendColdString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+type coldReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *coldReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *coldReader) varint() int64 {
+	v, n := binary.Varint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *coldReader) string() string {
+	n := int(r.uvarint())
+	s := string(r.data[r.pos : r.pos+n])
+	r.pos += n
+	return s
+}
+
+func decodeColdPost(data []byte, users map[int64]*User) *Post {
+	r := &coldReader{data: data}
+	post := &Post{}
+	post.ID = int64(r.uvarint())
+	post.Author = coldAuthor(users, int64(r.uvarint()))
+	post.Votes = int(r.varint())
+	post.WeightedVotes = math.Float64frombits(r.uvarint())
+	post.CreatedAt = time.Unix(0, r.varint())
+	post.Content = r.string()
+	post.URL = r.string()
+	post.Attachments = decodeColdAttachments(r)
+	post.Comments = decodeColdComments(r, users)
+	return post
+}
+
+// coldAuthor resolves an archived author ID, following account merges to
+// the account that owns the content now.
+func coldAuthor(users map[int64]*User, id int64) *User {
+	author := users[id]
+	for author != nil && author.MergedInto != 0 {
+		author = users[author.MergedInto]
+	}
+	return author
+}
+
+func decodeColdComments(r *coldReader, users map[int64]*User) []*Comment {
+	count := int(r.uvarint())
+	comments := make([]*Comment, 0, count)
+	for i := 0; i < count; i++ {
+		comment := &Comment{}
+		comment.ID = int64(r.uvarint())
+		comment.Author = coldAuthor(users, int64(r.uvarint()))
+		comment.Votes = int(r.varint())
+		comment.CreatedAt = time.Unix(0, r.varint())
+		if editedAt := r.varint(); editedAt != 0 {
+			comment.Edited = true
+			comment.EditedAt = time.Unix(0, editedAt)
+		}
+		comment.body = r.string()
+		comment.Reactions = decodeColdReactions(r)
+		comment.Replies = decodeColdComments(r, users)
+		comments = append(comments, comment)
+	}
+	return comments
+}
+
+func setColdCommentOrigin(comments []*Comment, postID int64, subRedditName string) {
+	for _, comment := range comments {
+		comment.PostID = postID
+		comment.SubReddit = subRedditName
+		setColdCommentOrigin(comment.Replies, postID, subRedditName)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package engine
+
+import (
+	"io"
+	"os"
+)
+
+// Without mmap support the cold store falls back to reading the file into
+// memory on access.
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(io.NewSectionReader(file, 0, size), data)
+	return data, err
+}
+
+func unmapFile(data []byte) {}
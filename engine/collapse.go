@@ -0,0 +1,112 @@
+package engine
+
+// Comment Auto-Collapse
+
+// defaultCollapseThreshold collapses a reply chain once its root's score
+// drops below -3.
+const defaultCollapseThreshold = -3
+
+// CollapseStats describes the collapsed reply chains on hot posts. Chains
+// counts the topmost collapsed comments and Comments every comment hidden
+// under them, roots included. Collapses and Uncollapses count every time a
+// comment's score crossed the threshold.
+type CollapseStats struct {
+	Threshold   int
+	Chains      int
+	Comments    int
+	Collapses   int
+	Uncollapses int
+	BySubReddit map[string]int
+}
+
+// SetCollapseThreshold collapses every reply chain whose root has a score
+// below threshold, rechecking every comment on hot posts.
+func (e *Engine) SetCollapseThreshold(threshold int) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.setCollapseThreshold(threshold)
+}
+
+// setCollapseThreshold is SetCollapseThreshold. Callers must hold e.Mutex.
+func (e *Engine) setCollapseThreshold(threshold int) {
+	e.CollapseThreshold = threshold
+	var recheck func(comments []*Comment, collapsed bool)
+	recheck = func(comments []*Comment, collapsed bool) {
+		for _, comment := range comments {
+			if comment.Votes < threshold {
+				e.CollapseRoots[comment.ID] = true
+			} else {
+				delete(e.CollapseRoots, comment.ID)
+			}
+			comment.Collapsed = collapsed || e.CollapseRoots[comment.ID]
+			recheck(comment.Replies, comment.Collapsed)
+		}
+	}
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			recheck(post.Comments, false)
+		}
+	}
+}
+
+// GetCollapseStats reports on collapsed reply chains.
+func (e *Engine) GetCollapseStats() CollapseStats {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	stats := CollapseStats{Threshold: e.CollapseThreshold, Collapses: e.CommentCollapses, Uncollapses: e.CommentUncollapses, BySubReddit: make(map[string]int)}
+	var count func(comments []*Comment, collapsed bool)
+	count = func(comments []*Comment, collapsed bool) {
+		for _, comment := range comments {
+			if comment.Collapsed {
+				if !collapsed {
+					stats.Chains++
+				}
+				stats.Comments++
+				stats.BySubReddit[comment.SubReddit]++
+			}
+			count(comment.Replies, comment.Collapsed)
+		}
+	}
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			count(post.Comments, false)
+		}
+	}
+	return stats
+}
+
+// refreshCollapse rechecks comment against the threshold after its score
+// changed or it was created, and updates Collapsed on its whole reply chain.
+// Callers must hold e.Mutex.
+func (e *Engine) refreshCollapse(comment *Comment) {
+	if below := comment.Votes < e.CollapseThreshold; below != e.CollapseRoots[comment.ID] {
+		if below {
+			e.CollapseRoots[comment.ID] = true
+			e.CommentCollapses++
+		} else {
+			delete(e.CollapseRoots, comment.ID)
+			e.CommentUncollapses++
+		}
+	}
+	e.markCollapsed(comment, e.ancestorCollapsed(comment.ID))
+}
+
+// ancestorCollapsed reports whether any comment above id roots a collapsed
+// chain. Callers must hold e.Mutex.
+func (e *Engine) ancestorCollapsed(id int64) bool {
+	for id = e.CommentParents[id]; id != 0; id = e.CommentParents[id] {
+		if e.CollapseRoots[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// markCollapsed sets Collapsed on comment and its replies, given whether a
+// comment above it is collapsed. Callers must hold e.Mutex.
+func (e *Engine) markCollapsed(comment *Comment, ancestor bool) {
+	comment.Collapsed = ancestor || e.CollapseRoots[comment.ID]
+	for _, reply := range comment.Replies {
+		e.markCollapsed(reply, comment.Collapsed)
+	}
+}
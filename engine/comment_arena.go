@@ -0,0 +1,51 @@
+package engine
+
+import "unsafe"
+
+// Comment Arena
+
+// commentSlabSize is how many bytes of comment text each slab holds. A body
+// longer than that gets a slab of its own.
+const commentSlabSize = 1 << 20
+
+// CommentArena packs comment bodies back to back into large byte slabs
+// instead of giving each comment its own string allocation. With tens of
+// millions of short comments this roughly halves the memory their text
+// takes, as no body is rounded up to an allocator size class, and leaves
+// the garbage collector a few slabs to track instead of one object per
+// comment. Slabs are append-only, so the strings handed out can alias them:
+// bytes are never written again once stored. Editing a comment appends the
+// new text; the old text stays behind and is counted in Wasted.
+type CommentArena struct {
+	slab   []byte
+	Slabs  int
+	Bytes  int64
+	Wasted int64
+}
+
+// store copies content into the arena and returns a string backed by it.
+func (a *CommentArena) store(content string) string {
+	if content == "" {
+		return ""
+	}
+	if len(content) > cap(a.slab)-len(a.slab) {
+		a.slab = make([]byte, 0, max(commentSlabSize, len(content)))
+		a.Slabs++
+	}
+	start := len(a.slab)
+	a.slab = append(a.slab, content...)
+	a.Bytes += int64(len(content))
+	return unsafe.String(&a.slab[start], len(content))
+}
+
+// Content returns the comment's text.
+func (c *Comment) Content() string {
+	return c.body
+}
+
+// setCommentContent stores content as comment's text in the engine's
+// arena. Callers must hold e.Mutex.
+func (e *Engine) setCommentContent(comment *Comment, content string) {
+	e.CommentArena.Wasted += int64(len(comment.body))
+	comment.body = e.CommentArena.store(content)
+}
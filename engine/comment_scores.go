@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"sort"
+)
+
+// Comment Vote Aggregation
+
+// CommentParents maps a comment ID to its parent comment ID (0 for top-level
+// comments) and BranchScores maps a comment ID to the sum of votes on that
+// comment and all of its descendants. Both are keyed by ID so they stay
+// correct regardless of which copy of a Comment a caller holds. CommentVotes
+// maps a comment ID to each voter's standing vote on it.
+
+// UpvoteComment sets voter's vote on the comment to an upvote, switching a
+// downvote if they had one, and credits the author's comment karma. It
+// returns ErrAlreadyVoted if they had already upvoted.
+func (e *Engine) UpvoteComment(voter *User, comment *Comment) error {
+	return e.UpvoteCommentContext(context.Background(), voter, comment)
+}
+
+// UpvoteCommentContext is UpvoteComment, traced as a child of the span in ctx.
+func (e *Engine) UpvoteCommentContext(ctx context.Context, voter *User, comment *Comment) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	return e.setCommentVote(voter, comment, 1)
+}
+
+// DownvoteComment sets voter's vote on the comment to a downvote, switching
+// an upvote if they had one. It returns ErrAlreadyVoted if they had already
+// downvoted.
+func (e *Engine) DownvoteComment(voter *User, comment *Comment) error {
+	return e.DownvoteCommentContext(context.Background(), voter, comment)
+}
+
+// DownvoteCommentContext is DownvoteComment, traced as a child of the span in ctx.
+func (e *Engine) DownvoteCommentContext(ctx context.Context, voter *User, comment *Comment) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	return e.setCommentVote(voter, comment, -1)
+}
+
+// UnvoteComment withdraws voter's vote on the comment. It returns
+// ErrNotVoted if they hadn't voted.
+func (e *Engine) UnvoteComment(voter *User, comment *Comment) error {
+	return e.UnvoteCommentContext(context.Background(), voter, comment)
+}
+
+// UnvoteCommentContext is UnvoteComment, traced as a child of the span in ctx.
+func (e *Engine) UnvoteCommentContext(ctx context.Context, voter *User, comment *Comment) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	return e.setCommentVote(voter, comment, 0)
+}
+
+// GetCommentVote returns voter's current vote on the comment: 1, -1, or 0
+// if they haven't voted.
+func (e *Engine) GetCommentVote(voter *User, comment *Comment) int {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.CommentVotes[comment.ID][voter.ID]
+}
+
+// setCommentVote moves voter's vote on the comment to direction, where 0
+// withdraws it. Callers must hold e.Mutex.
+func (e *Engine) setCommentVote(voter *User, comment *Comment, direction int) error {
+	if e.isSuspended(voter) {
+		return ErrUserSuspended
+	}
+	current := e.CommentVotes[comment.ID][voter.ID]
+	if current == direction {
+		if direction == 0 {
+			return ErrNotVoted
+		}
+		return ErrAlreadyVoted
+	}
+	e.shiftCommentScore(comment, direction-current)
+	voter.Actions++
+	e.TotalActions++
+	if direction == 0 {
+		delete(e.CommentVotes[comment.ID], voter.ID)
+		e.recordEvent("comment_unvote", voter.ID, comment.SubReddit, comment.ID)
+		return nil
+	}
+	if e.CommentVotes[comment.ID] == nil {
+		e.CommentVotes[comment.ID] = make(map[int64]int)
+	}
+	e.CommentVotes[comment.ID][voter.ID] = direction
+	e.TotalVotes++
+	e.ActionBreakdown["Votes"]++
+	eventType := "comment_upvote"
+	if direction < 0 {
+		eventType = "comment_downvote"
+	}
+	e.recordEvent(eventType, voter.ID, comment.SubReddit, comment.ID)
+	e.recordContribution(comment.SubReddit, voter, contributionVote)
+	return nil
+}
+
+// shiftCommentScore moves the comment's score by delta along with its
+// branch scores, collapse state and author's comment karma. Callers must
+// hold e.Mutex.
+func (e *Engine) shiftCommentScore(comment *Comment, delta int) {
+	comment.Votes += delta
+	e.applyCommentVote(comment.ID, delta)
+	e.refreshCollapse(comment)
+	e.creditCommentKarma(comment, delta)
+	e.bumpShared(CommentVotesKey(comment.ID), int64(delta))
+	e.publishVote("comment", comment.SubReddit, comment.ID, delta)
+}
+
+// mergeCommentVotes moves duplicate's vote on the comment to primary. If
+// both accounts voted, duplicate's vote is taken back so the merged account
+// counts once. Callers must hold e.Mutex.
+func (e *Engine) mergeCommentVotes(comment *Comment, primary, duplicate *User) {
+	votes := e.CommentVotes[comment.ID]
+	direction, voted := votes[duplicate.ID]
+	if !voted {
+		return
+	}
+	delete(votes, duplicate.ID)
+	if _, both := votes[primary.ID]; both {
+		e.shiftCommentScore(comment, -direction)
+		return
+	}
+	votes[primary.ID] = direction
+}
+
+// applyCommentVote propagates a vote delta from a comment up to the root of
+// its thread. Callers must hold e.Mutex.
+func (e *Engine) applyCommentVote(commentID int64, delta int) {
+	for id := commentID; id != 0; id = e.CommentParents[id] {
+		e.BranchScores[id] += delta
+	}
+}
+
+// BranchScore is the total score of the comment and every reply below it.
+func (e *Engine) BranchScore(comment *Comment) int {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.BranchScores[comment.ID]
+}
+
+// SortCommentsByBranchActivity returns the comments ordered by branch score,
+// highest first, without walking their reply trees.
+func (e *Engine) SortCommentsByBranchActivity(comments []*Comment) []*Comment {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	sorted := make([]*Comment, len(comments))
+	copy(sorted, comments)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return e.BranchScores[sorted[i].ID] > e.BranchScores[sorted[j].ID]
+	})
+	return sorted
+}
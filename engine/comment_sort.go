@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+)
+
+// Comment Sorting
+
+// CommentSort is the order a post's comments are shown in.
+type CommentSort int
+
+const (
+	CommentSortTop CommentSort = iota
+	CommentSortNew
+	CommentSortBranch
+	CommentSortQA
+)
+
+var ErrInvalidCommentSort = errors.New("invalid comment sort")
+
+// SetCommentSort selects how a post's comments are ordered. Only the post's
+// author or a moderator of its subreddit may change it.
+func (e *Engine) SetCommentSort(user *User, post *Post, mode CommentSort) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if mode < CommentSortTop || mode > CommentSortQA {
+		return ErrInvalidCommentSort
+	}
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if post.Author != user && !e.isModerator(user, subReddit) {
+		return ErrNotModerator
+	}
+	post.CommentSort = mode
+	e.CommentSorts[post.ID] = mode
+	e.recordEvent("set_comment_sort", user.ID, post.SubReddit, post.ID)
+	return nil
+}
+
+// GetSortedComments returns a copy of the post's comment tree ordered by the
+// post's selected sort at every level, with any stickied comment first.
+func (e *Engine) GetSortedComments(post *Post) []*Comment {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.sortPostComments(post)
+}
+
+// sortComments deep-copies and orders a comment forest. Callers must hold
+// e.Mutex.
+func (e *Engine) sortComments(comments []*Comment, op *User, mode CommentSort) []*Comment {
+	nodes := make([]Comment, len(comments))
+	sorted := make([]*Comment, len(comments))
+	for i, comment := range comments {
+		nodes[i] = *comment
+		nodes[i].Replies = e.sortComments(comment.Replies, op, mode)
+		nodes[i].Reactions = copyCounts(comment.Reactions)
+		sorted[i] = &nodes[i]
+	}
+	var less func(a, b *Comment) bool
+	switch mode {
+	case CommentSortNew:
+		less = func(a, b *Comment) bool { return a.CreatedAt.After(b.CreatedAt) }
+	case CommentSortBranch:
+		less = func(a, b *Comment) bool { return e.BranchScores[a.ID] > e.BranchScores[b.ID] }
+	case CommentSortQA:
+		less = func(a, b *Comment) bool {
+			if ra, rb := qaRank(a, op), qaRank(b, op); ra != rb {
+				return ra < rb
+			}
+			return a.Votes > b.Votes
+		}
+	default:
+		less = func(a, b *Comment) bool { return a.Votes > b.Votes }
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// qaRank puts comments the OP answered first, then the OP's own comments,
+// then everything else.
+func qaRank(comment *Comment, op *User) int {
+	for _, reply := range comment.Replies {
+		if reply.Author == op {
+			return 0
+		}
+	}
+	if comment.Author == op {
+		return 1
+	}
+	return 2
+}
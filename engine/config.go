@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"time"
+)
+
+// Runtime Configuration
+
+var ErrInvalidConfig = errors.New("invalid engine configuration")
+
+// Config is the engine's runtime-tunable configuration: rate and quota
+// limits, karma policy, ranking constants and discovery slots. Durations are nanoseconds in
+// JSON, as elsewhere in the engine's files.
+type Config struct {
+	Backpressure      BackpressureLimits
+	Quota             TenantQuota
+	EditGracePeriod   time.Duration
+	DuplicateWindow   time.Duration
+	VoteDecay         VoteDecay
+	CollapseThreshold int
+	Ranking           RankingConstants
+	Discovery         DiscoveryConfig
+}
+
+// ConfigError is returned for a configuration ApplyConfig refuses. It
+// matches ErrInvalidConfig with errors.Is.
+type ConfigError struct {
+	Setting string
+	Reason  string
+}
+
+func (err *ConfigError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidConfig, err.Setting, err.Reason)
+}
+
+func (err *ConfigError) Unwrap() error {
+	return ErrInvalidConfig
+}
+
+// ConfigChange is one setting ApplyConfig changed, with its old and new
+// values as they appear in the audit log.
+type ConfigChange struct {
+	Setting string
+	Old     string
+	New     string
+}
+
+// GetConfig returns the engine's current configuration.
+func (e *Engine) GetConfig() Config {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.config()
+}
+
+// config snapshots the configuration. Callers must hold e.Mutex.
+func (e *Engine) config() Config {
+	return Config{
+		Backpressure:      e.BackpressureLimits,
+		Quota:             e.Quota,
+		EditGracePeriod:   e.EditGracePeriod,
+		DuplicateWindow:   e.DuplicateWindow,
+		VoteDecay:         e.VoteDecay,
+		CollapseThreshold: e.CollapseThreshold,
+		Ranking:           e.Ranking,
+		Discovery:         e.Discovery,
+	}
+}
+
+// ApplyConfig switches the engine to config in one step under the engine
+// lock, so no action sees some settings old and others new, and returns the
+// settings that changed. Each change is written to the audit log under
+// admin, who must be a site admin; admin is nil for changes by the system,
+// such as reloads of a config file.
+func (e *Engine) ApplyConfig(admin *User, config Config) ([]ConfigChange, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if admin != nil && !admin.IsAdmin {
+		return nil, ErrNotAdmin
+	}
+	return e.applyConfig(admin, config)
+}
+
+// PatchConfig is ApplyConfig for a JSON configuration read from patch on top
+// of the current one, so settings it leaves out keep their values. Reading
+// and applying happen under one lock, so concurrent changes aren't lost.
+func (e *Engine) PatchConfig(admin *User, patch io.Reader) ([]ConfigChange, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if admin != nil && !admin.IsAdmin {
+		return nil, ErrNotAdmin
+	}
+	decoder := json.NewDecoder(patch)
+	decoder.DisallowUnknownFields()
+	config := e.config()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return e.applyConfig(admin, config)
+}
+
+// ReloadConfig patches the configuration with the JSON file at path, as the
+// system.
+func (e *Engine) ReloadConfig(path string) ([]ConfigChange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	changes, err := e.PatchConfig(nil, file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return changes, nil
+}
+
+// applyConfig is ApplyConfig for an already authorized admin. Callers must
+// hold e.Mutex.
+func (e *Engine) applyConfig(admin *User, config Config) ([]ConfigChange, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	changes := diffConfig(e.config(), config)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	e.BackpressureLimits = config.Backpressure
+	e.Quota = config.Quota
+	e.EditGracePeriod = config.EditGracePeriod
+	e.DuplicateWindow = config.DuplicateWindow
+	e.VoteDecay = config.VoteDecay
+	if config.CollapseThreshold != e.CollapseThreshold {
+		e.setCollapseThreshold(config.CollapseThreshold)
+	}
+	e.Ranking = config.Ranking
+	e.rankingPool.setRanking(config.Ranking)
+	e.Discovery = config.Discovery
+	e.ConfigReloads++
+	actorID := int64(0)
+	if admin != nil {
+		actorID = admin.ID
+	}
+	for _, change := range changes {
+		e.recordAudit(actorID, "config_change", 0, fmt.Sprintf("%s: %s -> %s", change.Setting, change.Old, change.New))
+	}
+	return changes, nil
+}
+
+// validate rejects settings the engine can't run with.
+func (c Config) validate() error {
+	switch {
+	case c.Backpressure.VoteQueue < 0:
+		return &ConfigError{Setting: "Backpressure.VoteQueue", Reason: "is negative"}
+	case c.Backpressure.NotificationQueue < 0:
+		return &ConfigError{Setting: "Backpressure.NotificationQueue", Reason: "is negative"}
+	case c.Backpressure.HookFill < 0 || c.Backpressure.HookFill > 1:
+		return &ConfigError{Setting: "Backpressure.HookFill", Reason: "is outside [0, 1]"}
+	case c.Quota.MaxUsers < 0 || c.Quota.MaxSubReddits < 0 || c.Quota.MaxPosts < 0:
+		return &ConfigError{Setting: "Quota", Reason: "has a negative limit"}
+	case c.EditGracePeriod < 0:
+		return &ConfigError{Setting: "EditGracePeriod", Reason: "is negative"}
+	case c.DuplicateWindow < 0:
+		return &ConfigError{Setting: "DuplicateWindow", Reason: "is negative"}
+	case c.VoteDecay.ZeroWeightAge > 0 && c.VoteDecay.ZeroWeightAge <= c.VoteDecay.FullWeightAge:
+		return &ConfigError{Setting: "VoteDecay.ZeroWeightAge", Reason: "is not after FullWeightAge"}
+	case c.Ranking.HotDecay <= 0:
+		return &ConfigError{Setting: "Ranking.HotDecay", Reason: "must be positive"}
+	case c.Ranking.PersonalizationWeight < 0:
+		return &ConfigError{Setting: "Ranking.PersonalizationWeight", Reason: "is negative"}
+	case c.Discovery.Share < 0 || c.Discovery.Share > 1:
+		return &ConfigError{Setting: "Discovery.Share", Reason: "is outside [0, 1]"}
+	case c.Discovery.PerSubReddit < 1:
+		return &ConfigError{Setting: "Discovery.PerSubReddit", Reason: "must be at least 1"}
+	}
+	return nil
+}
+
+// diffConfig lists the leaf settings that differ between before and after,
+// named by their path in Config, in field order.
+func diffConfig(before, after Config) []ConfigChange {
+	var changes []ConfigChange
+	var walk func(path string, a, b reflect.Value)
+	walk = func(path string, a, b reflect.Value) {
+		if a.Kind() == reflect.Struct {
+			for i := 0; i < a.NumField(); i++ {
+				name := a.Type().Field(i).Name
+				if path != "" {
+					name = path + "." + name
+				}
+				walk(name, a.Field(i), b.Field(i))
+			}
+			return
+		}
+		if a.Interface() != b.Interface() {
+			changes = append(changes, ConfigChange{Setting: path, Old: fmt.Sprint(a.Interface()), New: fmt.Sprint(b.Interface())})
+		}
+	}
+	walk("", reflect.ValueOf(before), reflect.ValueOf(after))
+	return changes
+}
@@ -0,0 +1,64 @@
+package engine
+
+// Post and Comment Lookup
+
+// GetPost returns the hot post with the given ID. Archived posts are read
+// back with GetArchivedPost.
+func (e *Engine) GetPost(id int64) (*Post, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	if post := e.postIndex[id]; post != nil {
+		return post, nil
+	}
+	return nil, ErrPostNotFound
+}
+
+// GetComment returns the comment with the given ID on a hot post.
+func (e *Engine) GetComment(id int64) (*Comment, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	if comment := e.commentIndex[id]; comment != nil {
+		return comment, nil
+	}
+	return nil, ErrCommentNotFound
+}
+
+// indexPost makes post and every comment on it findable by ID. Callers must
+// hold e.Mutex.
+func (e *Engine) indexPost(post *Post) {
+	e.postIndex[post.ID] = post
+	var index func(comments []*Comment)
+	index = func(comments []*Comment) {
+		for _, comment := range comments {
+			e.commentIndex[comment.ID] = comment
+			index(comment.Replies)
+		}
+	}
+	index(post.Comments)
+}
+
+// unindexPost forgets post and its comments once it leaves the hot set.
+// Callers must hold e.Mutex.
+func (e *Engine) unindexPost(post *Post) {
+	delete(e.postIndex, post.ID)
+	var unindex func(comments []*Comment)
+	unindex = func(comments []*Comment) {
+		for _, comment := range comments {
+			delete(e.commentIndex, comment.ID)
+			unindex(comment.Replies)
+		}
+	}
+	unindex(post.Comments)
+}
+
+// reindexContent rebuilds the lookup indices from the subreddits, after
+// their posts have been replaced wholesale. Callers must hold e.Mutex.
+func (e *Engine) reindexContent() {
+	e.postIndex = make(map[int64]*Post)
+	e.commentIndex = make(map[int64]*Comment)
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			e.indexPost(post)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package engine
+
+import "time"
+
+// Unique Contributors
+
+// Contribution kinds tracked per subreddit and day.
+const (
+	contributionPost = iota
+	contributionComment
+	contributionVote
+)
+
+// EngagementStats counts the distinct users who took part in a subreddit
+// over a window. Contributors posted, commented or voted; Lurkers are
+// members who did none of those. ContributorRatio is Contributors over
+// Members, so drive-by contributors can push it above 1.
+type EngagementStats struct {
+	SubReddit        string
+	Window           time.Duration
+	Members          int
+	Posters          int
+	Commenters       int
+	Voters           int
+	Contributors     int
+	Lurkers          int
+	ContributorRatio float64
+}
+
+// recordContribution counts user as a poster, commenter or voter in the
+// named subreddit today. Callers must hold e.Mutex.
+func (e *Engine) recordContribution(subRedditName string, user *User, kind int) {
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return
+	}
+	day := e.trafficToday(subReddit)
+	if day.contributors[kind][user.ID] {
+		return
+	}
+	day.contributors[kind][user.ID] = true
+	switch kind {
+	case contributionPost:
+		day.Posters++
+	case contributionComment:
+		day.Commenters++
+	case contributionVote:
+		day.Voters++
+	}
+}
+
+// GetEngagementStats returns the distinct posters, commenters and voters in
+// the subreddit over the simulated days that overlap the window ending now.
+// A window of 0 covers the subreddit's whole history.
+func (e *Engine) GetEngagementStats(subRedditName string, window time.Duration) (EngagementStats, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return EngagementStats{}, ErrSubRedditNotFound
+	}
+	stats := EngagementStats{SubReddit: subRedditName, Window: window, Members: len(subReddit.Users)}
+	since := ""
+	if window > 0 {
+		since = e.Clock.Now().Add(-window).Format(trafficDateLayout)
+	}
+	var kinds [3]map[int64]bool
+	for kind := range kinds {
+		kinds[kind] = make(map[int64]bool)
+	}
+	contributors := make(map[int64]bool)
+	subReddit.mu.Lock()
+	defer subReddit.mu.Unlock()
+	for date, day := range subReddit.Traffic {
+		if date < since {
+			continue
+		}
+		for kind, users := range day.contributors {
+			for id := range users {
+				kinds[kind][id] = true
+				contributors[id] = true
+			}
+		}
+	}
+	stats.Posters = len(kinds[contributionPost])
+	stats.Commenters = len(kinds[contributionComment])
+	stats.Voters = len(kinds[contributionVote])
+	stats.Contributors = len(contributors)
+	for id := range subReddit.Users {
+		if !contributors[id] {
+			stats.Lurkers++
+		}
+	}
+	if stats.Members > 0 {
+		stats.ContributorRatio = float64(stats.Contributors) / float64(stats.Members)
+	}
+	return stats, nil
+}
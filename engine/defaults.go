@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Default SubReddits
+
+// SetDefaultSubReddits replaces the set of subreddits new users are
+// subscribed to when they register. Users who registered earlier keep their
+// subscriptions.
+func (e *Engine) SetDefaultSubReddits(admin *User, names ...string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if !admin.IsAdmin {
+		return ErrNotAdmin
+	}
+	if err := e.setDefaultSubReddits(names); err != nil {
+		return err
+	}
+	e.recordAudit(admin.ID, "set_default_subreddits", 0, strings.Join(e.DefaultSubReddits, "+"))
+	return nil
+}
+
+// setDefaultSubReddits validates and stores the default set, sorted and
+// without duplicates. Callers must hold e.Mutex.
+func (e *Engine) setDefaultSubReddits(names []string) error {
+	defaults := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, exists := e.SubReddits[name]; !exists {
+			return ErrSubRedditNotFound
+		}
+		if !seen[name] {
+			seen[name] = true
+			defaults = append(defaults, name)
+		}
+	}
+	sort.Strings(defaults)
+	e.DefaultSubReddits = defaults
+	return nil
+}
+
+// GetDefaultSubReddits returns the default set, sorted.
+func (e *Engine) GetDefaultSubReddits() []string {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return append([]string(nil), e.DefaultSubReddits...)
+}
+
+// joinDefaults subscribes a newly registered user to every default
+// subreddit. These memberships are marked so they can be told apart from
+// organic joins until the user joins or leaves the subreddit themselves.
+// Callers must hold e.Mutex.
+func (e *Engine) joinDefaults(user *User) {
+	for _, name := range e.DefaultSubReddits {
+		subReddit, exists := e.SubReddits[name]
+		if !exists || subReddit.Settings.NSFW && minor(user) {
+			continue
+		}
+		if _, member := subReddit.Users[user.ID]; member {
+			continue
+		}
+		e.trafficToday(subReddit).Subscriptions++
+		subReddit.Users[user.ID] = user
+		subReddit.DefaultMembers[user.ID] = true
+		e.DefaultSubscriptions++
+		e.recordEvent("default_join", user.ID, name, 0)
+		e.checkBigCommunity(subReddit)
+	}
+}
+
+// OptOutOfDefaults unsubscribes the user from the default subreddits they
+// were subscribed to at registration and haven't joined themselves since,
+// returning how many they left.
+func (e *Engine) OptOutOfDefaults(user *User) int {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	left := 0
+	for name, subReddit := range e.SubReddits {
+		if !subReddit.DefaultMembers[user.ID] {
+			continue
+		}
+		delete(subReddit.DefaultMembers, user.ID)
+		delete(subReddit.Users, user.ID)
+		e.trafficToday(subReddit).Unsubscriptions++
+		e.recordEvent("default_opt_out", user.ID, name, 0)
+		left++
+	}
+	if left > 0 {
+		e.DefaultOptOuts++
+	}
+	return left
+}
+
+// MembershipSkew describes how concentrated memberships are in a few
+// subreddits.
+type MembershipSkew struct {
+	// OrganicGini and TotalGini are the Gini coefficients of membership
+	// across subreddits counting only organic joins, and counting default
+	// subscriptions too.
+	OrganicGini float64
+	TotalGini   float64
+	// DefaultShareOrganic and DefaultShareTotal are the share of memberships
+	// held by the default subreddits under each count.
+	DefaultShareOrganic float64
+	DefaultShareTotal   float64
+}
+
+// GetMembershipSkew compares how memberships are spread across subreddits
+// with and without the subscriptions defaults handed out.
+func (e *Engine) GetMembershipSkew() MembershipSkew {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	isDefault := make(map[string]bool, len(e.DefaultSubReddits))
+	for _, name := range e.DefaultSubReddits {
+		isDefault[name] = true
+	}
+	var organic, total []float64
+	var organicDefault, organicAll, totalDefault, totalAll int
+	for name, subReddit := range e.SubReddits {
+		members := len(subReddit.Users)
+		joined := members - len(subReddit.DefaultMembers)
+		organic, total = append(organic, float64(joined)), append(total, float64(members))
+		organicAll += joined
+		totalAll += members
+		if isDefault[name] {
+			organicDefault += joined
+			totalDefault += members
+		}
+	}
+	return MembershipSkew{
+		OrganicGini:         gini(organic),
+		TotalGini:           gini(total),
+		DefaultShareOrganic: ratio(organicDefault, organicAll),
+		DefaultShareTotal:   ratio(totalDefault, totalAll),
+	}
+}
+
+// gini is the Gini coefficient of values: 0 when they are all equal, nearing
+// 1 when one holds everything.
+func gini(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	sum, weighted := 0.0, 0.0
+	for i, value := range sorted {
+		sum += value
+		weighted += float64(i+1) * value
+	}
+	if sum == 0 {
+		return 0
+	}
+	n := float64(len(sorted))
+	return math.Max(0, (2*weighted)/(n*sum)-(n+1)/n)
+}
@@ -0,0 +1,176 @@
+package engine
+
+import "errors"
+
+// Author Deletion
+
+const deletedContentMarker = "[deleted]"
+
+var (
+	ErrAlreadyDeleted = errors.New("content already deleted")
+	ErrContentDeleted = errors.New("deleted content can't be changed")
+)
+
+// ContentStatus tells apart content that is visible, deleted by its author
+// and removed by a moderator or automod. Deletion wins when both happened,
+// as the text is gone either way.
+type ContentStatus string
+
+const (
+	ContentVisible ContentStatus = ""
+	ContentDeleted ContentStatus = "deleted"
+	ContentRemoved ContentStatus = "removed"
+)
+
+// RemovalStats counts a subreddit's hidden content by who hid it, from its
+// modlog.
+type RemovalStats struct {
+	RemovedPosts    int
+	RemovedComments int
+	DeletedPosts    int
+	DeletedComments int
+}
+
+// DeletePost lets its author delete a post. The title, link and attachments
+// are erased and the post leaves feeds, but its comment thread stays
+// readable.
+func (e *Engine) DeletePost(user *User, post *Post) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	if post.Author != user {
+		return ErrNotAuthor
+	}
+	if _, deleted := e.DeletedPosts[post.ID]; deleted {
+		return ErrAlreadyDeleted
+	}
+	if subReddit, exists := e.SubReddits[post.SubReddit]; exists {
+		if post.Pending {
+			post.Pending = false
+			for i, queued := range subReddit.ApprovalQueue {
+				if queued == post {
+					subReddit.ApprovalQueue = append(subReddit.ApprovalQueue[:i], subReddit.ApprovalQueue[i+1:]...)
+					break
+				}
+			}
+		}
+		e.recordAuthorDeletion(subReddit, "author_delete_post", post.ID)
+	}
+	post.Deleted = true
+	post.Content, post.URL, post.Attachments = "", "", nil
+	e.DeletedPosts[post.ID] = e.Clock.Now()
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("delete_post", user.ID, post.SubReddit, post.ID)
+	return nil
+}
+
+// DeleteComment lets its author delete a comment. Its text is erased; its
+// replies stay in place under a "[deleted]" parent.
+func (e *Engine) DeleteComment(user *User, comment *Comment) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	if comment.Author != user {
+		return ErrNotAuthor
+	}
+	if _, deleted := e.DeletedComments[comment.ID]; deleted {
+		return ErrAlreadyDeleted
+	}
+	comment.Deleted = true
+	e.setCommentContent(comment, "")
+	e.DeletedComments[comment.ID] = e.Clock.Now()
+	e.dropSticky(comment)
+	if subReddit, exists := e.SubReddits[comment.SubReddit]; exists {
+		e.recordAuthorDeletion(subReddit, "author_delete_comment", comment.ID)
+	}
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("delete_comment", user.ID, comment.SubReddit, comment.ID)
+	return nil
+}
+
+// recordAuthorDeletion logs a deletion in the subreddit's modlog so
+// moderators see it beside their own removals. Like automod entries it has
+// no moderator. Callers must hold e.Mutex.
+func (e *Engine) recordAuthorDeletion(subReddit *SubReddit, action string, targetID int64) {
+	subReddit.ModLog = append(subReddit.ModLog, ModLogEntry{Time: e.Clock.Now(), Action: action, TargetID: targetID})
+}
+
+// postStatus reports whether a post was deleted or removed, using the
+// indices rather than the post's flags so archived posts are covered.
+// Callers must hold e.Mutex.
+func (e *Engine) postStatus(post *Post) ContentStatus {
+	if _, deleted := e.DeletedPosts[post.ID]; deleted {
+		return ContentDeleted
+	}
+	if _, removed := e.RemovedPosts[post.ID]; removed {
+		return ContentRemoved
+	}
+	return ContentVisible
+}
+
+// commentStatus is postStatus for comments. Callers must hold e.Mutex.
+func (e *Engine) commentStatus(comment *Comment) ContentStatus {
+	if _, deleted := e.DeletedComments[comment.ID]; deleted {
+		return ContentDeleted
+	}
+	if _, removed := e.RemovedComments[comment.ID]; removed {
+		return ContentRemoved
+	}
+	return ContentVisible
+}
+
+// GetPostStatus reports whether the post was deleted or removed.
+func (e *Engine) GetPostStatus(post *Post) ContentStatus {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.postStatus(post)
+}
+
+// GetCommentStatus reports whether the comment was deleted or removed.
+func (e *Engine) GetCommentStatus(comment *Comment) ContentStatus {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.commentStatus(comment)
+}
+
+// statusMarker is what read APIs show in place of hidden content, and
+// whether content with that status is hidden at all.
+func statusMarker(status ContentStatus) (string, bool) {
+	switch status {
+	case ContentDeleted:
+		return deletedContentMarker, true
+	case ContentRemoved:
+		return removedContentMarker, true
+	}
+	return "", false
+}
+
+// GetRemovalStats counts the subreddit's removed and deleted content.
+func (e *Engine) GetRemovalStats(subRedditName string) (RemovalStats, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return RemovalStats{}, ErrSubRedditNotFound
+	}
+	var stats RemovalStats
+	for _, entry := range subReddit.ModLog {
+		switch entry.Action {
+		case "remove_post", "automod_remove_post":
+			stats.RemovedPosts++
+		case "remove_comment", "automod_remove_comment":
+			stats.RemovedComments++
+		case "author_delete_post":
+			stats.DeletedPosts++
+		case "author_delete_comment":
+			stats.DeletedComments++
+		}
+	}
+	return stats, nil
+}
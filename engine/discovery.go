@@ -0,0 +1,141 @@
+package engine
+
+import "sort"
+
+// Discovery Slots
+
+// DiscoveryConfig sets how GetDiscoveryFeed blends in posts from subreddits
+// the user doesn't follow: Share of the feed's positions, spread evenly, go
+// to the hottest such posts, at most PerSubReddit from each subreddit. It is
+// part of Config, so ApplyConfig and config files change it.
+type DiscoveryConfig struct {
+	Share        float64
+	PerSubReddit int
+}
+
+var DefaultDiscoveryConfig = DiscoveryConfig{Share: 0.1, PerSubReddit: 1}
+
+// DiscoveryStats totals the discovery slots served. Reached counts each
+// user and subreddit they were shown once, and Joins those of them the user
+// went on to join; JoinRate is Joins over Reached.
+type DiscoveryStats struct {
+	Impressions int
+	Reached     int
+	Joins       int
+	JoinRate    float64
+}
+
+// GetDiscoveryFeed returns the user's sorted feed with discovery slots: the
+// configured share of positions goes to hot posts from subreddits the user
+// isn't a member of, hottest first, and the candidates left once the
+// user's own feed runs out follow it, so a user who has joined nothing
+// still gets a feed. The second return value marks which positions are
+// discovery slots. Every subreddit shown is remembered, so a later join of
+// it counts toward the discovery stats.
+func (e *Engine) GetDiscoveryFeed(user *User, order FeedSort) ([]*Post, []bool) {
+	organic := e.GetSortedFeed(user, order)
+	e.Mutex.RLock()
+	candidates := e.discoveryCandidates(user)
+	share := e.Discovery.Share
+	e.Mutex.RUnlock()
+
+	feed := make([]*Post, 0, len(organic)+len(candidates))
+	discovered := make([]bool, 0, cap(feed))
+	organicIndex, next := 0, 0
+	for organicIndex < len(organic) {
+		position := float64(len(feed))
+		if next < len(candidates) && int((position+1)*share) > int(position*share) {
+			feed = append(feed, candidates[next])
+			discovered = append(discovered, true)
+			next++
+			continue
+		}
+		feed = append(feed, organic[organicIndex])
+		discovered = append(discovered, false)
+		organicIndex++
+	}
+	for ; next < len(candidates); next++ {
+		feed = append(feed, candidates[next])
+		discovered = append(discovered, true)
+	}
+
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	for _, post := range candidates {
+		e.noteDiscoveryImpression(user, post.SubReddit)
+	}
+	return feed, discovered
+}
+
+// discoveryCandidates returns the hottest listed posts of every subreddit
+// user isn't a member of, hottest first. Callers must hold e.Mutex.
+func (e *Engine) discoveryCandidates(user *User) []*Post {
+	type scored struct {
+		post  *Post
+		score float64
+	}
+	hottest := func(posts []scored) {
+		sort.Slice(posts, func(i, j int) bool {
+			if posts[i].score != posts[j].score {
+				return posts[i].score > posts[j].score
+			}
+			return posts[i].post.ID < posts[j].post.ID
+		})
+	}
+	var candidates []scored
+	for _, subReddit := range e.SubReddits {
+		if _, member := subReddit.Users[user.ID]; member {
+			continue
+		}
+		var listed []scored
+		for _, post := range subReddit.Posts {
+			if e.inFeeds(post) && !e.ageGated(user, post) {
+				listed = append(listed, scored{post, hotScore(post, e.Ranking)})
+			}
+		}
+		hottest(listed)
+		candidates = append(candidates, listed[:min(len(listed), e.Discovery.PerSubReddit)]...)
+	}
+	hottest(candidates)
+	posts := make([]*Post, len(candidates))
+	for i, candidate := range candidates {
+		posts[i] = candidate.post
+	}
+	return posts
+}
+
+// noteDiscoveryImpression counts a discovery slot showing user a post from
+// subRedditName. Callers must hold e.Mutex.
+func (e *Engine) noteDiscoveryImpression(user *User, subRedditName string) {
+	e.DiscoveryImpressions++
+	shown := e.discoveryShown[user.ID]
+	if shown == nil {
+		shown = make(map[string]bool)
+		e.discoveryShown[user.ID] = shown
+	}
+	if _, seen := shown[subRedditName]; !seen {
+		shown[subRedditName] = true
+		e.DiscoveryReached++
+	}
+}
+
+// noteDiscoveryJoin counts user joining a subreddit a discovery slot showed
+// them, the first time they join it after being shown it. Callers must hold
+// e.Mutex.
+func (e *Engine) noteDiscoveryJoin(user *User, subReddit *SubReddit) {
+	if pending := e.discoveryShown[user.ID][subReddit.Name]; pending {
+		e.discoveryShown[user.ID][subReddit.Name] = false
+		e.DiscoveryJoins++
+	}
+}
+
+// GetDiscoveryStats totals the discovery slots served so far.
+func (e *Engine) GetDiscoveryStats() DiscoveryStats {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	stats := DiscoveryStats{Impressions: e.DiscoveryImpressions, Reached: e.DiscoveryReached, Joins: e.DiscoveryJoins}
+	if stats.Reached > 0 {
+		stats.JoinRate = float64(stats.Joins) / float64(stats.Reached)
+	}
+	return stats
+}
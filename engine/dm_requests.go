@@ -0,0 +1,312 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Message Requests and Follows
+
+var (
+	ErrNoDMRequest       = errors.New("no pending message request from that user")
+	ErrDMRequestDeclined = errors.New("recipient declined messages from this user")
+	ErrSelfFollow        = errors.New("users can't follow themselves")
+	ErrAlreadyFollowing  = errors.New("already following that user")
+	ErrNotFollowing      = errors.New("not following that user")
+)
+
+// DMRequestStatus is where a message request stands.
+type DMRequestStatus string
+
+const (
+	DMRequestPending  DMRequestStatus = "pending"
+	DMRequestAccepted DMRequestStatus = "accepted"
+	DMRequestDeclined DMRequestStatus = "declined"
+)
+
+// DMRequest is a stranger's request to message a user. Until To accepts
+// it, messages From sends To land in To's message requests rather than the
+// inbox; once declined, they are dropped. Messages counts those sent while
+// the request was pending.
+type DMRequest struct {
+	From     *User
+	To       *User
+	SentAt   time.Time
+	Status   DMRequestStatus
+	Messages int
+}
+
+// DMRequestStats counts message requests by how they were settled.
+// AutoAccepted requests are counted in Accepted too; Dropped is messages
+// discarded because their recipient had declined the sender.
+type DMRequestStats struct {
+	Pending      int
+	Accepted     int
+	AutoAccepted int
+	Declined     int
+	Dropped      int
+}
+
+// FollowUser makes follower follow target. Once two users follow each
+// other, message requests between them are accepted automatically.
+func (e *Engine) FollowUser(follower, target *User) error {
+	return e.FollowUserContext(context.Background(), follower, target)
+}
+
+// FollowUserContext is FollowUser, traced as a child of the span in ctx.
+func (e *Engine) FollowUserContext(ctx context.Context, follower, target *User) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(follower) {
+		return ErrUserSuspended
+	}
+	if follower == target {
+		return ErrSelfFollow
+	}
+	if e.Follows[follower.ID][target.ID] {
+		return ErrAlreadyFollowing
+	}
+	if e.Follows[follower.ID] == nil {
+		e.Follows[follower.ID] = make(map[int64]bool)
+	}
+	e.Follows[follower.ID][target.ID] = true
+	follower.Actions++
+	e.TotalActions++
+	e.recordEvent("follow", follower.ID, "", target.ID)
+	if e.Follows[target.ID][follower.ID] {
+		for _, request := range []*DMRequest{e.DMRequests[follower.ID][target.ID], e.DMRequests[target.ID][follower.ID]} {
+			if request != nil && request.Status == DMRequestPending {
+				e.acceptDMRequest(request)
+				e.AutoAcceptedDMRequests++
+			}
+		}
+	}
+	return nil
+}
+
+// UnfollowUser stops follower following target. Requests already accepted
+// stay accepted.
+func (e *Engine) UnfollowUser(follower, target *User) error {
+	return e.UnfollowUserContext(context.Background(), follower, target)
+}
+
+// UnfollowUserContext is UnfollowUser, traced as a child of the span in ctx.
+func (e *Engine) UnfollowUserContext(ctx context.Context, follower, target *User) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(follower) {
+		return ErrUserSuspended
+	}
+	if !e.Follows[follower.ID][target.ID] {
+		return ErrNotFollowing
+	}
+	delete(e.Follows[follower.ID], target.ID)
+	follower.Actions++
+	e.TotalActions++
+	e.recordEvent("unfollow", follower.ID, "", target.ID)
+	return nil
+}
+
+// IsFollowing reports whether follower follows target.
+func (e *Engine) IsFollowing(follower, target *User) bool {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.Follows[follower.ID][target.ID]
+}
+
+// GetDMRequests returns the pending message requests to user, oldest first.
+func (e *Engine) GetDMRequests(user *User) []DMRequest {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var requests []DMRequest
+	for _, request := range e.DMRequests[user.ID] {
+		if request.Status == DMRequestPending {
+			requests = append(requests, *request)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if !requests[i].SentAt.Equal(requests[j].SentAt) {
+			return requests[i].SentAt.Before(requests[j].SentAt)
+		}
+		return requests[i].From.ID < requests[j].From.ID
+	})
+	return requests
+}
+
+// GetRequestFolder returns the messages to user waiting in pending message
+// requests. RetrieveMessages returns the inbox.
+func (e *Engine) GetRequestFolder(user *User) []Message {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var requested []Message
+	for _, message := range e.Messages {
+		if request := e.DMRequests[user.ID][message.From.ID]; message.To == user && message.Request && request != nil && request.Status == DMRequestPending {
+			requested = append(requested, message)
+		}
+	}
+	return requested
+}
+
+// AcceptDMRequest accepts from's pending message request to user, moving
+// the messages it holds into user's inbox.
+func (e *Engine) AcceptDMRequest(user, from *User) error {
+	return e.AcceptDMRequestContext(context.Background(), user, from)
+}
+
+// AcceptDMRequestContext is AcceptDMRequest, traced as a child of the span in ctx.
+func (e *Engine) AcceptDMRequestContext(ctx context.Context, user, from *User) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	request, err := e.pendingDMRequest(user, from)
+	if err != nil {
+		return err
+	}
+	e.acceptDMRequest(request)
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("accept_dm_request", user.ID, "", from.ID)
+	return nil
+}
+
+// DeclineDMRequest declines from's pending message request to user. Its
+// messages stay out of the inbox, and later messages from from to user are
+// dropped unless the two come to follow each other.
+func (e *Engine) DeclineDMRequest(user, from *User) error {
+	return e.DeclineDMRequestContext(context.Background(), user, from)
+}
+
+// DeclineDMRequestContext is DeclineDMRequest, traced as a child of the span in ctx.
+func (e *Engine) DeclineDMRequestContext(ctx context.Context, user, from *User) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	request, err := e.pendingDMRequest(user, from)
+	if err != nil {
+		return err
+	}
+	request.Status = DMRequestDeclined
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("decline_dm_request", user.ID, "", from.ID)
+	return nil
+}
+
+// GetDMRequestStats counts message requests site-wide.
+func (e *Engine) GetDMRequestStats() DMRequestStats {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	stats := DMRequestStats{AutoAccepted: e.AutoAcceptedDMRequests, Dropped: e.DroppedDMs}
+	for _, requests := range e.DMRequests {
+		for _, request := range requests {
+			switch request.Status {
+			case DMRequestPending:
+				stats.Pending++
+			case DMRequestAccepted:
+				stats.Accepted++
+			case DMRequestDeclined:
+				stats.Declined++
+			}
+		}
+	}
+	return stats
+}
+
+// pendingDMRequest returns from's pending request to user. Callers must
+// hold e.Mutex.
+func (e *Engine) pendingDMRequest(user, from *User) (*DMRequest, error) {
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	request := e.DMRequests[user.ID][from.ID]
+	if request == nil || request.Status != DMRequestPending {
+		return nil, ErrNoDMRequest
+	}
+	return request, nil
+}
+
+// acceptDMRequest accepts request and moves its messages to the inbox.
+// Callers must hold e.Mutex.
+func (e *Engine) acceptDMRequest(request *DMRequest) {
+	request.Status = DMRequestAccepted
+	for i := range e.Messages {
+		if message := &e.Messages[i]; message.Request && message.From == request.From && message.To == request.To {
+			message.Request = false
+		}
+	}
+}
+
+// routeMessage decides where a message from from to to goes. It returns
+// the request the message joins, or nil if the two may already message each
+// other: users who follow each other may, as may either side of an accepted
+// request, and writing back to someone whose request is pending accepts it.
+// deliver is false if to declined from. Callers must hold e.Mutex.
+func (e *Engine) routeMessage(from, to *User, now time.Time) (request *DMRequest, deliver bool) {
+	if e.Follows[from.ID][to.ID] && e.Follows[to.ID][from.ID] {
+		return nil, true
+	}
+	outgoing, incoming := e.DMRequests[to.ID][from.ID], e.DMRequests[from.ID][to.ID]
+	if outgoing != nil && outgoing.Status == DMRequestAccepted || incoming != nil && incoming.Status == DMRequestAccepted {
+		return nil, true
+	}
+	if incoming != nil && incoming.Status == DMRequestPending {
+		e.acceptDMRequest(incoming)
+		return nil, true
+	}
+	if outgoing != nil {
+		return outgoing, outgoing.Status != DMRequestDeclined
+	}
+	request = &DMRequest{From: from, To: to, SentAt: now, Status: DMRequestPending}
+	if e.DMRequests[to.ID] == nil {
+		e.DMRequests[to.ID] = make(map[int64]*DMRequest)
+	}
+	e.DMRequests[to.ID][from.ID] = request
+	e.recordEvent("dm_request", from.ID, "", to.ID)
+	return request, true
+}
+
+// mergeFollows moves duplicate's follows, followers and message requests to
+// primary, dropping any that would be between primary and itself. Callers
+// must hold e.Mutex.
+func (e *Engine) mergeFollows(primary, duplicate *User) {
+	for followee := range e.Follows[duplicate.ID] {
+		if followee != primary.ID {
+			if e.Follows[primary.ID] == nil {
+				e.Follows[primary.ID] = make(map[int64]bool)
+			}
+			e.Follows[primary.ID][followee] = true
+		}
+	}
+	delete(e.Follows, duplicate.ID)
+	for follower, followees := range e.Follows {
+		if followees[duplicate.ID] {
+			delete(followees, duplicate.ID)
+			if follower != primary.ID {
+				followees[primary.ID] = true
+			}
+		}
+	}
+
+	for senderID, request := range e.DMRequests[duplicate.ID] {
+		request.To = primary
+		if _, exists := e.DMRequests[primary.ID][senderID]; exists || senderID == primary.ID {
+			continue
+		}
+		if e.DMRequests[primary.ID] == nil {
+			e.DMRequests[primary.ID] = make(map[int64]*DMRequest)
+		}
+		e.DMRequests[primary.ID][senderID] = request
+	}
+	delete(e.DMRequests, duplicate.ID)
+	for recipientID, requests := range e.DMRequests {
+		request := requests[duplicate.ID]
+		if request == nil {
+			continue
+		}
+		delete(requests, duplicate.ID)
+		request.From = primary
+		if _, exists := requests[primary.ID]; !exists && recipientID != primary.ID {
+			requests[primary.ID] = request
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Package engine is the core of the Reddit clone: users, subreddits, posts,
+// comments, votes and messages, and the moderation, ranking, notification
+// and storage features built on them. It doesn't depend on the simulator, so
+// it can be embedded in another service or tested on its own.
+//
+// An Engine is created with New and is safe for concurrent use. Its methods
+// take Engine.Mutex themselves: actions lock it for writing, while queries
+// take its read lock and run alongside each other. Exported fields may be
+// read by holding the read lock and changed by holding the write lock.
+// Methods documented with "Callers must hold e.Mutex", such as FindPost, are
+// for ActionHandlers, which run with the write lock held; the read lock is
+// enough for those that only read. A lock per subreddit and a sharded lock
+// per post guard the little state queries change, and are only ever taken
+// after the engine lock.
+//
+// Each operation is atomic: it updates state, counters and the event log in
+// one critical section, so readers see the state left by some prefix of the
+// log and never part of an operation. Event sequence numbers are the order
+// operations took effect in, and an operation that returns before another
+// starts is ordered before it. CheckHappensBefore checks observations
+// gathered by concurrent readers against that model, and chaos runs check
+// the simulator's concurrent actors with it; changes that shard the lock or
+// make work asynchronous have to keep it passing.
+//
+// Content is stamped with the time Engine.Clock tells: posts and comments
+// with CreatedAt, edited comments with EditedAt and messages with SentAt.
+// Ranking, archiving and the new sort read those stamps, so setting Clock to
+// a SimClock before anything is created runs the whole engine on virtual
+// time.
+//
+// Failures are reported with the sentinel errors declared beside each
+// feature, such as ErrSubRedditNotFound and ErrUserSuspended, and where the
+// caller needs details with typed errors that wrap them, such as
+// *ValidationError, *CrosspostError and *BackpressureError. Match them with
+// errors.Is and errors.As.
+package engine
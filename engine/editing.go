@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"errors"
+	"time"
+)
+
+// Comment Editing
+
+// defaultEditGracePeriod mirrors Reddit's window in which edits don't mark a
+// comment as edited.
+const defaultEditGracePeriod = 3 * time.Minute
+
+var (
+	ErrNotAuthor    = errors.New("user is not the author")
+	ErrEmptyContent = errors.New("content must not be empty")
+)
+
+// EditComment replaces a comment's content. Edits made after the engine's
+// grace period set Edited and EditedAt; earlier edits are silent.
+func (e *Engine) EditComment(user *User, comment *Comment, content string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	if comment.Author != user {
+		return ErrNotAuthor
+	}
+	if comment.Deleted {
+		return ErrContentDeleted
+	}
+	content, err := e.validateContent("comment", content, maxCommentLength)
+	if err != nil {
+		return err
+	}
+	now := e.Clock.Now()
+	e.setCommentContent(comment, content)
+	e.TotalCommentEdits++
+	if now.Sub(comment.CreatedAt) > e.EditGracePeriod {
+		comment.Edited = true
+		comment.EditedAt = now
+		e.EditedComments[comment.ID] = now
+	}
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("edit_comment", user.ID, comment.SubReddit, comment.ID)
+	if subReddit, exists := e.SubReddits[comment.SubReddit]; exists && !comment.Removed {
+		e.enforceCommentPolicy(subReddit, comment)
+	}
+	return nil
+}
+
+// SetEditGracePeriod sets how long after posting a comment may be edited
+// without being marked as edited.
+func (e *Engine) SetEditGracePeriod(grace time.Duration) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.EditGracePeriod = grace
+}
+
+// EditedContentRate is the fraction of comments carrying the edited marker.
+func (e *Engine) EditedContentRate() float64 {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	if e.TotalComments == 0 {
+		return 0
+	}
+	return float64(len(e.EditedComments)) / float64(e.TotalComments)
+}
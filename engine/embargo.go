@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"errors"
+	"time"
+)
+
+// Posting Embargoes
+
+var ErrUnlockInPast = errors.New("unlock time must be in the future")
+
+// EmbargoStatus describes a subreddit's scheduled unlock. UnlockAt is zero
+// when the subreddit is open; Queued counts the submissions waiting for it.
+type EmbargoStatus struct {
+	UnlockAt time.Time
+	Queued   int
+	Released int
+}
+
+// ScheduleUnlock closes the subreddit to new posts until at on the engine
+// clock, for event threads that should all go up at once. Posts submitted
+// before then, moderators' included, are accepted but held out of feeds
+// until the unlock, when they are released dated at the unlock time and, if
+// the subreddit requires approval, join the approval queue. Scheduling again
+// moves the unlock.
+func (e *Engine) ScheduleUnlock(mod *User, subRedditName string, at time.Time) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if e.isSuspended(mod) {
+		return ErrUserSuspended
+	}
+	if !e.isModerator(mod, subReddit) {
+		return ErrNotModerator
+	}
+	if !at.After(e.Clock.Now()) {
+		return ErrUnlockInPast
+	}
+	subReddit.UnlockAt = at
+	e.schedule(at, "unlock_subreddit", func() { e.releaseEmbargo(subReddit) })
+	e.recordModAction(subReddit, mod, "schedule_unlock", 0, 0)
+	e.recordEvent("schedule_unlock", mod.ID, subReddit.Name, at.Unix())
+	return nil
+}
+
+// GetEmbargoStatus reports on the subreddit's scheduled unlock.
+func (e *Engine) GetEmbargoStatus(subRedditName string) (EmbargoStatus, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return EmbargoStatus{}, ErrSubRedditNotFound
+	}
+	return EmbargoStatus{UnlockAt: subReddit.UnlockAt, Queued: len(subReddit.Embargoed), Released: subReddit.EmbargoReleased}, nil
+}
+
+// embargo holds a new post back until the subreddit unlocks, reporting
+// whether it did. Posts automod already removed aren't held. Callers must
+// hold e.Mutex.
+func (e *Engine) embargo(subReddit *SubReddit, post *Post) bool {
+	if subReddit.UnlockAt.IsZero() || post.Removed {
+		return false
+	}
+	if !e.Clock.Now().Before(subReddit.UnlockAt) {
+		e.releaseEmbargo(subReddit)
+		return false
+	}
+	post.Embargoed = true
+	subReddit.Embargoed = append(subReddit.Embargoed, post)
+	return true
+}
+
+// releaseEmbargo opens the subreddit if its unlock time has passed. It is a
+// no-op if the unlock was moved later or already happened. Callers must hold
+// e.Mutex.
+func (e *Engine) releaseEmbargo(subReddit *SubReddit) {
+	if subReddit.UnlockAt.IsZero() || e.Clock.Now().Before(subReddit.UnlockAt) {
+		return
+	}
+	for _, post := range subReddit.Embargoed {
+		post.Embargoed = false
+		if post.Removed || post.Deleted {
+			continue
+		}
+		post.CreatedAt = subReddit.UnlockAt
+		e.queueForApproval(subReddit, post)
+	}
+	subReddit.EmbargoReleased += len(subReddit.Embargoed)
+	e.recordEvent("unlock_subreddit", 0, subReddit.Name, int64(len(subReddit.Embargoed)))
+	subReddit.Embargoed = nil
+	subReddit.UnlockAt = time.Time{}
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// Engagement and Churn
+
+const (
+	InitialEngagement   = 0.5
+	engagementSmoothing = 0.2
+	churnThreshold      = 0.3
+	churnSensitivity    = 2.0
+	FeedRelevanceDepth  = 10
+	replyLatencyScale   = 30 * time.Minute
+)
+
+// EngagementSignals are what a user's engagement responds to after a feed
+// visit. FeedRelevance is in [0, 1]; see FeedRelevance.
+type EngagementSignals struct {
+	FeedRelevance float64
+	// ReplyLatency is how long the user's last comment waited for a reply
+	// from someone else, or 0 if nobody replied.
+	ReplyLatency time.Duration
+	KarmaDelta   int
+}
+
+// NextEngagement blends the signals into an exponential moving average of
+// the user's engagement in [0, 1].
+func NextEngagement(current float64, signals EngagementSignals) float64 {
+	latencyScore := 0.3
+	if signals.ReplyLatency > 0 {
+		latencyScore = math.Exp(-float64(signals.ReplyLatency) / float64(replyLatencyScale))
+	}
+	karmaScore := 0.5 + 0.5*math.Tanh(float64(signals.KarmaDelta)/5)
+	signal := 0.5*signals.FeedRelevance + 0.2*latencyScore + 0.3*karmaScore
+	return (1-engagementSmoothing)*current + engagementSmoothing*signal
+}
+
+// ChurnProbability is the chance a user with the given engagement leaves
+// after a visit.
+func ChurnProbability(engagement float64) float64 {
+	if engagement >= churnThreshold {
+		return 0
+	}
+	return math.Min(1, (churnThreshold-engagement)*churnSensitivity)
+}
+
+// FeedRelevance is the mean topic affinity of the first posts in a feed.
+func (e *Engine) FeedRelevance(user *User, feed []*Post) float64 {
+	if len(feed) == 0 {
+		return 0
+	}
+	n := FeedRelevanceDepth
+	if len(feed) < n {
+		n = len(feed)
+	}
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	total := 0.0
+	for _, post := range feed[:n] {
+		if subReddit, exists := e.SubReddits[post.SubReddit]; exists {
+			total += TopicAffinity(user.InterestProfile, subReddit.Topics)
+		}
+	}
+	return total / float64(n)
+}
+
+// EngagementSignalsFor gathers the user's current signals using the given
+// feed ranking.
+func (e *Engine) EngagementSignalsFor(user *User, order FeedSort) EngagementSignals {
+	relevance := e.FeedRelevance(user, e.GetSortedFeed(user, order))
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return EngagementSignals{
+		FeedRelevance: relevance,
+		ReplyLatency:  e.replyLatency[user.ID],
+		KarmaDelta:    user.Karma() - e.lastKarma[user.ID],
+	}
+}
+
+// ApplyEngagement updates the user's engagement score from signals and may
+// churn them: churned users are disconnected permanently and ConnectUser no
+// longer brings them back.
+func (e *Engine) ApplyEngagement(user *User, signals EngagementSignals) (churned bool) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if user.Churned {
+		return true
+	}
+	user.Engagement = NextEngagement(user.Engagement, signals)
+	e.lastKarma[user.ID] = user.Karma()
+	if e.Rand.Float64() >= ChurnProbability(user.Engagement) {
+		return false
+	}
+	user.Churned = true
+	if user.Connected {
+		user.Connected = false
+		e.DisconnectedUsers++
+	}
+	e.ChurnedUsers++
+	e.recordEvent("churn", user.ID, "", 0)
+	return true
+}
+
+// recordReplyLatency notes how long parent waited for a reply from another
+// user. Callers must hold e.Mutex.
+func (e *Engine) recordReplyLatency(parent *Comment, reply *Comment) {
+	if parent.Author == reply.Author {
+		return
+	}
+	e.replyLatency[parent.Author.ID] = reply.CreatedAt.Sub(parent.CreatedAt)
+}
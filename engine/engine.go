@@ -0,0 +1,807 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Data Structures
+
+// User is a registered account. Its counters are kept by the engine; read
+// them with e.Mutex held.
+type User struct {
+	ID                int64
+	Username          string
+	PreviousUsernames []string
+	DisplayName       string
+	AvatarURL         string
+	Bio               string
+	// PostKarma comes from votes and awards on the user's posts and
+	// CommentKarma from votes on their comments; Karma is the sum.
+	PostKarma       int
+	CommentKarma    int
+	Actions         int
+	Connected       bool
+	Region          string
+	InterestProfile map[string]float64
+	Engagement      float64
+	Churned         bool
+	IsAdmin         bool
+	SuspendedUntil  time.Time
+	CreatedAt       time.Time
+	MergedInto      int64
+	Persona         string
+	Activity        ActivityHeatmap
+	// Age is the user's age in years, or 0 if it isn't known; see
+	// SetUserAge.
+	Age int
+}
+
+// SubReddit is a community: its members and posts, and the moderators,
+// rules, settings and content policy that govern them.
+type SubReddit struct {
+	Name              string
+	Posts             []*Post
+	Users             map[int64]*User
+	HomeRegion        string
+	TotalPosts        int
+	TotalVotes        int
+	Settings          SubRedditSettings
+	Topics            []string
+	Moderators        map[int64]*User
+	Rules             []Rule
+	ModLog            []ModLogEntry
+	RuleViolations    map[int]int
+	Links             map[string]linkSubmission
+	Traffic           map[string]*trafficDay
+	Policy            ContentPolicy
+	bannedWords       map[string]bool
+	PolicyViolations  map[string]int
+	Warnings          map[int64]int
+	Banned            map[int64]time.Time
+	BanIDs            map[int64]int64
+	Appeals           []*BanAppeal
+	ApprovalQueue     []*Post
+	UnlockAt          time.Time
+	Embargoed         []*Post
+	EmbargoReleased   int
+	ApprovalLatencies []time.Duration
+	Approved          int
+	Rejected          int
+	CommentKarma      map[int64]int
+	// DefaultMembers marks members subscribed by the default set rather
+	// than by joining.
+	DefaultMembers map[int64]bool
+	// mu is the subreddit's lock. Queries hold only the read side of
+	// e.Mutex, so they take mu to count views in or read Traffic.
+	mu sync.Mutex
+}
+
+// Post is a text, link or media post in a subreddit. Votes is its net
+// score. Subreddits hold their posts, and posts and comments their
+// comments, by pointer: the *Post and *Comment an action returns is the one
+// the engine keeps, so later votes, edits and moderation through it change
+// engine state. Posts read back from cold storage are copies.
+type Post struct {
+	ID          int64
+	Author      *User
+	Content     string
+	Comments    []*Comment
+	Votes       int
+	CreatedAt   time.Time
+	SubReddit   string
+	Removed     bool
+	Deleted     bool
+	CommentSort CommentSort
+	URL         string
+	Pending     bool
+	// Embargoed posts wait for their subreddit's scheduled unlock; see
+	// ScheduleUnlock.
+	Embargoed bool
+	// Attachments are the post's media in display order; see IsGallery.
+	Attachments []Attachment
+	// WeightedVotes is Votes with each vote scaled by the engine's
+	// VoteDecay at the time it was cast.
+	WeightedVotes float64
+	Upvotes       int
+	Downvotes     int
+	// KarmaWeightedVotes is Votes with each vote scaled by its voter's
+	// karma when it was cast; see voterWeight.
+	KarmaWeightedVotes float64
+	// NSFW posts are withheld from minors, as is everything in an NSFW
+	// subreddit; see SetPostNSFW.
+	NSFW bool
+}
+
+// Comment is a comment on a post or a reply to another comment.
+type Comment struct {
+	ID        int64
+	PostID    int64
+	SubReddit string
+	Author    *User
+	// body is the comment's text, usually backed by the engine's
+	// CommentArena; read it with Content.
+	body      string
+	Replies   []*Comment
+	Votes     int
+	Removed   bool
+	Deleted   bool
+	CreatedAt time.Time
+	Edited    bool
+	EditedAt  time.Time
+	Reactions map[string]int
+	// Collapsed is set while the comment or one above it has a score below
+	// the engine's CollapseThreshold; it is kept current on every vote.
+	Collapsed bool
+}
+
+// Message is a direct message between two users. Request marks messages
+// from strangers that wait in the recipient's message requests; see
+// DMRequest.
+type Message struct {
+	From    *User
+	To      *User
+	Content string
+	SentAt  time.Time
+	Spam    bool
+	Request bool
+}
+
+// Engine holds everything on one site. Create it with New; the zero value
+// is not usable.
+type Engine struct {
+	Users                   map[int64]*User
+	SubReddits              map[string]*SubReddit
+	Messages                []Message
+	IDOffset                int64
+	UserID                  int64
+	PostID                  int64
+	CommentID               int64
+	TotalPosts              int
+	TotalVotes              int
+	TotalMessages           int
+	TotalActions            int
+	TotalComments           int
+	DisconnectedUsers       int
+	StartTime               time.Time
+	Mutex                   EngineMutex
+	ActionBreakdown         map[string]int
+	Events                  []Event
+	EventSeq                int
+	CustomActions           map[string]ActionHandler
+	CommentParents          map[int64]int64
+	postIndex               map[int64]*Post
+	commentIndex            map[int64]*Comment
+	BranchScores            map[int64]int
+	Usernames               map[string]int64
+	ColdStore               *ColdStore
+	Translator              Translator
+	TranslationCache        map[translationKey]string
+	TranslationHits         int
+	TranslationMisses       int
+	Summarizer              Summarizer
+	ThreadSummaries         map[int64]ThreadSummary
+	SummaryHits             int
+	SummaryMisses           int
+	SummaryInvalidations    int
+	TimeSeries              map[string][]SubRedditSample
+	lastSamples             map[string]subRedditCounters
+	Clock                   Clock
+	Rand                    *rand.Rand
+	Logger                  *slog.Logger
+	AuditLog                []AuditEntry
+	TotalSuspensions        int
+	BlockedActions          int
+	RejectedCrossposts      int
+	Anomalies               []Anomaly
+	postVelocity            map[int64]*postVelocity
+	velocityStats           map[string]*velocityStats
+	Notifications           map[int64][]Notification
+	PendingNotifications    map[int64][]Notification
+	NotificationID          int64
+	DeliveredNotifications  int
+	QueuedNotifications     int
+	TotalBroadcasts         int
+	Mutes                   map[int64]map[MuteTarget]bool
+	MutedNotifications      map[MuteKind]int
+	ReplyNotifications      int
+	MergedAccounts          int
+	Interests               map[int64]map[string]float64
+	RemovedPosts            map[int64]int
+	RemovedComments         map[int64]int
+	DeletedPosts            map[int64]time.Time
+	DeletedComments         map[int64]time.Time
+	EditGracePeriod         time.Duration
+	EditedComments          map[int64]time.Time
+	TotalCommentEdits       int
+	Shared                  SharedStore
+	Store                   Store
+	Codec                   Codec
+	SharedStoreErrors       int64
+	sharedWriter            *sharedWriter
+	unsentShared            map[string]int64
+	FeedCacheHits           int64
+	FeedCacheMisses         int64
+	hooks                   []*hookWorker
+	closedHooks             []*hookWorker
+	hookWG                  sync.WaitGroup
+	ChurnedUsers            int
+	replyLatency            map[int64]time.Duration
+	lastKarma               map[int64]int
+	CommentSorts            map[int64]CommentSort
+	StickyComments          map[int64]int64
+	DuplicateWindow         time.Duration
+	DedupHits               int
+	ValidationRejects       map[string]int
+	VoteSeq                 int64
+	voteStreams             []*VoteStream
+	liveThreads             map[int64]*liveThread
+	TotalPolicyViolations   int
+	TotalSubRedditBans      int
+	BanID                   int64
+	Milestones              map[int64]map[string]bool
+	Trophies                map[int64][]Trophy
+	Discovery               DiscoveryConfig
+	discoveryShown          map[int64]map[string]bool
+	DiscoveryImpressions    int
+	DiscoveryReached        int
+	DiscoveryJoins          int
+	MilestoneCounts         map[string]int
+	Quota                   TenantQuota
+	QuotaRejections         int
+	CommentReactions        map[int64]map[reactionKey]bool
+	ReactionCounts          map[string]int
+	TotalReactions          int
+	VoteDecay               VoteDecay
+	Ranking                 RankingConstants
+	ConfigReloads           int
+	karmaCredit             map[int64]float64
+	DecayedVotes            int
+	WithheldKarma           float64
+	Promotions              map[int64]*Promotion
+	PromotedSlots           []int
+	PromotionSlotsOffered   int64
+	PromotionSlotsFilled    int64
+	KarmaGatedPosts         int
+	AgeGatedImpressions     int64
+	VoteProvenance          []VoteRecord
+	PostVotes               map[int64]map[int64]PostVote
+	CommentVotes            map[int64]map[int64]int
+	CommentArena            CommentArena
+	dmRepeats               map[dmFingerprint]*dmRepeat
+	dmRepeatsSwept          time.Time
+	AttachmentPosts         int
+	GalleryPosts            int
+	TotalAttachments        int
+	BackpressureLimits      BackpressureLimits
+	backpressured           map[string]bool
+	BackpressureActivations []BackpressureActivation
+	BackpressureSignals     int
+	BackpressureWaits       int
+	jobs                    jobQueue
+	jobSeq                  int64
+	ScheduledRuns           map[string]int
+	CollapseThreshold       int
+	CollapseRoots           map[int64]bool
+	CommentCollapses        int
+	CommentUncollapses      int
+	notificationBacklog     int
+	DefaultSubReddits       []string
+	DefaultSubscriptions    int
+	DefaultOptOuts          int
+	Follows                 map[int64]map[int64]bool
+	DMRequests              map[int64]map[int64]*DMRequest
+	AutoAcceptedDMRequests  int
+	DroppedDMs              int
+	rankingPool             *RankingPool
+	postLocks               postLocks
+}
+
+// Initialization and Utility Functions
+
+// New returns an empty engine on the real clock, with an in-memory shared
+// store and the default edit, duplicate-link, collapse, ranking and
+// backpressure settings.
+func New() *Engine {
+	return &Engine{
+		Users:                make(map[int64]*User),
+		SubReddits:           make(map[string]*SubReddit),
+		Messages:             []Message{},
+		UserID:               1,
+		PostID:               1,
+		CommentID:            1,
+		StartTime:            time.Now(),
+		Clock:                realClock{},
+		Rand:                 NewRand(time.Now().UnixNano()),
+		Logger:               slog.Default(),
+		CustomActions:        make(map[string]ActionHandler),
+		CommentParents:       make(map[int64]int64),
+		postIndex:            make(map[int64]*Post),
+		commentIndex:         make(map[int64]*Comment),
+		BranchScores:         make(map[int64]int),
+		Usernames:            make(map[string]int64),
+		Translator:           MockTranslator{},
+		TranslationCache:     make(map[translationKey]string),
+		Summarizer:           MockSummarizer{TopComments: 3},
+		ThreadSummaries:      make(map[int64]ThreadSummary),
+		TimeSeries:           make(map[string][]SubRedditSample),
+		lastSamples:          make(map[string]subRedditCounters),
+		postVelocity:         make(map[int64]*postVelocity),
+		velocityStats:        make(map[string]*velocityStats),
+		Notifications:        make(map[int64][]Notification),
+		PendingNotifications: make(map[int64][]Notification),
+		Interests:            make(map[int64]map[string]float64),
+		RemovedPosts:         make(map[int64]int),
+		RemovedComments:      make(map[int64]int),
+		DeletedPosts:         make(map[int64]time.Time),
+		DeletedComments:      make(map[int64]time.Time),
+		EditGracePeriod:      defaultEditGracePeriod,
+		EditedComments:       make(map[int64]time.Time),
+		Shared:               NewMemoryStore(),
+		Store:                NewMapStore(),
+		Codec:                JSONCodec{},
+		replyLatency:         make(map[int64]time.Duration),
+		lastKarma:            make(map[int64]int),
+		CommentSorts:         make(map[int64]CommentSort),
+		liveThreads:          make(map[int64]*liveThread),
+		StickyComments:       make(map[int64]int64),
+		DuplicateWindow:      defaultDuplicateWindow,
+		Milestones:           make(map[int64]map[string]bool),
+		Trophies:             make(map[int64][]Trophy),
+		Discovery:            DefaultDiscoveryConfig,
+		discoveryShown:       make(map[int64]map[string]bool),
+		MilestoneCounts:      make(map[string]int),
+		CommentReactions:     make(map[int64]map[reactionKey]bool),
+		ReactionCounts:       make(map[string]int),
+		ValidationRejects:    make(map[string]int),
+		BackpressureLimits:   DefaultBackpressureLimits,
+		backpressured:        make(map[string]bool),
+		Mutes:                make(map[int64]map[MuteTarget]bool),
+		MutedNotifications:   make(map[MuteKind]int),
+		Promotions:           make(map[int64]*Promotion),
+		karmaCredit:          make(map[int64]float64),
+		dmRepeats:            make(map[dmFingerprint]*dmRepeat),
+		PromotedSlots:        defaultPromotedSlots,
+		ScheduledRuns:        make(map[string]int),
+		CollapseThreshold:    defaultCollapseThreshold,
+		Ranking:              DefaultRankingConstants,
+		CollapseRoots:        make(map[int64]bool),
+		CommentVotes:         make(map[int64]map[int64]int),
+		PostVotes:            make(map[int64]map[int64]PostVote),
+		Follows:              make(map[int64]map[int64]bool),
+		DMRequests:           make(map[int64]map[int64]*DMRequest),
+		ActionBreakdown: map[string]int{
+			"Posts":    0,
+			"Comments": 0,
+			"Votes":    0,
+			"Messages": 0,
+		},
+	}
+}
+
+// RegisterUser creates a user and subscribes them to the default
+// subreddits. It fails with ErrUsernameTaken if the name is, or once was,
+// someone's username and with ErrQuotaExceeded once the tenant quota on
+// users is reached.
+func (e *Engine) RegisterUser(username string) (*User, error) {
+	return e.RegisterUserContext(context.Background(), username)
+}
+
+// RegisterUserContext is RegisterUser, traced as a child of the span in ctx.
+func (e *Engine) RegisterUserContext(ctx context.Context, username string) (*User, error) {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if _, taken := e.Usernames[username]; taken {
+		return nil, ErrUsernameTaken
+	}
+	if e.overQuota(e.Quota.MaxUsers, len(e.Users)) {
+		return nil, ErrQuotaExceeded
+	}
+	id := e.UserID
+	e.UserID++
+	user := &User{ID: id, Username: username, Actions: 0, Connected: true, Engagement: InitialEngagement, CreatedAt: e.Clock.Now()}
+	e.Users[id] = user
+	e.Usernames[username] = id
+	e.recordEvent("register", id, "", 0)
+	e.joinDefaults(user)
+	return user, nil
+}
+
+// CreateSubReddit creates an empty subreddit. It fails with
+// ErrSubRedditExists if the name is taken and ErrQuotaExceeded once the
+// tenant quota on subreddits is reached.
+func (e *Engine) CreateSubReddit(name string) (*SubReddit, error) {
+	return e.CreateSubRedditContext(context.Background(), name)
+}
+
+// CreateSubRedditContext is CreateSubReddit, traced as a child of the span in ctx.
+func (e *Engine) CreateSubRedditContext(ctx context.Context, name string) (*SubReddit, error) {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if _, exists := e.SubReddits[name]; exists {
+		return nil, ErrSubRedditExists
+	}
+	if e.overQuota(e.Quota.MaxSubReddits, len(e.SubReddits)) {
+		return nil, ErrQuotaExceeded
+	}
+	subReddit := newSubReddit(name)
+	e.SubReddits[name] = subReddit
+	e.recordEvent("create_subreddit", 0, name, 0)
+	return subReddit, nil
+}
+
+// newSubReddit returns an empty subreddit with its maps allocated.
+func newSubReddit(name string) *SubReddit {
+	return &SubReddit{Name: name, Posts: []*Post{}, Users: make(map[int64]*User), Moderators: make(map[int64]*User), RuleViolations: make(map[int]int), Links: make(map[string]linkSubmission), Traffic: make(map[string]*trafficDay), PolicyViolations: make(map[string]int), Warnings: make(map[int64]int), Banned: make(map[int64]time.Time), BanIDs: make(map[int64]int64), CommentKarma: make(map[int64]int), DefaultMembers: make(map[int64]bool)}
+}
+
+// JoinSubReddit subscribes the user to the subreddit. Minors can't join NSFW
+// subreddits.
+func (e *Engine) JoinSubReddit(user *User, subRedditName string) error {
+	return e.JoinSubRedditContext(context.Background(), user, subRedditName)
+}
+
+// JoinSubRedditContext is JoinSubReddit, traced as a child of the span in ctx.
+func (e *Engine) JoinSubRedditContext(ctx context.Context, user *User, subRedditName string) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if subReddit.Settings.NSFW && minor(user) {
+		return ErrAgeRestricted
+	}
+	e.subscribe(user, subReddit)
+	return nil
+}
+
+// LeaveSubReddit unsubscribes the user from the subreddit.
+func (e *Engine) LeaveSubReddit(user *User, subRedditName string) error {
+	return e.LeaveSubRedditContext(context.Background(), user, subRedditName)
+}
+
+// LeaveSubRedditContext is LeaveSubReddit, traced as a child of the span in ctx.
+func (e *Engine) LeaveSubRedditContext(ctx context.Context, user *User, subRedditName string) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if _, member := subReddit.Users[user.ID]; member {
+		e.trafficToday(subReddit).Unsubscriptions++
+	}
+	delete(subReddit.Users, user.ID)
+	delete(subReddit.DefaultMembers, user.ID)
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("leave", user.ID, subRedditName, 0)
+	return nil
+}
+
+// CreatePost posts content to the subreddit. It fails if the user may not
+// post there, with a *KarmaRequirementError if they lack the karma the
+// subreddit asks for, or with a *ValidationError if the content is invalid.
+func (e *Engine) CreatePost(user *User, subRedditName, content string) (*Post, error) {
+	return e.CreatePostContext(context.Background(), user, subRedditName, content)
+}
+
+// CreatePostContext is CreatePost, traced as a child of the span in ctx.
+func (e *Engine) CreatePostContext(ctx context.Context, user *User, subRedditName, content string) (*Post, error) {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if e.isBanned(user, subReddit) {
+		return nil, ErrBannedFromSubReddit
+	}
+	if e.overQuota(e.Quota.MaxPosts, e.TotalPosts) {
+		return nil, ErrQuotaExceeded
+	}
+	if err := e.checkPostKarma(user, subReddit); err != nil {
+		return nil, err
+	}
+	content, err := e.validateContent("post", content, maxPostLength)
+	if err != nil {
+		return nil, err
+	}
+	post := Post{Author: user, Content: content}
+	return e.insertPost(subReddit, post, "post"), nil
+}
+
+// CreateRepost posts a copy of originalPost in another subreddit, subject
+// to both subreddits' crosspost settings.
+func (e *Engine) CreateRepost(user *User, originalPost *Post, subRedditName string) (*Post, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if e.isBanned(user, subReddit) {
+		return nil, ErrBannedFromSubReddit
+	}
+	if err := e.checkPostKarma(user, subReddit); err != nil {
+		return nil, err
+	}
+	if e.overQuota(e.Quota.MaxPosts, e.TotalPosts) {
+		return nil, ErrQuotaExceeded
+	}
+	if err := e.checkCrosspost(originalPost.SubReddit, subReddit); err != nil {
+		e.RejectedCrossposts++
+		return nil, err
+	}
+	repost := Post{Author: user, Content: originalPost.Content, NSFW: e.isNSFW(originalPost)}
+	return e.insertPost(subReddit, repost, "repost"), nil
+}
+
+// insertPost assigns the next ID and creation time to post, adds it to the
+// subreddit and updates counters. Callers must hold e.Mutex.
+func (e *Engine) insertPost(subReddit *SubReddit, post Post, eventType string) *Post {
+	post.ID = e.PostID
+	post.Comments = []*Comment{}
+	post.CreatedAt = e.Clock.Now()
+	post.SubReddit = subReddit.Name
+	e.PostID++
+	e.TotalPosts++
+	e.ActionBreakdown["Posts"]++
+	post.Author.Actions++
+	e.TotalActions++
+	stored := &post
+	subReddit.Posts = append(subReddit.Posts, stored)
+	e.postIndex[stored.ID] = stored
+	subReddit.TotalPosts++
+	e.recordInterest(post.Author, subReddit.Name, postInterestWeight)
+	e.recordEvent(eventType, post.Author.ID, subReddit.Name, post.ID)
+	e.recordContribution(subReddit.Name, post.Author, contributionPost)
+	e.enforcePostPolicy(subReddit, stored)
+	if !e.embargo(subReddit, stored) {
+		e.queueForApproval(subReddit, stored)
+	}
+	e.reachMilestone(post.Author, "first_post", "first_post", fmt.Sprintf("Congratulations on your first post in %s!", subReddit.Name))
+	e.awardTrophy(post.Author, TrophyFirstPost)
+	return stored
+}
+
+// CommentPost adds a top-level comment to post and notifies its author. It
+// fails if the user is suspended or banned from the post's subreddit, or
+// with a *ValidationError if the content is invalid.
+func (e *Engine) CommentPost(user *User, post *Post, content string) (*Comment, error) {
+	return e.CommentPostContext(context.Background(), user, post, content)
+}
+
+// CommentPostContext is CommentPost, traced as a child of the span in ctx.
+func (e *Engine) CommentPostContext(ctx context.Context, user *User, post *Post, content string) (*Comment, error) {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if exists && e.isBanned(user, subReddit) {
+		return nil, ErrBannedFromSubReddit
+	}
+	content, err := e.validateContent("comment", content, maxCommentLength)
+	if err != nil {
+		return nil, err
+	}
+	comment := &Comment{ID: e.CommentID, PostID: post.ID, SubReddit: post.SubReddit, Author: user, Votes: 0, CreatedAt: e.Clock.Now()}
+	e.setCommentContent(comment, content)
+	e.CommentID++
+	post.Comments = append(post.Comments, comment)
+	e.CommentParents[comment.ID] = 0
+	e.commentIndex[comment.ID] = comment
+	e.refreshCollapse(comment)
+	e.TotalComments++
+	e.ActionBreakdown["Comments"]++
+	user.Actions++
+	e.TotalActions++
+	e.recordInterest(user, comment.SubReddit, commentInterestWeight)
+	e.recordEvent("comment", user.ID, comment.SubReddit, comment.ID)
+	e.recordContribution(comment.SubReddit, user, contributionComment)
+	if exists {
+		e.enforceCommentPolicy(subReddit, comment)
+	}
+	if !comment.Removed {
+		e.notifyReply(post.Author, "post_reply", comment)
+		e.publishLiveComment(comment, 0)
+	}
+	return comment, nil
+}
+
+// AddReplyToComment replies to parentComment and notifies its author. It
+// fails for the same reasons as CommentPost.
+func (e *Engine) AddReplyToComment(user *User, parentComment *Comment, content string) (*Comment, error) {
+	return e.AddReplyToCommentContext(context.Background(), user, parentComment, content)
+}
+
+// AddReplyToCommentContext is AddReplyToComment, traced as a child of the span in ctx.
+func (e *Engine) AddReplyToCommentContext(ctx context.Context, user *User, parentComment *Comment, content string) (*Comment, error) {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[parentComment.SubReddit]
+	if exists && e.isBanned(user, subReddit) {
+		return nil, ErrBannedFromSubReddit
+	}
+	content, err := e.validateContent("comment", content, maxCommentLength)
+	if err != nil {
+		return nil, err
+	}
+	reply := &Comment{ID: e.CommentID, PostID: parentComment.PostID, SubReddit: parentComment.SubReddit, Author: user, Votes: 0, CreatedAt: e.Clock.Now()}
+	e.setCommentContent(reply, content)
+	e.CommentID++
+	parentComment.Replies = append(parentComment.Replies, reply)
+	e.CommentParents[reply.ID] = parentComment.ID
+	e.commentIndex[reply.ID] = reply
+	e.refreshCollapse(reply)
+	e.recordReplyLatency(parentComment, reply)
+	e.TotalComments++
+	e.ActionBreakdown["Comments"]++
+	user.Actions++
+	e.TotalActions++
+	e.recordInterest(user, reply.SubReddit, commentInterestWeight)
+	e.recordEvent("reply", user.ID, reply.SubReddit, reply.ID)
+	e.recordContribution(reply.SubReddit, user, contributionComment)
+	if exists {
+		e.enforceCommentPolicy(subReddit, reply)
+	}
+	if !reply.Removed {
+		e.notifyReply(parentComment.Author, "comment_reply", reply)
+		e.publishLiveComment(reply, parentComment.ID)
+	}
+	return reply, nil
+}
+
+// votePost casts voter's vote of delta on post and updates every counter
+// and feed that follows votes. Callers must hold e.Mutex and have retracted
+// any earlier vote by voter on post.
+func (e *Engine) votePost(voter *User, post *Post, delta int) {
+	weight := e.applyPostVote(post, delta)
+	if delta > 0 {
+		post.Upvotes++
+		if post.Upvotes >= popularPostUpvotes {
+			e.awardTrophy(post.Author, TrophyPopularPost)
+		}
+	} else {
+		post.Downvotes++
+	}
+	voterWeight := voterWeight(voter)
+	post.KarmaWeightedVotes += voterWeight * float64(delta)
+	if e.PostVotes[post.ID] == nil {
+		e.PostVotes[post.ID] = make(map[int64]PostVote)
+	}
+	e.PostVotes[post.ID][voter.ID] = PostVote{Direction: delta, Weight: weight, VoterWeight: voterWeight}
+	e.bumpShared(PostVotesKey(post.ID), int64(delta))
+	if subReddit, exists := e.SubReddits[post.SubReddit]; exists {
+		subReddit.TotalVotes++
+	}
+	e.TotalVotes++
+	e.ActionBreakdown["Votes"]++
+	voter.Actions++
+	e.TotalActions++
+	e.trackVoteVelocity(post)
+	e.rankingPool.invalidate(post)
+	e.publishVote("post", post.SubReddit, post.ID, delta)
+	eventType := "upvote"
+	if delta < 0 {
+		eventType = "downvote"
+	}
+	e.recordEvent(eventType, voter.ID, post.SubReddit, post.ID)
+	e.recordContribution(post.SubReddit, voter, contributionVote)
+}
+
+// SendDirectMessage sends a message, filing it in the recipient's spam
+// folder if it looks like spam and in their message requests if it comes
+// from a stranger. It fails with ErrDMRequestDeclined, counting the message
+// as dropped, if the recipient declined the sender's request, and with a
+// *ValidationError if the content is invalid.
+func (e *Engine) SendDirectMessage(from, to *User, content string) error {
+	return e.SendDirectMessageContext(context.Background(), from, to, content)
+}
+
+// SendDirectMessageContext is SendDirectMessage, traced as a child of the span in ctx.
+func (e *Engine) SendDirectMessageContext(ctx context.Context, from, to *User, content string) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(from) {
+		return ErrUserSuspended
+	}
+	content, err := e.validateContent("message", content, maxMessageLength)
+	if err != nil {
+		return err
+	}
+	now := e.Clock.Now()
+	request, deliver := e.routeMessage(from, to, now)
+	if !deliver {
+		e.DroppedDMs++
+		return ErrDMRequestDeclined
+	}
+	message := Message{From: from, To: to, Content: content, SentAt: now, Spam: e.classifyMessage(from, to, content, now)}
+	if request != nil && !message.Spam {
+		message.Request = true
+		request.Messages++
+	}
+	e.Messages = append(e.Messages, message)
+	e.TotalMessages++
+	e.ActionBreakdown["Messages"]++
+	from.Actions++
+	e.TotalActions++
+	e.recordEvent("message", from.ID, "", to.ID)
+	return nil
+}
+
+// RetrieveMessages returns user's inbox; messages classified as spam are in
+// GetSpamFolder and those from strangers in GetRequestFolder instead.
+func (e *Engine) RetrieveMessages(user *User) []Message {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var userMessages []Message
+	for _, message := range e.Messages {
+		if message.To == user && !message.Spam && !message.Request {
+			userMessages = append(userMessages, message)
+		}
+	}
+	return userMessages
+}
+
+// ReplyToMessage sends content back to the sender of original.
+func (e *Engine) ReplyToMessage(user *User, original Message, content string) error {
+	return e.SendDirectMessage(user, original.From, content)
+}
+
+// inFeeds reports whether post may be shown in listings: it must be neither
+// removed nor awaiting approval or an unlock. Callers must hold e.Mutex.
+func (e *Engine) inFeeds(post *Post) bool {
+	_, removed := e.RemovedPosts[post.ID]
+	_, deleted := e.DeletedPosts[post.ID]
+	return !removed && !deleted && !post.Pending && !post.Embargoed
+}
+
+// GetUserFeed returns every listed post in the user's subreddits in ID
+// order, leaving out NSFW posts for minors. GetSortedFeed ranks them.
+func (e *Engine) GetUserFeed(user *User) []*Post {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	size := 0
+	for _, subreddit := range e.SubReddits {
+		if _, subscribed := subreddit.Users[user.ID]; subscribed {
+			size += len(subreddit.Posts)
+		}
+	}
+	feed := make([]*Post, 0, size)
+	for _, subreddit := range e.SubReddits {
+		if _, subscribed := subreddit.Users[user.ID]; subscribed {
+			for _, post := range subreddit.Posts {
+				if e.inFeeds(post) && !e.ageGated(user, post) {
+					feed = append(feed, post)
+				}
+			}
+		}
+	}
+	slices.SortFunc(feed, func(a, b *Post) int { return cmp.Compare(a.ID, b.ID) })
+	return feed
+}
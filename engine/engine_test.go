@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// newTestSite returns an engine with one subreddit, news, that author and
+// voter have joined.
+func newTestSite(t testing.TB) (e *Engine, author, voter *User) {
+	t.Helper()
+	e = New()
+	var err error
+	if author, err = e.RegisterUser("author"); err != nil {
+		t.Fatal(err)
+	}
+	if voter, err = e.RegisterUser("voter"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.CreateSubReddit("news"); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []*User{author, voter} {
+		if err := e.JoinSubReddit(user, "news"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e, author, voter
+}
+
+func TestVotesReachStoredPost(t *testing.T) {
+	e, author, voter := newTestSite(t)
+	post, err := e.CreatePost(author, "news", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.UpvotePost(voter, post); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := e.SubReddits["news"].Posts
+	if len(posts) != 1 || posts[0] != post {
+		t.Fatalf("subreddit holds %v, want the post CreatePost returned", posts)
+	}
+	indexed, err := e.GetPost(post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexed != post {
+		t.Fatal("GetPost returned a different post than CreatePost")
+	}
+	if posts[0].Votes != 1 {
+		t.Fatalf("stored post has %d votes, want 1", posts[0].Votes)
+	}
+}
+
+func TestVotesReachStoredComment(t *testing.T) {
+	e, author, voter := newTestSite(t)
+	post, err := e.CreatePost(author, "news", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment, err := e.CommentPost(voter, post, "First")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := e.AddReplyToComment(author, comment, "Second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.UpvoteComment(author, comment); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DownvoteComment(voter, reply); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := e.SubReddits["news"].Posts[0]
+	if len(stored.Comments) != 1 || stored.Comments[0] != comment {
+		t.Fatalf("post holds %v, want the comment CommentPost returned", stored.Comments)
+	}
+	if len(comment.Replies) != 1 || comment.Replies[0] != reply {
+		t.Fatalf("comment holds %v, want the reply AddReplyToComment returned", comment.Replies)
+	}
+	for _, want := range []*Comment{comment, reply} {
+		indexed, err := e.GetComment(want.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if indexed != want {
+			t.Fatalf("GetComment(%d) returned a different comment", want.ID)
+		}
+	}
+	if stored.Comments[0].Votes != 1 {
+		t.Fatalf("stored comment has %d votes, want 1", stored.Comments[0].Votes)
+	}
+	if stored.Comments[0].Replies[0].Votes != -1 {
+		t.Fatalf("stored reply has %d votes, want -1", stored.Comments[0].Replies[0].Votes)
+	}
+}
+
+func TestRegisterTakenUsername(t *testing.T) {
+	e, author, _ := newTestSite(t)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	registered := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := e.RegisterUser("newcomer")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				registered++
+			case !errors.Is(err, ErrUsernameTaken):
+				t.Errorf("RegisterUser: %v, want ErrUsernameTaken", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if registered != 1 {
+		t.Errorf("%d users registered as newcomer, want 1", registered)
+	}
+	if err := e.ChangeUsername(author, "writer"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.RegisterUser("author"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("registering a previous username: %v, want ErrUsernameTaken", err)
+	}
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// JSON Export
+
+// ExportedMetrics is the summary ExportJSON writes.
+type ExportedMetrics struct {
+	Users               int
+	SubReddits          int
+	TotalPosts          int
+	TotalVotes          int
+	TotalComments       int
+	TotalMessages       int
+	TotalActions        int
+	DisconnectedUsers   int
+	DurationSeconds     float64
+	ActionBreakdown     map[string]int
+	Reactions           map[string]int
+	SubRedditTimeSeries map[string][]SubRedditSample
+}
+
+// ExportJSON writes the engine's metrics and per-subreddit time series as
+// JSON.
+func (e *Engine) ExportJSON(w io.Writer) error {
+	e.Mutex.RLock()
+	metrics := ExportedMetrics{
+		Users:               len(e.Users),
+		SubReddits:          len(e.SubReddits),
+		TotalPosts:          e.TotalPosts,
+		TotalVotes:          e.TotalVotes,
+		TotalComments:       e.TotalComments,
+		TotalMessages:       e.TotalMessages,
+		TotalActions:        e.TotalActions,
+		DisconnectedUsers:   e.DisconnectedUsers,
+		DurationSeconds:     time.Since(e.StartTime).Seconds(),
+		ActionBreakdown:     make(map[string]int, len(e.ActionBreakdown)),
+		Reactions:           copyCounts(e.ReactionCounts),
+		SubRedditTimeSeries: make(map[string][]SubRedditSample, len(e.TimeSeries)),
+	}
+	for action, count := range e.ActionBreakdown {
+		metrics.ActionBreakdown[action] = count
+	}
+	for name, series := range e.TimeSeries {
+		metrics.SubRedditTimeSeries[name] = append([]SubRedditSample(nil), series...)
+	}
+	e.Mutex.RUnlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(metrics)
+}
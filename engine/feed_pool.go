@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Feed Worker Pool
+
+var ErrFeedPoolClosed = errors.New("feed pool closed")
+
+type feedRequest struct {
+	ctx    context.Context
+	user   *User
+	order  FeedSort
+	result chan []*Post
+}
+
+// FeedPoolStats describes a FeedPool's load. Utilization is the share of
+// worker time spent building feeds.
+type FeedPoolStats struct {
+	Workers     int
+	Submitted   int64
+	Completed   int64
+	TimedOut    int64
+	PeakQueue   int
+	Utilization float64
+}
+
+// FeedPool builds feeds on a fixed set of workers so concurrent feed readers
+// are bounded and each request can time out independently.
+type FeedPool struct {
+	engine   *Engine
+	workers  int
+	requests chan *feedRequest
+	wg       sync.WaitGroup
+	started  time.Time
+	closed   chan struct{}
+	once     sync.Once
+
+	busy      int64
+	submitted int64
+	completed int64
+	timedOut  int64
+	peakMu    sync.Mutex
+	peakQueue int
+}
+
+// NewFeedPool starts workers that build feeds from engine, queueing at
+// most queueSize requests.
+func NewFeedPool(engine *Engine, workers, queueSize int) *FeedPool {
+	pool := &FeedPool{
+		engine:   engine,
+		workers:  workers,
+		requests: make(chan *feedRequest, queueSize),
+		started:  time.Now(),
+		closed:   make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		pool.wg.Add(1)
+		go pool.work()
+	}
+	return pool
+}
+
+func (p *FeedPool) work() {
+	defer p.wg.Done()
+	for req := range p.requests {
+		if req.ctx.Err() != nil {
+			continue
+		}
+		start := time.Now()
+		feed := p.engine.GetSortedFeed(req.user, req.order)
+		atomic.AddInt64(&p.busy, int64(time.Since(start)))
+		atomic.AddInt64(&p.completed, 1)
+		req.result <- feed
+	}
+}
+
+// GetFeed queues a feed build and waits for it, giving up when ctx is done.
+func (p *FeedPool) GetFeed(ctx context.Context, user *User, order FeedSort) ([]*Post, error) {
+	req := &feedRequest{ctx: ctx, user: user, order: order, result: make(chan []*Post, 1)}
+	atomic.AddInt64(&p.submitted, 1)
+	select {
+	case <-p.closed:
+		return nil, ErrFeedPoolClosed
+	default:
+	}
+	select {
+	case p.requests <- req:
+	case <-ctx.Done():
+		atomic.AddInt64(&p.timedOut, 1)
+		return nil, ctx.Err()
+	}
+	p.peakMu.Lock()
+	if depth := len(p.requests); depth > p.peakQueue {
+		p.peakQueue = depth
+	}
+	p.peakMu.Unlock()
+	select {
+	case feed := <-req.result:
+		return feed, nil
+	case <-ctx.Done():
+		atomic.AddInt64(&p.timedOut, 1)
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops accepting requests and waits for queued ones to drain. It must
+// not be called concurrently with GetFeed.
+func (p *FeedPool) Close() {
+	p.once.Do(func() {
+		close(p.closed)
+		close(p.requests)
+	})
+	p.wg.Wait()
+}
+
+// Stats reports the pool's load so far.
+func (p *FeedPool) Stats() FeedPoolStats {
+	p.peakMu.Lock()
+	peak := p.peakQueue
+	p.peakMu.Unlock()
+	stats := FeedPoolStats{
+		Workers:   p.workers,
+		Submitted: atomic.LoadInt64(&p.submitted),
+		Completed: atomic.LoadInt64(&p.completed),
+		TimedOut:  atomic.LoadInt64(&p.timedOut),
+		PeakQueue: peak,
+	}
+	if elapsed := time.Since(p.started); elapsed > 0 && p.workers > 0 {
+		stats.Utilization = float64(atomic.LoadInt64(&p.busy)) / (float64(elapsed) * float64(p.workers))
+	}
+	return stats
+}
@@ -0,0 +1,346 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// File Storage
+
+const (
+	fileStorePut    = 1
+	fileStoreDelete = 2
+
+	// fileStoreCompactSize is the smallest file that is ever compacted.
+	fileStoreCompactSize = 1 << 20
+)
+
+var errFileStoreCorrupt = errors.New("corrupt file store entry")
+
+// fileRecord locates the latest value of one key. size is the whole entry's
+// length, so superseding it can be counted as garbage.
+type fileRecord struct {
+	offset int64
+	length int
+	size   int64
+	sum    uint32
+}
+
+// FileStoreStats describes a FileStore. Unchanged counts puts skipped
+// because the key already held the same record; Garbage is the bytes taken
+// by superseded and deleted entries.
+type FileStoreStats struct {
+	Records   int
+	Writes    int
+	Unchanged int
+	Bytes     int64
+	Garbage   int64
+}
+
+// FileStore is a Store in a single append-only file. Each put or delete is
+// an entry of a varint length, the kind, key and record, and a CRC-32 of
+// them; only an index of where each key's latest record sits is kept in
+// memory, and records are read from disk when listed. Open drops a
+// torn entry left by a crash, and Open and Flush compact the file once more
+// than half of it is garbage.
+type FileStore struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	size      int64
+	live      int64
+	index     map[string]map[string]fileRecord
+	writes    int
+	unchanged int
+}
+
+// OpenFileStore opens the file store at path, creating it if need be.
+func OpenFileStore(path string) (*FileStore, error) {
+	store := &FileStore{path: path}
+	if err := store.open(); err != nil {
+		return nil, err
+	}
+	if err := store.compactIfWasteful(); err != nil {
+		store.file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// open opens the file and rebuilds the index from its entries, truncating
+// any that can't be read back.
+func (s *FileStore) open() error {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file, s.size, s.live = file, 0, 0
+	s.index = make(map[string]map[string]fileRecord)
+	reader := bufio.NewReader(file)
+	for {
+		size, err := s.scanEntry(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if err := file.Truncate(s.size); err != nil {
+				file.Close()
+				return err
+			}
+			break
+		}
+		s.size += size
+	}
+	return nil
+}
+
+// scanEntry reads the entry at s.size into the index and returns its length.
+func (s *FileStore) scanEntry(reader *bufio.Reader) (int64, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, errFileStoreCorrupt
+	}
+	header := int64(uvarintLen(length))
+	entry := make([]byte, length+4)
+	if _, err := io.ReadFull(reader, entry); err != nil {
+		return 0, errFileStoreCorrupt
+	}
+	payload := entry[:length]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(entry[length:]) {
+		return 0, errFileStoreCorrupt
+	}
+	op, kind, key, value, err := decodeFileEntry(payload)
+	if err != nil {
+		return 0, err
+	}
+	size := header + int64(len(entry))
+	switch op {
+	case fileStorePut:
+		offset := s.size + header + int64(len(payload)-len(value))
+		s.setRecord(kind, key, fileRecord{offset: offset, length: len(value), size: size, sum: crc32.ChecksumIEEE(value)})
+	case fileStoreDelete:
+		s.deleteRecord(kind, key)
+	default:
+		return 0, errFileStoreCorrupt
+	}
+	return size, nil
+}
+
+func decodeFileEntry(payload []byte) (op byte, kind, key string, value []byte, err error) {
+	if len(payload) == 0 {
+		return 0, "", "", nil, errFileStoreCorrupt
+	}
+	op, rest := payload[0], payload[1:]
+	var fields [2]string
+	for i := range fields {
+		n, read := binary.Uvarint(rest)
+		if read <= 0 || uint64(len(rest)-read) < n {
+			return 0, "", "", nil, errFileStoreCorrupt
+		}
+		fields[i] = string(rest[read : read+int(n)])
+		rest = rest[read+int(n):]
+	}
+	return op, fields[0], fields[1], rest, nil
+}
+
+func uvarintLen(n uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], n)
+}
+
+// setRecord indexes record as the latest value of key, counting the entry
+// it supersedes as garbage.
+func (s *FileStore) setRecord(kind, key string, record fileRecord) {
+	s.deleteRecord(kind, key)
+	if s.index[kind] == nil {
+		s.index[kind] = make(map[string]fileRecord)
+	}
+	s.index[kind][key] = record
+	s.live += record.size
+}
+
+func (s *FileStore) deleteRecord(kind, key string) {
+	if old, exists := s.index[kind][key]; exists {
+		s.live -= old.size
+		delete(s.index[kind], key)
+	}
+}
+
+// appendEntry writes one entry at the end of the file and returns the
+// record a put entry creates.
+func (s *FileStore) appendEntry(w io.Writer, at int64, op byte, kind, key string, value []byte) (fileRecord, error) {
+	payload := []byte{op}
+	payload = binary.AppendUvarint(payload, uint64(len(kind)))
+	payload = append(payload, kind...)
+	payload = binary.AppendUvarint(payload, uint64(len(key)))
+	payload = append(payload, key...)
+	valueAt := len(payload)
+	payload = append(payload, value...)
+	entry := binary.AppendUvarint(nil, uint64(len(payload)))
+	header := len(entry)
+	entry = append(entry, payload...)
+	entry = binary.LittleEndian.AppendUint32(entry, crc32.ChecksumIEEE(payload))
+	if _, err := w.Write(entry); err != nil {
+		return fileRecord{}, err
+	}
+	return fileRecord{offset: at + int64(header+valueAt), length: len(value), size: int64(len(entry)), sum: crc32.ChecksumIEEE(value)}, nil
+}
+
+func (s *FileStore) Name() string { return "file" }
+
+func (s *FileStore) Put(kind, key string, record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, exists := s.index[kind][key]; exists && old.length == len(record) && old.sum == crc32.ChecksumIEEE(record) {
+		current := make([]byte, old.length)
+		if _, err := s.file.ReadAt(current, old.offset); err != nil {
+			return err
+		}
+		if bytes.Equal(current, record) {
+			s.unchanged++
+			return nil
+		}
+	}
+	written, err := s.appendEntry(io.NewOffsetWriter(s.file, s.size), s.size, fileStorePut, kind, key, record)
+	if err != nil {
+		return err
+	}
+	s.size += written.size
+	s.setRecord(kind, key, written)
+	s.writes++
+	return nil
+}
+
+func (s *FileStore) Delete(kind, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.index[kind][key]; !exists {
+		return nil
+	}
+	written, err := s.appendEntry(io.NewOffsetWriter(s.file, s.size), s.size, fileStoreDelete, kind, key, nil)
+	if err != nil {
+		return err
+	}
+	s.size += written.size
+	s.deleteRecord(kind, key)
+	s.writes++
+	return nil
+}
+
+func (s *FileStore) Each(kind string, visit func(key string, record []byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.each(kind, visit)
+}
+
+func (s *FileStore) each(kind string, visit func(key string, record []byte) error) error {
+	keys := make([]string, 0, len(s.index[kind]))
+	for key := range s.index[kind] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf []byte
+	for _, key := range keys {
+		record := s.index[kind][key]
+		if cap(buf) < record.length {
+			buf = make([]byte, record.length)
+		}
+		buf = buf[:record.length]
+		if _, err := s.file.ReadAt(buf, record.offset); err != nil {
+			return err
+		}
+		if err := visit(key, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush syncs the file to disk, then compacts it if more than half of it is
+// garbage.
+func (s *FileStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	return s.compactIfWasteful()
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// Stats returns the store's record count, write counts and file size.
+func (s *FileStore) Stats() FileStoreStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := FileStoreStats{Writes: s.writes, Unchanged: s.unchanged, Bytes: s.size, Garbage: s.size - s.live}
+	for _, records := range s.index {
+		stats.Records += len(records)
+	}
+	return stats
+}
+
+func (s *FileStore) compactIfWasteful() error {
+	if s.size < fileStoreCompactSize || s.size-s.live <= s.live {
+		return nil
+	}
+	return s.compact()
+}
+
+// compact rewrites the live records to a new file, swaps it in for the old
+// one and reopens it.
+func (s *FileStore) compact() error {
+	temporary := s.path + ".compact"
+	file, err := os.Create(temporary)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	var size int64
+	kinds := make([]string, 0, len(s.index))
+	for kind := range s.index {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		err := s.each(kind, func(key string, record []byte) error {
+			written, err := s.appendEntry(writer, size, fileStorePut, kind, key, record)
+			size += written.size
+			return err
+		})
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary, s.path); err != nil {
+		return err
+	}
+	s.file.Close()
+	return s.open()
+}
@@ -0,0 +1,136 @@
+package engine
+
+import "fmt"
+
+// Happens-Before Checking
+
+// Observation is what one reader saw of the engine in a single critical
+// section: the sequence number of the last logged event and the counters
+// that event left behind.
+type Observation struct {
+	Seq           int
+	TotalActions  int
+	TotalPosts    int
+	TotalComments int
+	TotalVotes    int
+	TotalMessages int
+}
+
+// eventPrefix is the counters the first Seq events of the log account for.
+type eventPrefix struct {
+	actions, posts, comments, votes, messages int
+}
+
+// Observe returns the engine's counters and event sequence number as of one
+// moment, for CheckHappensBefore.
+func (e *Engine) Observe() Observation {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return Observation{
+		Seq:           e.EventSeq,
+		TotalActions:  e.TotalActions,
+		TotalPosts:    e.TotalPosts,
+		TotalComments: e.TotalComments,
+		TotalVotes:    e.TotalVotes,
+		TotalMessages: e.TotalMessages,
+	}
+}
+
+// CheckHappensBefore checks that the operations in the event log appeared
+// atomic to the readers that made sessions: each session is one reader's
+// observations in the order it made them. It returns a description of every
+// violation of the engine's concurrency model:
+//
+//   - Sequence numbers are a total order of operations: consecutive and
+//     never reused, with no event timed before the one ahead of it.
+//   - Every observation is of a prefix of the log: its counters are exactly
+//     what the events up to its Seq account for, so no reader saw an
+//     operation's counters without its event, or half of one operation.
+//   - Observations in one session never go back in the log, so what a
+//     reader did or saw happens before everything it observes later.
+//
+// The counters are rebuilt from the log as ReplayEvents does, so sessions
+// must have been observed after any state the log doesn't describe, such as
+// a loaded world or snapshot, was in place.
+func (e *Engine) CheckHappensBefore(sessions [][]Observation) []string {
+	e.Mutex.RLock()
+	events := append([]Event(nil), e.Events...)
+	e.Mutex.RUnlock()
+
+	var violations []string
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+	prefixes := make([]eventPrefix, len(events)+1)
+	for i, event := range events {
+		if event.Seq != i+1 {
+			fail("event %d has sequence %d", i, event.Seq)
+			return violations
+		}
+		if i > 0 && event.Time.Before(events[i-1].Time) {
+			fail("event %d (%s) is timed before event %d", event.Seq, event.Type, event.Seq-1)
+		}
+		prefix := prefixes[i]
+		switch event.Type {
+		case "post", "repost":
+			prefix.posts++
+		case "comment", "reply":
+			prefix.comments++
+		case "upvote", "downvote", "comment_upvote", "comment_downvote":
+			prefix.votes++
+		case "message":
+			prefix.messages++
+		}
+		if !nonActionEvents[event.Type] {
+			prefix.actions++
+		}
+		prefixes[i+1] = prefix
+	}
+
+	// The log may not start from an empty engine, so counters are compared
+	// by how far they moved from the earliest observation.
+	var base *Observation
+	for _, session := range sessions {
+		for i := range session {
+			if base == nil || session[i].Seq < base.Seq {
+				base = &session[i]
+			}
+		}
+	}
+	if base == nil {
+		return violations
+	}
+	if base.Seq > len(events) {
+		fail("observed sequence %d beyond the %d logged events", base.Seq, len(events))
+		return violations
+	}
+	start := prefixes[base.Seq]
+
+	seen := make(map[Observation]bool)
+	for s, session := range sessions {
+		for i, observed := range session {
+			if i > 0 && observed.Seq < session[i-1].Seq {
+				fail("session %d observed sequence %d after %d", s, observed.Seq, session[i-1].Seq)
+			}
+			if seen[observed] {
+				continue
+			}
+			seen[observed] = true
+			if observed.Seq > len(events) {
+				fail("session %d observed sequence %d beyond the %d logged events", s, observed.Seq, len(events))
+				continue
+			}
+			want, got := prefixes[observed.Seq], eventPrefix{
+				actions:  observed.TotalActions - base.TotalActions + start.actions,
+				posts:    observed.TotalPosts - base.TotalPosts + start.posts,
+				comments: observed.TotalComments - base.TotalComments + start.comments,
+				votes:    observed.TotalVotes - base.TotalVotes + start.votes,
+				messages: observed.TotalMessages - base.TotalMessages + start.messages,
+			}
+			if got != want {
+				fail("session %d at sequence %d saw %+v, want %+v", s, observed.Seq, got, want)
+			}
+		}
+	}
+	return violations
+}
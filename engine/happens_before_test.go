@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// runObservedActions has writers each take actions on their own user
+// while observing the engine around every one, and readers only observe,
+// all at once. It returns every goroutine's observations.
+func runObservedActions(t *testing.T, e *Engine, writers, readers, actions int) [][]Observation {
+	t.Helper()
+	users := make([]*User, writers)
+	for i := range users {
+		user, err := e.RegisterUser(fmt.Sprintf("writer%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.JoinSubReddit(user, "news"); err != nil {
+			t.Fatal(err)
+		}
+		users[i] = user
+	}
+	seed, err := e.CreatePost(users[0], "news", "Seed post")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := make([][]Observation, writers+readers)
+	done := make(chan struct{})
+	var writersWG, readersWG sync.WaitGroup
+	for w, user := range users {
+		writersWG.Add(1)
+		go func() {
+			defer writersWG.Done()
+			session := []Observation{e.Observe()}
+			for i := 0; i < actions; i++ {
+				var err error
+				switch i % 4 {
+				case 0:
+					_, err = e.CreatePost(user, "news", fmt.Sprintf("Post %d by writer %d", i, w))
+				case 1:
+					_, err = e.CommentPost(user, seed, fmt.Sprintf("Comment %d by writer %d", i, w))
+				case 2:
+					if i%8 == 2 {
+						err = e.UpvotePost(user, seed)
+					} else {
+						err = e.DownvotePost(user, seed)
+					}
+				case 3:
+					err = e.SendDirectMessage(user, users[(w+1)%writers], fmt.Sprintf("Message %d from writer %d", i, w))
+				}
+				if err != nil {
+					t.Errorf("writer %d action %d: %v", w, i, err)
+				}
+				session = append(session, e.Observe())
+			}
+			sessions[w] = session
+		}()
+	}
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			var session []Observation
+			for {
+				select {
+				case <-done:
+					sessions[writers+r] = append(session, e.Observe())
+					return
+				default:
+					session = append(session, e.Observe())
+				}
+			}
+		}()
+	}
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+	return sessions
+}
+
+func TestHappensBeforeUnderConcurrentActions(t *testing.T) {
+	const writers = 8
+	e, _, _ := newTestSite(t)
+	sessions := runObservedActions(t, e, writers, 4, 200)
+	for _, violation := range e.CheckHappensBefore(sessions) {
+		t.Error(violation)
+	}
+
+	final := e.Observe()
+	if final.Seq != len(e.Events) {
+		t.Errorf("final observation is at sequence %d, want the %d logged events", final.Seq, len(e.Events))
+	}
+	for r, session := range sessions[writers:] {
+		if last := session[len(session)-1]; last != final {
+			t.Errorf("reader %d ended at %+v after every writer finished, want %+v", r, last, final)
+		}
+	}
+}
+
+func TestHappensBeforeReportsViolations(t *testing.T) {
+	e, _, _ := newTestSite(t)
+	sessions := runObservedActions(t, e, 2, 0, 20)
+	if violations := e.CheckHappensBefore(sessions); len(violations) > 0 {
+		t.Fatalf("clean run reported %v", violations)
+	}
+
+	tests := []struct {
+		name   string
+		forge  func(session []Observation) []Observation
+		remark string
+	}{
+		{"torn counters", func(session []Observation) []Observation {
+			session[len(session)/2].TotalPosts++
+			return session
+		}, "a counter without its event"},
+		{"backwards", func(session []Observation) []Observation {
+			return append(session, session[0])
+		}, "a reader going back in the log"},
+		{"beyond the log", func(session []Observation) []Observation {
+			ahead := session[len(session)-1]
+			ahead.Seq += 10
+			return append(session, ahead)
+		}, "a sequence never logged"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			forged := append([][]Observation{test.forge(append([]Observation(nil), sessions[0]...))}, sessions[1:]...)
+			if len(e.CheckHappensBefore(forged)) == 0 {
+				t.Errorf("checker missed %s", test.remark)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Latency Histograms
+
+// histogramSubBits sets the histogram's precision: each power of two of
+// nanoseconds is split into 1<<histogramSubBits buckets.
+const (
+	histogramSubBits    = 5
+	histogramSubBuckets = 1 << histogramSubBits
+	histogramBuckets    = (64 - histogramSubBits) * histogramSubBuckets
+)
+
+// LatencyHistogram counts durations the way an HDR histogram does: every
+// power of two of nanoseconds is split into 32 equal buckets, so a
+// recorded value is known to within about 3% whatever its magnitude, in a
+// fixed 15 KB. It isn't safe for concurrent use.
+type LatencyHistogram struct {
+	counts [histogramBuckets]int64
+	total  int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// histogramBucket is the bucket holding a value of ns nanoseconds.
+func histogramBucket(ns uint64) int {
+	if ns < histogramSubBuckets {
+		return int(ns)
+	}
+	shift := bits.Len64(ns) - 1 - histogramSubBits
+	return (shift+1)*histogramSubBuckets + int(ns>>shift) - histogramSubBuckets
+}
+
+// histogramUpper is the largest value bucket i holds, in nanoseconds.
+func histogramUpper(i int) uint64 {
+	if i < histogramSubBuckets {
+		return uint64(i)
+	}
+	shift := i/histogramSubBuckets - 1
+	low := uint64(i%histogramSubBuckets+histogramSubBuckets) << shift
+	return low + 1<<shift - 1
+}
+
+// Record counts one duration. Negative durations count as zero.
+func (h *LatencyHistogram) Record(d time.Duration) {
+	d = max(d, 0)
+	h.counts[histogramBucket(uint64(d))]++
+	h.total++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+// Merge adds every duration other counted.
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	h.total += other.total
+	h.sum += other.sum
+	h.max = max(h.max, other.max)
+}
+
+// Count is how many durations were recorded.
+func (h *LatencyHistogram) Count() int64 {
+	return h.total
+}
+
+// Sum is the total of the durations recorded.
+func (h *LatencyHistogram) Sum() time.Duration {
+	return h.sum
+}
+
+// Max is the longest duration recorded.
+func (h *LatencyHistogram) Max() time.Duration {
+	return h.max
+}
+
+// Percentile returns the duration at or below which a share p of the
+// recorded durations fall, rounded up to its bucket's upper bound, or 0 if
+// none were recorded.
+func (h *LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := max(int64(math.Ceil(p*float64(h.total))), 1)
+	seen := int64(0)
+	for i, count := range h.counts {
+		if seen += count; seen >= rank {
+			return min(time.Duration(histogramUpper(i)), h.max)
+		}
+	}
+	return h.max
+}
+
+// cumulative returns how many recorded durations fall at or below each of
+// bounds, which must be ascending. A bucket counts toward a bound only if it
+// lies wholly below it, so counts are as precise as the buckets.
+func (h *LatencyHistogram) cumulative(bounds []time.Duration) []int64 {
+	counts := make([]int64, len(bounds))
+	seen, next := int64(0), 0
+	for i, count := range h.counts {
+		for next < len(bounds) && histogramUpper(i) > uint64(bounds[next]) {
+			counts[next] = seen
+			next++
+		}
+		if next == len(bounds) {
+			break
+		}
+		seen += count
+	}
+	for ; next < len(bounds); next++ {
+		counts[next] = seen
+	}
+	return counts
+}
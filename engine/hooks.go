@@ -0,0 +1,119 @@
+package engine
+
+import "sync/atomic"
+
+// Event Hooks
+
+// EventHook receives events from the event log as they are recorded.
+type EventHook func(Event)
+
+// hookWorker delivers events to one hook on its own goroutine so slow hooks
+// never run under the engine lock. Events that don't fit in the buffer are
+// dropped and counted.
+type hookWorker struct {
+	fn        EventHook
+	events    chan Event
+	emitted   int64
+	delivered int64
+	dropped   int64
+	restarts  int64
+}
+
+// HookStats counts one hook's deliveries.
+type HookStats struct {
+	Emitted   int64
+	Delivered int64
+	Dropped   int64
+	Restarts  int64
+}
+
+// AddEventHook delivers every new event to fn on its own goroutine,
+// buffering up to buffer events and dropping those that don't fit.
+func (e *Engine) AddEventHook(fn EventHook, buffer int) {
+	hook := &hookWorker{fn: fn, events: make(chan Event, buffer)}
+	e.Mutex.Lock()
+	e.hooks = append(e.hooks, hook)
+	e.Mutex.Unlock()
+	e.hookWG.Add(1)
+	go e.superviseHook(hook)
+}
+
+// dispatchEvent hands an event to every hook. Callers must hold e.Mutex.
+func (e *Engine) dispatchEvent(event Event) {
+	for _, hook := range e.hooks {
+		hook.emitted++
+		if e.chaos().shouldDropHook() {
+			atomic.AddInt64(&hook.dropped, 1)
+			continue
+		}
+		select {
+		case hook.events <- event:
+		default:
+			atomic.AddInt64(&hook.dropped, 1)
+		}
+	}
+}
+
+// superviseHook restarts the hook's worker whenever it is killed, handing the
+// restarted worker the event that was in flight.
+func (e *Engine) superviseHook(hook *hookWorker) {
+	defer e.hookWG.Done()
+	var pending *Event
+	for hook.run(&pending, e.chaos()) {
+		atomic.AddInt64(&hook.restarts, 1)
+	}
+}
+
+func (hook *hookWorker) run(pending **Event, chaos *Chaos) (killed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errWorkerKilled {
+				panic(r)
+			}
+			killed = true
+		}
+	}()
+	for {
+		if *pending == nil {
+			event, ok := <-hook.events
+			if !ok {
+				return false
+			}
+			*pending = &event
+		}
+		chaos.maybeKillWorker()
+		hook.fn(**pending)
+		*pending = nil
+		atomic.AddInt64(&hook.delivered, 1)
+	}
+}
+
+// CloseEventHooks stops accepting events and waits for every hook to drain.
+func (e *Engine) CloseEventHooks() {
+	e.Mutex.Lock()
+	hooks := e.hooks
+	e.hooks = nil
+	e.closedHooks = append(e.closedHooks, hooks...)
+	e.Mutex.Unlock()
+	for _, hook := range hooks {
+		close(hook.events)
+	}
+	e.hookWG.Wait()
+}
+
+// HookStats reports every hook, closed ones first, in the order they were
+// added.
+func (e *Engine) HookStats() []HookStats {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var stats []HookStats
+	for _, hook := range append(append([]*hookWorker(nil), e.closedHooks...), e.hooks...) {
+		stats = append(stats, HookStats{
+			Emitted:   hook.emitted,
+			Delivered: atomic.LoadInt64(&hook.delivered),
+			Dropped:   atomic.LoadInt64(&hook.dropped),
+			Restarts:  atomic.LoadInt64(&hook.restarts),
+		})
+	}
+	return stats
+}
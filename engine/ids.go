@@ -0,0 +1,36 @@
+package engine
+
+import "errors"
+
+// ID Space
+
+var (
+	ErrIDSpaceInUse    = errors.New("IDs have already been allocated")
+	ErrInvalidIDOffset = errors.New("ID offset must not be negative")
+)
+
+// tenantIDStride separates the ID spaces of tenants on one host. At a
+// million IDs a second a tenant would take over thirty years to run into the
+// next tenant's range.
+const tenantIDStride int64 = 1 << 50
+
+// SetIDOffset makes every ID sequence (users, posts, comments,
+// notifications) start just after offset, so engines whose data ends up in
+// one place can be given disjoint ranges. It must be called before any ID
+// has been handed out.
+func (e *Engine) SetIDOffset(offset int64) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if offset < 0 {
+		return ErrInvalidIDOffset
+	}
+	if e.UserID != e.IDOffset+1 || e.PostID != e.IDOffset+1 || e.CommentID != e.IDOffset+1 || e.NotificationID != e.IDOffset {
+		return ErrIDSpaceInUse
+	}
+	e.IDOffset = offset
+	e.UserID = offset + 1
+	e.PostID = offset + 1
+	e.CommentID = offset + 1
+	e.NotificationID = offset
+	return nil
+}
@@ -0,0 +1,48 @@
+package engine
+
+import "math"
+
+// Interest Vectors
+
+const (
+	postInterestWeight    = 2.0
+	commentInterestWeight = 1.0
+)
+
+// recordInterest credits user with affinity for a subreddit. Only authored
+// posts and comments contribute.
+// Callers must hold e.Mutex.
+func (e *Engine) recordInterest(user *User, subRedditName string, weight float64) {
+	if subRedditName == "" {
+		return
+	}
+	vector, exists := e.Interests[user.ID]
+	if !exists {
+		vector = make(map[string]float64)
+		e.Interests[user.ID] = vector
+	}
+	vector[subRedditName] += weight
+}
+
+// interestVector returns the user's affinities normalized so the strongest is
+// 1. Callers must hold e.Mutex.
+func (e *Engine) interestVector(user *User) map[string]float64 {
+	raw := e.Interests[user.ID]
+	max := 0.0
+	for _, weight := range raw {
+		max = math.Max(max, weight)
+	}
+	vector := make(map[string]float64, len(raw))
+	for name, weight := range raw {
+		vector[name] = weight / max
+	}
+	return vector
+}
+
+// GetInterestVector returns the user's learned affinity for each
+// subreddit, normalized so the strongest is 1.
+func (e *Engine) GetInterestVector(user *User) map[string]float64 {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.interestVector(user)
+}
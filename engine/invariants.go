@@ -0,0 +1,72 @@
+package engine
+
+import "fmt"
+
+// Invariants
+
+// CheckInvariants recounts engine state and returns a description of every
+// invariant that doesn't hold. Hook accounting is only checked for hooks that
+// have been closed and drained.
+func (e *Engine) CheckInvariants() []string {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.checkInvariants()
+}
+
+// checkInvariants implements CheckInvariants. Callers must hold e.Mutex.
+func (e *Engine) checkInvariants() []string {
+	var violations []string
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	if e.TotalMessages != len(e.Messages) {
+		fail("TotalMessages %d != %d stored messages", e.TotalMessages, len(e.Messages))
+	}
+	for counter, total := range map[string]int{
+		"Posts":    e.TotalPosts,
+		"Comments": e.TotalComments,
+		"Votes":    e.TotalVotes,
+		"Messages": e.TotalMessages,
+	} {
+		if e.ActionBreakdown[counter] != total {
+			fail("ActionBreakdown[%s] %d != total %d", counter, e.ActionBreakdown[counter], total)
+		}
+	}
+	if e.PostID-e.IDOffset-1 != int64(e.TotalPosts) {
+		fail("next post ID %d doesn't follow %d posts", e.PostID, e.TotalPosts)
+	}
+	if e.CommentID-e.IDOffset-1 != int64(e.TotalComments) {
+		fail("next comment ID %d doesn't follow %d comments", e.CommentID, e.TotalComments)
+	}
+
+	subRedditPosts := 0
+	for name, subReddit := range e.SubReddits {
+		subRedditPosts += subReddit.TotalPosts
+		for id, member := range subReddit.Users {
+			if e.Users[id] != member {
+				fail("%s has member %d that isn't a registered user", name, id)
+			}
+		}
+	}
+	if subRedditPosts != e.TotalPosts {
+		fail("subreddit post counts sum to %d, want %d", subRedditPosts, e.TotalPosts)
+	}
+
+	if e.EventSeq != len(e.Events) {
+		fail("event sequence %d != %d logged events", e.EventSeq, len(e.Events))
+	}
+	for i, event := range e.Events {
+		if event.Seq != i+1 {
+			fail("event %d has sequence %d", i, event.Seq)
+			break
+		}
+	}
+
+	for i, hook := range e.closedHooks {
+		if hook.emitted != hook.delivered+hook.dropped {
+			fail("hook %d emitted %d events but delivered %d and dropped %d", i, hook.emitted, hook.delivered, hook.dropped)
+		}
+	}
+	return violations
+}
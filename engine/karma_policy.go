@@ -0,0 +1,71 @@
+package engine
+
+import "time"
+
+// Karma Policy
+
+// VoteDecay scales the effect of votes on old posts. Votes on posts younger
+// than FullWeightAge count fully, votes on posts older than ZeroWeightAge
+// count for nothing, and the weight falls linearly in between. The zero value
+// disables decay.
+type VoteDecay struct {
+	FullWeightAge time.Duration
+	ZeroWeightAge time.Duration
+}
+
+// Weight is how much a vote on a post of the given age counts, in [0, 1].
+func (d VoteDecay) Weight(age time.Duration) float64 {
+	if d.ZeroWeightAge <= 0 || age <= d.FullWeightAge {
+		return 1
+	}
+	if age >= d.ZeroWeightAge {
+		return 0
+	}
+	return 1 - float64(age-d.FullWeightAge)/float64(d.ZeroWeightAge-d.FullWeightAge)
+}
+
+// SetVoteDecay sets how votes lose weight as posts age. It applies to votes
+// cast from then on.
+func (e *Engine) SetVoteDecay(decay VoteDecay) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.VoteDecay = decay
+}
+
+// applyPostVote records a vote of delta on post and returns the weight it
+// counted for. The raw tally always moves by delta; the weighted tally used
+// for hot ranking and the author's karma move by the decayed weight.
+// Callers must hold e.Mutex.
+func (e *Engine) applyPostVote(post *Post, delta int) float64 {
+	weight := e.VoteDecay.Weight(e.Clock.Now().Sub(post.CreatedAt))
+	post.Votes += delta
+	post.WeightedVotes += weight * float64(delta)
+	if weight < 1 {
+		e.DecayedVotes++
+		e.WithheldKarma += 1 - weight
+	}
+	e.creditPostKarma(post.Author, weight*float64(delta))
+	return weight
+}
+
+// creditPostKarma adds amount to author's post karma. Fractional karma is
+// carried per author until it adds up to a whole point. Callers must hold
+// e.Mutex.
+func (e *Engine) creditPostKarma(author *User, amount float64) {
+	credit := e.karmaCredit[author.ID] + amount
+	whole := int(credit)
+	e.karmaCredit[author.ID] = credit - float64(whole)
+	if whole == 0 {
+		return
+	}
+	author.PostKarma += whole
+	e.bumpShared(UserKarmaKey(author.ID), int64(whole))
+	if whole > 0 {
+		e.checkKarmaMilestones(author)
+	}
+}
+
+// Karma is the user's total karma, from posts and comments alike.
+func (u *User) Karma() int {
+	return u.PostKarma + u.CommentKarma
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Per-SubReddit Karma Requirements
+
+var ErrInsufficientKarma = errors.New("not enough comment karma in this subreddit to post")
+
+// KarmaRequirementError reports how far a user is from a subreddit's
+// MinCommentKarmaToPost. It matches ErrInsufficientKarma with errors.Is.
+type KarmaRequirementError struct {
+	SubReddit string
+	Required  int
+	Have      int
+}
+
+func (err *KarmaRequirementError) Error() string {
+	return fmt.Sprintf("posting in %s needs %d comment karma earned there, have %d", err.SubReddit, err.Required, err.Have)
+}
+
+func (err *KarmaRequirementError) Unwrap() error {
+	return ErrInsufficientKarma
+}
+
+// creditCommentKarma adds delta to the comment author's comment karma, both
+// overall and within the comment's subreddit. Callers must hold e.Mutex.
+func (e *Engine) creditCommentKarma(comment *Comment, delta int) {
+	author := comment.Author
+	if author == nil {
+		return
+	}
+	author.CommentKarma += delta
+	e.bumpShared(UserKarmaKey(author.ID), int64(delta))
+	if delta > 0 {
+		e.checkKarmaMilestones(author)
+	}
+	if subReddit, exists := e.SubReddits[comment.SubReddit]; exists {
+		subReddit.CommentKarma[author.ID] += delta
+	}
+}
+
+// checkPostKarma enforces subReddit's MinCommentKarmaToPost. Moderators are
+// exempt. Callers must hold e.Mutex.
+func (e *Engine) checkPostKarma(user *User, subReddit *SubReddit) error {
+	required := subReddit.Settings.MinCommentKarmaToPost
+	if required <= 0 || e.isModerator(user, subReddit) {
+		return nil
+	}
+	if have := subReddit.CommentKarma[user.ID]; have < required {
+		e.KarmaGatedPosts++
+		return &KarmaRequirementError{SubReddit: subReddit.Name, Required: required, Have: have}
+	}
+	return nil
+}
+
+// GetSubRedditCommentKarma returns the comment karma user has earned in the
+// subreddit.
+func (e *Engine) GetSubRedditCommentKarma(user *User, subRedditName string) (int, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return 0, ErrSubRedditNotFound
+	}
+	return subReddit.CommentKarma[user.ID], nil
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Link Posts
+
+const defaultDuplicateWindow = 24 * time.Hour
+
+var (
+	ErrInvalidURL   = errors.New("invalid URL")
+	ErrDuplicateURL = errors.New("URL already submitted")
+)
+
+// DuplicateURLError is returned with the existing post when a URL was already
+// submitted to the subreddit within the duplicate window. It matches
+// ErrDuplicateURL with errors.Is.
+type DuplicateURLError struct {
+	URL      string
+	Existing *Post
+}
+
+func (err *DuplicateURLError) Error() string {
+	return fmt.Sprintf("%s was already submitted as post %d", err.URL, err.Existing.ID)
+}
+
+func (err *DuplicateURLError) Unwrap() error {
+	return ErrDuplicateURL
+}
+
+type linkSubmission struct {
+	postID      int64
+	submittedAt time.Time
+}
+
+// normalizeURL reduces trivially different spellings of a link to one key:
+// scheme and host are lowercased, a leading "www." and any fragment are
+// dropped, and trailing slashes are trimmed.
+func normalizeURL(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", ErrInvalidURL
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	path := strings.TrimRight(parsed.EscapedPath(), "/")
+	normalized := host + path
+	if parsed.RawQuery != "" {
+		normalized += "?" + parsed.RawQuery
+	}
+	return normalized, nil
+}
+
+// CreateLinkPost submits a link. If the same URL was submitted to the
+// subreddit within the duplicate window, it returns the existing post along
+// with a *DuplicateURLError instead of creating a new one.
+func (e *Engine) CreateLinkPost(user *User, subRedditName, title, link string) (*Post, error) {
+	return e.CreateLinkPostContext(context.Background(), user, subRedditName, title, link)
+}
+
+// CreateLinkPostContext is CreateLinkPost, traced as a child of the span in ctx.
+func (e *Engine) CreateLinkPostContext(ctx context.Context, user *User, subRedditName, title, link string) (*Post, error) {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return nil, ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if e.isBanned(user, subReddit) {
+		return nil, ErrBannedFromSubReddit
+	}
+	if err := e.checkPostKarma(user, subReddit); err != nil {
+		return nil, err
+	}
+	if e.overQuota(e.Quota.MaxPosts, e.TotalPosts) {
+		return nil, ErrQuotaExceeded
+	}
+	title, err := e.validateContent("title", title, maxTitleLength)
+	if err != nil {
+		return nil, err
+	}
+	key, err := normalizeURL(link)
+	if err != nil {
+		return nil, err
+	}
+	if previous, seen := subReddit.Links[key]; seen && e.Clock.Now().Sub(previous.submittedAt) < e.DuplicateWindow {
+		for _, existing := range subReddit.Posts {
+			if existing.ID == previous.postID {
+				e.DedupHits++
+				return existing, &DuplicateURLError{URL: link, Existing: existing}
+			}
+		}
+	}
+	post := e.insertPost(subReddit, Post{Author: user, Content: title, URL: link}, "post")
+	subReddit.Links[key] = linkSubmission{postID: post.ID, submittedAt: post.CreatedAt}
+	return post, nil
+}
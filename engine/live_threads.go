@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Live Threads
+
+var (
+	ErrNotLiveThread     = errors.New("post is not a live thread")
+	ErrAlreadyLiveThread = errors.New("post is already a live thread")
+)
+
+// LiveComment is a new comment on a live thread as its watchers receive it.
+// ParentID is 0 for top-level comments; Participants is how many users had
+// commented on the thread once it was posted.
+type LiveComment struct {
+	Seq          int64
+	PostID       int64
+	CommentID    int64
+	ParentID     int64
+	Author       string
+	Content      string
+	CreatedAt    time.Time
+	Participants int
+}
+
+// LiveThreadStats describes a live thread: the comments and distinct
+// commenters since it went live, its open streams, and the comments handed
+// to streams or dropped because a watcher fell behind.
+type LiveThreadStats struct {
+	StartedAt    time.Time
+	Comments     int
+	Participants int
+	Watchers     int
+	Delivered    int64
+	Dropped      int64
+}
+
+// liveThread is one live post's state, guarded by e.Mutex.
+type liveThread struct {
+	startedAt    time.Time
+	previousSort CommentSort
+	participants map[int64]bool
+	comments     int
+	seq          int64
+	delivered    int64
+	dropped      int64
+	watchers     []*LiveThreadStream
+}
+
+// LiveThreadStream delivers a live thread's new comments in the order they
+// were posted. Comments that don't fit in its buffer are dropped rather than
+// holding up the commenter, so a watcher that falls behind misses some and
+// should reload the thread from GetSortedComments. C is closed when the
+// thread ends or the stream is closed.
+type LiveThreadStream struct {
+	C <-chan LiveComment
+
+	out    chan LiveComment
+	mu     sync.Mutex
+	closed bool
+}
+
+// StartLiveThread puts post in live mode: its comments sort newest first,
+// and each new one is streamed to the thread's watchers as it is posted.
+// Only the post's author or a moderator of its subreddit may start one.
+// Live mode isn't saved in snapshots.
+func (e *Engine) StartLiveThread(user *User, post *Post) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if post.Author != user && !e.isModerator(user, subReddit) {
+		return ErrNotModerator
+	}
+	if e.liveThreads[post.ID] != nil {
+		return ErrAlreadyLiveThread
+	}
+	e.liveThreads[post.ID] = &liveThread{startedAt: e.Clock.Now(), previousSort: post.CommentSort, participants: make(map[int64]bool)}
+	post.CommentSort = CommentSortNew
+	e.CommentSorts[post.ID] = CommentSortNew
+	e.recordEvent("start_live_thread", user.ID, post.SubReddit, post.ID)
+	return nil
+}
+
+// EndLiveThread takes post out of live mode, restoring the comment sort it
+// had before and closing every stream watching it.
+func (e *Engine) EndLiveThread(user *User, post *Post) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if post.Author != user && !e.isModerator(user, subReddit) {
+		return ErrNotModerator
+	}
+	thread := e.liveThreads[post.ID]
+	if thread == nil {
+		return ErrNotLiveThread
+	}
+	delete(e.liveThreads, post.ID)
+	post.CommentSort = thread.previousSort
+	if thread.previousSort == CommentSortTop {
+		delete(e.CommentSorts, post.ID)
+	} else {
+		e.CommentSorts[post.ID] = thread.previousSort
+	}
+	for _, stream := range thread.watchers {
+		stream.Close()
+	}
+	e.recordEvent("end_live_thread", user.ID, post.SubReddit, post.ID)
+	return nil
+}
+
+// SubscribeLiveThread opens a stream of post's new comments from now on,
+// buffering up to buffer of them.
+func (e *Engine) SubscribeLiveThread(post *Post, buffer int) (*LiveThreadStream, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	thread := e.liveThreads[post.ID]
+	if thread == nil {
+		return nil, ErrNotLiveThread
+	}
+	out := make(chan LiveComment, buffer)
+	stream := &LiveThreadStream{C: out, out: out}
+	thread.watchers = append(thread.watchers, stream)
+	return stream, nil
+}
+
+// GetLiveThreadStats describes the live thread on post.
+func (e *Engine) GetLiveThreadStats(post *Post) (LiveThreadStats, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	thread := e.liveThreads[post.ID]
+	if thread == nil {
+		return LiveThreadStats{}, ErrNotLiveThread
+	}
+	stats := LiveThreadStats{StartedAt: thread.startedAt, Comments: thread.comments, Participants: len(thread.participants), Delivered: thread.delivered, Dropped: thread.dropped}
+	for _, stream := range thread.watchers {
+		if stream.open() {
+			stats.Watchers++
+		}
+	}
+	return stats, nil
+}
+
+// publishLiveComment streams comment to the watchers of its post if the
+// post is live, forgetting streams that were closed. Callers must hold
+// e.Mutex.
+func (e *Engine) publishLiveComment(comment *Comment, parentID int64) {
+	thread := e.liveThreads[comment.PostID]
+	if thread == nil {
+		return
+	}
+	thread.comments++
+	thread.participants[comment.Author.ID] = true
+	thread.seq++
+	live := LiveComment{
+		Seq:          thread.seq,
+		PostID:       comment.PostID,
+		CommentID:    comment.ID,
+		ParentID:     parentID,
+		Author:       comment.Author.Username,
+		Content:      comment.Content(),
+		CreatedAt:    comment.CreatedAt,
+		Participants: len(thread.participants),
+	}
+	open := thread.watchers[:0]
+	for _, stream := range thread.watchers {
+		switch delivered, ok := stream.send(live); {
+		case !ok:
+			continue
+		case delivered:
+			thread.delivered++
+		default:
+			thread.dropped++
+		}
+		open = append(open, stream)
+	}
+	clear(thread.watchers[len(open):])
+	thread.watchers = open
+}
+
+// send offers comment to the stream without waiting, reporting whether it
+// was buffered and whether the stream is still open.
+func (s *LiveThreadStream) send(comment LiveComment) (delivered, open bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false, false
+	}
+	select {
+	case s.out <- comment:
+		return true, true
+	default:
+		return false, true
+	}
+}
+
+func (s *LiveThreadStream) open() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.closed
+}
+
+// Close stops the stream and closes C. Comments already buffered can still
+// be read from it.
+func (s *LiveThreadStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.out)
+	}
+}
@@ -0,0 +1,39 @@
+package engine
+
+import "sync"
+
+// Lock Hierarchy
+//
+// Locks are taken in this order, and a goroutine never takes one while
+// holding a lock below it:
+//
+//  1. e.Mutex, the engine lock. Anything that changes shared state, which
+//     is every action that records an event, takes it for writing; queries
+//     take its read lock and run alongside each other.
+//  2. SubReddit.mu, a subreddit's lock, for the traffic counters queries
+//     update while reading a subreddit.
+//  3. e.postLock(id), the lock of a shard of posts, for the promotion
+//     budgets and counters that serving and clicking promoted posts update.
+//
+// Locks 2 and 3 are only needed under the read lock: a writer excludes
+// every other holder of e.Mutex, so it may touch what they guard freely.
+// Leaf locks owned by a single component, such as the cold store's and the
+// file store's, may be taken under any of these.
+//
+// Writes are not sharded: every write, whatever subreddit or post it
+// touches, serializes on e.Mutex. Each action appends to the one event log
+// and updates global vote, karma and provenance indexes, so those would have
+// to be split before writers could lock per subreddit or per post. Locks 2
+// and 3 only let queries run alongside each other.
+
+// postLockShards is how many locks the posts are spread over.
+const postLockShards = 64
+
+// postLocks is a sharded lock keyed by post ID.
+type postLocks [postLockShards]sync.Mutex
+
+// postLock returns the lock guarding the post numbered id. Callers must
+// hold e.Mutex's read lock.
+func (e *Engine) postLock(id int64) *sync.Mutex {
+	return &e.postLocks[uint64(id)%postLockShards]
+}
@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logging
+
+// e.Logger defaults to slog.Default() as it was when the engine was made.
+// Every recorded event is logged to it at debug level, so a run only pays
+// for them when debug logging is on.
+
+// logEvent logs an event at debug level with its action, user and
+// subreddit. Callers must hold e.Mutex.
+func (e *Engine) logEvent(event Event) {
+	if !e.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("action", event.Type)}
+	if event.UserID != 0 {
+		attrs = append(attrs, slog.Int64("user", event.UserID))
+	}
+	if event.SubReddit != "" {
+		attrs = append(attrs, slog.String("subreddit", event.SubReddit))
+	}
+	if event.TargetID != 0 {
+		attrs = append(attrs, slog.Int64("target", event.TargetID))
+	}
+	e.Logger.LogAttrs(context.Background(), slog.LevelDebug, "event", attrs...)
+}
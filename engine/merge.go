@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Account Merging
+
+var (
+	ErrSameAccount   = errors.New("cannot merge an account into itself")
+	ErrAccountMerged = errors.New("account has been merged into another")
+)
+
+// MergeStats counts what MergeAccounts moved to the primary account.
+type MergeStats struct {
+	Karma         int
+	Subscriptions int
+	Posts         int
+	Comments      int
+	Messages      int
+	Notifications int
+}
+
+// MergeAccounts folds duplicate into primary: karma, subscriptions,
+// moderator seats, posts, comments, votes, messages, follows, message
+// requests, notifications, reactions, interests, mutes and milestones all
+// move to primary, and
+// duplicate's usernames resolve to primary from then on. Archived posts and
+// comments are rewritten in cold storage as primary's first; if that fails,
+// nothing else is merged. Where both accounts voted on the same post, only
+// primary's vote stands. Warnings, bans and ban appeals carry over too, so
+// merging can't be used to shed them. duplicate stays in e.Users as a
+// tombstone with MergedInto set so IDs in logs still resolve; it can no
+// longer act.
+func (e *Engine) MergeAccounts(primary, duplicate *User) (MergeStats, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if primary == duplicate {
+		return MergeStats{}, ErrSameAccount
+	}
+	if primary.MergedInto != 0 || duplicate.MergedInto != 0 {
+		return MergeStats{}, ErrAccountMerged
+	}
+	archivedPosts, archivedComments, err := e.reassignArchived(primary, duplicate)
+	if err != nil {
+		return MergeStats{}, err
+	}
+	karma := duplicate.Karma()
+	stats := MergeStats{Karma: karma, Posts: archivedPosts, Comments: archivedComments}
+
+	primary.PostKarma += duplicate.PostKarma
+	primary.CommentKarma += duplicate.CommentKarma
+	primary.Actions += duplicate.Actions
+	primary.Activity.add(duplicate.Activity)
+	primary.IsAdmin = primary.IsAdmin || duplicate.IsAdmin
+	if duplicate.CreatedAt.Before(primary.CreatedAt) {
+		primary.CreatedAt = duplicate.CreatedAt
+	}
+	if duplicate.SuspendedUntil.After(primary.SuspendedUntil) {
+		primary.SuspendedUntil = duplicate.SuspendedUntil
+	}
+	if primary.DisplayName == "" && primary.AvatarURL == "" && primary.Bio == "" {
+		primary.DisplayName, primary.AvatarURL, primary.Bio = duplicate.DisplayName, duplicate.AvatarURL, duplicate.Bio
+	}
+	e.bumpShared(UserKarmaKey(primary.ID), int64(karma))
+	e.bumpShared(UserKarmaKey(duplicate.ID), -int64(karma))
+	e.karmaCredit[primary.ID] += e.karmaCredit[duplicate.ID]
+	delete(e.karmaCredit, duplicate.ID)
+
+	for _, name := range append(duplicate.PreviousUsernames, duplicate.Username) {
+		e.Usernames[name] = primary.ID
+		primary.PreviousUsernames = append(primary.PreviousUsernames, name)
+	}
+
+	for _, subReddit := range e.SubReddits {
+		if _, member := subReddit.Users[duplicate.ID]; member {
+			if _, already := subReddit.Users[primary.ID]; !already {
+				subReddit.Users[primary.ID] = primary
+				if subReddit.DefaultMembers[duplicate.ID] {
+					subReddit.DefaultMembers[primary.ID] = true
+				}
+				stats.Subscriptions++
+			}
+			delete(subReddit.Users, duplicate.ID)
+			delete(subReddit.DefaultMembers, duplicate.ID)
+		}
+		if _, mod := subReddit.Moderators[duplicate.ID]; mod {
+			subReddit.Moderators[primary.ID] = primary
+			delete(subReddit.Moderators, duplicate.ID)
+		}
+		if warnings, warned := subReddit.Warnings[duplicate.ID]; warned {
+			subReddit.Warnings[primary.ID] += warnings
+			delete(subReddit.Warnings, duplicate.ID)
+		}
+		if karma, earned := subReddit.CommentKarma[duplicate.ID]; earned {
+			subReddit.CommentKarma[primary.ID] += karma
+			delete(subReddit.CommentKarma, duplicate.ID)
+		}
+		if until, banned := subReddit.Banned[duplicate.ID]; banned {
+			if current, primaryBanned := subReddit.Banned[primary.ID]; !primaryBanned || banOutlasts(until, current) {
+				subReddit.Banned[primary.ID] = until
+				subReddit.BanIDs[primary.ID] = subReddit.BanIDs[duplicate.ID]
+			}
+			e.unban(subReddit, duplicate.ID)
+		}
+		for _, appeal := range subReddit.Appeals {
+			if appeal.UserID == duplicate.ID {
+				appeal.UserID = primary.ID
+			}
+		}
+		for _, post := range subReddit.Posts {
+			if post.Author == duplicate {
+				post.Author = primary
+				stats.Posts++
+			}
+			e.mergePostVotes(post, primary, duplicate)
+			stats.Comments += e.reassignComments(post.Comments, primary, duplicate)
+		}
+	}
+
+	for i := range e.Messages {
+		moved := false
+		if e.Messages[i].From == duplicate {
+			e.Messages[i].From, moved = primary, true
+		}
+		if e.Messages[i].To == duplicate {
+			e.Messages[i].To, moved = primary, true
+		}
+		if moved {
+			stats.Messages++
+		}
+	}
+	e.mergeFollows(primary, duplicate)
+
+	for _, inbox := range []map[int64][]Notification{e.Notifications, e.PendingNotifications} {
+		for _, notification := range inbox[duplicate.ID] {
+			notification.UserID = primary.ID
+			inbox[primary.ID] = append(inbox[primary.ID], notification)
+			stats.Notifications++
+		}
+		delete(inbox, duplicate.ID)
+	}
+
+	for topic, weight := range e.Interests[duplicate.ID] {
+		if e.Interests[primary.ID] == nil {
+			e.Interests[primary.ID] = make(map[string]float64)
+		}
+		e.Interests[primary.ID][topic] += weight
+	}
+	delete(e.Interests, duplicate.ID)
+	for target := range e.Mutes[duplicate.ID] {
+		if e.Mutes[primary.ID] == nil {
+			e.Mutes[primary.ID] = make(map[MuteTarget]bool)
+		}
+		e.Mutes[primary.ID][target] = true
+	}
+	delete(e.Mutes, duplicate.ID)
+	for key := range e.Milestones[duplicate.ID] {
+		if e.Milestones[primary.ID] == nil {
+			e.Milestones[primary.ID] = make(map[string]bool)
+		}
+		e.Milestones[primary.ID][key] = true
+	}
+	delete(e.Milestones, duplicate.ID)
+	e.checkKarmaMilestones(primary)
+	for _, trophy := range e.Trophies[duplicate.ID] {
+		e.awardTrophy(primary, trophy.Key)
+	}
+	delete(e.Trophies, duplicate.ID)
+
+	duplicate.MergedInto = primary.ID
+	e.MergedAccounts++
+	duplicate.PostKarma, duplicate.CommentKarma = 0, 0
+	if duplicate.Connected {
+		duplicate.Connected = false
+		e.DisconnectedUsers++
+	}
+	e.recordAudit(primary.ID, "merge_accounts", duplicate.ID, fmt.Sprintf("%s merged into %s", duplicate.Username, primary.Username))
+	e.recordEvent("merge_accounts", primary.ID, "", duplicate.ID)
+	return stats, nil
+}
+
+// reassignComments moves authorship of duplicate's comments and votes in
+// the tree to primary, dropping votes and reactions that would now be
+// counted twice because both accounts left the same one. Callers must hold e.Mutex.
+func (e *Engine) reassignComments(comments []*Comment, primary, duplicate *User) int {
+	moved := 0
+	for _, comment := range comments {
+		if comment.Author == duplicate {
+			comment.Author = primary
+			moved++
+		}
+		e.mergeCommentVotes(comment, primary, duplicate)
+		if reacted := e.CommentReactions[comment.ID]; reacted != nil {
+			for key := range reacted {
+				if key.userID != duplicate.ID {
+					continue
+				}
+				delete(reacted, key)
+				merged := reactionKey{userID: primary.ID, emoji: key.emoji}
+				if reacted[merged] {
+					comment.Reactions[key.emoji]--
+					e.ReactionCounts[key.emoji]--
+					e.TotalReactions--
+					continue
+				}
+				reacted[merged] = true
+			}
+		}
+		moved += e.reassignComments(comment.Replies, primary, duplicate)
+	}
+	return moved
+}
+
+// banOutlasts reports whether a ban lasting until a ends strictly later than
+// one lasting until b, a zero time meaning a permanent ban.
+func banOutlasts(a, b time.Time) bool {
+	return !b.IsZero() && (a.IsZero() || a.After(b))
+}
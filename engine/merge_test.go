@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMergeMovesArchivedPostsAndAppeals merges an account whose posts and
+// comments are in cold storage and who has a ban appeal pending, and checks
+// all of them end up the primary account's.
+func TestMergeMovesArchivedPostsAndAppeals(t *testing.T) {
+	e, author, duplicate := newTestSite(t)
+	clock := NewSimClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	e.Clock = clock
+	if err := e.EnableColdStorage(filepath.Join(t.TempDir(), "cold")); err != nil {
+		t.Fatal(err)
+	}
+	defer e.ColdStore.Close()
+	primary, err := e.RegisterUser("primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, err := e.CreatePost(duplicate, "news", "Archived post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := e.CreatePost(author, "news", "Archived thread")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.CommentPost(duplicate, other, "Archived comment"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if archived, err := e.ArchiveOldPosts(time.Minute); err != nil || archived != 2 {
+		t.Fatalf("archived %d posts (%v), want 2", archived, err)
+	}
+
+	e.Mutex.Lock()
+	e.ban(e.SubReddits["news"], duplicate.ID, time.Time{})
+	e.Mutex.Unlock()
+	appeal, err := e.FileBanAppeal(duplicate, "news", "Please")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := e.MergeAccounts(primary, duplicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Posts != 1 || stats.Comments != 1 {
+		t.Errorf("merge moved %d posts and %d comments, want 1 and 1", stats.Posts, stats.Comments)
+	}
+	if appeal.UserID != primary.ID {
+		t.Errorf("appeal belongs to user %d, want %d", appeal.UserID, primary.ID)
+	}
+	if e.ColdStore.Archived != 2 {
+		t.Errorf("cold store counts %d archived posts, want 2", e.ColdStore.Archived)
+	}
+
+	// Decode without the tombstone, so only a rewritten record resolves.
+	users := map[int64]*User{primary.ID: primary, author.ID: author}
+	archivedPost, _, err := e.ColdStore.get(post.ID, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archivedPost.Author != primary {
+		t.Error("archived post still credits the merged account")
+	}
+	archivedThread, _, err := e.ColdStore.get(other.ID, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment := archivedThread.Comments[0]; comment.Author != primary {
+		t.Error("archived comment still credits the merged account")
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prometheus Metrics
+
+// metricsPrefix starts every metric name the engine exposes.
+const metricsPrefix = "redditclone_"
+
+// metricsBuckets are the upper bounds of the Prometheus histogram buckets
+// latency histograms are exported with.
+var metricsBuckets = []time.Duration{
+	10 * time.Microsecond, 25 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter writes the Prometheus text exposition format and keeps the
+// first write error.
+type metricsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (m *metricsWriter) printf(format string, args ...interface{}) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
+
+// family starts a metric family of the given type: counter, gauge or
+// histogram.
+func (m *metricsWriter) family(name, kind, help string) {
+	m.printf("# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+}
+
+// sample writes one value of the family name, labelled with label=value
+// unless label is empty.
+func (m *metricsWriter) sample(name, label, value string, v float64) {
+	m.printf("%s%s%s %s\n", metricsPrefix, name, labels(label, value, ""), strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// labels renders a sample's label set: label=value if label isn't empty,
+// followed by le=bound if bound isn't.
+func labels(label, value, bound string) string {
+	var pairs []string
+	if label != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, labelEscaper.Replace(value)))
+	}
+	if bound != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%s"`, bound))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// WriteMetrics writes the engine's counters, its connected users and the
+// size of every subreddit in the Prometheus text format, for a /metrics
+// endpoint.
+func (e *Engine) WriteMetrics(w io.Writer) error {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	m := &metricsWriter{w: w}
+	counters := []struct {
+		name, help string
+		value      int
+	}{
+		{"posts_total", "Posts created.", e.TotalPosts},
+		{"comments_total", "Comments and replies created.", e.TotalComments},
+		{"votes_total", "Votes cast on posts and comments.", e.TotalVotes},
+		{"messages_total", "Direct messages sent.", e.TotalMessages},
+		{"actions_total", "Actions taken by users.", e.TotalActions},
+	}
+	for _, counter := range counters {
+		m.family(counter.name, "counter", counter.help)
+		m.sample(counter.name, "", "", float64(counter.value))
+	}
+
+	connected := 0
+	for _, user := range e.Users {
+		if user.Connected {
+			connected++
+		}
+	}
+	m.family("users", "gauge", "Registered users.")
+	m.sample("users", "", "", float64(len(e.Users)))
+	m.family("connected_users", "gauge", "Users currently connected.")
+	m.sample("connected_users", "", "", float64(connected))
+	m.family("subreddits", "gauge", "Subreddits.")
+	m.sample("subreddits", "", "", float64(len(e.SubReddits)))
+
+	names := make([]string, 0, len(e.SubReddits))
+	for name := range e.SubReddits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m.family("subreddit_members", "gauge", "Members of each subreddit.")
+	for _, name := range names {
+		m.sample("subreddit_members", "subreddit", name, float64(len(e.SubReddits[name].Users)))
+	}
+	m.family("subreddit_posts", "gauge", "Posts in each subreddit, not counting archived ones.")
+	for _, name := range names {
+		m.sample("subreddit_posts", "subreddit", name, float64(len(e.SubReddits[name].Posts)))
+	}
+	return m.err
+}
+
+// WriteHistogramMetrics writes histograms as the Prometheus histogram family
+// name, prefixed like the engine's own metrics and in seconds, with one
+// series per key labelled label=key. Bucket counts are as precise as the
+// histograms' own buckets, within about 3%. The caller must keep the
+// histograms from changing while they are written.
+func WriteHistogramMetrics(w io.Writer, name, help, label string, histograms map[string]*LatencyHistogram) error {
+	m := &metricsWriter{w: w}
+	keys := make([]string, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	m.family(name, "histogram", help)
+	for _, key := range keys {
+		histogram := histograms[key]
+		for i, count := range histogram.cumulative(metricsBuckets) {
+			m.printf("%s%s_bucket%s %d\n", metricsPrefix, name, labels(label, key, strconv.FormatFloat(metricsBuckets[i].Seconds(), 'g', -1, 64)), count)
+		}
+		m.printf("%s%s_bucket%s %d\n", metricsPrefix, name, labels(label, key, "+Inf"), histogram.Count())
+		m.printf("%s%s_sum%s %s\n", metricsPrefix, name, labels(label, key, ""), strconv.FormatFloat(histogram.Sum().Seconds(), 'g', -1, 64))
+		m.printf("%s%s_count%s %d\n", metricsPrefix, name, labels(label, key, ""), histogram.Count())
+	}
+	return m.err
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Milestones
+
+var karmaMilestones = []int{100, 1000, 10000}
+
+// reachMilestone records a one-time milestone for user and notifies them,
+// reporting whether it was new. kind groups milestones for the report.
+// Callers must hold e.Mutex.
+func (e *Engine) reachMilestone(user *User, key, kind, content string) bool {
+	reached := e.Milestones[user.ID]
+	if reached == nil {
+		reached = make(map[string]bool)
+		e.Milestones[user.ID] = reached
+	}
+	if reached[key] {
+		return false
+	}
+	reached[key] = true
+	e.MilestoneCounts[kind]++
+	e.notify(user, "milestone", content)
+	return true
+}
+
+// checkKarmaMilestones must be called after any increase to user's karma.
+// Callers must hold e.Mutex.
+func (e *Engine) checkKarmaMilestones(user *User) {
+	for _, threshold := range karmaMilestones {
+		if user.Karma() < threshold {
+			return
+		}
+		kind := fmt.Sprintf("karma_%d", threshold)
+		e.reachMilestone(user, kind, kind, fmt.Sprintf("You reached %d karma!", threshold))
+	}
+}
+
+// CheckCakeDays notifies every user whose account anniversary falls on the
+// current day of the engine clock, and gives the One-Year Club trophy to
+// every user whose account is at least a year old.
+func (e *Engine) CheckCakeDays() int {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	now := e.Clock.Now()
+	celebrated := 0
+	for _, user := range e.Users {
+		if user.MergedInto == 0 && !now.Before(user.CreatedAt.AddDate(1, 0, 0)) {
+			e.awardTrophy(user, TrophyOneYearClub)
+		}
+		years := now.Year() - user.CreatedAt.Year()
+		if user.MergedInto != 0 || years < 1 || now.Month() != user.CreatedAt.Month() || now.Day() != user.CreatedAt.Day() {
+			continue
+		}
+		if e.reachMilestone(user, fmt.Sprintf("cake_day_%d", years), "cake_day", fmt.Sprintf("Happy %d-year cake day!", years)) {
+			celebrated++
+		}
+	}
+	return celebrated
+}
+
+// MilestoneCount is how many users have reached a kind of milestone.
+type MilestoneCount struct {
+	Kind  string
+	Count int
+}
+
+// GetMilestoneCounts returns the count of every milestone reached, sorted
+// by kind.
+func (e *Engine) GetMilestoneCounts() []MilestoneCount {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	counts := make([]MilestoneCount, 0, len(e.MilestoneCounts))
+	for kind, count := range e.MilestoneCounts {
+		counts = append(counts, MilestoneCount{Kind: kind, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Kind < counts[j].Kind })
+	return counts
+}
@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Moderation
+
+var (
+	ErrNotModerator   = errors.New("user is not a moderator")
+	ErrRuleNotFound   = errors.New("rule not found")
+	ErrInvalidRule    = errors.New("rule title must not be empty")
+	ErrAlreadyRemoved = errors.New("content already removed")
+)
+
+// Rule is one of a subreddit's rules. IDs are numbered from 1.
+type Rule struct {
+	ID          int
+	Title       string
+	Description string
+}
+
+// ModLogEntry is one action in a subreddit's moderation log. ModeratorID
+// is 0 for automod and author deletions.
+type ModLogEntry struct {
+	Time        time.Time
+	ModeratorID int64
+	Action      string
+	TargetID    int64
+	RuleID      int
+}
+
+// RuleStat counts removals citing a rule.
+type RuleStat struct {
+	Rule       Rule
+	Violations int
+}
+
+// isModerator reports whether user may moderate subReddit. Admins moderate
+// everywhere. Callers must hold e.Mutex.
+func (e *Engine) isModerator(user *User, subReddit *SubReddit) bool {
+	if user.IsAdmin {
+		return true
+	}
+	_, exists := subReddit.Moderators[user.ID]
+	return exists
+}
+
+// recordModAction appends to a subreddit's modlog. Callers must hold e.Mutex.
+func (e *Engine) recordModAction(subReddit *SubReddit, mod *User, action string, targetID int64, ruleID int) {
+	subReddit.ModLog = append(subReddit.ModLog, ModLogEntry{
+		Time:        e.Clock.Now(),
+		ModeratorID: mod.ID,
+		Action:      action,
+		TargetID:    targetID,
+		RuleID:      ruleID,
+	})
+}
+
+// AddModerator makes the user a moderator of the subreddit. The actor must
+// already moderate it or be an admin.
+func (e *Engine) AddModerator(actor, user *User, subRedditName string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if !e.isModerator(actor, subReddit) {
+		return ErrNotModerator
+	}
+	subReddit.Moderators[user.ID] = user
+	e.recordModAction(subReddit, actor, "add_moderator", user.ID, 0)
+	e.checkBigCommunity(subReddit)
+	return nil
+}
+
+// AddRule appends a rule to the subreddit's ordered rules list and returns
+// it. Rule IDs are stable and never reused within a subreddit.
+func (e *Engine) AddRule(mod *User, subRedditName, title, description string) (Rule, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return Rule{}, ErrSubRedditNotFound
+	}
+	if !e.isModerator(mod, subReddit) {
+		return Rule{}, ErrNotModerator
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return Rule{}, ErrInvalidRule
+	}
+	rule := Rule{ID: len(subReddit.Rules) + 1, Title: title, Description: description}
+	subReddit.Rules = append(subReddit.Rules, rule)
+	e.recordModAction(subReddit, mod, "add_rule", int64(rule.ID), rule.ID)
+	return rule, nil
+}
+
+// GetRules returns the subreddit's rules in order.
+func (e *Engine) GetRules(subRedditName string) ([]Rule, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	return append([]Rule(nil), subReddit.Rules...), nil
+}
+
+// findRule looks up a rule by ID.
+func findRule(subReddit *SubReddit, ruleID int) (Rule, bool) {
+	for _, rule := range subReddit.Rules {
+		if rule.ID == ruleID {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// checkRemoval validates a moderator removal and returns the subreddit it
+// applies to. Callers must hold e.Mutex.
+func (e *Engine) checkRemoval(mod *User, subRedditName string, ruleID int) (*SubReddit, error) {
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if e.isSuspended(mod) {
+		return nil, ErrUserSuspended
+	}
+	if !e.isModerator(mod, subReddit) {
+		return nil, ErrNotModerator
+	}
+	if _, exists := findRule(subReddit, ruleID); !exists {
+		return nil, ErrRuleNotFound
+	}
+	return subReddit, nil
+}
+
+// RemovePost hides a post from feeds, citing the subreddit rule it broke.
+func (e *Engine) RemovePost(mod *User, post *Post, ruleID int) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, err := e.checkRemoval(mod, post.SubReddit, ruleID)
+	if err != nil {
+		return err
+	}
+	if _, removed := e.RemovedPosts[post.ID]; removed {
+		return ErrAlreadyRemoved
+	}
+	if post.Pending {
+		e.dequeuePending(subReddit, post)
+		subReddit.Rejected++
+	}
+	post.Removed = true
+	e.RemovedPosts[post.ID] = ruleID
+	subReddit.RuleViolations[ruleID]++
+	e.recordModAction(subReddit, mod, "remove_post", post.ID, ruleID)
+	e.recordEvent("remove_post", mod.ID, subReddit.Name, post.ID)
+	return nil
+}
+
+// RemoveComment hides a comment, citing the subreddit rule it broke.
+func (e *Engine) RemoveComment(mod *User, comment *Comment, ruleID int) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, err := e.checkRemoval(mod, comment.SubReddit, ruleID)
+	if err != nil {
+		return err
+	}
+	if _, removed := e.RemovedComments[comment.ID]; removed {
+		return ErrAlreadyRemoved
+	}
+	comment.Removed = true
+	e.RemovedComments[comment.ID] = ruleID
+	e.dropSticky(comment)
+	subReddit.RuleViolations[ruleID]++
+	e.recordModAction(subReddit, mod, "remove_comment", comment.ID, ruleID)
+	e.recordEvent("remove_comment", mod.ID, subReddit.Name, comment.ID)
+	return nil
+}
+
+// GetModLog returns the subreddit's moderation log, oldest first.
+func (e *Engine) GetModLog(subRedditName string) ([]ModLogEntry, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	return append([]ModLogEntry(nil), subReddit.ModLog...), nil
+}
+
+// GetRuleViolationStats returns removal counts per rule in rule order.
+func (e *Engine) GetRuleViolationStats(subRedditName string) ([]RuleStat, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	stats := make([]RuleStat, 0, len(subReddit.Rules))
+	for _, rule := range subReddit.Rules {
+		stats = append(stats, RuleStat{Rule: rule, Violations: subReddit.RuleViolations[rule.ID]})
+	}
+	return stats, nil
+}
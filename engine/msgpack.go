@@ -0,0 +1,693 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessagePack
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+// MsgpackCodec encodes in MessagePack (msgpack.org), a binary JSON: structs
+// become maps keyed by field name, following the same json tags, and times
+// the standard timestamp extension, in UTC. Map keys are sorted, so an
+// unchanged record encodes to the same bytes and FileStore can skip it.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return appendMsgpack(nil, reflect.ValueOf(v))
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("msgpack: cannot decode into %T", v)
+	}
+	d := &msgpackDecoder{data: data}
+	if err := d.decode(target.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(data) {
+		return fmt.Errorf("msgpack: %d bytes left over", len(data)-d.pos)
+	}
+	return nil
+}
+
+var msgpackTimeType = reflect.TypeOf(time.Time{})
+
+// msgpackField is an encoded struct field: its name and where it sits,
+// through any embedded structs.
+type msgpackField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	omitZero  bool
+}
+
+type msgpackStruct struct {
+	fields []msgpackField
+	byName map[string]*msgpackField
+}
+
+var msgpackStructs sync.Map // reflect.Type -> *msgpackStruct
+
+// msgpackFields returns the fields of struct type t the way encoding/json
+// sees them: exported ones, renamed or left out by their json tags, with
+// the fields of embedded structs promoted unless the outer struct has one
+// of the same name.
+func msgpackFields(t reflect.Type) *msgpackStruct {
+	if cached, ok := msgpackStructs.Load(t); ok {
+		return cached.(*msgpackStruct)
+	}
+	fields := &msgpackStruct{byName: make(map[string]*msgpackField)}
+	var collect func(t reflect.Type, index []int) []msgpackField
+	collect = func(t reflect.Type, index []int) []msgpackField {
+		var direct, promoted []msgpackField
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			at := append(append([]int(nil), index...), i)
+			if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+				promoted = append(promoted, collect(field.Type, at)...)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			direct = append(direct, msgpackField{
+				name:      name,
+				index:     at,
+				omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+				omitZero:  strings.Contains(","+options+",", ",omitzero,"),
+			})
+		}
+		taken := make(map[string]bool, len(direct))
+		for _, field := range direct {
+			taken[field.name] = true
+		}
+		for _, field := range promoted {
+			if !taken[field.name] {
+				direct = append(direct, field)
+			}
+		}
+		return direct
+	}
+	fields.fields = collect(t, nil)
+	for i := range fields.fields {
+		fields.byName[fields.fields[i].name] = &fields.fields[i]
+	}
+	cached, _ := msgpackStructs.LoadOrStore(t, fields)
+	return cached.(*msgpackStruct)
+}
+
+// omitted reports whether the field's value is left out of the encoding.
+func (f *msgpackField) omitted(v reflect.Value) bool {
+	if f.omitZero {
+		if zeroer, ok := v.Interface().(interface{ IsZero() bool }); ok {
+			return zeroer.IsZero()
+		}
+		return v.IsZero()
+	}
+	if !f.omitEmpty {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// appendMsgpack appends the encoding of v to b.
+func appendMsgpack(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, 0xc0), nil
+	}
+	if v.Type() == msgpackTimeType {
+		return appendMsgpackTime(b, v.Interface().(time.Time)), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendMsgpackUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendMsgpackString(b, v.String()), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgpack(b, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return append(appendMsgpackLen(b, v.Len(), 0, 0xc4, 0xc5, 0xc6), v.Bytes()...), nil
+		}
+		fallthrough
+	case reflect.Array:
+		b = appendMsgpackLen(b, v.Len(), 0x90, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			var err error
+			if b, err = appendMsgpack(b, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		keys := v.MapKeys()
+		switch v.Type().Key().Kind() {
+		case reflect.String:
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+		default:
+			return nil, fmt.Errorf("msgpack: unsupported map key type %s", v.Type().Key())
+		}
+		b = appendMsgpackLen(b, len(keys), 0x80, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			var err error
+			if b, err = appendMsgpack(b, key); err != nil {
+				return nil, err
+			}
+			if b, err = appendMsgpack(b, v.MapIndex(key)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Struct:
+		fields := msgpackFields(v.Type())
+		present := make([]bool, len(fields.fields))
+		count := 0
+		for i := range fields.fields {
+			if !fields.fields[i].omitted(v.FieldByIndex(fields.fields[i].index)) {
+				present[i] = true
+				count++
+			}
+		}
+		b = appendMsgpackLen(b, count, 0x80, 0, 0xde, 0xdf)
+		for i := range fields.fields {
+			if !present[i] {
+				continue
+			}
+			b = appendMsgpackString(b, fields.fields[i].name)
+			var err error
+			if b, err = appendMsgpack(b, v.FieldByIndex(fields.fields[i].index)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type %s", v.Type())
+}
+
+// appendMsgpackLen appends a length header: fixed is the tag of the format
+// that fits small lengths in its low bits (0 if there is none), and the
+// others those of the formats with 8, 16 and 32-bit lengths.
+func appendMsgpackLen(b []byte, n int, fixed, bits8, bits16, bits32 byte) []byte {
+	switch {
+	case fixed == 0x90 || fixed == 0x80:
+		if n < 16 {
+			return append(b, fixed|byte(n))
+		}
+	case fixed == 0xa0:
+		if n < 32 {
+			return append(b, fixed|byte(n))
+		}
+	}
+	switch {
+	case bits8 != 0 && n <= math.MaxUint8:
+		return append(b, bits8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, bits16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, bits32), uint32(n))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	return append(appendMsgpackLen(b, len(s), 0xa0, 0xd9, 0xda, 0xdb), s...)
+}
+
+func appendMsgpackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendMsgpackUint(b, uint64(n))
+	case n >= -32:
+		return append(b, byte(n))
+	case n >= math.MinInt8:
+		return append(b, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+}
+
+func appendMsgpackUint(b []byte, n uint64) []byte {
+	switch {
+	case n <= math.MaxInt8:
+		return append(b, byte(n))
+	case n <= math.MaxUint8:
+		return append(b, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), n)
+}
+
+// appendMsgpackTime appends t as a timestamp extension (type -1), in the
+// shortest of its 32, 64 and 96-bit forms that holds it.
+func appendMsgpackTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec < 1<<34 && nsec == 0 && sec <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd6, 0xff), uint32(sec))
+	case sec >= 0 && sec < 1<<34:
+		return binary.BigEndian.AppendUint64(append(b, 0xd7, 0xff), uint64(nsec)<<34|uint64(sec))
+	}
+	b = binary.BigEndian.AppendUint32(append(b, 0xc7, 12, 0xff), uint32(nsec))
+	return binary.BigEndian.AppendUint64(b, uint64(sec))
+}
+
+// msgpackDecoder reads values from data, starting at pos.
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next n bytes.
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos], nil
+}
+
+func (d *msgpackDecoder) byte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+// decode reads one value into v, which must be settable.
+func (d *msgpackDecoder) decode(v reflect.Value) error {
+	if d.pos < len(d.data) && d.data[d.pos] == 0xc0 {
+		d.pos++
+		v.SetZero()
+		return nil
+	}
+	if v.Type() == msgpackTimeType {
+		t, err := d.time()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		switch b, err := d.byte(); {
+		case err != nil:
+			return err
+		case b == 0xc2 || b == 0xc3:
+			v.SetBool(b == 0xc3)
+			return nil
+		}
+		return d.mismatch(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, negative, err := d.integer()
+		if err != nil {
+			return err
+		}
+		if (!negative && n > math.MaxInt64) || v.OverflowInt(int64(n)) {
+			return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+		}
+		v.SetInt(int64(n))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, negative, err := d.integer()
+		if err != nil {
+			return err
+		}
+		if negative || v.OverflowUint(n) {
+			return fmt.Errorf("msgpack: %d overflows %s", int64(n), v.Type())
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := d.float()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.String:
+		s, err := d.string()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && d.pos < len(d.data) && d.data[d.pos] >= 0xc4 && d.data[d.pos] <= 0xc6 {
+			n, err := d.length(0, 0, 0xc4, 0xc5, 0xc6)
+			if err != nil {
+				return err
+			}
+			b, err := d.next(n)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		n, err := d.length(0x90, 0x0f, 0, 0xdc, 0xdd)
+		if err != nil {
+			return err
+		}
+		if n > len(d.data)-d.pos {
+			return errMsgpackShort
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		n, err := d.length(0x90, 0x0f, 0, 0xdc, 0xdd)
+		if err != nil {
+			return err
+		}
+		v.SetZero()
+		for i := 0; i < n; i++ {
+			if i >= v.Len() {
+				err = d.skip()
+			} else {
+				err = d.decode(v.Index(i))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		n, err := d.length(0x80, 0x0f, 0, 0xde, 0xdf)
+		if err != nil {
+			return err
+		}
+		if n > len(d.data)-d.pos {
+			return errMsgpackShort
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := d.decode(key); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decode(value); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+		return nil
+	case reflect.Struct:
+		n, err := d.length(0x80, 0x0f, 0, 0xde, 0xdf)
+		if err != nil {
+			return err
+		}
+		fields := msgpackFields(v.Type())
+		v.SetZero()
+		for i := 0; i < n; i++ {
+			name, err := d.string()
+			if err != nil {
+				return err
+			}
+			if field := fields.byName[name]; field != nil {
+				err = d.decode(v.FieldByIndex(field.index))
+			} else {
+				err = d.skip()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+}
+
+// mismatch reports that the value just read doesn't fit v.
+func (d *msgpackDecoder) mismatch(v reflect.Value) error {
+	return fmt.Errorf("msgpack: value at offset %d doesn't fit %s", d.pos-1, v.Type())
+}
+
+// integer reads an integer in any format. negative reports that n holds a
+// negative int64.
+func (d *msgpackDecoder) integer() (n uint64, negative bool, err error) {
+	b, err := d.byte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case b <= 0x7f:
+		return uint64(b), false, nil
+	case b >= 0xe0:
+		return uint64(int64(int8(b))), true, nil
+	case b >= 0xcc && b <= 0xcf:
+		n, err := d.uint(1 << (b - 0xcc))
+		return n, false, err
+	case b >= 0xd0 && b <= 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return 0, false, err
+		}
+		// Sign-extend from size bytes.
+		shift := 64 - 8*size
+		signed := int64(n<<shift) >> shift
+		return uint64(signed), signed < 0, nil
+	}
+	return 0, false, fmt.Errorf("msgpack: expected an integer at offset %d", d.pos-1)
+}
+
+func (d *msgpackDecoder) float() (float64, error) {
+	if d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case 0xca:
+			d.pos++
+			bits, err := d.uint(4)
+			return float64(math.Float32frombits(uint32(bits))), err
+		case 0xcb:
+			d.pos++
+			bits, err := d.uint(8)
+			return math.Float64frombits(bits), err
+		}
+	}
+	n, negative, err := d.integer()
+	if negative {
+		return float64(int64(n)), err
+	}
+	return float64(n), err
+}
+
+func (d *msgpackDecoder) string() (string, error) {
+	n, err := d.length(0xa0, 0x1f, 0xd9, 0xda, 0xdb)
+	if err != nil {
+		return "", err
+	}
+	b, err := d.next(n)
+	return string(b), err
+}
+
+// length reads the header of a string, binary, array or map: fixed and
+// mask pick out the format holding the length in its own low bits (fixed
+// is 0 for binary), and the others are those of the formats with 8, 16 and
+// 32-bit lengths.
+func (d *msgpackDecoder) length(fixed, mask, bits8, bits16, bits32 byte) (int, error) {
+	b, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch {
+	case fixed != 0 && b&^mask == fixed:
+		return int(b & mask), nil
+	case bits8 != 0 && b == bits8:
+		n, err = d.uint(1)
+	case b == bits16:
+		n, err = d.uint(2)
+	case b == bits32:
+		n, err = d.uint(4)
+	default:
+		return 0, fmt.Errorf("msgpack: unexpected format 0x%02x at offset %d", b, d.pos-1)
+	}
+	return int(n), err
+}
+
+// time reads a timestamp extension.
+func (d *msgpackDecoder) time() (time.Time, error) {
+	b, err := d.byte()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var size int
+	switch b {
+	case 0xd6:
+		size = 4
+	case 0xd7:
+		size = 8
+	case 0xc7:
+		if size, err = d.uintLen(1); err != nil {
+			return time.Time{}, err
+		}
+	default:
+		return time.Time{}, fmt.Errorf("msgpack: expected a timestamp at offset %d", d.pos-1)
+	}
+	if kind, err := d.byte(); err != nil {
+		return time.Time{}, err
+	} else if kind != 0xff {
+		return time.Time{}, fmt.Errorf("msgpack: extension type %d is not a timestamp", int8(kind))
+	}
+	switch size {
+	case 4:
+		sec, err := d.uint(4)
+		return time.Unix(int64(sec), 0).UTC(), err
+	case 8:
+		packed, err := d.uint(8)
+		return time.Unix(int64(packed&(1<<34-1)), int64(packed>>34)).UTC(), err
+	case 12:
+		nsec, err := d.uint(4)
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec, err := d.uint(8)
+		return time.Unix(int64(sec), int64(nsec)).UTC(), err
+	}
+	return time.Time{}, fmt.Errorf("msgpack: timestamp of %d bytes", size)
+}
+
+func (d *msgpackDecoder) uintLen(size int) (int, error) {
+	n, err := d.uint(size)
+	return int(n), err
+}
+
+// skip reads past one value of any type, for fields the target lacks.
+func (d *msgpackDecoder) skip() error {
+	b, err := d.byte()
+	if err != nil {
+		return err
+	}
+	var size, values int
+	switch {
+	case b <= 0x7f || b >= 0xe0 || b == 0xc0 || b == 0xc2 || b == 0xc3:
+	case b <= 0x8f:
+		values = 2 * int(b&0x0f)
+	case b <= 0x9f:
+		values = int(b & 0x0f)
+	case b <= 0xbf:
+		size = int(b & 0x1f)
+	case b == 0xc4 || b == 0xd9:
+		size, err = d.uintLen(1)
+	case b == 0xc5 || b == 0xda:
+		size, err = d.uintLen(2)
+	case b == 0xc6 || b == 0xdb:
+		size, err = d.uintLen(4)
+	case b >= 0xc7 && b <= 0xc9:
+		size, err = d.uintLen(1 << (b - 0xc7))
+		size++
+	case b >= 0xca && b <= 0xd3:
+		size = [...]int{4, 8, 1, 2, 4, 8, 1, 2, 4, 8}[b-0xca]
+	case b >= 0xd4 && b <= 0xd8:
+		size = 1 + 1<<(b-0xd4)
+	case b == 0xdc || b == 0xde:
+		values, err = d.uintLen(2)
+	case b == 0xdd || b == 0xdf:
+		values, err = d.uintLen(4)
+	default:
+		return fmt.Errorf("msgpack: unexpected format 0x%02x at offset %d", b, d.pos-1)
+	}
+	if err != nil {
+		return err
+	}
+	if b == 0xde || b == 0xdf {
+		values *= 2
+	}
+	if _, err := d.next(size); err != nil {
+		return err
+	}
+	for i := 0; i < values; i++ {
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Notification Preferences and Muting
+
+var (
+	ErrInvalidMute  = errors.New("invalid mute target")
+	ErrAlreadyMuted = errors.New("already muted")
+	ErrNotMuted     = errors.New("not muted")
+)
+
+// MuteKind is what a mute silences reply notifications from.
+type MuteKind string
+
+const (
+	MutePost      MuteKind = "post"
+	MuteThread    MuteKind = "thread"
+	MuteSubReddit MuteKind = "subreddit"
+)
+
+// MuteTarget names what a user no longer wants activity notifications
+// about. Posts and threads are identified by ID, subreddits by name. A
+// thread can be named by any comment in it; it is stored under its
+// top-level comment.
+type MuteTarget struct {
+	Kind      MuteKind
+	ID        int64
+	SubReddit string
+}
+
+// MutePostTarget mutes replies anywhere on post.
+func MutePostTarget(post *Post) MuteTarget {
+	return MuteTarget{Kind: MutePost, ID: post.ID}
+}
+
+// MuteThreadTarget mutes replies below comment.
+func MuteThreadTarget(comment *Comment) MuteTarget {
+	return MuteTarget{Kind: MuteThread, ID: comment.ID}
+}
+
+// MuteSubRedditTarget mutes replies anywhere in the subreddit.
+func MuteSubRedditTarget(name string) MuteTarget {
+	return MuteTarget{Kind: MuteSubReddit, SubReddit: name}
+}
+
+func (target MuteTarget) valid() bool {
+	switch target.Kind {
+	case MutePost, MuteThread:
+		return target.ID != 0 && target.SubReddit == ""
+	case MuteSubReddit:
+		return target.ID == 0 && target.SubReddit != ""
+	}
+	return false
+}
+
+// Mute stops reply notifications to the user from target.
+func (e *Engine) Mute(user *User, target MuteTarget) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	if !target.valid() {
+		return ErrInvalidMute
+	}
+	target = e.normalizeMute(target)
+	if e.Mutes[user.ID][target] {
+		return ErrAlreadyMuted
+	}
+	if e.Mutes[user.ID] == nil {
+		e.Mutes[user.ID] = make(map[MuteTarget]bool)
+	}
+	e.Mutes[user.ID][target] = true
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("mute", user.ID, target.SubReddit, target.ID)
+	return nil
+}
+
+// Unmute lifts a mute added with Mute.
+func (e *Engine) Unmute(user *User, target MuteTarget) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	target = e.normalizeMute(target)
+	if !e.Mutes[user.ID][target] {
+		return ErrNotMuted
+	}
+	delete(e.Mutes[user.ID], target)
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("unmute", user.ID, target.SubReddit, target.ID)
+	return nil
+}
+
+// GetMutes returns the user's mutes in no particular order.
+func (e *Engine) GetMutes(user *User) []MuteTarget {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	mutes := make([]MuteTarget, 0, len(e.Mutes[user.ID]))
+	for target := range e.Mutes[user.ID] {
+		mutes = append(mutes, target)
+	}
+	return mutes
+}
+
+// normalizeMute stores thread mutes under the thread's top-level comment.
+// Callers must hold e.Mutex.
+func (e *Engine) normalizeMute(target MuteTarget) MuteTarget {
+	if target.Kind == MuteThread {
+		target.ID = e.threadRoot(target.ID)
+	}
+	return target
+}
+
+// mutedBy returns which of user's mutes covers reply, checking the
+// narrowest target first. Callers must hold e.Mutex.
+func (e *Engine) mutedBy(user *User, reply *Comment) (MuteKind, bool) {
+	mutes := e.Mutes[user.ID]
+	if len(mutes) == 0 {
+		return "", false
+	}
+	if mutes[MuteTarget{Kind: MuteThread, ID: e.threadRoot(reply.ID)}] {
+		return MuteThread, true
+	}
+	if mutes[MuteTarget{Kind: MutePost, ID: reply.PostID}] {
+		return MutePost, true
+	}
+	if mutes[MuteTarget{Kind: MuteSubReddit, SubReddit: reply.SubReddit}] {
+		return MuteSubReddit, true
+	}
+	return "", false
+}
+
+// threadRoot returns the top-level comment that commentID descends from.
+// Callers must hold e.Mutex.
+func (e *Engine) threadRoot(commentID int64) int64 {
+	for e.CommentParents[commentID] != 0 {
+		commentID = e.CommentParents[commentID]
+	}
+	return commentID
+}
+
+// notifyReply tells recipient, the author of what reply answered, about it
+// unless they wrote the reply themselves or have muted its thread, post or
+// subreddit. Callers must hold e.Mutex.
+func (e *Engine) notifyReply(recipient *User, kind string, reply *Comment) {
+	if recipient == reply.Author {
+		return
+	}
+	if muteKind, muted := e.mutedBy(recipient, reply); muted {
+		e.MutedNotifications[muteKind]++
+		return
+	}
+	e.ReplyNotifications++
+	e.notify(recipient, kind, fmt.Sprintf("u/%s replied in r/%s: %s", reply.Author.Username, reply.SubReddit, reply.Content()))
+}
+
+// GetMutedNotifications counts the notifications suppressed by each kind
+// of mute.
+func (e *Engine) GetMutedNotifications() map[MuteKind]int {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	counts := make(map[MuteKind]int, len(e.MutedNotifications))
+	for kind, count := range e.MutedNotifications {
+		counts[kind] = count
+	}
+	return counts
+}
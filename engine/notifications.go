@@ -0,0 +1,119 @@
+package engine
+
+import "time"
+
+// Notifications
+
+// Notification is a message in a user's notification inbox.
+type Notification struct {
+	ID        int64
+	UserID    int64
+	Kind      string
+	Content   string
+	CreatedAt time.Time
+}
+
+// BroadcastStats describes the fan-out of one Broadcast.
+type BroadcastStats struct {
+	Recipients int
+	Delivered  int
+	Queued     int
+	Duration   time.Duration
+}
+
+// notify delivers a notification to a connected user's inbox or queues it
+// until the user reconnects. Callers must hold e.Mutex.
+func (e *Engine) notify(user *User, kind, content string) {
+	e.NotificationID++
+	notification := Notification{
+		ID:        e.NotificationID,
+		UserID:    user.ID,
+		Kind:      kind,
+		Content:   content,
+		CreatedAt: e.Clock.Now(),
+	}
+	if user.Connected {
+		e.Notifications[user.ID] = append(e.Notifications[user.ID], notification)
+		e.DeliveredNotifications++
+		return
+	}
+	e.PendingNotifications[user.ID] = append(e.PendingNotifications[user.ID], notification)
+	e.QueuedNotifications++
+	e.notificationBacklog++
+}
+
+// GetNotifications returns the user's delivered notifications, oldest
+// first.
+func (e *Engine) GetNotifications(user *User) []Notification {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return append([]Notification(nil), e.Notifications[user.ID]...)
+}
+
+// ConnectUser marks a user online and flushes any notifications queued while
+// they were away, returning how many were delivered. Churned users stay
+// offline.
+func (e *Engine) ConnectUser(user *User) int {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if user.Churned || user.MergedInto != 0 {
+		return 0
+	}
+	if !user.Connected {
+		user.Connected = true
+		e.DisconnectedUsers--
+	}
+	pending := e.PendingNotifications[user.ID]
+	delete(e.PendingNotifications, user.ID)
+	e.notificationBacklog -= len(pending)
+	e.Notifications[user.ID] = append(e.Notifications[user.ID], pending...)
+	e.DeliveredNotifications += len(pending)
+	e.recordEvent("connect", user.ID, "", 0)
+	return len(pending)
+}
+
+// DisconnectUser takes the user offline. Notifications for them queue until
+// the next ConnectUser.
+func (e *Engine) DisconnectUser(user *User) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if user.Connected {
+		user.Connected = false
+		e.DisconnectedUsers++
+	}
+	e.recordEvent("disconnect", user.ID, "", 0)
+}
+
+// Broadcast fans an announcement out to every user: connected users receive
+// it immediately and offline users get it on their next ConnectUser. Merged
+// accounts are skipped.
+func (e *Engine) Broadcast(admin *User, content string) (BroadcastStats, error) {
+	start := time.Now()
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if !admin.IsAdmin {
+		return BroadcastStats{}, ErrNotAdmin
+	}
+	content, err := e.validateContent("announcement", content, maxMessageLength)
+	if err != nil {
+		return BroadcastStats{}, err
+	}
+	stats := BroadcastStats{}
+	for _, user := range e.Users {
+		if user.MergedInto != 0 {
+			continue
+		}
+		stats.Recipients++
+		if user.Connected {
+			stats.Delivered++
+		} else {
+			stats.Queued++
+		}
+		e.notify(user, "announcement", content)
+	}
+	e.TotalBroadcasts++
+	e.recordAudit(admin.ID, "broadcast", 0, content)
+	e.recordEvent("broadcast", admin.ID, "", 0)
+	stats.Duration = time.Since(start)
+	return stats, nil
+}
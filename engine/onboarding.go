@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"math"
+	"sort"
+)
+
+// User Onboarding
+
+const (
+	// A shown subreddit is joined with probability onboardingBaseJoinRate
+	// plus its relevance times onboardingRelevanceWeight.
+	onboardingBaseJoinRate    = 0.05
+	onboardingRelevanceWeight = 0.85
+)
+
+// SubRedditRecommendation is a subreddit suggested to a user. Relevance is in
+// [0, 1]: the user's declared interest in its topics, or their learned
+// affinity for it from what they've posted, whichever is stronger.
+type SubRedditRecommendation struct {
+	SubReddit string
+	Relevance float64
+	Members   int
+}
+
+// OnboardingOutcome is what a new user did with the subreddits shown to them.
+type OnboardingOutcome struct {
+	Shown  int
+	Joined []string
+	// Relevance is the mean relevance of the subreddits joined.
+	Relevance float64
+}
+
+// RecommendSubReddits returns up to count subreddits the user hasn't joined
+// and isn't banned from, leaving out NSFW ones for minors, most relevant
+// first. Relevance is weighted by the
+// log of membership so that, all else equal, active communities come first.
+func (e *Engine) RecommendSubReddits(user *User, count int) []SubRedditRecommendation {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	recommendations := e.candidateSubReddits(user)
+	score := func(r SubRedditRecommendation) float64 {
+		return r.Relevance * math.Log2(float64(r.Members)+2)
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return score(recommendations[i]) > score(recommendations[j])
+	})
+	return recommendations[:min(count, len(recommendations))]
+}
+
+// candidateSubReddits returns every subreddit the user could join, sorted by
+// name, with its relevance to them. Callers must hold e.Mutex.
+func (e *Engine) candidateSubReddits(user *User) []SubRedditRecommendation {
+	learned := e.interestVector(user)
+	var candidates []SubRedditRecommendation
+	for name, subReddit := range e.SubReddits {
+		if _, member := subReddit.Users[user.ID]; member || e.isBanned(user, subReddit) || subReddit.Settings.NSFW && minor(user) {
+			continue
+		}
+		relevance := math.Max(TopicAffinity(user.InterestProfile, subReddit.Topics), learned[name])
+		candidates = append(candidates, SubRedditRecommendation{SubReddit: name, Relevance: relevance, Members: len(subReddit.Users)})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].SubReddit < candidates[j].SubReddit })
+	return candidates
+}
+
+// OnboardUser shows a new user the given subreddits and subscribes them to
+// each with a probability that grows with its relevance.
+func (e *Engine) OnboardUser(user *User, shown []SubRedditRecommendation) OnboardingOutcome {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	outcome := OnboardingOutcome{Shown: len(shown)}
+	if e.isSuspended(user) {
+		return outcome
+	}
+	total := 0.0
+	for _, recommendation := range shown {
+		subReddit, exists := e.SubReddits[recommendation.SubReddit]
+		if !exists || subReddit.Settings.NSFW && minor(user) || e.Rand.Float64() >= onboardingBaseJoinRate+onboardingRelevanceWeight*recommendation.Relevance {
+			continue
+		}
+		e.subscribe(user, subReddit)
+		outcome.Joined = append(outcome.Joined, subReddit.Name)
+		total += recommendation.Relevance
+	}
+	if len(outcome.Joined) > 0 {
+		outcome.Relevance = total / float64(len(outcome.Joined))
+	}
+	e.recordEvent("onboard", user.ID, "", int64(len(outcome.Joined)))
+	return outcome
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Permalinks
+
+var (
+	ErrInvalidPermalink = errors.New("invalid permalink code")
+	ErrCommentNotFound  = errors.New("comment not found")
+)
+
+const (
+	permalinkPost    = 'p'
+	permalinkComment = 'c'
+	base36Digits     = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
+
+// PostPermalink returns a short URL-safe code for a post: "p", the post ID
+// in base 36 and a check digit. Codes depend only on the ID, so they stay
+// valid across edits, renames, archiving and restarts.
+func PostPermalink(post *Post) string {
+	return permalinkCode(permalinkPost, post.ID)
+}
+
+// CommentPermalink is PostPermalink for comments, prefixed with "c".
+func CommentPermalink(comment *Comment) string {
+	return permalinkCode(permalinkComment, comment.ID)
+}
+
+func permalinkCode(kind byte, id int64) string {
+	body := string(kind) + strconv.FormatInt(id, 36)
+	return body + string(base36Digits[luhnMod36(body, false)])
+}
+
+// luhnMod36 computes the Luhn mod N check digit for body, or validates when
+// body already ends in one (returning 0 if it's correct). It catches every
+// single-character typo and every swap of adjacent characters but one: 0
+// and z, since doubling either leaves what it adds to the sum unchanged.
+func luhnMod36(body string, hasCheckDigit bool) int {
+	factor, sum := 2, 0
+	if hasCheckDigit {
+		factor = 1
+	}
+	for i := len(body) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(base36Digits, body[i])
+		sum += addend/36 + addend%36
+		factor = 3 - factor
+	}
+	if hasCheckDigit {
+		return sum % 36
+	}
+	return (36 - sum%36) % 36
+}
+
+// parsePermalink validates code and splits it into kind and ID.
+func parsePermalink(code string) (byte, int64, error) {
+	code = strings.ToLower(code)
+	if len(code) < 3 || (code[0] != permalinkPost && code[0] != permalinkComment) {
+		return 0, 0, ErrInvalidPermalink
+	}
+	for i := 1; i < len(code); i++ {
+		if strings.IndexByte(base36Digits, code[i]) < 0 {
+			return 0, 0, ErrInvalidPermalink
+		}
+	}
+	if luhnMod36(code, true) != 0 {
+		return 0, 0, ErrInvalidPermalink
+	}
+	id, err := strconv.ParseInt(code[1:len(code)-1], 36, 64)
+	if err != nil || id <= 0 {
+		return 0, 0, ErrInvalidPermalink
+	}
+	return code[0], id, nil
+}
+
+// Resolve looks up the content a permalink code names. Post codes return the
+// post and a nil comment; comment codes return the comment and the post it
+// belongs to. Post codes also resolve archived posts; comment codes only
+// find comments on hot posts.
+func (e *Engine) Resolve(code string) (*Post, *Comment, error) {
+	kind, id, err := parsePermalink(code)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	if kind == permalinkPost {
+		if post := e.FindPost(id); post != nil {
+			return post, nil, nil
+		}
+		if post, err := e.archivedPost(id); err == nil {
+			return post, nil, nil
+		}
+		return nil, nil, ErrPostNotFound
+	}
+	if comment := e.commentIndex[id]; comment != nil {
+		return e.postIndex[comment.PostID], comment, nil
+	}
+	return nil, nil, ErrCommentNotFound
+}
+
+// archivedPost loads a post from cold storage with its subreddit filled in.
+// Callers must hold e.Mutex.
+func (e *Engine) archivedPost(id int64) (*Post, error) {
+	if e.ColdStore == nil {
+		return nil, ErrPostNotArchived
+	}
+	post, subRedditName, err := e.ColdStore.get(id, e.Users)
+	if err != nil {
+		return nil, err
+	}
+	post.SubReddit = subRedditName
+	for _, comment := range post.Comments {
+		e.markCollapsed(comment, false)
+	}
+	return post, nil
+}
+
+// FindComment looks a comment on a hot post up by ID. Callers must hold
+// e.Mutex; other callers should use GetComment.
+func (e *Engine) FindComment(id int64) *Comment {
+	return e.commentIndex[id]
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"errors"
+)
+
+// Per-User Post Votes
+
+var (
+	ErrAlreadyVoted = errors.New("user has already cast that vote")
+	ErrNotVoted     = errors.New("user has no vote there to withdraw")
+)
+
+// PostVote is a user's standing vote on a post. Weight and VoterWeight are
+// what the vote counted for in WeightedVotes and KarmaWeightedVotes when it
+// was cast, so retracting it takes back exactly what it gave.
+type PostVote struct {
+	Direction   int
+	Weight      float64
+	VoterWeight float64
+}
+
+// UpvotePost sets voter's vote on post to an upvote, switching a downvote
+// if they had one. It returns ErrAlreadyVoted if they had already upvoted.
+func (e *Engine) UpvotePost(voter *User, post *Post) error {
+	return e.UpvotePostContext(context.Background(), voter, post)
+}
+
+// UpvotePostContext is UpvotePost, traced as a child of the span in ctx.
+func (e *Engine) UpvotePostContext(ctx context.Context, voter *User, post *Post) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	return e.setPostVote(voter, post, 1)
+}
+
+// DownvotePost sets voter's vote on post to a downvote, switching an upvote
+// if they had one. It returns ErrAlreadyVoted if they had already
+// downvoted.
+func (e *Engine) DownvotePost(voter *User, post *Post) error {
+	return e.DownvotePostContext(context.Background(), voter, post)
+}
+
+// DownvotePostContext is DownvotePost, traced as a child of the span in ctx.
+func (e *Engine) DownvotePostContext(ctx context.Context, voter *User, post *Post) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	return e.setPostVote(voter, post, -1)
+}
+
+// UnvotePost withdraws voter's vote on post, taking back the score and
+// karma it gave. It returns ErrNotVoted if they hadn't voted.
+func (e *Engine) UnvotePost(voter *User, post *Post) error {
+	return e.UnvotePostContext(context.Background(), voter, post)
+}
+
+// UnvotePostContext is UnvotePost, traced as a child of the span in ctx.
+func (e *Engine) UnvotePostContext(ctx context.Context, voter *User, post *Post) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	return e.setPostVote(voter, post, 0)
+}
+
+// GetPostVote returns voter's current vote on post: 1, -1, or 0 if they
+// haven't voted.
+func (e *Engine) GetPostVote(voter *User, post *Post) int {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.PostVotes[post.ID][voter.ID].Direction
+}
+
+// setPostVote moves voter's vote on post to direction, where 0 withdraws
+// it. A switch retracts the old vote before casting the new one, so the
+// post and its author end up as if only the new vote had been cast.
+// Callers must hold e.Mutex.
+func (e *Engine) setPostVote(voter *User, post *Post, direction int) error {
+	if e.isSuspended(voter) {
+		return ErrUserSuspended
+	}
+	current, voted := e.PostVotes[post.ID][voter.ID]
+	if !voted && direction == 0 {
+		return ErrNotVoted
+	}
+	if voted && current.Direction == direction {
+		return ErrAlreadyVoted
+	}
+	if voted {
+		e.retractPostVote(voter.ID, post, current)
+	}
+	if direction != 0 {
+		e.votePost(voter, post, direction)
+		return nil
+	}
+	voter.Actions++
+	e.TotalActions++
+	e.recordEvent("unvote", voter.ID, post.SubReddit, post.ID)
+	return nil
+}
+
+// retractPostVote takes vote, cast by voterID, back off post and its
+// author's karma. Callers must hold e.Mutex.
+func (e *Engine) retractPostVote(voterID int64, post *Post, vote PostVote) {
+	delta := float64(vote.Direction)
+	post.Votes -= vote.Direction
+	post.WeightedVotes -= vote.Weight * delta
+	post.KarmaWeightedVotes -= vote.VoterWeight * delta
+	if vote.Direction > 0 {
+		post.Upvotes--
+	} else {
+		post.Downvotes--
+	}
+	e.creditPostKarma(post.Author, -vote.Weight*delta)
+	e.bumpShared(PostVotesKey(post.ID), -int64(vote.Direction))
+	delete(e.PostVotes[post.ID], voterID)
+	e.rankingPool.invalidate(post)
+	e.publishVote("post", post.SubReddit, post.ID, -vote.Direction)
+}
+
+// mergePostVotes moves duplicate's vote on post to primary. If both
+// accounts voted, duplicate's vote is retracted so the merged account
+// counts once. Callers must hold e.Mutex.
+func (e *Engine) mergePostVotes(post *Post, primary, duplicate *User) {
+	votes := e.PostVotes[post.ID]
+	vote, voted := votes[duplicate.ID]
+	if !voted {
+		return
+	}
+	if _, both := votes[primary.ID]; both {
+		e.retractPostVote(duplicate.ID, post, vote)
+		return
+	}
+	delete(votes, duplicate.ID)
+	votes[primary.ID] = vote
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Pre-moderation
+
+var ErrNotPending = errors.New("post is not awaiting approval")
+
+// ApprovalStats describes a subreddit's approval queue. Latencies are from
+// submission to decision.
+type ApprovalStats struct {
+	Pending     int
+	Approved    int
+	Rejected    int
+	MeanLatency time.Duration
+	P95Latency  time.Duration
+}
+
+// queueForApproval holds a new post back from feeds when the subreddit
+// requires approval. Moderators' own posts skip the queue. Callers must hold
+// e.Mutex.
+func (e *Engine) queueForApproval(subReddit *SubReddit, post *Post) {
+	if !subReddit.Settings.RequireApproval || post.Removed || e.isModerator(post.Author, subReddit) {
+		return
+	}
+	post.Pending = true
+	subReddit.ApprovalQueue = append(subReddit.ApprovalQueue, post)
+}
+
+// dequeuePending takes post out of the approval queue and records how long it
+// waited. Callers must hold e.Mutex.
+func (e *Engine) dequeuePending(subReddit *SubReddit, post *Post) {
+	post.Pending = false
+	for i, queued := range subReddit.ApprovalQueue {
+		if queued == post {
+			subReddit.ApprovalQueue = append(subReddit.ApprovalQueue[:i], subReddit.ApprovalQueue[i+1:]...)
+			break
+		}
+	}
+	subReddit.ApprovalLatencies = append(subReddit.ApprovalLatencies, e.Clock.Now().Sub(post.CreatedAt))
+}
+
+// GetApprovalQueue returns the subreddit's pending posts, oldest first.
+func (e *Engine) GetApprovalQueue(mod *User, subRedditName string) ([]*Post, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	if !e.isModerator(mod, subReddit) {
+		return nil, ErrNotModerator
+	}
+	return append([]*Post(nil), subReddit.ApprovalQueue...), nil
+}
+
+// ApprovePost releases a pending post into feeds. Rejecting a pending post is
+// done with RemovePost.
+func (e *Engine) ApprovePost(mod *User, post *Post) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if e.isSuspended(mod) {
+		return ErrUserSuspended
+	}
+	if !e.isModerator(mod, subReddit) {
+		return ErrNotModerator
+	}
+	if !post.Pending {
+		return ErrNotPending
+	}
+	e.dequeuePending(subReddit, post)
+	subReddit.Approved++
+	e.recordModAction(subReddit, mod, "approve_post", post.ID, 0)
+	e.recordEvent("approve_post", mod.ID, subReddit.Name, post.ID)
+	return nil
+}
+
+// GetApprovalStats reports on the subreddit's approval queue.
+func (e *Engine) GetApprovalStats(subRedditName string) (ApprovalStats, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ApprovalStats{}, ErrSubRedditNotFound
+	}
+	stats := ApprovalStats{Pending: len(subReddit.ApprovalQueue), Approved: subReddit.Approved, Rejected: subReddit.Rejected}
+	if len(subReddit.ApprovalLatencies) > 0 {
+		sorted := append([]time.Duration(nil), subReddit.ApprovalLatencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var total time.Duration
+		for _, latency := range sorted {
+			total += latency
+		}
+		stats.MeanLatency = total / time.Duration(len(sorted))
+		stats.P95Latency = percentile(sorted, 0.95)
+	}
+	return stats, nil
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
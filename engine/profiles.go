@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Usernames and Profiles
+
+var (
+	ErrUsernameTaken      = errors.New("username already taken")
+	ErrInvalidUsername    = errors.New("invalid username")
+	ErrInvalidDisplayName = errors.New("display name must be 1-30 printable characters")
+	ErrInvalidAvatarURL   = errors.New("avatar URL must be an absolute http or https URL")
+	ErrBioTooLong         = errors.New("bio must be at most 200 characters")
+)
+
+const (
+	maxDisplayNameLength = 30
+	maxAvatarURLLength   = 2048
+	maxBioLength         = 200
+)
+
+// UserProfile is the public view of a user.
+type UserProfile struct {
+	ID                int64
+	Username          string
+	PreviousUsernames []string
+	DisplayName       string
+	AvatarURL         string
+	Bio               string
+	Karma             int
+	PostKarma         int
+	CommentKarma      int
+	Actions           int
+	Connected         bool
+	Trophies          []Trophy
+}
+
+// ChangeUsername renames a user. Old names stay reserved for the same user so
+// they can't be claimed by someone else and still resolve via
+// GetUserByUsername. Messages and memberships reference users by pointer/ID and
+// are unaffected.
+func (e *Engine) ChangeUsername(user *User, newName string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	newName = strings.TrimSpace(newName)
+	if newName == "" || newName == user.Username {
+		return ErrInvalidUsername
+	}
+	if ownerID, taken := e.Usernames[newName]; taken && ownerID != user.ID {
+		return ErrUsernameTaken
+	}
+	user.PreviousUsernames = append(user.PreviousUsernames, user.Username)
+	user.Username = newName
+	e.Usernames[newName] = user.ID
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("rename", user.ID, "", 0)
+	return nil
+}
+
+// GetUserByUsername returns the user with that username, or nil.
+func (e *Engine) GetUserByUsername(name string) *User {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	id, exists := e.Usernames[name]
+	if !exists {
+		return nil
+	}
+	return e.Users[id]
+}
+
+// GetUserProfile returns the user's public profile.
+func (e *Engine) GetUserProfile(user *User) UserProfile {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return e.userProfile(user)
+}
+
+// userProfile snapshots user. Callers must hold e.Mutex.
+func (e *Engine) userProfile(user *User) UserProfile {
+	return UserProfile{
+		ID:                user.ID,
+		Username:          user.Username,
+		PreviousUsernames: append([]string(nil), user.PreviousUsernames...),
+		DisplayName:       user.DisplayName,
+		AvatarURL:         user.AvatarURL,
+		Bio:               user.Bio,
+		Karma:             user.Karma(),
+		PostKarma:         user.PostKarma,
+		CommentKarma:      user.CommentKarma,
+		Actions:           user.Actions,
+		Connected:         user.Connected,
+		Trophies:          append([]Trophy(nil), e.Trophies[user.ID]...),
+	}
+}
+
+// ProfileUpdate changes the fields that are non-nil. An empty string clears a
+// field, except DisplayName, which falls back to the username when cleared.
+type ProfileUpdate struct {
+	DisplayName *string
+	AvatarURL   *string
+	Bio         *string
+}
+
+// UpdateProfile validates every field in update before applying any of them.
+func (e *Engine) UpdateProfile(user *User, update ProfileUpdate) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	if update.DisplayName != nil && *update.DisplayName != "" && !validDisplayName(*update.DisplayName) {
+		return ErrInvalidDisplayName
+	}
+	if update.AvatarURL != nil && *update.AvatarURL != "" && !validAvatarURL(*update.AvatarURL) {
+		return ErrInvalidAvatarURL
+	}
+	if update.Bio != nil && utf8.RuneCountInString(*update.Bio) > maxBioLength {
+		return ErrBioTooLong
+	}
+	if update.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.AvatarURL != nil {
+		user.AvatarURL = *update.AvatarURL
+	}
+	if update.Bio != nil {
+		user.Bio = *update.Bio
+	}
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("update_profile", user.ID, "", 0)
+	return nil
+}
+
+func validDisplayName(name string) bool {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func validAvatarURL(raw string) bool {
+	if len(raw) > maxAvatarURLLength {
+		return false
+	}
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// DisplayedName is what a frontend should show for user.
+func DisplayedName(user *User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Username
+}
+
+// Feed DTOs
+
+// AuthorSummary identifies a post's author in a FeedItem.
+type AuthorSummary struct {
+	ID          int64
+	Username    string
+	DisplayName string
+	AvatarURL   string `json:",omitempty"`
+}
+
+// FeedItem is one post as a feed presents it.
+type FeedItem struct {
+	PostID      int64
+	Permalink   string
+	SubReddit   string
+	Author      AuthorSummary
+	Content     string
+	URL         string       `json:",omitempty"`
+	Attachments []Attachment `json:",omitempty"`
+	Votes       int
+	Comments    int
+	CreatedAt   time.Time
+}
+
+// GetFeedItems returns the user's sorted feed flattened into render-ready
+// items.
+func (e *Engine) GetFeedItems(user *User, order FeedSort) []FeedItem {
+	feed := e.GetSortedFeed(user, order)
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	items := make([]FeedItem, 0, len(feed))
+	for _, post := range feed {
+		items = append(items, FeedItem{
+			PostID:    post.ID,
+			Permalink: PostPermalink(post),
+			SubReddit: post.SubReddit,
+			Author: AuthorSummary{
+				ID:          post.Author.ID,
+				Username:    post.Author.Username,
+				DisplayName: DisplayedName(post.Author),
+				AvatarURL:   post.Author.AvatarURL,
+			},
+			Content:     post.Content,
+			URL:         post.URL,
+			Attachments: post.Attachments,
+			Votes:       post.Votes,
+			Comments:    len(post.Comments),
+			CreatedAt:   post.CreatedAt,
+		})
+	}
+	return items
+}
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+)
+
+// Promoted Posts
+
+var (
+	ErrInvalidBudget   = errors.New("promotion budget and bid must be positive")
+	ErrNotPromoted     = errors.New("post is not promoted")
+	ErrAlreadyPromoted = errors.New("post is already promoted")
+)
+
+// defaultPromotedSlots are the feed positions, counted from zero, where
+// promoted posts are inserted.
+var defaultPromotedSlots = []int{1, 6, 15}
+
+// Promotion is a paid placement for a post. Budget is the number of
+// impressions still to serve and CPM the price per thousand impressions.
+// Serving and clicking a promotion only take the engine's read lock, so
+// Budget and the counters are guarded by the post's lock.
+type Promotion struct {
+	Post        *Post
+	Budget      int
+	CPM         float64
+	Impressions int
+	Clicks      int
+	Spend       float64
+}
+
+// PromotionStats totals every promotion. FillRate is the share of slots
+// offered that were filled.
+type PromotionStats struct {
+	Promotions   int
+	Active       int
+	Impressions  int
+	Clicks       int
+	SlotsOffered int
+	SlotsFilled  int
+	Spend        float64
+	FillRate     float64
+	CTR          float64
+	ECPM         float64
+	ECPC         float64
+}
+
+// PromotePost buys impressions for the author's own post.
+func (e *Engine) PromotePost(user *User, post *Post, impressions int, cpm float64) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	if post.Author != user {
+		return ErrNotAuthor
+	}
+	if impressions <= 0 || cpm <= 0 {
+		return ErrInvalidBudget
+	}
+	if _, exists := e.Promotions[post.ID]; exists {
+		return ErrAlreadyPromoted
+	}
+	e.Promotions[post.ID] = &Promotion{Post: post, Budget: impressions, CPM: cpm}
+	e.recordEvent("promote", user.ID, post.SubReddit, post.ID)
+	return nil
+}
+
+// SetPromotedSlots sets the feed positions promoted posts may fill.
+// Negative and duplicate positions are ignored.
+func (e *Engine) SetPromotedSlots(positions []int) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	sorted := append([]int(nil), positions...)
+	sort.Ints(sorted)
+	slots := make([]int, 0, len(sorted))
+	for _, position := range sorted {
+		if position >= 0 && (len(slots) == 0 || slots[len(slots)-1] != position) {
+			slots = append(slots, position)
+		}
+	}
+	e.PromotedSlots = slots
+}
+
+// GetPromotedFeed returns the user's sorted feed with promoted posts inserted
+// at the configured slots. Each slot goes to the highest bidder with budget
+// left that isn't already in the feed; every insertion is billed as one
+// impression. The second return value marks which positions are promoted.
+// Feeds are served under the engine's read lock, so concurrent feeds never
+// spend more of a promotion's budget than it has.
+func (e *Engine) GetPromotedFeed(user *User, order FeedSort) ([]*Post, []bool) {
+	organic := e.GetSortedFeed(user, order)
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	shown := make(map[int64]bool, len(organic))
+	for _, post := range organic {
+		shown[post.ID] = true
+	}
+	candidates := make([]*Promotion, 0, len(e.Promotions))
+	for _, promotion := range e.Promotions {
+		if !shown[promotion.Post.ID] && e.inFeeds(promotion.Post) && e.promotionActive(promotion) && !e.ageGated(user, promotion.Post) {
+			candidates = append(candidates, promotion)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].CPM != candidates[j].CPM {
+			return candidates[i].CPM > candidates[j].CPM
+		}
+		return candidates[i].Post.ID < candidates[j].Post.ID
+	})
+
+	slots := e.PromotedSlots
+	feed := make([]*Post, 0, len(organic)+len(slots))
+	promoted := make([]bool, 0, cap(feed))
+	organicIndex, slot, next := 0, 0, 0
+	for organicIndex < len(organic) || slot < len(slots) && slots[slot] == len(feed) {
+		if slot < len(slots) && slots[slot] == len(feed) {
+			slot++
+			atomic.AddInt64(&e.PromotionSlotsOffered, 1)
+			filled := false
+			for next < len(candidates) && !filled {
+				filled = e.billImpression(candidates[next])
+				next++
+			}
+			if filled {
+				atomic.AddInt64(&e.PromotionSlotsFilled, 1)
+				feed = append(feed, candidates[next-1].Post)
+				promoted = append(promoted, true)
+				continue
+			}
+		}
+		if organicIndex == len(organic) {
+			break
+		}
+		feed = append(feed, organic[organicIndex])
+		promoted = append(promoted, false)
+		organicIndex++
+	}
+	return feed, promoted
+}
+
+// RecordPromotionClick counts a click on a promoted post.
+func (e *Engine) RecordPromotionClick(post *Post) error {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	promotion, exists := e.Promotions[post.ID]
+	if !exists {
+		return ErrNotPromoted
+	}
+	lock := e.postLock(post.ID)
+	lock.Lock()
+	promotion.Clicks++
+	lock.Unlock()
+	return nil
+}
+
+// GetPromotionStats totals every promotion so far.
+func (e *Engine) GetPromotionStats() PromotionStats {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	stats := PromotionStats{
+		Promotions:   len(e.Promotions),
+		SlotsOffered: int(atomic.LoadInt64(&e.PromotionSlotsOffered)),
+		SlotsFilled:  int(atomic.LoadInt64(&e.PromotionSlotsFilled)),
+	}
+	for id, promotion := range e.Promotions {
+		lock := e.postLock(id)
+		lock.Lock()
+		if promotion.Budget > 0 {
+			stats.Active++
+		}
+		stats.Impressions += promotion.Impressions
+		stats.Clicks += promotion.Clicks
+		stats.Spend += promotion.Spend
+		lock.Unlock()
+	}
+	if stats.SlotsOffered > 0 {
+		stats.FillRate = float64(stats.SlotsFilled) / float64(stats.SlotsOffered)
+	}
+	if stats.Impressions > 0 {
+		stats.CTR = float64(stats.Clicks) / float64(stats.Impressions)
+		stats.ECPM = stats.Spend / float64(stats.Impressions) * 1000
+	}
+	if stats.Clicks > 0 {
+		stats.ECPC = stats.Spend / float64(stats.Clicks)
+	}
+	return stats
+}
+
+// promotionActive reports whether promotion has budget left. Callers must
+// hold e.Mutex's read lock.
+func (e *Engine) promotionActive(promotion *Promotion) bool {
+	lock := e.postLock(promotion.Post.ID)
+	lock.Lock()
+	defer lock.Unlock()
+	return promotion.Budget > 0
+}
+
+// billImpression charges promotion for one impression, or reports false if
+// a concurrent feed spent the last of its budget first. Callers must hold
+// e.Mutex's read lock.
+func (e *Engine) billImpression(promotion *Promotion) bool {
+	lock := e.postLock(promotion.Post.ID)
+	lock.Lock()
+	defer lock.Unlock()
+	if promotion.Budget <= 0 {
+		return false
+	}
+	promotion.Budget--
+	promotion.Impressions++
+	promotion.Spend += promotion.CPM / 1000
+	return true
+}
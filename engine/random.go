@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Randomness
+
+// Every random choice the engine and the simulator make is drawn from
+// e.Rand, so a run can be repeated by replacing it with NewRand(seed) before
+// the engine is shared between goroutines.
+
+// lockedSource makes a rand.Source safe for concurrent use, as the source
+// behind the top-level math/rand functions is.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// NewRand returns a generator seeded with seed that may be shared between
+// goroutines. A run draws the same numbers from it for the same seed only
+// as long as the draws happen in the same order, so code that draws from
+// several goroutines at once should give each its own generator seeded from
+// this one.
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Ranking
+
+// FeedSort is the order a feed is ranked in.
+type FeedSort int
+
+const (
+	SortNew FeedSort = iota
+	SortHot
+	SortPersonalized
+	// SortBest orders by the lower bound of the 95% Wilson score interval
+	// of the upvote fraction, ignoring age.
+	SortBest
+	// SortKarmaWeighted is SortHot with votes scaled by voter karma, so
+	// votes from new, low-karma accounts move posts very little.
+	SortKarmaWeighted
+	// SortControversial puts posts with many votes split evenly between up
+	// and down first.
+	SortControversial
+)
+
+// RankingConstants tune the hot-family rankings. HotDecay is how much newer
+// a post has to be to outrank one with ten times its score, and
+// PersonalizationWeight how many hot-score units full affinity for a
+// subreddit is worth.
+type RankingConstants struct {
+	HotDecay              time.Duration
+	PersonalizationWeight float64
+}
+
+// DefaultRankingConstants use the 12.5 hour decay constant of Reddit's hot
+// ranking.
+var DefaultRankingConstants = RankingConstants{HotDecay: 45000 * time.Second, PersonalizationWeight: 2}
+
+// hotScore ranks by decayed votes, so necro-votes on old posts can't lift
+// them back up the way fresh votes would.
+func hotScore(post *Post, ranking RankingConstants) float64 {
+	return decayedScore(post.WeightedVotes, post.CreatedAt.Unix(), ranking)
+}
+
+func decayedScore(votes float64, createdAt int64, ranking RankingConstants) float64 {
+	order := math.Log10(math.Max(math.Abs(votes), 1))
+	sign := 0.0
+	if votes > 0 {
+		sign = 1
+	} else if votes < 0 {
+		sign = -1
+	}
+	return sign*order + float64(createdAt)/ranking.HotDecay.Seconds()
+}
+
+// wilsonLowerBound is the Wilson score lower bound used by SortBest.
+func wilsonLowerBound(upvotes, downvotes int) float64 {
+	const z = 1.96
+	n := float64(upvotes + downvotes)
+	if n == 0 {
+		return 0
+	}
+	p := float64(upvotes) / n
+	return (p + z*z/(2*n) - z*math.Sqrt((p*(1-p)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
+
+// controversy is Reddit's controversial score: the vote count raised to the
+// balance between up and down votes, so only posts with both score above 0.
+func controversy(upvotes, downvotes int) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	magnitude := float64(upvotes + downvotes)
+	balance := float64(min(upvotes, downvotes)) / float64(max(upvotes, downvotes))
+	return math.Pow(magnitude, balance)
+}
+
+// GetSortedFeed returns the user's feed ordered by the requested sort. The
+// feed, the user's affinities and the votes and ages the sort ranks by are
+// read under the engine lock, and the sort runs after it is released on
+// that copy, so votes cast meanwhile take effect on the next read. With a
+// ranking pool enabled, scores come from its cache instead.
+func (e *Engine) GetSortedFeed(user *User, order FeedSort) []*Post {
+	feed := e.GetUserFeed(user)
+	var affinity map[string]float64
+	if order == SortPersonalized {
+		affinity = e.GetInterestVector(user)
+	}
+	e.Mutex.RLock()
+	ranking := e.Ranking
+	inputs := scoreInputs(feed)
+	e.Mutex.RUnlock()
+	if e.rankingPool != nil {
+		e.rankingPool.sortPosts(feed, inputs, order, affinity)
+		return feed
+	}
+	sortPosts(feed, inputs, order, affinity, ranking)
+	return feed
+}
+
+// SortPosts orders posts in place using DefaultRankingConstants. affinity
+// is only consulted for SortPersonalized. Nothing may vote on the posts
+// while they are sorted; GetSortedFeed sorts a live engine's posts.
+func SortPosts(posts []*Post, order FeedSort, affinity map[string]float64) {
+	sortPosts(posts, scoreInputs(posts), order, affinity, DefaultRankingConstants)
+}
+
+// scoreInputs snapshots what each post is ranked by. Callers must hold
+// e.Mutex.
+func scoreInputs(posts []*Post) []scoreInput {
+	inputs := make([]scoreInput, len(posts))
+	for i, post := range posts {
+		inputs[i] = snapshotScoreInput(post)
+	}
+	return inputs
+}
+
+// rankedPosts sorts posts by the score inputs snapshotted from them,
+// keeping the two slices in step.
+type rankedPosts struct {
+	posts  []*Post
+	inputs []scoreInput
+	less   func(a, b *scoreInput) bool
+}
+
+func (r rankedPosts) Len() int           { return len(r.posts) }
+func (r rankedPosts) Less(i, j int) bool { return r.less(&r.inputs[i], &r.inputs[j]) }
+func (r rankedPosts) Swap(i, j int) {
+	r.posts[i], r.posts[j] = r.posts[j], r.posts[i]
+	r.inputs[i], r.inputs[j] = r.inputs[j], r.inputs[i]
+}
+
+// sortPosts orders posts by inputs, their snapshotted score inputs. Posts
+// that score the same are ordered by ID, so a feed's order doesn't depend
+// on the order its posts were collected in.
+func sortPosts(posts []*Post, inputs []scoreInput, order FeedSort, affinity map[string]float64, ranking RankingConstants) {
+	var score func(in *scoreInput) float64
+	switch order {
+	case SortHot:
+		score = func(in *scoreInput) float64 { return decayedScore(in.weightedVotes, in.createdAt.Unix(), ranking) }
+	case SortPersonalized:
+		score = func(in *scoreInput) float64 {
+			return decayedScore(in.weightedVotes, in.createdAt.Unix(), ranking) + ranking.PersonalizationWeight*affinity[in.subReddit]
+		}
+	case SortBest:
+		score = func(in *scoreInput) float64 { return wilsonLowerBound(in.upvotes, in.downvotes) }
+	case SortKarmaWeighted:
+		score = func(in *scoreInput) float64 { return decayedScore(in.karmaWeightedVotes, in.createdAt.Unix(), ranking) }
+	case SortControversial:
+		score = func(in *scoreInput) float64 { return controversy(in.upvotes, in.downvotes) }
+	default:
+		sort.Sort(rankedPosts{posts: posts, inputs: inputs, less: func(a, b *scoreInput) bool {
+			if !a.createdAt.Equal(b.createdAt) {
+				return a.createdAt.After(b.createdAt)
+			}
+			return a.id > b.id
+		}})
+		return
+	}
+	sort.Sort(rankedPosts{posts: posts, inputs: inputs, less: func(a, b *scoreInput) bool {
+		if sa, sb := score(a), score(b); sa != sb {
+			return sa > sb
+		}
+		return a.id < b.id
+	}})
+}
+
+// ListedPosts returns every post that may appear in feeds, across all
+// subreddits, in ID order so ties keep the same order when it is sorted.
+func (e *Engine) ListedPosts() []*Post {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var posts []*Post
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			if e.inFeeds(post) {
+				posts = append(posts, post)
+			}
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts
+}
@@ -0,0 +1,301 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ranking Compute Pool
+
+var DefaultRankingPoolOptions = RankingPoolOptions{Workers: 2, QueueSize: 1024, MaxStaleness: 250 * time.Millisecond}
+
+// RankingPoolOptions configures a RankingPool.
+type RankingPoolOptions struct {
+	Workers   int
+	QueueSize int
+	// MaxStaleness bounds how long after a vote a feed may still be ranked
+	// with the post's old scores. Past it, the read recomputes them itself.
+	MaxStaleness time.Duration
+}
+
+// PostScores are a post's precomputed ranking scores.
+type PostScores struct {
+	Hot           float64
+	Best          float64
+	Controversial float64
+	KarmaWeighted float64
+}
+
+// scoreInput is a snapshot of the vote tallies the scores depend on, taken
+// under the engine lock so workers can compute without it.
+type scoreInput struct {
+	id                 int64
+	subReddit          string
+	weightedVotes      float64
+	karmaWeightedVotes float64
+	upvotes            int
+	downvotes          int
+	createdAt          time.Time
+}
+
+func snapshotScoreInput(post *Post) scoreInput {
+	return scoreInput{
+		id:                 post.ID,
+		subReddit:          post.SubReddit,
+		weightedVotes:      post.WeightedVotes,
+		karmaWeightedVotes: post.KarmaWeightedVotes,
+		upvotes:            post.Upvotes,
+		downvotes:          post.Downvotes,
+		createdAt:          post.CreatedAt,
+	}
+}
+
+func (in scoreInput) scores(ranking RankingConstants) PostScores {
+	return PostScores{
+		Hot:           decayedScore(in.weightedVotes, in.createdAt.Unix(), ranking),
+		Best:          wilsonLowerBound(in.upvotes, in.downvotes),
+		Controversial: controversy(in.upvotes, in.downvotes),
+		KarmaWeighted: decayedScore(in.karmaWeightedVotes, in.createdAt.Unix(), ranking),
+	}
+}
+
+// RankingPool keeps every voted-on post's ranking scores cached and
+// recomputes them on a bounded set of workers as votes arrive, so sorting a
+// feed is a lookup per post instead of a score computation per comparison.
+// Votes on a post already queued coalesce into one recomputation. When the
+// queue is full the post's cached scores are left to go stale, and a feed
+// read that finds them older than MaxStaleness recomputes them inline.
+type RankingPool struct {
+	opts RankingPoolOptions
+	jobs chan int64
+	wg   sync.WaitGroup
+
+	mu sync.Mutex
+	// pending holds the newest snapshot of each post awaiting a worker.
+	pending map[int64]scoreInput
+	cache   map[int64]PostScores
+	// dirty is when each post's cached scores first fell behind its votes.
+	dirty   map[int64]time.Time
+	version map[int64]int64
+	// ranking is what scores are computed with. generation counts changes
+	// to it, so workers drop scores computed with constants since replaced.
+	ranking    RankingConstants
+	generation int64
+	closed     bool
+	stats      RankingPoolStats
+}
+
+// RankingPoolStats counts a RankingPool's work.
+type RankingPoolStats struct {
+	Workers   int
+	Computed  int64
+	Coalesced int64
+	Dropped   int64
+	// Hits are scores served from the cache and Misses scores computed on
+	// the read path, either because the post had none cached or because
+	// they were older than MaxStaleness (counted in StaleRecomputes too).
+	Hits            int64
+	Misses          int64
+	StaleRecomputes int64
+	// StaleHits are hits on scores that had fallen behind the post's votes,
+	// and MeanStaleness and MaxStaleness how far behind they were.
+	StaleHits     int64
+	MeanStaleness time.Duration
+	MaxStaleness  time.Duration
+	totalStale    time.Duration
+}
+
+// NewRankingPool starts a RankingPool's workers.
+func NewRankingPool(opts RankingPoolOptions) *RankingPool {
+	pool := &RankingPool{
+		opts:    opts,
+		jobs:    make(chan int64, opts.QueueSize),
+		pending: make(map[int64]scoreInput),
+		cache:   make(map[int64]PostScores),
+		dirty:   make(map[int64]time.Time),
+		version: make(map[int64]int64),
+		ranking: DefaultRankingConstants,
+	}
+	pool.stats.Workers = opts.Workers
+	for i := 0; i < opts.Workers; i++ {
+		pool.wg.Add(1)
+		go pool.work()
+	}
+	return pool
+}
+
+// EnableRankingPool makes GetSortedFeed rank with the pool's cached scores.
+// It must be called before the engine is shared between goroutines.
+func (e *Engine) EnableRankingPool(pool *RankingPool) {
+	e.rankingPool = pool
+	pool.setRanking(e.Ranking)
+}
+
+// setRanking switches the pool to new ranking constants, dropping every
+// cached score so none computed with the old ones is served.
+func (p *RankingPool) setRanking(ranking RankingConstants) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ranking == ranking {
+		return
+	}
+	p.ranking = ranking
+	p.generation++
+	clear(p.cache)
+	clear(p.dirty)
+}
+
+// invalidate queues a post for recomputation after a vote. It never blocks.
+// Callers must hold e.Mutex.
+func (p *RankingPool) invalidate(post *Post) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.version[post.ID]++
+	if _, dirty := p.dirty[post.ID]; !dirty {
+		p.dirty[post.ID] = time.Now()
+	}
+	_, queued := p.pending[post.ID]
+	p.pending[post.ID] = snapshotScoreInput(post)
+	if queued {
+		p.stats.Coalesced++
+		return
+	}
+	select {
+	case p.jobs <- post.ID:
+	default:
+		delete(p.pending, post.ID)
+		p.stats.Dropped++
+	}
+}
+
+func (p *RankingPool) work() {
+	defer p.wg.Done()
+	for id := range p.jobs {
+		p.mu.Lock()
+		input, queued := p.pending[id]
+		delete(p.pending, id)
+		version := p.version[id]
+		ranking, generation := p.ranking, p.generation
+		p.mu.Unlock()
+		if !queued {
+			continue
+		}
+		scores := input.scores(ranking)
+		p.mu.Lock()
+		if p.generation == generation {
+			p.cache[id] = scores
+			if p.version[id] == version {
+				delete(p.dirty, id)
+			}
+		}
+		p.stats.Computed++
+		p.mu.Unlock()
+	}
+}
+
+// scoresFor returns the scores of each post, from the cache where they are
+// fresh enough and computed from inputs, the post's score inputs
+// snapshotted under the engine lock, otherwise.
+func (p *RankingPool) scoresFor(posts []*Post, inputs []scoreInput) map[*Post]PostScores {
+	scores := make(map[*Post]PostScores, len(posts))
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, post := range posts {
+		cached, hit := p.cache[post.ID]
+		since, dirty := p.dirty[post.ID]
+		if hit && dirty && now.Sub(since) > p.opts.MaxStaleness {
+			hit = false
+			p.stats.StaleRecomputes++
+		}
+		if !hit {
+			cached = inputs[i].scores(p.ranking)
+			p.stats.Misses++
+			if _, queued := p.pending[post.ID]; !queued {
+				p.cache[post.ID] = cached
+				delete(p.dirty, post.ID)
+			}
+		} else {
+			p.stats.Hits++
+			if dirty {
+				staleness := now.Sub(since)
+				p.stats.StaleHits++
+				p.stats.totalStale += staleness
+				p.stats.MaxStaleness = max(p.stats.MaxStaleness, staleness)
+			}
+		}
+		scores[post] = cached
+	}
+	return scores
+}
+
+// sortPosts is the package sortPosts using cached scores, with ties
+// likewise ordered by ID.
+func (p *RankingPool) sortPosts(posts []*Post, inputs []scoreInput, order FeedSort, affinity map[string]float64) {
+	p.mu.Lock()
+	ranking := p.ranking
+	p.mu.Unlock()
+	var key func(PostScores, *Post) float64
+	switch order {
+	case SortHot:
+		key = func(s PostScores, _ *Post) float64 { return s.Hot }
+	case SortPersonalized:
+		key = func(s PostScores, post *Post) float64 {
+			return s.Hot + ranking.PersonalizationWeight*affinity[post.SubReddit]
+		}
+	case SortBest:
+		key = func(s PostScores, _ *Post) float64 { return s.Best }
+	case SortControversial:
+		key = func(s PostScores, _ *Post) float64 { return s.Controversial }
+	case SortKarmaWeighted:
+		key = func(s PostScores, _ *Post) float64 { return s.KarmaWeighted }
+	default:
+		sortPosts(posts, inputs, order, affinity, ranking)
+		return
+	}
+	scores := p.scoresFor(posts, inputs)
+	keys := make(map[*Post]float64, len(posts))
+	for post, s := range scores {
+		keys[post] = key(s, post)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if ki, kj := keys[posts[i]], keys[posts[j]]; ki != kj {
+			return ki > kj
+		}
+		return posts[i].ID < posts[j].ID
+	})
+}
+
+// Stats reports the pool's work so far.
+func (p *RankingPool) Stats() RankingPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	if stats.StaleHits > 0 {
+		stats.MeanStaleness = stats.totalStale / time.Duration(stats.StaleHits)
+	}
+	return stats
+}
+
+// Close stops the workers once they have drained the queue.
+func (p *RankingPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.jobs)
+	p.mu.Unlock()
+	p.wg.Wait()
+}
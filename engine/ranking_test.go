@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestSortedFeedWhileVoting sorts feeds in every order while votes land on
+// the posts being sorted; run with -race to check the sort reads nothing
+// the voters write.
+func TestSortedFeedWhileVoting(t *testing.T) {
+	for _, pooled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pooled=%t", pooled), func(t *testing.T) {
+			e, author, voter := newTestSite(t)
+			if pooled {
+				pool := NewRankingPool(DefaultRankingPoolOptions)
+				defer pool.Close()
+				e.EnableRankingPool(pool)
+			}
+			var posts []*Post
+			for i := 0; i < 20; i++ {
+				post, err := e.CreatePost(author, "news", fmt.Sprintf("Post %d", i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				posts = append(posts, post)
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					post := posts[i%len(posts)]
+					if i%2 == 0 {
+						e.UpvotePost(voter, post)
+					} else {
+						e.DownvotePost(voter, post)
+					}
+				}
+			}()
+			for i := 0; i < 50; i++ {
+				for order := SortNew; order <= SortControversial; order++ {
+					if feed := e.GetSortedFeed(voter, order); len(feed) != len(posts) {
+						t.Fatalf("sort %d returned %d posts, want %d", order, len(feed), len(posts))
+					}
+				}
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// TestSortedFeedTiesByID checks that posts scoring the same come out in ID
+// order however the subreddits holding them are iterated.
+func TestSortedFeedTiesByID(t *testing.T) {
+	for _, pooled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pooled=%t", pooled), func(t *testing.T) {
+			e, author, voter := newTestSite(t)
+			if pooled {
+				pool := NewRankingPool(DefaultRankingPoolOptions)
+				defer pool.Close()
+				e.EnableRankingPool(pool)
+			}
+			for i := 0; i < 8; i++ {
+				name := fmt.Sprintf("sub%d", i)
+				if _, err := e.CreateSubReddit(name); err != nil {
+					t.Fatal(err)
+				}
+				for _, user := range []*User{author, voter} {
+					if err := e.JoinSubReddit(user, name); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for j := 0; j < 3; j++ {
+					if _, err := e.CreatePost(author, name, fmt.Sprintf("Post %d.%d", i, j)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			// Unvoted posts all score zero for best and controversial.
+			for _, order := range []FeedSort{SortBest, SortControversial} {
+				for i := 0; i < 10; i++ {
+					feed := e.GetSortedFeed(voter, order)
+					for k := 1; k < len(feed); k++ {
+						if feed[k-1].ID > feed[k].ID {
+							t.Fatalf("sort %d put post %d before post %d", order, feed[k-1].ID, feed[k].ID)
+						}
+					}
+				}
+			}
+		})
+	}
+}
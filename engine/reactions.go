@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+)
+
+// Comment Reactions
+
+var (
+	ErrUnknownReaction = errors.New("reaction not enabled in this subreddit")
+	ErrAlreadyReacted  = errors.New("user already reacted with this emoji")
+	ErrNotReacted      = errors.New("user has not reacted with this emoji")
+)
+
+// DefaultReactions apply to subreddits whose settings don't list their own.
+var DefaultReactions = []string{"👍", "😂", "❤️", "😮", "😢", "🔥"}
+
+type reactionKey struct {
+	userID int64
+	emoji  string
+}
+
+// allowedReaction reports whether emoji is enabled in subReddit.
+func allowedReaction(subReddit *SubReddit, emoji string) bool {
+	allowed := subReddit.Settings.Reactions
+	if allowed == nil {
+		allowed = DefaultReactions
+	}
+	for _, candidate := range allowed {
+		if candidate == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+// React adds user's emoji reaction to comment. Users may react with several
+// different emojis but only once with each.
+func (e *Engine) React(user *User, comment *Comment, emoji string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return ErrUserSuspended
+	}
+	subReddit, exists := e.SubReddits[comment.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if !allowedReaction(subReddit, emoji) {
+		return ErrUnknownReaction
+	}
+	reacted := e.CommentReactions[comment.ID]
+	if reacted == nil {
+		reacted = make(map[reactionKey]bool)
+		e.CommentReactions[comment.ID] = reacted
+	}
+	key := reactionKey{userID: user.ID, emoji: emoji}
+	if reacted[key] {
+		return ErrAlreadyReacted
+	}
+	reacted[key] = true
+	if comment.Reactions == nil {
+		comment.Reactions = make(map[string]int)
+	}
+	comment.Reactions[emoji]++
+	e.ReactionCounts[emoji]++
+	e.TotalReactions++
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("react", user.ID, comment.SubReddit, comment.ID)
+	return nil
+}
+
+// Unreact removes a reaction added with React.
+func (e *Engine) Unreact(user *User, comment *Comment, emoji string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	key := reactionKey{userID: user.ID, emoji: emoji}
+	if !e.CommentReactions[comment.ID][key] {
+		return ErrNotReacted
+	}
+	delete(e.CommentReactions[comment.ID], key)
+	comment.Reactions[emoji]--
+	if comment.Reactions[emoji] == 0 {
+		delete(comment.Reactions, emoji)
+	}
+	e.ReactionCounts[emoji]--
+	e.TotalReactions--
+	e.recordEvent("unreact", user.ID, comment.SubReddit, comment.ID)
+	return nil
+}
+
+// ReactionCount is how many users reacted to a comment with an emoji.
+type ReactionCount struct {
+	Emoji string
+	Count int
+}
+
+// reactionBreakdown orders tallies most popular first, ties by emoji.
+func reactionBreakdown(tallies map[string]int) []ReactionCount {
+	breakdown := make([]ReactionCount, 0, len(tallies))
+	for emoji, count := range tallies {
+		breakdown = append(breakdown, ReactionCount{Emoji: emoji, Count: count})
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Count != breakdown[j].Count {
+			return breakdown[i].Count > breakdown[j].Count
+		}
+		return breakdown[i].Emoji < breakdown[j].Emoji
+	})
+	return breakdown
+}
+
+// GetReactions tallies the comment's reactions, most popular first.
+func (e *Engine) GetReactions(comment *Comment) []ReactionCount {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return reactionBreakdown(comment.Reactions)
+}
+
+// GetReactionTotals returns site-wide reaction counts.
+func (e *Engine) GetReactionTotals() []ReactionCount {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return reactionBreakdown(e.ReactionCounts)
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	if counts == nil {
+		return nil
+	}
+	copied := make(map[string]int, len(counts))
+	for key, count := range counts {
+		copied[key] = count
+	}
+	return copied
+}
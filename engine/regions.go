@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Regions and Latency Model
+
+// Region is where users act from, and how far it is from the engine.
+type Region struct {
+	Name string
+	// RTT to the engine's region and exponential jitter on top of it.
+	BaseLatency time.Duration
+	Jitter      time.Duration
+}
+
+var DefaultRegions = []Region{
+	{Name: "us-east", BaseLatency: 5 * time.Millisecond, Jitter: 2 * time.Millisecond},
+	{Name: "us-west", BaseLatency: 35 * time.Millisecond, Jitter: 5 * time.Millisecond},
+	{Name: "eu-west", BaseLatency: 45 * time.Millisecond, Jitter: 8 * time.Millisecond},
+	{Name: "ap-south", BaseLatency: 110 * time.Millisecond, Jitter: 25 * time.Millisecond},
+}
+
+// crossRegionPenalty is the extra hop paid when a user acts on a subreddit
+// homed in another region.
+const crossRegionPenalty = 40 * time.Millisecond
+
+// LatencyModel samples network latencies for users in its regions.
+type LatencyModel struct {
+	Regions []Region
+	byName  map[string]Region
+	rng     *rand.Rand
+}
+
+// NewLatencyModel returns a model of the given regions that draws from rng,
+// typically the engine's Rand.
+func NewLatencyModel(regions []Region, rng *rand.Rand) *LatencyModel {
+	model := &LatencyModel{Regions: regions, byName: make(map[string]Region), rng: rng}
+	for _, region := range regions {
+		model.byName[region.Name] = region
+	}
+	return model
+}
+
+// RandomRegion picks one of the model's regions uniformly.
+func (m *LatencyModel) RandomRegion() string {
+	return m.Regions[m.rng.Intn(len(m.Regions))].Name
+}
+
+// Sample returns a simulated network latency for a user in userRegion acting
+// on a subreddit homed in homeRegion.
+func (m *LatencyModel) Sample(userRegion, homeRegion string) time.Duration {
+	region := m.byName[userRegion]
+	latency := region.BaseLatency + time.Duration(m.rng.ExpFloat64()*float64(region.Jitter))
+	if homeRegion != "" && homeRegion != userRegion {
+		latency += crossRegionPenalty + time.Duration(m.rng.ExpFloat64()*float64(m.byName[homeRegion].Jitter))
+	}
+	return latency
+}
+
+// AssignRegions places every user, and every subreddit's home, in a random
+// region of the model. Users are placed in ID order and subreddits in name
+// order so a seeded model places them the same way every run.
+func (e *Engine) AssignRegions(model *LatencyModel) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	for id := e.IDOffset + 1; id < e.UserID; id++ {
+		if user := e.Users[id]; user != nil {
+			user.Region = model.RandomRegion()
+		}
+	}
+	names := make([]string, 0, len(e.SubReddits))
+	for name := range e.SubReddits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e.SubReddits[name].HomeRegion = model.RandomRegion()
+	}
+}
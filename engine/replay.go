@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Event Log Persistence and Replay
+
+// SaveEventLog writes the event log as JSON lines, one event per line.
+func (e *Engine) SaveEventLog(w io.Writer) error {
+	e.Mutex.RLock()
+	events := append([]Event(nil), e.Events...)
+	e.Mutex.RUnlock()
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// LoadEventLog reads an event log written by SaveEventLog.
+func LoadEventLog(r io.Reader) ([]Event, error) {
+	var events []Event
+	decoder := json.NewDecoder(r)
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+// nonActionEvents are logged for bookkeeping but don't count toward
+// TotalActions. Any event type not listed here or in replayBreakdown is
+// treated as a custom action.
+var nonActionEvents = map[string]bool{
+	"register":               true,
+	"create_subreddit":       true,
+	"update_settings":        true,
+	"set_comment_sort":       true,
+	"vote_anomaly":           true,
+	"remove_post":            true,
+	"approve_post":           true,
+	"remove_comment":         true,
+	"sticky_comment":         true,
+	"unsticky_comment":       true,
+	"schedule_unlock":        true,
+	"unlock_subreddit":       true,
+	"connect":                true,
+	"onboard":                true,
+	"default_join":           true,
+	"default_opt_out":        true,
+	"disconnect":             true,
+	"broadcast":              true,
+	"churn":                  true,
+	"promote":                true,
+	"automod_remove_post":    true,
+	"automod_remove_comment": true,
+	"automod_ban":            true,
+	"file_ban_appeal":        true,
+	"accept_ban_appeal":      true,
+	"deny_ban_appeal":        true,
+	"merge_accounts":         true,
+	"control_pause":          true,
+	"control_resume":         true,
+	"control_rate":           true,
+	"control_add_users":      true,
+	"control_inject":         true,
+	"dm_request":             true,
+	"start_live_thread":      true,
+	"end_live_thread":        true,
+	"set_age":                true,
+	"mark_nsfw":              true,
+	"unmark_nsfw":            true,
+}
+
+var replayBreakdown = map[string]string{
+	"post":               "Posts",
+	"repost":             "Posts",
+	"comment":            "Comments",
+	"reply":              "Comments",
+	"upvote":             "Votes",
+	"downvote":           "Votes",
+	"comment_upvote":     "Votes",
+	"comment_downvote":   "Votes",
+	"unvote":             "",
+	"comment_unvote":     "",
+	"message":            "Messages",
+	"join":               "",
+	"leave":              "",
+	"rename":             "",
+	"edit_comment":       "",
+	"delete_post":        "",
+	"delete_comment":     "",
+	"update_profile":     "",
+	"react":              "",
+	"unreact":            "",
+	"mute":               "",
+	"unmute":             "",
+	"follow":             "",
+	"unfollow":           "",
+	"accept_dm_request":  "",
+	"decline_dm_request": "",
+}
+
+// ReplayMetrics are the totals a replayed event log reproduces.
+type ReplayMetrics struct {
+	Events           int
+	Users            int
+	SubReddits       int
+	TotalPosts       int
+	TotalVotes       int
+	TotalComments    int
+	TotalMessages    int
+	TotalActions     int
+	SimulatedTime    time.Duration
+	ActionBreakdown  map[string]int
+	SubRedditMembers map[string]int
+	SubRedditPosts   map[string]int
+}
+
+// ReplayEvents recomputes the simulation metrics from an event log alone.
+func ReplayEvents(events []Event) ReplayMetrics {
+	m := ReplayMetrics{
+		Events:           len(events),
+		ActionBreakdown:  map[string]int{"Posts": 0, "Comments": 0, "Votes": 0, "Messages": 0},
+		SubRedditMembers: make(map[string]int),
+		SubRedditPosts:   make(map[string]int),
+	}
+	members := make(map[string]map[int64]bool)
+	for _, event := range events {
+		switch event.Type {
+		case "register":
+			m.Users++
+		case "create_subreddit":
+			m.SubReddits++
+			members[event.SubReddit] = make(map[int64]bool)
+		case "join", "default_join":
+			if members[event.SubReddit] != nil {
+				members[event.SubReddit][event.UserID] = true
+			}
+		case "leave", "default_opt_out":
+			delete(members[event.SubReddit], event.UserID)
+		case "post", "repost":
+			m.TotalPosts++
+			m.SubRedditPosts[event.SubReddit]++
+		case "comment", "reply":
+			m.TotalComments++
+		case "upvote", "downvote", "comment_upvote", "comment_downvote":
+			m.TotalVotes++
+		case "message":
+			m.TotalMessages++
+		}
+
+		if nonActionEvents[event.Type] {
+			continue
+		}
+		m.TotalActions++
+		bucket, builtin := replayBreakdown[event.Type]
+		if !builtin {
+			bucket = event.Type
+		}
+		if bucket != "" {
+			m.ActionBreakdown[bucket]++
+		}
+	}
+	for name, users := range members {
+		m.SubRedditMembers[name] = len(users)
+	}
+	if len(events) > 1 {
+		m.SimulatedTime = events[len(events)-1].Time.Sub(events[0].Time)
+	}
+	return m
+}
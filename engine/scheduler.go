@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Scheduler
+
+// scheduledJob runs once the engine clock reaches at. Jobs due at the same
+// time run in the order they were scheduled.
+type scheduledJob struct {
+	at   time.Time
+	seq  int64
+	name string
+	run  func()
+}
+
+type jobQueue []*scheduledJob
+
+func (q jobQueue) Len() int { return len(q) }
+func (q jobQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q jobQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *jobQueue) Push(x interface{}) { *q = append(*q, x.(*scheduledJob)) }
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	job := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return job
+}
+
+// schedule queues run for when the engine clock reaches at. run is called
+// with e.Mutex held and must re-check whatever it acts on, since the state
+// that scheduled it may have changed. Callers must hold e.Mutex.
+func (e *Engine) schedule(at time.Time, name string, run func()) {
+	e.jobSeq++
+	heap.Push(&e.jobs, &scheduledJob{at: at, seq: e.jobSeq, name: name, run: run})
+}
+
+// RunScheduled runs every job that has come due on the engine clock, oldest
+// first, and returns how many ran. Nothing runs jobs on its own: whatever
+// advances the clock, such as the simulator, calls this after it does, and
+// StartScheduler calls it periodically for a clock that runs by itself.
+func (e *Engine) RunScheduled() int {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	now := e.Clock.Now()
+	ran := 0
+	for len(e.jobs) > 0 && !now.Before(e.jobs[0].at) {
+		job := heap.Pop(&e.jobs).(*scheduledJob)
+		job.run()
+		e.ScheduledRuns[job.name]++
+		ran++
+	}
+	return ran
+}
+
+// StartScheduler runs due jobs every interval of real time until the
+// returned stop function is called.
+func (e *Engine) StartScheduler(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				e.RunScheduled()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// NextScheduled returns when the earliest pending job is due, or false if
+// none is pending.
+func (e *Engine) NextScheduled() (time.Time, bool) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	if len(e.jobs) == 0 {
+		return time.Time{}, false
+	}
+	return e.jobs[0].at, true
+}
@@ -0,0 +1,444 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Shared Counters and Caches
+
+// SharedStore holds state that several engine or API instances may share:
+// hot counters (votes, karma) and cached feeds. MemoryStore keeps it in
+// process; RedisStore puts it in Redis.
+type SharedStore interface {
+	Name() string
+	IncrBy(key string, delta int64) (int64, error)
+	Counter(key string) (int64, error)
+	SetCache(key string, value []byte, ttl time.Duration) error
+	GetCache(key string) ([]byte, bool, error)
+	Close() error
+}
+
+const feedCacheTTL = 30 * time.Second
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryStore is a SharedStore private to one process. Its cache entries
+// never expire.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	cache    map[string]memoryCacheEntry
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]int64), cache: make(map[string]memoryCacheEntry)}
+}
+
+func (s *MemoryStore) Name() string { return "memory" }
+
+func (s *MemoryStore) IncrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[key] += delta
+	return s.counters[key], nil
+}
+
+func (s *MemoryStore) Counter(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key], nil
+}
+
+func (s *MemoryStore) SetCache(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[key] = memoryCacheEntry{value: append([]byte(nil), value...), expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) GetCache(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, exists := s.cache[key]
+	if !exists || time.Now().After(entry.expiresAt) {
+		delete(s.cache, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
+
+// RedisStore speaks the RESP protocol over a single connection, which it
+// redials after any error that may have left a reply unread on it. Keys are
+// namespaced with prefix so several simulations can share one Redis.
+type RedisStore struct {
+	mu      sync.Mutex
+	addr    string
+	conn    net.Conn
+	rw      *bufio.ReadWriter
+	prefix  string
+	timeout time.Duration
+}
+
+var errRedisNil = errors.New("redis: nil reply")
+
+// redisError is an error reply from the server. The reply has been read in
+// full, so the connection is still usable after one.
+type redisError string
+
+func (err redisError) Error() string { return "redis: " + string(err) }
+
+// DialRedisStore connects to the Redis server at addr. Every key it
+// touches is prefixed with prefix.
+func DialRedisStore(addr, prefix string, timeout time.Duration) (*RedisStore, error) {
+	store := &RedisStore{addr: addr, prefix: prefix, timeout: timeout}
+	if _, err := store.do("PING"); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *RedisStore) Name() string { return "redis" }
+
+// do sends one command and reads its reply, dialing first if the last
+// command broke the connection.
+func (s *RedisStore) do(args ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.addr, s.timeout)
+		if err != nil {
+			return nil, err
+		}
+		s.conn, s.rw = conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	}
+	reply, err := s.roundTrip(args)
+	var serverErr redisError
+	if err != nil && err != errRedisNil && !errors.As(err, &serverErr) {
+		// A timeout or a malformed reply leaves the stream out of step with
+		// the commands sent, so the next command would read this reply.
+		s.conn.Close()
+		s.conn, s.rw = nil, nil
+	}
+	return reply, err
+}
+
+// roundTrip writes a command and reads its reply. Callers must hold s.mu.
+func (s *RedisStore) roundTrip(args []string) (interface{}, error) {
+	if err := s.conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(s.rw, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(s.rw, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := s.rw.Flush(); err != nil {
+		return nil, err
+	}
+	return s.readReply()
+}
+
+func (s *RedisStore) readReply() (interface{}, error) {
+	line, err := s.rw.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(s.rw, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := s.readReply()
+			var serverErr redisError
+			if errors.As(err, &serverErr) {
+				item = serverErr
+			} else if err != nil && err != errRedisNil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
+
+func (s *RedisStore) IncrBy(key string, delta int64) (int64, error) {
+	reply, err := s.do("INCRBY", s.prefix+key, strconv.FormatInt(delta, 10))
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: INCRBY replied with %T, not an integer", reply)
+	}
+	return n, nil
+}
+
+func (s *RedisStore) Counter(key string) (int64, error) {
+	reply, err := s.do("GET", s.prefix+key)
+	if err == errRedisNil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return 0, fmt.Errorf("redis: GET replied with %T, not a string", reply)
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+func (s *RedisStore) SetCache(key string, value []byte, ttl time.Duration) error {
+	_, err := s.do("SET", s.prefix+key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (s *RedisStore) GetCache(key string) ([]byte, bool, error) {
+	reply, err := s.do("GET", s.prefix+key)
+	if err == errRedisNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: GET replied with %T, not a string", reply)
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn, s.rw = nil, nil
+	return err
+}
+
+// sharedWriter sends counter changes to a remote shared store in the
+// background. Changes made between two sends are summed per key in
+// pending, which is guarded by e.Mutex, so no change is lost however far
+// the store falls behind.
+type sharedWriter struct {
+	store   SharedStore
+	pending map[string]int64
+	wake    chan struct{}
+	flushes chan chan struct{}
+	stop    chan struct{}
+}
+
+// bumpShared mirrors a counter change into the shared store. Failures are
+// counted rather than failing the action, and the change is kept to be sent
+// again with the next one. Callers must hold e.Mutex, so a remote store
+// only gets the change queued for its writer.
+func (e *Engine) bumpShared(key string, delta int64) {
+	w := e.sharedWriter
+	if w == nil {
+		if len(e.unsentShared) == 0 {
+			if _, err := e.Shared.IncrBy(key, delta); err != nil {
+				atomic.AddInt64(&e.SharedStoreErrors, 1)
+				e.unsentShared = map[string]int64{key: delta}
+			}
+			return
+		}
+		e.unsentShared[key] += delta
+		e.unsentShared = e.incrShared(e.Shared, e.unsentShared)
+		return
+	}
+	w.pending[key] += delta
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// writeShared sends w's pending changes whenever there are some, until w is
+// stopped, then sends what is left.
+func (e *Engine) writeShared(w *sharedWriter) {
+	for {
+		var flushed chan struct{}
+		select {
+		case <-w.wake:
+		case flushed = <-w.flushes:
+		case <-w.stop:
+			e.sendShared(w)
+			return
+		}
+		e.sendShared(w)
+		if flushed != nil {
+			close(flushed)
+		}
+	}
+}
+
+// sendShared takes w's pending changes and sends them with the engine lock
+// released. Changes the store fails to take go back into pending, so the
+// next send retries them.
+func (e *Engine) sendShared(w *sharedWriter) {
+	e.Mutex.Lock()
+	pending := w.pending
+	w.pending = make(map[string]int64)
+	e.Mutex.Unlock()
+	failed := e.incrShared(w.store, pending)
+	if len(failed) == 0 {
+		return
+	}
+	e.Mutex.Lock()
+	for key, delta := range failed {
+		w.pending[key] += delta
+	}
+	e.Mutex.Unlock()
+}
+
+// incrShared sends each change in pending to store and returns the ones it
+// failed to take. A store that fails after applying a change will count it
+// twice once it is retried; that is preferred to losing it.
+func (e *Engine) incrShared(store SharedStore, pending map[string]int64) map[string]int64 {
+	var failed map[string]int64
+	for key, delta := range pending {
+		if delta == 0 {
+			continue
+		}
+		if _, err := store.IncrBy(key, delta); err != nil {
+			atomic.AddInt64(&e.SharedStoreErrors, 1)
+			if failed == nil {
+				failed = make(map[string]int64)
+			}
+			failed[key] = delta
+		}
+	}
+	return failed
+}
+
+// SetSharedStore replaces the store counters and feed caches are shared
+// through. A MemoryStore is updated in line; any other store gets counter
+// changes from a background writer, so no round trip to it is made with
+// the engine lock held. Changes still pending for the previous store are
+// sent to it.
+func (e *Engine) SetSharedStore(store SharedStore) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.sharedWriter != nil {
+		close(e.sharedWriter.stop)
+		e.sharedWriter = nil
+	}
+	if len(e.unsentShared) > 0 {
+		e.incrShared(e.Shared, e.unsentShared)
+		e.unsentShared = nil
+	}
+	e.Shared = store
+	if _, inProcess := store.(*MemoryStore); !inProcess {
+		e.sharedWriter = &sharedWriter{store: store, pending: make(map[string]int64), wake: make(chan struct{}, 1), flushes: make(chan chan struct{}), stop: make(chan struct{})}
+		go e.writeShared(e.sharedWriter)
+	}
+}
+
+// FlushShared waits until every counter change made so far has been sent
+// to the shared store, or failed and been queued to send again.
+func (e *Engine) FlushShared() {
+	e.Mutex.RLock()
+	w := e.sharedWriter
+	e.Mutex.RUnlock()
+	if w == nil {
+		return
+	}
+	flushed := make(chan struct{})
+	select {
+	case w.flushes <- flushed:
+		<-flushed
+	case <-w.stop:
+	}
+}
+
+// SharedKarma returns the user's karma as the shared store counts it,
+// summed over every instance that shares the store. Counter changes reach
+// a remote store shortly after the action that made them.
+func (e *Engine) SharedKarma(user *User) (int64, error) {
+	e.Mutex.RLock()
+	store := e.Shared
+	e.Mutex.RUnlock()
+	return store.Counter(UserKarmaKey(user.ID))
+}
+
+// CachedFeedItems returns the user's feed in the given order, serving it
+// from the shared feed cache when another instance (or an earlier call)
+// already built it. Cached feeds may be up to feedCacheTTL stale. The feed
+// cache is only a cache: when the store fails, the feed is built here and
+// the failure counted.
+func (e *Engine) CachedFeedItems(user *User, order FeedSort) []FeedItem {
+	e.Mutex.RLock()
+	store := e.Shared
+	e.Mutex.RUnlock()
+	key := fmt.Sprintf("feed:%d:%d", user.ID, order)
+	data, hit, err := store.GetCache(key)
+	if err != nil {
+		atomic.AddInt64(&e.SharedStoreErrors, 1)
+	}
+	if hit {
+		var items []FeedItem
+		if json.Unmarshal(data, &items) == nil {
+			atomic.AddInt64(&e.FeedCacheHits, 1)
+			return items
+		}
+	}
+	items := e.GetFeedItems(user, order)
+	atomic.AddInt64(&e.FeedCacheMisses, 1)
+	if data, err := json.Marshal(items); err == nil {
+		if err := store.SetCache(key, data, feedCacheTTL); err != nil {
+			atomic.AddInt64(&e.SharedStoreErrors, 1)
+		}
+	}
+	return items
+}
+
+// PostVotesKey, CommentVotesKey and UserKarmaKey name the shared counters
+// the engine keeps.
+func PostVotesKey(id int64) string    { return "votes:post:" + strconv.FormatInt(id, 10) }
+func CommentVotesKey(id int64) string { return "votes:comment:" + strconv.FormatInt(id, 10) }
+func UserKarmaKey(id int64) string    { return "karma:user:" + strconv.FormatInt(id, 10) }
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// flakyStore is a shared store that fails every other counter change.
+type flakyStore struct {
+	*MemoryStore
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *flakyStore) IncrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	s.calls++
+	fail := s.calls%2 == 1
+	s.mu.Unlock()
+	if fail {
+		return 0, errors.New("flaky store: connection reset")
+	}
+	return s.MemoryStore.IncrBy(key, delta)
+}
+
+func TestSharedVotesSurviveStoreErrors(t *testing.T) {
+	for _, background := range []bool{false, true} {
+		t.Run(fmt.Sprintf("background=%v", background), func(t *testing.T) {
+			e, author, voter := newTestSite(t)
+			store := &flakyStore{MemoryStore: NewMemoryStore()}
+			if background {
+				e.SetSharedStore(store)
+			} else {
+				e.Shared = store
+			}
+			var posts []*Post
+			for i := 0; i < 10; i++ {
+				post, err := e.CreatePost(author, "news", fmt.Sprintf("Post %d", i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := e.UpvotePost(voter, post); err != nil {
+					t.Fatal(err)
+				}
+				posts = append(posts, post)
+			}
+			// Each send gets at least every other change through, so a few
+			// flushes (or bumps, in line) clear whatever failed.
+			for i := 0; i < 10; i++ {
+				e.FlushShared()
+				e.Mutex.Lock()
+				e.bumpShared("flush", 0)
+				e.Mutex.Unlock()
+			}
+			if e.SharedStoreErrors == 0 {
+				t.Fatal("store never failed")
+			}
+			for _, post := range posts {
+				if got, _ := store.Counter(PostVotesKey(post.ID)); got != 1 {
+					t.Errorf("post %d: shared votes = %d, want 1", post.ID, got)
+				}
+			}
+		})
+	}
+}
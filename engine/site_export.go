@@ -0,0 +1,322 @@
+package engine
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Static Site Export
+
+// siteTitleLength is how much of a post's content its listing shows.
+const siteTitleLength = 120
+
+// SiteStats counts the pages ExportSite wrote.
+type SiteStats struct {
+	SubReddits int
+	Posts      int
+	Users      int
+}
+
+// sitePostLink is a post as listings and profiles show it.
+type sitePostLink struct {
+	ID        int64
+	Title     string
+	SubReddit string
+	Author    string
+	AuthorID  int64
+	Votes     int
+	Comments  int
+	CreatedAt time.Time
+}
+
+type siteSubReddit struct {
+	Name    string
+	Slug    string
+	Members int
+	Posts   []sitePostLink
+}
+
+// siteThread is a post page. AuthorID links the post's author to their
+// profile, and is 0 when the author is hidden.
+type siteThread struct {
+	Post          ThreadPost
+	AuthorID      int64
+	SubRedditSlug string
+	Comments      []siteComment
+}
+
+// siteComment is a comment on a post page. AuthorID is 0 for hidden
+// comments.
+type siteComment struct {
+	ThreadComment
+	AuthorID int64
+	Replies  []siteComment
+}
+
+type siteCommentLink struct {
+	ID        int64
+	PostID    int64
+	Content   string
+	Votes     int
+	CreatedAt time.Time
+}
+
+type siteUser struct {
+	Profile  UserProfile
+	Posts    []sitePostLink
+	Comments []siteCommentLink
+}
+
+// sitePage is one page of the site. Kind names the template that renders
+// Body, and Root leads back to the top of the site.
+type sitePage struct {
+	Kind  string
+	Title string
+	Root  string
+	Body  interface{}
+}
+
+// ExportSite renders the engine's state as a static HTML site in dir: an
+// index of subreddits, a page per subreddit listing its posts by hot score,
+// a page per post with its whole comment tree, and a profile page per user
+// listing their posts and comments. Only listed posts get pages, and
+// deleted or removed content shows the same markers as ExportThread and is
+// left off profiles. Archived posts aren't exported.
+func (e *Engine) ExportSite(dir string) (SiteStats, error) {
+	subReddits, threads, users := e.siteContent()
+	pages := map[string]sitePage{
+		"index.html": {Kind: "index", Title: "Subreddits", Root: "", Body: subReddits},
+	}
+	for _, subReddit := range subReddits {
+		pages[filepath.Join("r", subReddit.Slug+".html")] = sitePage{Kind: "subreddit", Title: "r/" + subReddit.Name, Root: "../", Body: subReddit}
+	}
+	for _, thread := range threads {
+		pages[filepath.Join("posts", fmt.Sprintf("%d.html", thread.Post.ID))] = sitePage{Kind: "post", Title: siteTitle(thread.Post.Content), Root: "../", Body: thread}
+	}
+	for _, user := range users {
+		pages[filepath.Join("u", fmt.Sprintf("%d.html", user.Profile.ID))] = sitePage{Kind: "user", Title: "u/" + user.Profile.Username, Root: "../", Body: user}
+	}
+
+	for _, sub := range []string{"r", "posts", "u"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return SiteStats{}, err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "style.css"), []byte(siteStylesheet), 0o644); err != nil {
+		return SiteStats{}, err
+	}
+	for name, page := range pages {
+		if err := writeSitePage(filepath.Join(dir, name), page); err != nil {
+			return SiteStats{}, fmt.Errorf("rendering %s: %w", name, err)
+		}
+	}
+	return SiteStats{SubReddits: len(subReddits), Posts: len(threads), Users: len(users)}, nil
+}
+
+// siteContent gathers everything ExportSite renders, so pages can be
+// written without holding the lock.
+func (e *Engine) siteContent() ([]siteSubReddit, []siteThread, []siteUser) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	users := make(map[int64]*siteUser)
+	for id, user := range e.Users {
+		if user.MergedInto == 0 {
+			users[id] = &siteUser{Profile: e.userProfile(user)}
+		}
+	}
+
+	var subReddits []siteSubReddit
+	var threads []siteThread
+	for name, subReddit := range e.SubReddits {
+		listed := make([]*Post, 0, len(subReddit.Posts))
+		for _, post := range subReddit.Posts {
+			if e.inFeeds(post) {
+				listed = append(listed, post)
+			}
+		}
+		sortPosts(listed, scoreInputs(listed), SortHot, nil, e.Ranking)
+		page := siteSubReddit{Name: name, Slug: siteSlug(name), Members: len(subReddit.Users)}
+		for _, post := range listed {
+			link := sitePostLink{
+				ID:        post.ID,
+				Title:     siteTitle(post.Content),
+				SubReddit: name,
+				Author:    DisplayedName(post.Author),
+				AuthorID:  post.Author.ID,
+				Votes:     post.Votes,
+				Comments:  countComments(post.Comments),
+				CreatedAt: post.CreatedAt,
+			}
+			page.Posts = append(page.Posts, link)
+			authors := make(map[int64]int64)
+			e.siteComments(post, post.Comments, authors, users)
+			thread := e.thread(post)
+			threads = append(threads, siteThread{Post: thread.Post, AuthorID: post.Author.ID, SubRedditSlug: page.Slug, Comments: siteCommentTree(thread.Comments, authors)})
+			if author := users[post.Author.ID]; author != nil {
+				author.Posts = append(author.Posts, link)
+			}
+		}
+		subReddits = append(subReddits, page)
+	}
+	sort.Slice(subReddits, func(i, j int) bool { return subReddits[i].Name < subReddits[j].Name })
+
+	profiles := make([]siteUser, 0, len(users))
+	for _, user := range users {
+		sort.Slice(user.Posts, func(i, j int) bool { return user.Posts[i].CreatedAt.After(user.Posts[j].CreatedAt) })
+		sort.Slice(user.Comments, func(i, j int) bool { return user.Comments[i].CreatedAt.After(user.Comments[j].CreatedAt) })
+		profiles = append(profiles, *user)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Profile.ID < profiles[j].Profile.ID })
+	return subReddits, threads, profiles
+}
+
+// siteComments records the authors of post's visible comments and adds the
+// comments to their profiles. Callers must hold e.Mutex.
+func (e *Engine) siteComments(post *Post, comments []*Comment, authors map[int64]int64, users map[int64]*siteUser) {
+	for _, comment := range comments {
+		if e.commentStatus(comment) == ContentVisible {
+			authors[comment.ID] = comment.Author.ID
+			if author := users[comment.Author.ID]; author != nil {
+				author.Comments = append(author.Comments, siteCommentLink{ID: comment.ID, PostID: post.ID, Content: comment.Content(), Votes: comment.Votes, CreatedAt: comment.CreatedAt})
+			}
+		}
+		e.siteComments(post, comment.Replies, authors, users)
+	}
+}
+
+// siteCommentTree pairs each comment in a thread with its author's ID.
+func siteCommentTree(comments []ThreadComment, authors map[int64]int64) []siteComment {
+	tree := make([]siteComment, 0, len(comments))
+	for _, comment := range comments {
+		tree = append(tree, siteComment{ThreadComment: comment, AuthorID: authors[comment.ID], Replies: siteCommentTree(comment.Replies, authors)})
+	}
+	return tree
+}
+
+func writeSitePage(path string, page sitePage) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := siteTemplates.ExecuteTemplate(file, "page", page); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// siteTitle is the first line of content, shortened for listings.
+func siteTitle(content string) string {
+	title, _, _ := strings.Cut(content, "\n")
+	if len(title) > siteTitleLength {
+		title = strings.ToValidUTF8(title[:siteTitleLength], "") + "…"
+	}
+	return title
+}
+
+// siteSlug turns a subreddit name into a file name, escaping anything but
+// letters, digits, '-' and '_' as ~XX so names can't reach outside the
+// export or collide.
+func siteSlug(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "~%02X", c)
+		}
+	}
+	return b.String()
+}
+
+var siteTemplates = template.Must(template.New("site").Funcs(template.FuncMap{
+	"points": points,
+	"date":   func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04") },
+}).Parse(`
+{{define "page"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Root}}style.css">
+</head>
+<body>
+<header><a href="{{.Root}}index.html">Subreddits</a></header>
+<main>
+{{if eq .Kind "index"}}{{template "index" .Body}}{{else if eq .Kind "subreddit"}}{{template "subreddit" .Body}}{{else if eq .Kind "post"}}{{template "post" .Body}}{{else}}{{template "user" .Body}}{{end}}
+</main>
+</body>
+</html>
+{{end}}
+
+{{define "postlink"}}<li><a href="../posts/{{.ID}}.html">{{.Title}}</a>
+<div class="meta">{{points .Votes}} · {{.Comments}} comments · r/{{.SubReddit}} · <a href="../u/{{.AuthorID}}.html">u/{{.Author}}</a> · {{date .CreatedAt}}</div></li>
+{{end}}
+
+{{define "author"}}{{if .AuthorID}}<a href="../u/{{.AuthorID}}.html">u/{{.Author}}</a>{{else}}{{.Author}}{{end}}{{end}}
+
+{{define "index"}}<h1>Subreddits</h1>
+<ul class="subreddits">
+{{range .}}<li><a href="r/{{.Slug}}.html">r/{{.Name}}</a> <span class="meta">{{.Members}} members · {{len .Posts}} posts</span></li>
+{{end}}</ul>
+{{end}}
+
+{{define "subreddit"}}<h1>r/{{.Name}}</h1>
+<p class="meta">{{.Members}} members</p>
+{{if .Posts}}<ol class="posts">
+{{range .Posts}}{{template "postlink" .}}{{end}}</ol>
+{{else}}<p>No posts yet.</p>
+{{end}}{{end}}
+
+{{define "post"}}<article>
+<div class="meta"><a href="../r/{{.SubRedditSlug}}.html">r/{{.Post.SubReddit}}</a> · {{if .AuthorID}}<a href="../u/{{.AuthorID}}.html">u/{{.Post.Author}}</a>{{else}}{{.Post.Author}}{{end}} · {{points .Post.Votes}} · {{date .Post.CreatedAt}}</div>
+<div class="body">{{.Post.Content}}</div>
+{{if .Post.URL}}<p><a href="{{.Post.URL}}" rel="nofollow">{{.Post.URL}}</a></p>{{end}}
+{{range .Post.Attachments}}<p class="meta">{{.Type}}: <a href="{{.Reference}}" rel="nofollow">{{if .Caption}}{{.Caption}}{{else}}{{.Reference}}{{end}}</a></p>
+{{end}}</article>
+<section class="comments">
+{{if .Comments}}{{template "comments" .Comments}}{{else}}<p>No comments yet.</p>{{end}}
+</section>
+{{end}}
+
+{{define "user"}}<h1>u/{{.Profile.Username}}</h1>
+{{if .Profile.DisplayName}}<p>{{.Profile.DisplayName}}</p>{{end}}
+{{if .Profile.Bio}}<p>{{.Profile.Bio}}</p>{{end}}
+<p class="meta">{{.Profile.Karma}} karma ({{.Profile.PostKarma}} post, {{.Profile.CommentKarma}} comment) · {{.Profile.Actions}} actions</p>
+<h2>Posts</h2>
+{{if .Posts}}<ol class="posts">
+{{range .Posts}}{{template "postlink" .}}{{end}}</ol>
+{{else}}<p>No posts.</p>
+{{end}}<h2>Comments</h2>
+{{if .Comments}}<ul class="profile-comments">
+{{range .Comments}}<li><a href="../posts/{{.PostID}}.html#c{{.ID}}">{{.Content}}</a> <span class="meta">{{points .Votes}} · {{date .CreatedAt}}</span></li>
+{{end}}</ul>
+{{else}}<p>No comments.</p>
+{{end}}{{end}}
+
+{{define "comments"}}<ul>
+{{range .}}<li id="c{{.ID}}"{{if .Collapsed}} class="collapsed"{{end}}>
+<div class="meta">{{template "author" .}} · {{points .Votes}} · {{date .CreatedAt}}{{if not .EditedAt.IsZero}} (edited){{end}}{{if .Stickied}} · stickied{{end}}</div>
+<div class="body">{{.Content}}</div>
+{{if .Reactions}}<div class="meta">{{range .Reactions}}{{.Emoji}} {{.Count}} {{end}}</div>{{end}}
+{{if .Replies}}{{template "comments" .Replies}}{{end}}
+</li>
+{{end}}</ul>
+{{end}}
+`))
+
+const siteStylesheet = `body { font-family: sans-serif; max-width: 50em; margin: 0 auto; padding: 1em; color: #1a1a1b; }
+header { border-bottom: 1px solid #ccc; padding-bottom: 0.5em; margin-bottom: 1em; }
+a { color: #0079d3; text-decoration: none; }
+.meta { color: #787c7e; font-size: 0.85em; }
+ol.posts li, ul.subreddits li, ul.profile-comments li { margin-bottom: 0.75em; }
+.comments ul { list-style: none; padding-left: 1.25em; border-left: 2px solid #edeff1; }
+.comments li { margin: 0.5em 0; }
+.collapsed > .body { color: #787c7e; }
+`
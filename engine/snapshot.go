@@ -0,0 +1,690 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Save and Load
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid engine snapshot")
+	ErrEngineNotEmpty  = errors.New("engine already holds users or subreddits")
+)
+
+// snapshotVersion is written into every snapshot; Load refuses others.
+const snapshotVersion = 1
+
+// snapshot is the document Save writes. Pointers between users, subreddits
+// and content become IDs.
+type snapshot struct {
+	Version           int
+	SavedAt           time.Time
+	IDOffset          int64
+	UserID            int64
+	PostID            int64
+	CommentID         int64
+	BanID             int64
+	TotalPosts        int
+	TotalVotes        int
+	TotalMessages     int
+	TotalActions      int
+	TotalComments     int
+	TotalReactions    int
+	ActionBreakdown   map[string]int
+	ReactionCounts    map[string]int
+	Users             []*User
+	Usernames         map[string]int64
+	SubReddits        []savedSubReddit
+	Messages          []savedMessage
+	Follows           map[int64]map[int64]bool
+	DMRequests        []savedDMRequest
+	AutoAcceptedDMs   int
+	DroppedDMs        int
+	PostVotes         map[int64]map[int64]PostVote
+	CommentVotes      map[int64]map[int64]int
+	CommentReactions  map[int64]map[int64][]string
+	BranchScores      map[int64]int
+	CommentSorts      map[int64]CommentSort
+	StickyComments    map[int64]int64
+	RemovedPosts      map[int64]int
+	RemovedComments   map[int64]int
+	DeletedPosts      map[int64]time.Time
+	DeletedComments   map[int64]time.Time
+	EditedComments    map[int64]time.Time
+	Interests         map[int64]map[string]float64
+	Milestones        map[int64]map[string]bool
+	Trophies          map[int64][]Trophy
+	MilestoneCounts   map[string]int
+	DefaultSubReddits []string
+	Events            []Event
+}
+
+type savedSubReddit struct {
+	Name              string
+	HomeRegion        string
+	TotalPosts        int
+	TotalVotes        int
+	Settings          SubRedditSettings
+	Topics            []string
+	Members           []int64
+	Moderators        []int64
+	DefaultMembers    []int64
+	Rules             []Rule
+	ModLog            []ModLogEntry
+	RuleViolations    map[int]int
+	Policy            ContentPolicy
+	PolicyViolations  map[string]int
+	Warnings          map[int64]int
+	Banned            map[int64]time.Time
+	BanIDs            map[int64]int64
+	Appeals           []BanAppeal
+	UnlockAt          time.Time
+	EmbargoReleased   int
+	ApprovalLatencies []time.Duration
+	Approved          int
+	Rejected          int
+	CommentKarma      map[int64]int
+	Posts             []savedPost
+	ApprovalQueue     []int64
+	Embargoed         []int64
+}
+
+type savedPost struct {
+	ID                 int64
+	Author             int64
+	Content            string
+	Comments           []savedComment
+	Votes              int
+	CreatedAt          time.Time
+	Removed            bool
+	Deleted            bool
+	CommentSort        CommentSort
+	URL                string
+	Pending            bool
+	Embargoed          bool
+	Attachments        []Attachment
+	WeightedVotes      float64
+	Upvotes            int
+	Downvotes          int
+	KarmaWeightedVotes float64
+	NSFW               bool
+	// Archived posts were read back from cold storage, which doesn't keep
+	// every field; Load recomputes the rest from the engine's indexes.
+	Archived bool `json:",omitempty"`
+}
+
+type savedComment struct {
+	ID        int64
+	Author    int64
+	Content   string
+	Replies   []savedComment `json:",omitempty"`
+	Votes     int
+	Removed   bool
+	Deleted   bool
+	CreatedAt time.Time
+	Edited    bool
+	EditedAt  time.Time      `json:",omitzero"`
+	Reactions map[string]int `json:",omitempty"`
+}
+
+type savedMessage struct {
+	From    int64
+	To      int64
+	Content string
+	SentAt  time.Time
+	Spam    bool
+	Request bool `json:",omitempty"`
+}
+
+type savedDMRequest struct {
+	From     int64
+	To       int64
+	SentAt   time.Time
+	Status   DMRequestStatus
+	Messages int
+}
+
+// Save writes the engine's users, subreddits, posts, comments, votes,
+// messages, follows, message requests and event log to w, encoded with
+// e.Codec, for Load to resume from. Posts in cold storage are read back and
+// saved with the hot ones. Runtime settings, notifications, caches and
+// traffic stats are not saved.
+func (e *Engine) Save(w io.Writer) error {
+	e.Mutex.RLock()
+	saved, err := e.snapshot()
+	var data []byte
+	if err == nil {
+		// The snapshot shares the engine's maps, so encode it under the lock.
+		data, err = e.Codec.Marshal(saved)
+	}
+	e.Mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// snapshot builds the document Save writes. Callers must hold e.Mutex.
+func (e *Engine) snapshot() (*snapshot, error) {
+	saved := &snapshot{
+		Version:           snapshotVersion,
+		SavedAt:           e.Clock.Now(),
+		IDOffset:          e.IDOffset,
+		UserID:            e.UserID,
+		PostID:            e.PostID,
+		CommentID:         e.CommentID,
+		BanID:             e.BanID,
+		TotalPosts:        e.TotalPosts,
+		TotalVotes:        e.TotalVotes,
+		TotalMessages:     e.TotalMessages,
+		TotalActions:      e.TotalActions,
+		TotalComments:     e.TotalComments,
+		TotalReactions:    e.TotalReactions,
+		ActionBreakdown:   e.ActionBreakdown,
+		ReactionCounts:    e.ReactionCounts,
+		Usernames:         e.Usernames,
+		Follows:           e.Follows,
+		AutoAcceptedDMs:   e.AutoAcceptedDMRequests,
+		DroppedDMs:        e.DroppedDMs,
+		PostVotes:         e.PostVotes,
+		CommentVotes:      e.CommentVotes,
+		CommentReactions:  make(map[int64]map[int64][]string, len(e.CommentReactions)),
+		BranchScores:      e.BranchScores,
+		CommentSorts:      e.CommentSorts,
+		StickyComments:    e.StickyComments,
+		RemovedPosts:      e.RemovedPosts,
+		RemovedComments:   e.RemovedComments,
+		DeletedPosts:      e.DeletedPosts,
+		DeletedComments:   e.DeletedComments,
+		EditedComments:    e.EditedComments,
+		Interests:         e.Interests,
+		Milestones:        e.Milestones,
+		Trophies:          e.Trophies,
+		MilestoneCounts:   e.MilestoneCounts,
+		DefaultSubReddits: e.DefaultSubReddits,
+		Events:            e.Events,
+	}
+	for _, user := range e.Users {
+		saved.Users = append(saved.Users, user)
+	}
+	sort.Slice(saved.Users, func(i, j int) bool { return saved.Users[i].ID < saved.Users[j].ID })
+	for commentID, reacted := range e.CommentReactions {
+		byUser := make(map[int64][]string)
+		for key := range reacted {
+			byUser[key.userID] = append(byUser[key.userID], key.emoji)
+		}
+		for _, emojis := range byUser {
+			sort.Strings(emojis)
+		}
+		saved.CommentReactions[commentID] = byUser
+	}
+	for _, message := range e.Messages {
+		saved.Messages = append(saved.Messages, savedMessage{From: message.From.ID, To: message.To.ID, Content: message.Content, SentAt: message.SentAt, Spam: message.Spam, Request: message.Request})
+	}
+	for _, requests := range e.DMRequests {
+		for _, request := range requests {
+			saved.DMRequests = append(saved.DMRequests, savedDMRequest{From: request.From.ID, To: request.To.ID, SentAt: request.SentAt, Status: request.Status, Messages: request.Messages})
+		}
+	}
+	sort.Slice(saved.DMRequests, func(i, j int) bool {
+		if saved.DMRequests[i].To != saved.DMRequests[j].To {
+			return saved.DMRequests[i].To < saved.DMRequests[j].To
+		}
+		return saved.DMRequests[i].From < saved.DMRequests[j].From
+	})
+
+	archived := make(map[string][]savedPost)
+	if e.ColdStore != nil {
+		for _, id := range e.ColdStore.ids() {
+			post, err := e.archivedPost(id)
+			if err != nil {
+				return nil, fmt.Errorf("saving archived post %d: %w", id, err)
+			}
+			savedPost := savePost(post)
+			savedPost.Archived = true
+			archived[post.SubReddit] = append(archived[post.SubReddit], savedPost)
+		}
+	}
+	for _, subReddit := range e.SubReddits {
+		savedSubReddit := savedSubReddit{
+			Name:              subReddit.Name,
+			HomeRegion:        subReddit.HomeRegion,
+			TotalPosts:        subReddit.TotalPosts,
+			TotalVotes:        subReddit.TotalVotes,
+			Settings:          subReddit.Settings,
+			Topics:            subReddit.Topics,
+			Members:           userIDs(subReddit.Users),
+			Moderators:        userIDs(subReddit.Moderators),
+			Rules:             subReddit.Rules,
+			ModLog:            subReddit.ModLog,
+			RuleViolations:    subReddit.RuleViolations,
+			Policy:            subReddit.Policy,
+			PolicyViolations:  subReddit.PolicyViolations,
+			Warnings:          subReddit.Warnings,
+			Banned:            subReddit.Banned,
+			BanIDs:            subReddit.BanIDs,
+			UnlockAt:          subReddit.UnlockAt,
+			EmbargoReleased:   subReddit.EmbargoReleased,
+			ApprovalLatencies: subReddit.ApprovalLatencies,
+			Approved:          subReddit.Approved,
+			Rejected:          subReddit.Rejected,
+			CommentKarma:      subReddit.CommentKarma,
+			Posts:             archived[subReddit.Name],
+		}
+		for id := range subReddit.DefaultMembers {
+			savedSubReddit.DefaultMembers = append(savedSubReddit.DefaultMembers, id)
+		}
+		sort.Slice(savedSubReddit.DefaultMembers, func(i, j int) bool { return savedSubReddit.DefaultMembers[i] < savedSubReddit.DefaultMembers[j] })
+		for _, post := range subReddit.Posts {
+			savedSubReddit.Posts = append(savedSubReddit.Posts, savePost(post))
+		}
+		for _, appeal := range subReddit.Appeals {
+			savedSubReddit.Appeals = append(savedSubReddit.Appeals, *appeal)
+		}
+		for _, post := range subReddit.ApprovalQueue {
+			savedSubReddit.ApprovalQueue = append(savedSubReddit.ApprovalQueue, post.ID)
+		}
+		for _, post := range subReddit.Embargoed {
+			savedSubReddit.Embargoed = append(savedSubReddit.Embargoed, post.ID)
+		}
+		saved.SubReddits = append(saved.SubReddits, savedSubReddit)
+	}
+	sort.Slice(saved.SubReddits, func(i, j int) bool { return saved.SubReddits[i].Name < saved.SubReddits[j].Name })
+	return saved, nil
+}
+
+func userIDs(users map[int64]*User) []int64 {
+	ids := make([]int64, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func savePost(post *Post) savedPost {
+	return savedPost{
+		ID:                 post.ID,
+		Author:             post.Author.ID,
+		Content:            post.Content,
+		Comments:           saveComments(post.Comments),
+		Votes:              post.Votes,
+		CreatedAt:          post.CreatedAt,
+		Removed:            post.Removed,
+		Deleted:            post.Deleted,
+		CommentSort:        post.CommentSort,
+		URL:                post.URL,
+		Pending:            post.Pending,
+		Embargoed:          post.Embargoed,
+		Attachments:        post.Attachments,
+		WeightedVotes:      post.WeightedVotes,
+		Upvotes:            post.Upvotes,
+		Downvotes:          post.Downvotes,
+		KarmaWeightedVotes: post.KarmaWeightedVotes,
+		NSFW:               post.NSFW,
+	}
+}
+
+func saveComments(comments []*Comment) []savedComment {
+	saved := make([]savedComment, 0, len(comments))
+	for _, comment := range comments {
+		saved = append(saved, savedComment{
+			ID:        comment.ID,
+			Author:    comment.Author.ID,
+			Content:   comment.Content(),
+			Replies:   saveComments(comment.Replies),
+			Votes:     comment.Votes,
+			Removed:   comment.Removed,
+			Deleted:   comment.Deleted,
+			CreatedAt: comment.CreatedAt,
+			Edited:    comment.Edited,
+			EditedAt:  comment.EditedAt,
+			Reactions: comment.Reactions,
+		})
+	}
+	return saved
+}
+
+// Load restores a snapshot written by Save into an engine that has no users
+// or subreddits yet, such as one fresh from New, decoding it with e.Codec;
+// it keeps the engine's own settings, clock and stores. Archived posts come
+// back hot. An engine on a SimClock behind the snapshot is advanced to the
+// time it was saved, and scheduled unlocks are scheduled again.
+func (e *Engine) Load(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	e.Mutex.RLock()
+	codec := e.Codec
+	e.Mutex.RUnlock()
+	var saved snapshot
+	if err := codec.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if saved.Version != snapshotVersion {
+		return fmt.Errorf("%w: version %d, want %d", ErrInvalidSnapshot, saved.Version, snapshotVersion)
+	}
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if len(e.Users) > 0 || len(e.SubReddits) > 0 {
+		return ErrEngineNotEmpty
+	}
+	return e.resume(&saved)
+}
+
+// resume restores saved, catches a SimClock up to when it was saved and
+// reschedules unlocks. Callers must hold e.Mutex.
+func (e *Engine) resume(saved *snapshot) error {
+	if err := e.restore(saved); err != nil {
+		return err
+	}
+	if clock, simulated := e.Clock.(*SimClock); simulated && clock.Now().Before(saved.SavedAt) {
+		clock.Advance(saved.SavedAt.Sub(clock.Now()))
+	}
+	for _, subReddit := range e.SubReddits {
+		if !subReddit.UnlockAt.IsZero() {
+			e.schedule(subReddit.UnlockAt, "unlock_subreddit", func() { e.releaseEmbargo(subReddit) })
+		}
+	}
+	return nil
+}
+
+// restore rebuilds the engine's state from saved. Callers must hold
+// e.Mutex.
+func (e *Engine) restore(saved *snapshot) error {
+	users := make(map[int64]*User, len(saved.Users))
+	for _, user := range saved.Users {
+		users[user.ID] = user
+	}
+	user := func(id int64, what string) (*User, error) {
+		if user := users[id]; user != nil {
+			return user, nil
+		}
+		return nil, fmt.Errorf("%w: %s refers to unknown user %d", ErrInvalidSnapshot, what, id)
+	}
+
+	subReddits := make(map[string]*SubReddit, len(saved.SubReddits))
+	parents := make(map[int64]int64)
+	var restoreComments func(saved []savedComment, post *Post, parentID int64) ([]*Comment, error)
+	restoreComments = func(saved []savedComment, post *Post, parentID int64) ([]*Comment, error) {
+		comments := make([]*Comment, 0, len(saved))
+		for _, savedComment := range saved {
+			author, err := user(savedComment.Author, fmt.Sprintf("comment %d", savedComment.ID))
+			if err != nil {
+				return nil, err
+			}
+			comment := &Comment{
+				ID:        savedComment.ID,
+				PostID:    post.ID,
+				SubReddit: post.SubReddit,
+				Author:    author,
+				Votes:     savedComment.Votes,
+				Removed:   savedComment.Removed,
+				Deleted:   savedComment.Deleted,
+				CreatedAt: savedComment.CreatedAt,
+				Edited:    savedComment.Edited,
+				EditedAt:  savedComment.EditedAt,
+				Reactions: savedComment.Reactions,
+			}
+			e.setCommentContent(comment, savedComment.Content)
+			parents[comment.ID] = parentID
+			if comment.Replies, err = restoreComments(savedComment.Replies, post, comment.ID); err != nil {
+				return nil, err
+			}
+			comments = append(comments, comment)
+		}
+		return comments, nil
+	}
+	for _, savedSubReddit := range saved.SubReddits {
+		subReddit := newSubReddit(savedSubReddit.Name)
+		subReddit.HomeRegion = savedSubReddit.HomeRegion
+		subReddit.TotalPosts = savedSubReddit.TotalPosts
+		subReddit.TotalVotes = savedSubReddit.TotalVotes
+		subReddit.Settings = savedSubReddit.Settings
+		subReddit.Topics = savedSubReddit.Topics
+		subReddit.Rules = savedSubReddit.Rules
+		subReddit.ModLog = savedSubReddit.ModLog
+		setContentPolicy(subReddit, savedSubReddit.Policy)
+		subReddit.UnlockAt = savedSubReddit.UnlockAt
+		subReddit.EmbargoReleased = savedSubReddit.EmbargoReleased
+		subReddit.ApprovalLatencies = savedSubReddit.ApprovalLatencies
+		subReddit.Approved = savedSubReddit.Approved
+		subReddit.Rejected = savedSubReddit.Rejected
+		for _, counts := range []struct{ into, from map[int64]int }{
+			{subReddit.Warnings, savedSubReddit.Warnings},
+			{subReddit.CommentKarma, savedSubReddit.CommentKarma},
+		} {
+			for id, count := range counts.from {
+				counts.into[id] = count
+			}
+		}
+		for rule, count := range savedSubReddit.RuleViolations {
+			subReddit.RuleViolations[rule] = count
+		}
+		for violation, count := range savedSubReddit.PolicyViolations {
+			subReddit.PolicyViolations[violation] = count
+		}
+		for id, until := range savedSubReddit.Banned {
+			subReddit.Banned[id] = until
+		}
+		for id, banID := range savedSubReddit.BanIDs {
+			subReddit.BanIDs[id] = banID
+		}
+		for _, appeal := range savedSubReddit.Appeals {
+			appeal := appeal
+			subReddit.Appeals = append(subReddit.Appeals, &appeal)
+		}
+		for _, id := range savedSubReddit.Members {
+			member, err := user(id, "a member of "+subReddit.Name)
+			if err != nil {
+				return err
+			}
+			subReddit.Users[id] = member
+		}
+		for _, id := range savedSubReddit.Moderators {
+			mod, err := user(id, "a moderator of "+subReddit.Name)
+			if err != nil {
+				return err
+			}
+			subReddit.Moderators[id] = mod
+		}
+		for _, id := range savedSubReddit.DefaultMembers {
+			subReddit.DefaultMembers[id] = true
+		}
+
+		posts := make(map[int64]*Post, len(savedSubReddit.Posts))
+		for _, savedPost := range savedSubReddit.Posts {
+			author, err := user(savedPost.Author, fmt.Sprintf("post %d", savedPost.ID))
+			if err != nil {
+				return err
+			}
+			post := &Post{
+				ID:                 savedPost.ID,
+				Author:             author,
+				Content:            savedPost.Content,
+				Votes:              savedPost.Votes,
+				CreatedAt:          savedPost.CreatedAt,
+				SubReddit:          subReddit.Name,
+				Removed:            savedPost.Removed,
+				Deleted:            savedPost.Deleted,
+				CommentSort:        savedPost.CommentSort,
+				URL:                savedPost.URL,
+				Pending:            savedPost.Pending,
+				Embargoed:          savedPost.Embargoed,
+				Attachments:        savedPost.Attachments,
+				WeightedVotes:      savedPost.WeightedVotes,
+				Upvotes:            savedPost.Upvotes,
+				Downvotes:          savedPost.Downvotes,
+				KarmaWeightedVotes: savedPost.KarmaWeightedVotes,
+				NSFW:               savedPost.NSFW,
+			}
+			if post.Comments, err = restoreComments(savedPost.Comments, post, 0); err != nil {
+				return err
+			}
+			if savedPost.Archived {
+				restoreArchived(saved, post)
+			}
+			posts[post.ID] = post
+			subReddit.Posts = append(subReddit.Posts, post)
+		}
+		sort.SliceStable(subReddit.Posts, func(i, j int) bool { return subReddit.Posts[i].ID < subReddit.Posts[j].ID })
+		for _, queue := range []struct {
+			ids  []int64
+			into *[]*Post
+		}{
+			{savedSubReddit.ApprovalQueue, &subReddit.ApprovalQueue},
+			{savedSubReddit.Embargoed, &subReddit.Embargoed},
+		} {
+			for _, id := range queue.ids {
+				post := posts[id]
+				if post == nil {
+					return fmt.Errorf("%w: %s queues unknown post %d", ErrInvalidSnapshot, subReddit.Name, id)
+				}
+				*queue.into = append(*queue.into, post)
+			}
+		}
+		subReddits[subReddit.Name] = subReddit
+	}
+
+	messages := make([]Message, 0, len(saved.Messages))
+	for i, savedMessage := range saved.Messages {
+		from, err := user(savedMessage.From, fmt.Sprintf("message %d", i))
+		if err != nil {
+			return err
+		}
+		to, err := user(savedMessage.To, fmt.Sprintf("message %d", i))
+		if err != nil {
+			return err
+		}
+		messages = append(messages, Message{From: from, To: to, Content: savedMessage.Content, SentAt: savedMessage.SentAt, Spam: savedMessage.Spam, Request: savedMessage.Request})
+	}
+	dmRequests := make(map[int64]map[int64]*DMRequest)
+	for i, savedRequest := range saved.DMRequests {
+		from, err := user(savedRequest.From, fmt.Sprintf("message request %d", i))
+		if err != nil {
+			return err
+		}
+		to, err := user(savedRequest.To, fmt.Sprintf("message request %d", i))
+		if err != nil {
+			return err
+		}
+		if dmRequests[to.ID] == nil {
+			dmRequests[to.ID] = make(map[int64]*DMRequest)
+		}
+		dmRequests[to.ID][from.ID] = &DMRequest{From: from, To: to, SentAt: savedRequest.SentAt, Status: savedRequest.Status, Messages: savedRequest.Messages}
+	}
+
+	e.Users = users
+	e.SubReddits = subReddits
+	e.reindexContent()
+	e.Messages = messages
+	e.DMRequests = dmRequests
+	if saved.Follows != nil {
+		e.Follows = saved.Follows
+	}
+	e.AutoAcceptedDMRequests = saved.AutoAcceptedDMs
+	e.DroppedDMs = saved.DroppedDMs
+	e.CommentParents = parents
+	e.IDOffset = saved.IDOffset
+	e.UserID = saved.UserID
+	e.PostID = saved.PostID
+	e.CommentID = saved.CommentID
+	e.BanID = saved.BanID
+	e.numberBans()
+	e.TotalPosts = saved.TotalPosts
+	e.TotalVotes = saved.TotalVotes
+	e.TotalMessages = saved.TotalMessages
+	e.TotalActions = saved.TotalActions
+	e.TotalComments = saved.TotalComments
+	e.TotalReactions = saved.TotalReactions
+	e.DefaultSubReddits = saved.DefaultSubReddits
+	e.Events = saved.Events
+	e.EventSeq = len(saved.Events)
+	e.ActionBreakdown = saved.ActionBreakdown
+	e.ReactionCounts = saved.ReactionCounts
+	e.Usernames = saved.Usernames
+	e.PostVotes = saved.PostVotes
+	e.CommentVotes = saved.CommentVotes
+	e.BranchScores = saved.BranchScores
+	e.CommentSorts = saved.CommentSorts
+	e.StickyComments = saved.StickyComments
+	e.RemovedPosts = saved.RemovedPosts
+	e.RemovedComments = saved.RemovedComments
+	e.DeletedPosts = saved.DeletedPosts
+	e.DeletedComments = saved.DeletedComments
+	e.EditedComments = saved.EditedComments
+	e.Interests = saved.Interests
+	e.Milestones = saved.Milestones
+	e.MilestoneCounts = saved.MilestoneCounts
+	if saved.Trophies != nil {
+		e.Trophies = saved.Trophies
+	}
+	for commentID, byUser := range saved.CommentReactions {
+		reacted := make(map[reactionKey]bool)
+		for userID, emojis := range byUser {
+			for _, emoji := range emojis {
+				reacted[reactionKey{userID: userID, emoji: emoji}] = true
+			}
+		}
+		e.CommentReactions[commentID] = reacted
+	}
+	// Collapse state follows this engine's threshold, not the saved one's.
+	e.setCollapseThreshold(e.CollapseThreshold)
+	return nil
+}
+
+// restoreArchived fills in what cold storage dropped from an archived post:
+// its moderation flags from the engine's indexes and its up and down vote
+// counts from its voters.
+func restoreArchived(saved *snapshot, post *Post) {
+	_, post.Removed = saved.RemovedPosts[post.ID]
+	_, post.Deleted = saved.DeletedPosts[post.ID]
+	post.Upvotes, post.Downvotes = 0, 0
+	for _, vote := range saved.PostVotes[post.ID] {
+		if vote.Direction > 0 {
+			post.Upvotes++
+		} else {
+			post.Downvotes++
+		}
+	}
+	var walk func(comments []*Comment)
+	walk = func(comments []*Comment) {
+		for _, comment := range comments {
+			_, comment.Removed = saved.RemovedComments[comment.ID]
+			_, comment.Deleted = saved.DeletedComments[comment.ID]
+			_, comment.Edited = saved.EditedComments[comment.ID]
+			walk(comment.Replies)
+		}
+	}
+	walk(post.Comments)
+}
+
+// numberBans gives an ID to every ban restored from a snapshot written
+// before bans had them. Callers must hold e.Mutex.
+func (e *Engine) numberBans() {
+	names := make([]string, 0, len(e.SubReddits))
+	for name := range e.SubReddits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		subReddit := e.SubReddits[name]
+		ids := make([]int64, 0, len(subReddit.Banned))
+		for id := range subReddit.Banned {
+			if _, numbered := subReddit.BanIDs[id]; !numbered {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			e.BanID++
+			subReddit.BanIDs[id] = e.BanID
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"strings"
+	"time"
+)
+
+// Direct Message Spam Folder
+
+const (
+	// Senders younger than spamNewAccountAge or with less than spamMinKarma
+	// look like throwaway accounts.
+	spamNewAccountAge = 7 * 24 * time.Hour
+	spamMinKarma      = 1
+	// The same text sent to spamRepeatRecipients distinct users within
+	// spamRepeatWindow looks like a campaign.
+	spamRepeatRecipients = 3
+	spamRepeatWindow     = time.Hour
+	// A message is filed as spam once its signals add up to spamThreshold.
+	// Repetition counts double, so a new or unproven sender alone never is.
+	spamThreshold = 3
+
+	// PersonaSpammer marks simulated accounts that send bulk DMs. The
+	// classifier never looks at it; it is the ground truth SpamAccuracy is
+	// measured against.
+	PersonaSpammer = "spammer"
+)
+
+type dmFingerprint struct {
+	senderID int64
+	content  string
+}
+
+type dmRepeat struct {
+	since      time.Time
+	recipients map[int64]bool
+}
+
+// classifyMessage decides whether a DM from from to to belongs in the
+// recipient's spam folder, scoring the sender's account age and karma and
+// how often they have sent the same text to others lately. It also records
+// the message for later repetition checks. Callers must hold e.Mutex.
+func (e *Engine) classifyMessage(from, to *User, content string, now time.Time) bool {
+	if now.Sub(e.dmRepeatsSwept) > spamRepeatWindow {
+		for key, repeat := range e.dmRepeats {
+			if now.Sub(repeat.since) > spamRepeatWindow {
+				delete(e.dmRepeats, key)
+			}
+		}
+		e.dmRepeatsSwept = now
+	}
+	key := dmFingerprint{senderID: from.ID, content: strings.ToLower(strings.Join(strings.Fields(content), " "))}
+	repeat := e.dmRepeats[key]
+	if repeat == nil || now.Sub(repeat.since) > spamRepeatWindow {
+		repeat = &dmRepeat{since: now, recipients: make(map[int64]bool)}
+		e.dmRepeats[key] = repeat
+	}
+	repeat.recipients[to.ID] = true
+
+	score := 0
+	if now.Sub(from.CreatedAt) < spamNewAccountAge {
+		score++
+	}
+	if from.Karma() < spamMinKarma {
+		score++
+	}
+	if len(repeat.recipients) >= spamRepeatRecipients {
+		score += 2
+	}
+	return score >= spamThreshold
+}
+
+// GetSpamFolder returns the messages to user that were filed as spam.
+// RetrieveMessages returns the rest.
+func (e *Engine) GetSpamFolder(user *User) []Message {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var spam []Message
+	for _, message := range e.Messages {
+		if message.To == user && message.Spam {
+			spam = append(spam, message)
+		}
+	}
+	return spam
+}
+
+// InboxRates summarizes how many DMs reach recipients' inboxes. PeakHourly
+// is the most inbox messages any one user received in a single clock hour.
+// Requests counts messages still waiting in message requests.
+type InboxRates struct {
+	Inbox            int
+	Spam             int
+	Requests         int
+	Recipients       int
+	MeanPerRecipient float64
+	PeakHourly       int
+	PeakUser         string
+}
+
+type inboxHour struct {
+	userID int64
+	hour   time.Time
+}
+
+// GetInboxRates describes how many direct messages reached inboxes.
+func (e *Engine) GetInboxRates() InboxRates {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var rates InboxRates
+	recipients := make(map[int64]bool)
+	hourly := make(map[inboxHour]int)
+	for _, message := range e.Messages {
+		if message.Spam {
+			rates.Spam++
+			continue
+		}
+		if message.Request {
+			rates.Requests++
+			continue
+		}
+		rates.Inbox++
+		recipients[message.To.ID] = true
+		bucket := inboxHour{userID: message.To.ID, hour: message.SentAt.Truncate(time.Hour)}
+		hourly[bucket]++
+		if hourly[bucket] > rates.PeakHourly {
+			rates.PeakHourly, rates.PeakUser = hourly[bucket], message.To.Username
+		}
+	}
+	rates.Recipients = len(recipients)
+	if rates.Recipients > 0 {
+		rates.MeanPerRecipient = float64(rates.Inbox) / float64(rates.Recipients)
+	}
+	return rates
+}
+
+// SpamAccuracy is the classifier's confusion matrix against the senders'
+// simulated personas.
+type SpamAccuracy struct {
+	TruePositives  int
+	FalsePositives int
+	TrueNegatives  int
+	FalseNegatives int
+}
+
+// Accuracy is the share of messages classified correctly.
+func (a SpamAccuracy) Accuracy() float64 {
+	return ratio(a.TruePositives+a.TrueNegatives, a.TruePositives+a.TrueNegatives+a.FalsePositives+a.FalseNegatives)
+}
+
+// Precision is the share of messages marked spam that were spam.
+func (a SpamAccuracy) Precision() float64 {
+	return ratio(a.TruePositives, a.TruePositives+a.FalsePositives)
+}
+
+// Recall is the share of spam that was marked spam.
+func (a SpamAccuracy) Recall() float64 {
+	return ratio(a.TruePositives, a.TruePositives+a.FalseNegatives)
+}
+
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// GetSpamAccuracy scores the spam classifier against the messages' true
+// labels.
+func (e *Engine) GetSpamAccuracy() SpamAccuracy {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var accuracy SpamAccuracy
+	for _, message := range e.Messages {
+		spammer := message.From.Persona == PersonaSpammer
+		switch {
+		case message.Spam && spammer:
+			accuracy.TruePositives++
+		case message.Spam:
+			accuracy.FalsePositives++
+		case spammer:
+			accuracy.FalseNegatives++
+		default:
+			accuracy.TrueNegatives++
+		}
+	}
+	return accuracy
+}
@@ -0,0 +1,90 @@
+package engine
+
+import "errors"
+
+// Sticky Comments
+
+var (
+	ErrCommentNotOnPost = errors.New("comment does not belong to this post")
+	ErrNotTopLevel      = errors.New("only top-level comments can be stickied")
+	ErrNoStickyComment  = errors.New("post has no stickied comment")
+)
+
+// StickyComment pins a top-level comment above all others on its post,
+// replacing any comment stickied before. Only moderators of the post's
+// subreddit may sticky, and removed comments can't be stickied.
+func (e *Engine) StickyComment(mod *User, post *Post, comment *Comment) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if !e.isModerator(mod, subReddit) {
+		return ErrNotModerator
+	}
+	if comment.PostID != post.ID {
+		return ErrCommentNotOnPost
+	}
+	if e.CommentParents[comment.ID] != 0 {
+		return ErrNotTopLevel
+	}
+	if comment.Removed {
+		return ErrAlreadyRemoved
+	}
+	if comment.Deleted {
+		return ErrContentDeleted
+	}
+	e.StickyComments[post.ID] = comment.ID
+	e.recordModAction(subReddit, mod, "sticky_comment", comment.ID, 0)
+	e.recordEvent("sticky_comment", mod.ID, subReddit.Name, comment.ID)
+	return nil
+}
+
+// UnstickyComment unpins the post's stickied comment.
+func (e *Engine) UnstickyComment(mod *User, post *Post) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[post.SubReddit]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	if !e.isModerator(mod, subReddit) {
+		return ErrNotModerator
+	}
+	commentID, stickied := e.StickyComments[post.ID]
+	if !stickied {
+		return ErrNoStickyComment
+	}
+	delete(e.StickyComments, post.ID)
+	e.recordModAction(subReddit, mod, "unsticky_comment", commentID, 0)
+	e.recordEvent("unsticky_comment", mod.ID, subReddit.Name, commentID)
+	return nil
+}
+
+// sortPostComments orders a post's comment tree by its comment sort and then
+// moves the stickied comment, if any, to the front. Callers must hold
+// e.Mutex.
+func (e *Engine) sortPostComments(post *Post) []*Comment {
+	sorted := e.sortComments(post.Comments, post.Author, e.CommentSorts[post.ID])
+	stickyID, stickied := e.StickyComments[post.ID]
+	if !stickied {
+		return sorted
+	}
+	for i, comment := range sorted {
+		if comment.ID == stickyID {
+			copy(sorted[1:i+1], sorted[:i])
+			sorted[0] = comment
+			break
+		}
+	}
+	return sorted
+}
+
+// dropSticky unpins comment if it is its post's stickied comment, as removed
+// comments must not stay pinned. Callers must hold e.Mutex.
+func (e *Engine) dropSticky(comment *Comment) {
+	if stickyID, stickied := e.StickyComments[comment.PostID]; stickied && stickyID == comment.ID {
+		delete(e.StickyComments, comment.PostID)
+	}
+}
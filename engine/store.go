@@ -0,0 +1,376 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Storage Backends
+
+var ErrStoreEmpty = errors.New("store holds no engine state")
+
+// Store is where Sync checkpoints the engine's users, subreddits, posts,
+// comments and messages: the snapshot Save writes, split into one record
+// per entity so a sync only rewrites what changed. It is a checkpoint, not
+// a backing store: the engine still serves every read and write from its
+// in-memory maps and only reads the store back in LoadFromStore, so its
+// state must fit in memory. Records are opaque bytes grouped by kind and
+// keyed within it; the engine encodes them with e.Codec, so a backend only
+// has to put, delete and list them. MapStore keeps records in process;
+// FileStore keeps them in an append-only file.
+type Store interface {
+	Name() string
+	Put(kind, key string, record []byte) error
+	Delete(kind, key string) error
+	// Each calls visit for every record of kind, in key order, stopping at
+	// the first error. record is only valid until visit returns.
+	Each(kind string, visit func(key string, record []byte) error) error
+	// Flush makes every write so far durable.
+	Flush() error
+	Close() error
+}
+
+// Record kinds the engine writes. Events are records of their own, so
+// syncing only appends the new ones; storeMeta holds a single record with
+// the counters and indexes that belong to no one entity.
+const (
+	storeUsers      = "users"
+	storeSubReddits = "subreddits"
+	storePosts      = "posts"
+	storeComments   = "comments"
+	storeMessages   = "messages"
+	storeEvents     = "events"
+	storeMeta       = "meta"
+	storeMetaKey    = "engine"
+)
+
+var storeKinds = []string{storeUsers, storeSubReddits, storePosts, storeComments, storeMessages, storeEvents, storeMeta}
+
+// storedPost is one post record. Index is the post's position in its
+// subreddit's post list, which isn't always ID order once approval queues
+// and embargoes release posts late.
+type storedPost struct {
+	SubReddit string
+	Index     int
+	savedPost
+}
+
+// storedComment is one comment record, without its replies, which are
+// records of their own. ParentID is 0 for top-level comments; Index is the
+// comment's position among its siblings.
+type storedComment struct {
+	PostID   int64
+	ParentID int64
+	Index    int
+	savedComment
+}
+
+// storedPostGob and storedCommentGob are how GobCodec encodes storedPost
+// and storedComment: gob skips their unexported embedded records, so these
+// carry them as named fields.
+type storedPostGob struct {
+	SubReddit string
+	Index     int
+	Post      savedPost
+}
+
+type storedCommentGob struct {
+	PostID   int64
+	ParentID int64
+	Index    int
+	Comment  savedComment
+}
+
+func (p storedPost) GobEncode() ([]byte, error) {
+	return GobCodec{}.Marshal(storedPostGob{SubReddit: p.SubReddit, Index: p.Index, Post: p.savedPost})
+}
+
+func (p *storedPost) GobDecode(data []byte) error {
+	var record storedPostGob
+	if err := (GobCodec{}).Unmarshal(data, &record); err != nil {
+		return err
+	}
+	*p = storedPost{SubReddit: record.SubReddit, Index: record.Index, savedPost: record.Post}
+	return nil
+}
+
+func (c storedComment) GobEncode() ([]byte, error) {
+	return GobCodec{}.Marshal(storedCommentGob{PostID: c.PostID, ParentID: c.ParentID, Index: c.Index, Comment: c.savedComment})
+}
+
+func (c *storedComment) GobDecode(data []byte) error {
+	var record storedCommentGob
+	if err := (GobCodec{}).Unmarshal(data, &record); err != nil {
+		return err
+	}
+	*c = storedComment{PostID: record.PostID, ParentID: record.ParentID, Index: record.Index, savedComment: record.Comment}
+	return nil
+}
+
+// MapStore is a Store held in maps in this process. It is the engine's
+// default: Sync works against it, but nothing outlives the process.
+type MapStore struct {
+	mu      sync.Mutex
+	records map[string]map[string][]byte
+}
+
+// NewMapStore returns an empty MapStore.
+func NewMapStore() *MapStore {
+	return &MapStore{records: make(map[string]map[string][]byte)}
+}
+
+func (s *MapStore) Name() string { return "memory" }
+
+func (s *MapStore) Put(kind, key string, record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.records[kind] == nil {
+		s.records[kind] = make(map[string][]byte)
+	}
+	s.records[kind][key] = append([]byte(nil), record...)
+	return nil
+}
+
+func (s *MapStore) Delete(kind, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records[kind], key)
+	return nil
+}
+
+func (s *MapStore) Each(kind string, visit func(key string, record []byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.records[kind]))
+	for key := range s.records[kind] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := visit(key, s.records[kind][key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MapStore) Flush() error { return nil }
+
+func (s *MapStore) Close() error { return nil }
+
+// SetStore replaces the store Sync and LoadFromStore use.
+func (e *Engine) SetStore(store Store) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.Store = store
+}
+
+// Sync checkpoints the engine's state to e.Store: it takes the snapshot
+// Save would write and stores it as one record per user, subreddit, post,
+// comment and message, deletes records of anything no longer there, and
+// flushes the store. Archived posts are read back from cold storage and
+// written with the hot ones.
+func (e *Engine) Sync() error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	saved, err := e.snapshot()
+	if err != nil {
+		return err
+	}
+	records := make(map[string]map[string]interface{}, len(storeKinds))
+	for _, kind := range storeKinds {
+		records[kind] = make(map[string]interface{})
+	}
+	for _, user := range saved.Users {
+		records[storeUsers][strconv.FormatInt(user.ID, 10)] = user
+	}
+	for i := range saved.Messages {
+		records[storeMessages][fmt.Sprintf("%012d", i)] = saved.Messages[i]
+	}
+	for _, event := range saved.Events {
+		records[storeEvents][fmt.Sprintf("%012d", event.Seq)] = event
+	}
+	var flatten func(comments []savedComment, postID, parentID int64)
+	flatten = func(comments []savedComment, postID, parentID int64) {
+		for i, comment := range comments {
+			replies := comment.Replies
+			comment.Replies = nil
+			records[storeComments][strconv.FormatInt(comment.ID, 10)] = storedComment{PostID: postID, ParentID: parentID, Index: i, savedComment: comment}
+			flatten(replies, postID, comment.ID)
+		}
+	}
+	for _, subReddit := range saved.SubReddits {
+		for i, post := range subReddit.Posts {
+			comments := post.Comments
+			post.Comments = nil
+			records[storePosts][strconv.FormatInt(post.ID, 10)] = storedPost{SubReddit: subReddit.Name, Index: i, savedPost: post}
+			flatten(comments, post.ID, 0)
+		}
+		subReddit.Posts = nil
+		records[storeSubReddits][subReddit.Name] = subReddit
+	}
+	saved.Users, saved.Messages, saved.SubReddits, saved.Events = nil, nil, nil, nil
+	records[storeMeta][storeMetaKey] = saved
+
+	for _, kind := range storeKinds {
+		var stale []string
+		err := e.Store.Each(kind, func(key string, _ []byte) error {
+			if _, exists := records[kind][key]; !exists {
+				stale = append(stale, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range stale {
+			if err := e.Store.Delete(kind, key); err != nil {
+				return err
+			}
+		}
+		for key, record := range records[kind] {
+			data, err := e.Codec.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := e.Store.Put(kind, key, data); err != nil {
+				return fmt.Errorf("storing %s %s: %w", kind, key, err)
+			}
+		}
+	}
+	return e.Store.Flush()
+}
+
+// LoadFromStore restores the state last synced to e.Store into an engine
+// that has no users or subreddits yet, the way Load restores a snapshot. It
+// returns ErrStoreEmpty if nothing was ever synced.
+func (e *Engine) LoadFromStore() error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if len(e.Users) > 0 || len(e.SubReddits) > 0 {
+		return ErrEngineNotEmpty
+	}
+	var saved *snapshot
+	err := e.Store.Each(storeMeta, func(key string, record []byte) error {
+		if key == storeMetaKey {
+			saved = &snapshot{}
+			return e.Codec.Unmarshal(record, saved)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if saved == nil {
+		return ErrStoreEmpty
+	}
+	if saved.Version != snapshotVersion {
+		return fmt.Errorf("%w: version %d, want %d", ErrInvalidSnapshot, saved.Version, snapshotVersion)
+	}
+	if err := e.assembleSnapshot(saved); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	return e.resume(saved)
+}
+
+// assembleSnapshot reads every other record kind into saved, nesting posts
+// under their subreddits and comments under their parents. Callers must
+// hold e.Mutex.
+func (e *Engine) assembleSnapshot(saved *snapshot) error {
+	err := e.Store.Each(storeUsers, func(key string, record []byte) error {
+		user := &User{}
+		saved.Users = append(saved.Users, user)
+		return e.Codec.Unmarshal(record, user)
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(saved.Users, func(i, j int) bool { return saved.Users[i].ID < saved.Users[j].ID })
+	err = e.Store.Each(storeEvents, func(key string, record []byte) error {
+		var event Event
+		if err := e.Codec.Unmarshal(record, &event); err != nil {
+			return err
+		}
+		saved.Events = append(saved.Events, event)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = e.Store.Each(storeMessages, func(key string, record []byte) error {
+		var message savedMessage
+		if err := e.Codec.Unmarshal(record, &message); err != nil {
+			return err
+		}
+		saved.Messages = append(saved.Messages, message)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	type commentKey struct{ postID, parentID int64 }
+	children := make(map[commentKey][]storedComment)
+	err = e.Store.Each(storeComments, func(key string, record []byte) error {
+		var comment storedComment
+		if err := e.Codec.Unmarshal(record, &comment); err != nil {
+			return err
+		}
+		parent := commentKey{comment.PostID, comment.ParentID}
+		children[parent] = append(children[parent], comment)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var nest func(parent commentKey) []savedComment
+	nest = func(parent commentKey) []savedComment {
+		siblings := children[parent]
+		sort.Slice(siblings, func(i, j int) bool { return siblings[i].Index < siblings[j].Index })
+		comments := make([]savedComment, 0, len(siblings))
+		for _, stored := range siblings {
+			comment := stored.savedComment
+			comment.Replies = nest(commentKey{parent.postID, comment.ID})
+			comments = append(comments, comment)
+		}
+		return comments
+	}
+
+	posts := make(map[string][]storedPost)
+	err = e.Store.Each(storePosts, func(key string, record []byte) error {
+		var post storedPost
+		if err := e.Codec.Unmarshal(record, &post); err != nil {
+			return err
+		}
+		posts[post.SubReddit] = append(posts[post.SubReddit], post)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = e.Store.Each(storeSubReddits, func(key string, record []byte) error {
+		var subReddit savedSubReddit
+		if err := e.Codec.Unmarshal(record, &subReddit); err != nil {
+			return err
+		}
+		stored := posts[subReddit.Name]
+		delete(posts, subReddit.Name)
+		sort.Slice(stored, func(i, j int) bool { return stored[i].Index < stored[j].Index })
+		for _, post := range stored {
+			post.Comments = nest(commentKey{post.ID, 0})
+			subReddit.Posts = append(subReddit.Posts, post.savedPost)
+		}
+		saved.SubReddits = append(saved.SubReddits, subReddit)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for name := range posts {
+		return fmt.Errorf("posts stored for unknown subreddit %s", name)
+	}
+	return nil
+}
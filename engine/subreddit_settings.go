@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SubReddit Settings
+
+var (
+	ErrSubRedditNotFound   = errors.New("subreddit not found")
+	ErrSubRedditExists     = errors.New("subreddit already exists")
+	ErrCrosspostNotAllowed = errors.New("crosspost not allowed")
+)
+
+// SubRedditSettings holds per-subreddit policy. The zero value is the default
+// policy.
+type SubRedditSettings struct {
+	DisallowCrosspostsIn  bool
+	DisallowCrosspostsOut bool
+	// RequireApproval holds new posts in a queue until a moderator
+	// approves them.
+	RequireApproval bool
+	// Reactions lists the emojis allowed on comments; nil means
+	// DefaultReactions.
+	Reactions []string
+	// MinCommentKarmaToPost is how much comment karma a user must have
+	// earned in the subreddit before they may post there. Moderators are
+	// exempt; zero disables the requirement.
+	MinCommentKarmaToPost int
+	// NSFW marks everything in the subreddit as adult content, so minors
+	// can't join it or see its posts.
+	NSFW bool
+}
+
+// CrosspostError reports which subreddit's policy rejected a crosspost. It
+// matches ErrCrosspostNotAllowed with errors.Is.
+type CrosspostError struct {
+	SubReddit string
+	Outbound  bool
+}
+
+func (err *CrosspostError) Error() string {
+	if err.Outbound {
+		return fmt.Sprintf("crossposts out of %s are not allowed", err.SubReddit)
+	}
+	return fmt.Sprintf("crossposts into %s are not allowed", err.SubReddit)
+}
+
+func (err *CrosspostError) Unwrap() error {
+	return ErrCrosspostNotAllowed
+}
+
+// SetSubRedditSettings replaces the subreddit's settings, rejecting
+// invalid ones with a *SettingsError.
+func (e *Engine) SetSubRedditSettings(subRedditName string, settings SubRedditSettings) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	subReddit.Settings = settings
+	e.recordEvent("update_settings", 0, subRedditName, 0)
+	return nil
+}
+
+// GetSubRedditSettings returns the subreddit's settings.
+func (e *Engine) GetSubRedditSettings(subRedditName string) (SubRedditSettings, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return SubRedditSettings{}, ErrSubRedditNotFound
+	}
+	return subReddit.Settings, nil
+}
+
+// checkCrosspost applies the source and target crosspost policies. Callers
+// must hold e.Mutex.
+func (e *Engine) checkCrosspost(source string, target *SubReddit) error {
+	if source == target.Name {
+		return nil
+	}
+	if sourceSub, exists := e.SubReddits[source]; exists && sourceSub.Settings.DisallowCrosspostsOut {
+		return &CrosspostError{SubReddit: source, Outbound: true}
+	}
+	if target.Settings.DisallowCrosspostsIn {
+		return &CrosspostError{SubReddit: target.Name}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+)
+
+// Subscription Import and Export
+
+var ErrInvalidSubscriptionList = errors.New("invalid subscription list")
+
+// BulkSubscribeResult says what happened to each subreddit in a bulk
+// subscribe. Unknown lists names that don't exist on this engine, so a list
+// exported elsewhere imports as much as it can, and AgeRestricted the NSFW
+// subreddits a minor was left out of.
+type BulkSubscribeResult struct {
+	Joined            int
+	AlreadySubscribed int
+	Unknown           []string
+	AgeRestricted     []string
+}
+
+// ExportSubscriptions returns the user's subscriptions as a multireddit
+// path, "r/name+name+...", sorted by name. It is "" when the user has none.
+func (e *Engine) ExportSubscriptions(user *User) string {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	names := e.exportUserSubscriptions(user)
+	if len(names) == 0 {
+		return ""
+	}
+	return "r/" + strings.Join(names, "+")
+}
+
+// ImportSubscriptions subscribes user to every subreddit in a multireddit
+// path as produced by ExportSubscriptions. The "r/" prefix is optional.
+func (e *Engine) ImportSubscriptions(user *User, list string) (BulkSubscribeResult, error) {
+	names, err := parseSubscriptionList(list)
+	if err != nil {
+		return BulkSubscribeResult{}, err
+	}
+	return e.BulkSubscribe(user, names)
+}
+
+func parseSubscriptionList(list string) ([]string, error) {
+	list = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(list), "/"), "r/")
+	if list == "" {
+		return nil, nil
+	}
+	names := strings.Split(list, "+")
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, " /\t\n") {
+			return nil, ErrInvalidSubscriptionList
+		}
+	}
+	return names, nil
+}
+
+// BulkSubscribe joins user to each named subreddit under a single lock.
+// Each new subscription counts as a join action; subreddits the user is
+// already in are skipped without one.
+func (e *Engine) BulkSubscribe(user *User, subRedditNames []string) (BulkSubscribeResult, error) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if e.isSuspended(user) {
+		return BulkSubscribeResult{}, ErrUserSuspended
+	}
+	var result BulkSubscribeResult
+	for _, name := range subRedditNames {
+		subReddit, exists := e.SubReddits[name]
+		if !exists {
+			result.Unknown = append(result.Unknown, name)
+			continue
+		}
+		if _, member := subReddit.Users[user.ID]; member {
+			result.AlreadySubscribed++
+			continue
+		}
+		if subReddit.Settings.NSFW && minor(user) {
+			result.AgeRestricted = append(result.AgeRestricted, name)
+			continue
+		}
+		e.subscribe(user, subReddit)
+		result.Joined++
+	}
+	return result, nil
+}
+
+// subscribe adds user to subReddit and records the join. Callers must hold
+// e.Mutex.
+func (e *Engine) subscribe(user *User, subReddit *SubReddit) {
+	if _, member := subReddit.Users[user.ID]; !member {
+		e.trafficToday(subReddit).Subscriptions++
+	}
+	subReddit.Users[user.ID] = user
+	delete(subReddit.DefaultMembers, user.ID)
+	user.Actions++
+	e.TotalActions++
+	e.recordEvent("join", user.ID, subReddit.Name, 0)
+	e.checkBigCommunity(subReddit)
+	e.noteDiscoveryJoin(user, subReddit)
+}
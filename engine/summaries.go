@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Thread Summaries
+
+var ErrNoSummarizer = errors.New("no summarizer configured")
+
+const (
+	// A cached summary goes stale once its thread has gained
+	// summaryRefreshShare more comments than it had when summarized, and
+	// at least summaryRefreshComments of them.
+	summaryRefreshShare    = 0.2
+	summaryRefreshComments = 5
+	summaryExcerptLength   = 80
+)
+
+// Summarizer condenses a thread into a short text. It is the hook for
+// AI-assisted features such as TL;DRs; the engine only caches what it says.
+type Summarizer interface {
+	Summarize(thread Thread) (string, error)
+}
+
+// MockSummarizer lists the TopComments highest-voted visible comments
+// instead of calling a real summarization model.
+type MockSummarizer struct {
+	TopComments int
+}
+
+func (s MockSummarizer) Summarize(thread Thread) (string, error) {
+	var comments []ThreadComment
+	var collect func([]ThreadComment)
+	collect = func(tree []ThreadComment) {
+		for _, comment := range tree {
+			if comment.Status == ContentVisible {
+				comments = append(comments, comment)
+			}
+			collect(comment.Replies)
+		}
+	}
+	collect(thread.Comments)
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].Votes > comments[j].Votes })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s in r/%s, %d comments)", excerpt(thread.Post.Content), points(thread.Post.Votes), thread.Post.SubReddit, countThreadComments(thread.Comments))
+	for _, comment := range comments[:min(s.TopComments, len(comments))] {
+		fmt.Fprintf(&b, "\n- %s (%s): %s", comment.Author, points(comment.Votes), excerpt(comment.Content))
+	}
+	return b.String(), nil
+}
+
+// ThreadSummary is a summary of a post's thread as it stood at SummarizedAt,
+// when it had Comments comments.
+type ThreadSummary struct {
+	PostID       int64
+	Summary      string
+	Comments     int
+	SummarizedAt time.Time
+}
+
+// SummaryStats is how well the summary cache did: Invalidations counts the
+// misses that replaced a stale summary.
+type SummaryStats struct {
+	Cached        int
+	Hits          int
+	Misses        int
+	Invalidations int
+}
+
+// SetSummarizer sets the summarizer SummarizeThread uses and drops the
+// summaries the previous one wrote.
+func (e *Engine) SetSummarizer(summarizer Summarizer) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.Summarizer = summarizer
+	e.ThreadSummaries = make(map[int64]ThreadSummary)
+}
+
+// SummarizeThread summarizes the post's thread, archived posts included. The
+// summary is cached and reused until the thread gains significantly more
+// comments or the post is deleted or removed. The summarizer runs without
+// the engine lock held.
+func (e *Engine) SummarizeThread(postID int64) (ThreadSummary, error) {
+	thread, err := e.snapshotThread(postID)
+	if err != nil {
+		return ThreadSummary{}, err
+	}
+	comments := countThreadComments(thread.Comments)
+	e.Mutex.Lock()
+	summarizer := e.Summarizer
+	cached, found := e.ThreadSummaries[postID]
+	if found && !summaryStale(cached, comments, thread.Post.Status) {
+		e.SummaryHits++
+		e.Mutex.Unlock()
+		return cached, nil
+	}
+	e.SummaryMisses++
+	if found {
+		e.SummaryInvalidations++
+	}
+	e.Mutex.Unlock()
+
+	if summarizer == nil {
+		return ThreadSummary{}, ErrNoSummarizer
+	}
+	text, err := summarizer.Summarize(thread)
+	if err != nil {
+		return ThreadSummary{}, err
+	}
+
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	summary := ThreadSummary{PostID: postID, Summary: text, Comments: comments, SummarizedAt: e.Clock.Now()}
+	if thread.Post.Status != ContentVisible {
+		delete(e.ThreadSummaries, postID)
+	} else {
+		e.ThreadSummaries[postID] = summary
+	}
+	return summary, nil
+}
+
+// summaryStale reports whether a cached summary no longer reflects a thread
+// that now has comments comments and the given post status. Summaries of
+// hidden posts are never cached, so any hidden status makes one stale.
+func summaryStale(summary ThreadSummary, comments int, status ContentStatus) bool {
+	if status != ContentVisible {
+		return true
+	}
+	added := comments - summary.Comments
+	return added >= summaryRefreshComments && float64(added) >= summaryRefreshShare*float64(summary.Comments)
+}
+
+// GetSummaryStats returns the summary cache's counters.
+func (e *Engine) GetSummaryStats() SummaryStats {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return SummaryStats{Cached: len(e.ThreadSummaries), Hits: e.SummaryHits, Misses: e.SummaryMisses, Invalidations: e.SummaryInvalidations}
+}
+
+func countThreadComments(comments []ThreadComment) int {
+	count := len(comments)
+	for _, comment := range comments {
+		count += countThreadComments(comment.Replies)
+	}
+	return count
+}
+
+// excerpt shortens content to summaryExcerptLength runes on one line.
+func excerpt(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if runes := []rune(content); len(runes) > summaryExcerptLength {
+		return string(runes[:summaryExcerptLength-1]) + "…"
+	}
+	return content
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Suspensions and Audit Trail
+
+var (
+	ErrNotAdmin         = errors.New("user is not an admin")
+	ErrInvalidDuration  = errors.New("suspension duration must be positive")
+	ErrUserNotSuspended = errors.New("user is not suspended")
+	ErrUserSuspended    = errors.New("user is suspended")
+)
+
+// AuditEntry is one action in the site-wide audit log. ActorID is 0 for
+// actions taken by the system.
+type AuditEntry struct {
+	Time    time.Time
+	ActorID int64
+	Action  string
+	UserID  int64
+	Detail  string
+}
+
+// recordAudit appends to the audit trail. Callers must hold e.Mutex.
+func (e *Engine) recordAudit(actorID int64, action string, userID int64, detail string) {
+	e.AuditLog = append(e.AuditLog, AuditEntry{
+		Time:    e.Clock.Now(),
+		ActorID: actorID,
+		Action:  action,
+		UserID:  userID,
+		Detail:  detail,
+	})
+}
+
+// MakeAdmin grants the user site admin rights.
+func (e *Engine) MakeAdmin(user *User) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	user.IsAdmin = true
+	e.recordAudit(0, "make_admin", user.ID, "")
+}
+
+// SuspendUser blocks every action by user until duration has passed on the
+// engine clock. Suspensions are site-wide and always expire; extending an
+// active suspension replaces its end time.
+func (e *Engine) SuspendUser(admin, user *User, duration time.Duration) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if !admin.IsAdmin {
+		return ErrNotAdmin
+	}
+	if duration <= 0 {
+		return ErrInvalidDuration
+	}
+	user.SuspendedUntil = e.Clock.Now().Add(duration)
+	e.TotalSuspensions++
+	e.recordAudit(admin.ID, "suspend", user.ID, fmt.Sprintf("until %s", user.SuspendedUntil.Format(time.RFC3339)))
+	return nil
+}
+
+// LiftSuspension ends the user's suspension early.
+func (e *Engine) LiftSuspension(admin, user *User) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if !admin.IsAdmin {
+		return ErrNotAdmin
+	}
+	if user.SuspendedUntil.IsZero() {
+		return ErrUserNotSuspended
+	}
+	user.SuspendedUntil = time.Time{}
+	e.recordAudit(admin.ID, "unsuspend", user.ID, "lifted by admin")
+	return nil
+}
+
+// IsSuspended reports whether the user is suspended right now.
+func (e *Engine) IsSuspended(user *User) bool {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	return e.isSuspended(user)
+}
+
+// isSuspended reports whether user is currently suspended, lifting the
+// suspension if it has expired. Accounts merged into another are suspended
+// for good. Callers must hold e.Mutex.
+func (e *Engine) isSuspended(user *User) bool {
+	if user.MergedInto != 0 {
+		e.BlockedActions++
+		return true
+	}
+	if user.SuspendedUntil.IsZero() {
+		return false
+	}
+	if e.Clock.Now().Before(user.SuspendedUntil) {
+		e.BlockedActions++
+		return true
+	}
+	user.SuspendedUntil = time.Time{}
+	e.recordAudit(0, "unsuspend", user.ID, "expired")
+	return false
+}
+
+// LiftExpiredSuspensions sweeps all users so expiries are logged even for
+// users who never try to act again.
+func (e *Engine) LiftExpiredSuspensions() int {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	now := e.Clock.Now()
+	lifted := 0
+	for _, user := range e.Users {
+		if !user.SuspendedUntil.IsZero() && !now.Before(user.SuspendedUntil) {
+			user.SuspendedUntil = time.Time{}
+			e.recordAudit(0, "unsuspend", user.ID, "expired")
+			lifted++
+		}
+	}
+	return lifted
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// User Data Export
+
+// ExportedPost is a post in a data takeout.
+type ExportedPost struct {
+	ID          int64
+	SubReddit   string
+	Content     string
+	URL         string       `json:",omitempty"`
+	Attachments []Attachment `json:",omitempty"`
+	Votes       int
+	CreatedAt   time.Time
+	Removed     bool
+}
+
+// ExportedComment is a comment in a data takeout.
+type ExportedComment struct {
+	ID        int64
+	PostID    int64
+	SubReddit string
+	Content   string
+	Votes     int
+	CreatedAt time.Time
+	EditedAt  time.Time       `json:",omitempty"`
+	Reactions []ReactionCount `json:",omitempty"`
+}
+
+// ExportedVote is a vote in a data takeout.
+type ExportedVote struct {
+	Time      time.Time
+	Type      string
+	SubReddit string
+	TargetID  int64
+}
+
+// ExportedMessage is a direct message in a data takeout.
+type ExportedMessage struct {
+	From    string
+	To      string
+	Content string
+	Spam    bool `json:",omitempty"`
+	Request bool `json:",omitempty"`
+}
+
+// ExportUserData writes a zip archive with one JSON file per section of the
+// user's data. Votes come from the event log, so only votes attributed to a
+// voter appear.
+func (e *Engine) ExportUserData(user *User, w io.Writer) error {
+	e.Mutex.RLock()
+	sections := map[string]interface{}{
+		"profile.json":       e.userProfile(user),
+		"posts.json":         e.exportUserPosts(user),
+		"comments.json":      e.exportUserComments(user),
+		"votes.json":         e.exportUserVotes(user),
+		"messages.json":      e.exportUserMessages(user),
+		"subscriptions.json": e.exportUserSubscriptions(user),
+	}
+	e.Mutex.RUnlock()
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	archive := zip.NewWriter(w)
+	for _, name := range names {
+		f, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(sections[name]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// The exportUser* helpers below must be called with e.Mutex held.
+
+func (e *Engine) exportUserPosts(user *User) []ExportedPost {
+	posts := []ExportedPost{}
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			if post.Author != user || post.Deleted {
+				continue
+			}
+			_, removed := e.RemovedPosts[post.ID]
+			posts = append(posts, ExportedPost{
+				ID:          post.ID,
+				SubReddit:   post.SubReddit,
+				Content:     post.Content,
+				URL:         post.URL,
+				Attachments: post.Attachments,
+				Votes:       post.Votes,
+				CreatedAt:   post.CreatedAt,
+				Removed:     removed,
+			})
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts
+}
+
+func (e *Engine) exportUserComments(user *User) []ExportedComment {
+	comments := []ExportedComment{}
+	var walk func([]*Comment)
+	walk = func(thread []*Comment) {
+		for _, comment := range thread {
+			if comment.Author == user && !comment.Deleted {
+				comments = append(comments, ExportedComment{
+					ID:        comment.ID,
+					PostID:    comment.PostID,
+					SubReddit: comment.SubReddit,
+					Content:   comment.Content(),
+					Votes:     comment.Votes,
+					CreatedAt: comment.CreatedAt,
+					EditedAt:  comment.EditedAt,
+					Reactions: reactionBreakdown(comment.Reactions),
+				})
+			}
+			walk(comment.Replies)
+		}
+	}
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			walk(post.Comments)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments
+}
+
+func (e *Engine) exportUserVotes(user *User) []ExportedVote {
+	votes := []ExportedVote{}
+	for _, event := range e.Events {
+		if event.UserID != user.ID {
+			continue
+		}
+		switch event.Type {
+		case "upvote", "downvote", "unvote", "comment_upvote", "comment_downvote", "comment_unvote":
+			votes = append(votes, ExportedVote{Time: event.Time, Type: event.Type, SubReddit: event.SubReddit, TargetID: event.TargetID})
+		}
+	}
+	return votes
+}
+
+func (e *Engine) exportUserMessages(user *User) []ExportedMessage {
+	messages := []ExportedMessage{}
+	for _, message := range e.Messages {
+		if message.From == user || message.To == user {
+			messages = append(messages, ExportedMessage{From: message.From.Username, To: message.To.Username, Content: message.Content, Spam: message.Spam, Request: message.Request})
+		}
+	}
+	return messages
+}
+
+func (e *Engine) exportUserSubscriptions(user *User) []string {
+	subscriptions := []string{}
+	for name, subReddit := range e.SubReddits {
+		if _, member := subReddit.Users[user.ID]; member {
+			subscriptions = append(subscriptions, name)
+		}
+	}
+	sort.Strings(subscriptions)
+	return subscriptions
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Tenants
+
+var (
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+	ErrTenantExists  = errors.New("tenant already exists")
+)
+
+// TenantQuota caps what a tenant may create. Zero means unlimited.
+type TenantQuota struct {
+	MaxUsers      int
+	MaxSubReddits int
+	MaxPosts      int
+}
+
+// overQuota reports whether creating one more item would exceed limit,
+// counting the rejection. Callers must hold e.Mutex.
+func (e *Engine) overQuota(limit, current int) bool {
+	if limit > 0 && current >= limit {
+		e.QuotaRejections++
+		return true
+	}
+	return false
+}
+
+// Tenant is one isolated site. Each tenant has its own engine, so users,
+// subreddits and locks are never shared between tenants, and its own ID
+// range, so IDs stay unique across the host.
+type Tenant struct {
+	ID     string
+	Engine *Engine
+}
+
+// Host runs any number of tenants in one process.
+type Host struct {
+	mu      sync.Mutex
+	tenants map[string]*Tenant
+	created int64
+}
+
+// TenantMetrics summarizes one tenant's engine.
+type TenantMetrics struct {
+	ID              string
+	Users           int
+	SubReddits      int
+	TotalPosts      int
+	TotalComments   int
+	TotalVotes      int
+	TotalActions    int
+	QuotaRejections int
+}
+
+// NewHost returns a host with no tenants.
+func NewHost() *Host {
+	return &Host{tenants: make(map[string]*Tenant)}
+}
+
+// CreateTenant starts an engine for a new tenant with the given quota. Its
+// IDs don't overlap those of other tenants.
+func (h *Host) CreateTenant(id string, quota TenantQuota) (*Tenant, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, exists := h.tenants[id]; exists {
+		return nil, ErrTenantExists
+	}
+	engine := New()
+	engine.Quota = quota
+	engine.SetIDOffset(h.created * tenantIDStride)
+	h.created++
+	tenant := &Tenant{ID: id, Engine: engine}
+	h.tenants[id] = tenant
+	return tenant, nil
+}
+
+// Tenant returns the tenant with that ID, or nil.
+func (h *Host) Tenant(id string) *Tenant {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.tenants[id]
+}
+
+// Tenants returns every tenant ordered by ID.
+func (h *Host) Tenants() []*Tenant {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tenants := make([]*Tenant, 0, len(h.tenants))
+	for _, tenant := range h.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// Metrics summarizes every tenant, ordered by ID.
+func (h *Host) Metrics() []TenantMetrics {
+	tenants := h.Tenants()
+	metrics := make([]TenantMetrics, 0, len(tenants))
+	for _, tenant := range tenants {
+		e := tenant.Engine
+		e.Mutex.RLock()
+		metrics = append(metrics, TenantMetrics{
+			ID:              tenant.ID,
+			Users:           len(e.Users),
+			SubReddits:      len(e.SubReddits),
+			TotalPosts:      e.TotalPosts,
+			TotalComments:   e.TotalComments,
+			TotalVotes:      e.TotalVotes,
+			TotalActions:    e.TotalActions,
+			QuotaRejections: e.QuotaRejections,
+		})
+		e.Mutex.RUnlock()
+	}
+	return metrics
+}
@@ -0,0 +1,254 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Thread Export
+
+var (
+	ErrPostNotFound  = errors.New("post not found")
+	ErrUnknownFormat = errors.New("unknown export format")
+)
+
+const removedContentMarker = "[removed]"
+
+// ThreadFormat is the encoding ExportThread writes.
+type ThreadFormat string
+
+const (
+	ThreadJSON     ThreadFormat = "json"
+	ThreadMarkdown ThreadFormat = "markdown"
+)
+
+// ThreadPost is the post at the top of a Thread.
+type ThreadPost struct {
+	ID          int64
+	Permalink   string
+	SubReddit   string
+	Author      string
+	Content     string
+	Status      ContentStatus `json:",omitempty"`
+	URL         string        `json:",omitempty"`
+	Attachments []Attachment  `json:",omitempty"`
+	Votes       int
+	CreatedAt   time.Time
+}
+
+// ThreadComment is a comment in a Thread, with its replies.
+type ThreadComment struct {
+	ID        int64
+	Permalink string
+	Author    string
+	Content   string
+	Status    ContentStatus `json:",omitempty"`
+	Votes     int
+	CreatedAt time.Time
+	EditedAt  time.Time       `json:",omitzero"`
+	Stickied  bool            `json:",omitempty"`
+	Collapsed bool            `json:",omitempty"`
+	Reactions []ReactionCount `json:",omitempty"`
+	Replies   []ThreadComment `json:",omitempty"`
+}
+
+// Thread is a post and its whole comment tree.
+type Thread struct {
+	Post     ThreadPost
+	Comments []ThreadComment
+}
+
+// ExportThread renders a post and its whole comment tree, ordered by the
+// post's comment sort. Archived posts are loaded from cold storage. Deleted
+// and removed content is replaced by a marker so exports can be shared
+// safely; Status tells the two apart.
+func (e *Engine) ExportThread(postID int64, format ThreadFormat) ([]byte, error) {
+	thread, err := e.snapshotThread(postID)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case ThreadJSON:
+		return json.MarshalIndent(thread, "", "  ")
+	case ThreadMarkdown:
+		return renderThreadMarkdown(thread), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+func (e *Engine) snapshotThread(postID int64) (Thread, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	post := e.FindPost(postID)
+	if post == nil {
+		post, _ = e.archivedPost(postID)
+	}
+	if post == nil {
+		return Thread{}, ErrPostNotFound
+	}
+	return e.thread(post), nil
+}
+
+// thread converts post and its comment tree. Callers must hold e.Mutex.
+func (e *Engine) thread(post *Post) Thread {
+	content, author := post.Content, DisplayedName(post.Author)
+	status := e.postStatus(post)
+	if marker, hidden := statusMarker(status); hidden {
+		content = marker
+	}
+	if status == ContentDeleted {
+		author = deletedContentMarker
+	}
+	thread := Thread{Post: ThreadPost{
+		ID:          post.ID,
+		Permalink:   PostPermalink(post),
+		SubReddit:   post.SubReddit,
+		Author:      author,
+		Content:     content,
+		Status:      status,
+		URL:         post.URL,
+		Attachments: post.Attachments,
+		Votes:       post.Votes,
+		CreatedAt:   post.CreatedAt,
+	}}
+	thread.Comments = e.threadComments(e.sortPostComments(post))
+	if stickyID, stickied := e.StickyComments[post.ID]; stickied && len(thread.Comments) > 0 && thread.Comments[0].ID == stickyID {
+		thread.Comments[0].Stickied = true
+	}
+	return thread
+}
+
+// FindPost looks a hot post up by ID. Callers must hold e.Mutex, so it is
+// also usable from an ActionHandler; other callers should use GetPost.
+func (e *Engine) FindPost(id int64) *Post {
+	return e.postIndex[id]
+}
+
+// threadComments converts a comment tree, showing "[deleted]" or "[removed]"
+// in place of hidden comments. Callers must hold e.Mutex.
+func (e *Engine) threadComments(comments []*Comment) []ThreadComment {
+	thread := make([]ThreadComment, 0, len(comments))
+	for _, comment := range comments {
+		content, author := comment.Content(), DisplayedName(comment.Author)
+		status := e.commentStatus(comment)
+		if marker, hidden := statusMarker(status); hidden {
+			content = marker
+		}
+		if status == ContentDeleted {
+			author = deletedContentMarker
+		}
+		thread = append(thread, ThreadComment{
+			ID:        comment.ID,
+			Permalink: CommentPermalink(comment),
+			Author:    author,
+			Content:   content,
+			Status:    status,
+			Votes:     comment.Votes,
+			CreatedAt: comment.CreatedAt,
+			EditedAt:  comment.EditedAt,
+			Collapsed: comment.Collapsed,
+			Reactions: reactionBreakdown(comment.Reactions),
+			Replies:   e.threadComments(comment.Replies),
+		})
+	}
+	return thread
+}
+
+func renderThreadMarkdown(thread Thread) []byte {
+	var b strings.Builder
+	post := thread.Post
+	fmt.Fprintf(&b, "# %s\n\n", post.Content)
+	if post.URL != "" {
+		fmt.Fprintf(&b, "<%s>\n\n", post.URL)
+	}
+	for _, attachment := range post.Attachments {
+		if attachment.Type == AttachmentVideo {
+			label := attachment.Caption
+			if label == "" {
+				label = "video"
+			}
+			fmt.Fprintf(&b, "[%s](%s)\n", label, attachment.Reference)
+		} else {
+			fmt.Fprintf(&b, "![%s](%s)\n", attachment.Caption, attachment.Reference)
+		}
+	}
+	if len(post.Attachments) > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "*Posted by %s in r/%s · %s · %s · %s*\n\n", markdownAuthor(post.Author), post.SubReddit, points(post.Votes), post.CreatedAt.UTC().Format(time.RFC3339), post.Permalink)
+	if len(thread.Comments) == 0 {
+		b.WriteString("_No comments._\n")
+	}
+	writeMarkdownComments(&b, thread.Comments, 0)
+	return []byte(b.String())
+}
+
+func writeMarkdownComments(b *strings.Builder, comments []ThreadComment, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, comment := range comments {
+		edited := ""
+		if !comment.EditedAt.IsZero() {
+			edited = " (edited)"
+		}
+		if comment.Stickied {
+			edited += " · stickied"
+		}
+		if comment.Collapsed {
+			edited += " · collapsed"
+		}
+		fmt.Fprintf(b, "%s- **%s** · %s · %s%s · %s\n", indent, markdownAuthor(comment.Author), points(comment.Votes), comment.CreatedAt.UTC().Format(time.RFC3339), edited, comment.Permalink)
+		for _, line := range strings.Split(comment.Content, "\n") {
+			fmt.Fprintf(b, "%s  %s\n", indent, line)
+		}
+		if len(comment.Reactions) > 0 {
+			reactions := make([]string, 0, len(comment.Reactions))
+			for _, reaction := range comment.Reactions {
+				reactions = append(reactions, fmt.Sprintf("%s %d", reaction.Emoji, reaction.Count))
+			}
+			fmt.Fprintf(b, "%s  %s\n", indent, strings.Join(reactions, " "))
+		}
+		writeMarkdownComments(b, comment.Replies, depth+1)
+	}
+}
+
+// BusiestPostID returns the ID of the hot post with the most comments,
+// counting replies, or 0 if there are no posts.
+func (e *Engine) BusiestPostID() int64 {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	bestID, best := int64(0), -1
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			if count := countComments(post.Comments); count > best || count == best && post.ID < bestID {
+				bestID, best = post.ID, count
+			}
+		}
+	}
+	return bestID
+}
+
+func countComments(comments []*Comment) int {
+	count := len(comments)
+	for _, comment := range comments {
+		count += countComments(comment.Replies)
+	}
+	return count
+}
+
+func points(votes int) string {
+	if votes == 1 || votes == -1 {
+		return fmt.Sprintf("%d point", votes)
+	}
+	return fmt.Sprintf("%d points", votes)
+}
+
+// markdownAuthor links an author as u/name, leaving "[deleted]" as is.
+func markdownAuthor(author string) string {
+	if author == deletedContentMarker {
+		return author
+	}
+	return "u/" + author
+}
@@ -0,0 +1,70 @@
+package engine
+
+import "time"
+
+// SubReddit Time Series
+
+// SubRedditSample is one point of a subreddit's time series.
+type SubRedditSample struct {
+	Time           time.Time
+	Members        int
+	Posts          int
+	PostsPerMinute float64
+	VotesPerMinute float64
+}
+
+type subRedditCounters struct {
+	time  time.Time
+	posts int
+	votes int
+}
+
+// SampleSubReddits appends one sample per subreddit, computing rates from the
+// previous sample.
+func (e *Engine) SampleSubReddits() {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	now := e.Clock.Now()
+	for name, subReddit := range e.SubReddits {
+		sample := SubRedditSample{Time: now, Members: len(subReddit.Users), Posts: subReddit.TotalPosts}
+		if prev, exists := e.lastSamples[name]; exists {
+			if minutes := now.Sub(prev.time).Minutes(); minutes > 0 {
+				sample.PostsPerMinute = float64(subReddit.TotalPosts-prev.posts) / minutes
+				sample.VotesPerMinute = float64(subReddit.TotalVotes-prev.votes) / minutes
+			}
+		}
+		e.lastSamples[name] = subRedditCounters{time: now, posts: subReddit.TotalPosts, votes: subReddit.TotalVotes}
+		e.TimeSeries[name] = append(e.TimeSeries[name], sample)
+	}
+}
+
+// StartSubRedditSampler samples every interval until the returned stop
+// function is called.
+func (e *Engine) StartSubRedditSampler(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				e.SampleSubReddits()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// GetSubRedditTimeSeries returns the subreddit's samples, oldest first.
+func (e *Engine) GetSubRedditTimeSeries(name string) []SubRedditSample {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return append([]SubRedditSample(nil), e.TimeSeries[name]...)
+}
@@ -0,0 +1,36 @@
+package engine
+
+// Topics and Interest Profiles
+
+// SetSubRedditTopics replaces the topics the subreddit is about.
+func (e *Engine) SetSubRedditTopics(subRedditName string, topics ...string) error {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return ErrSubRedditNotFound
+	}
+	subReddit.Topics = append([]string(nil), topics...)
+	return nil
+}
+
+// SetInterestProfile replaces a user's declared topic weights.
+func (e *Engine) SetInterestProfile(user *User, profile map[string]float64) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	user.InterestProfile = make(map[string]float64, len(profile))
+	for topic, weight := range profile {
+		user.InterestProfile[topic] = weight
+	}
+}
+
+// TopicAffinity is the user's strongest declared interest among the topics.
+func TopicAffinity(profile map[string]float64, topics []string) float64 {
+	best := 0.0
+	for _, topic := range topics {
+		if weight := profile[topic]; weight > best {
+			best = weight
+		}
+	}
+	return best
+}
@@ -0,0 +1,365 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Request Tracing
+
+const (
+	tracerBuffer        = 4096
+	tracerBatchSize     = 512
+	tracerFlushInterval = time.Second
+	tracerServiceName   = "reddit-clone"
+)
+
+// OTLP span kinds and status codes.
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanStatusError  = 2
+)
+
+// SpanContext identifies a span so others can be parented to it.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+func (sc SpanContext) valid() bool {
+	return sc.TraceID != [16]byte{}
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying sc, so engine actions
+// given it, through their Context variants, are traced as sc's children.
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanFromContext returns the span context ctx carries, or the zero
+// SpanContext if it carries none.
+func SpanFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Span is one timed operation. Spans are exported when ended; a nil *Span
+// is a no-op so callers needn't check whether tracing is on.
+type Span struct {
+	tracer     *Tracer
+	context    SpanContext
+	parent     [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	failed     string
+}
+
+// Tracer exports spans to an OTLP/HTTP collector as JSON. Ended spans are
+// buffered and sent in batches from a background goroutine; spans that
+// don't fit in the buffer are dropped and counted, so tracing never blocks
+// the engine. Spans ended after Close are dropped too.
+type Tracer struct {
+	endpoint string
+	client   *http.Client
+	mu       sync.RWMutex
+	closed   bool
+	spans    chan *Span
+	done     sync.WaitGroup
+	Exported int64
+	Dropped  int64
+	Failed   int64
+}
+
+// NewTracer starts a tracer exporting to the collector at endpoint, such as
+// "http://localhost:4318". Close flushes it.
+func NewTracer(endpoint string) *Tracer {
+	t := &Tracer{endpoint: endpoint + "/v1/traces", client: &http.Client{Timeout: 5 * time.Second}, spans: make(chan *Span, tracerBuffer)}
+	t.done.Add(1)
+	go t.run()
+	return t
+}
+
+// Start begins a span, as a child of parent if it is valid or as the root
+// of a new trace otherwise.
+func (t *Tracer) Start(name string, parent SpanContext) *Span {
+	if t == nil {
+		return nil
+	}
+	span := &Span{tracer: t, name: name, kind: spanKindInternal, start: time.Now(), attributes: make(map[string]interface{})}
+	if parent.valid() {
+		span.context.TraceID, span.parent = parent.TraceID, parent.SpanID
+	} else {
+		putRandom(span.context.TraceID[:])
+	}
+	putRandom(span.context.SpanID[:])
+	return span
+}
+
+func putRandom(id []byte) {
+	for i := range id {
+		id[i] = byte(rand.Intn(256))
+	}
+}
+
+// Context identifies the span for propagation to child spans.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttribute records a string, integer or boolean attribute.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s != nil {
+		s.attributes[key] = value
+	}
+}
+
+// Fail marks the span as errored.
+func (s *Span) Fail(message string) {
+	if s != nil {
+		s.failed = message
+	}
+}
+
+// End records the span's duration and hands it to the tracer.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	t := s.tracer
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		atomic.AddInt64(&t.Dropped, 1)
+		return
+	}
+	select {
+	case t.spans <- s:
+	default:
+		atomic.AddInt64(&t.Dropped, 1)
+	}
+}
+
+// Close exports every buffered span and stops the tracer.
+func (t *Tracer) Close() {
+	t.mu.Lock()
+	t.closed = true
+	close(t.spans)
+	t.mu.Unlock()
+	t.done.Wait()
+}
+
+func (t *Tracer) run() {
+	defer t.done.Done()
+	ticker := time.NewTicker(tracerFlushInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			atomic.AddInt64(&t.Failed, 1)
+			atomic.AddInt64(&t.Dropped, int64(len(batch)))
+		} else {
+			atomic.AddInt64(&t.Exported, int64(len(batch)))
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= tracerBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// OTLP/JSON request shapes, per the OTLP protobuf JSON mapping.
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func otlpValue(value interface{}) map[string]interface{} {
+	switch value := value.(type) {
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(value)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case bool:
+		return map[string]interface{}{"boolValue": value}
+	case string:
+		return map[string]interface{}{"stringValue": value}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+}
+
+func (t *Tracer) export(batch []*Span) error {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		encoded := otlpSpan{
+			TraceID:           hex.EncodeToString(span.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.context.SpanID[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		}
+		if span.parent != [8]byte{} {
+			encoded.ParentSpanID = hex.EncodeToString(span.parent[:])
+		}
+		for key, value := range span.attributes {
+			encoded.Attributes = append(encoded.Attributes, otlpAttribute{Key: key, Value: otlpValue(value)})
+		}
+		if span.failed != "" {
+			encoded.Status = &otlpStatus{Code: spanStatusError, Message: span.failed}
+		}
+		spans = append(spans, encoded)
+	}
+	request := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttribute{{Key: "service.name", Value: otlpValue(tracerServiceName)}},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": tracerServiceName},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	response, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("otlp export: %s", response.Status)
+	}
+	return nil
+}
+
+// EnableTracing makes every engine action that records an event emit a
+// span timed from when it took the engine lock, with the action type, user,
+// subreddit and target as attributes. Actions called through their Context
+// variants, such as CreatePostContext, are children of the span their
+// context carries, so an HTTP request's span covers the actions it caused;
+// the rest start traces of their own. It must be called before the engine
+// is shared between goroutines.
+func (e *Engine) EnableTracing(tracer *Tracer) {
+	e.Mutex.tracer = tracer
+}
+
+// traceEvent emits the span for an event. Callers must hold e.Mutex.
+func (e *Engine) traceEvent(event Event) {
+	tracer := e.Mutex.tracer
+	if tracer == nil {
+		return
+	}
+	span := tracer.Start("engine."+event.Type, e.Mutex.parent)
+	span.start = e.Mutex.acquired
+	span.SetAttribute("action.type", event.Type)
+	if event.UserID != 0 {
+		span.SetAttribute("user.id", event.UserID)
+	}
+	if event.SubReddit != "" {
+		span.SetAttribute("subreddit", event.SubReddit)
+	}
+	if event.TargetID != 0 {
+		span.SetAttribute("target.id", event.TargetID)
+	}
+	span.End()
+}
+
+// Middleware wraps an HTTP handler so each request gets a server span,
+// continuing the caller's trace when the request carries a W3C traceparent
+// header. The span rides in the request's context for handlers to pass to
+// engine actions.
+func (t *Tracer) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := t.Start(r.Method+" "+r.URL.Path, parseTraceparent(r.Header.Get("traceparent")))
+		span.kind = spanKindServer
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("url.path", r.URL.Path)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r.WithContext(ContextWithSpan(r.Context(), span.Context())))
+		span.SetAttribute("http.response.status_code", recorder.status)
+		if recorder.status >= 500 {
+			span.Fail(http.StatusText(recorder.status))
+		}
+		span.End()
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// parseTraceparent reads a "00-<trace id>-<span id>-<flags>" header,
+// returning the zero SpanContext if it is missing or malformed.
+func parseTraceparent(header string) SpanContext {
+	var sc SpanContext
+	if len(header) != 55 || header[:3] != "00-" || header[35] != '-' || header[52] != '-' {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(header[3:35])); err != nil {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(header[36:52])); err != nil {
+		return SpanContext{}
+	}
+	return sc
+}
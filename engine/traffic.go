@@ -0,0 +1,88 @@
+package engine
+
+import "sort"
+
+// SubReddit Traffic
+
+const trafficDateLayout = "2006-01-02"
+
+// TrafficDay is one day of a subreddit's traffic stats. Posters,
+// Commenters and Voters count distinct users.
+type TrafficDay struct {
+	Date            string
+	Uniques         int
+	Pageviews       int
+	Subscriptions   int
+	Unsubscriptions int
+	Posters         int
+	Commenters      int
+	Voters          int
+}
+
+type trafficDay struct {
+	TrafficDay
+	visitors     map[int64]bool
+	contributors [3]map[int64]bool
+}
+
+// trafficToday returns today's traffic bucket for the subreddit, creating it
+// on first use. Callers must hold e.Mutex for writing, or its read lock and
+// subReddit.mu.
+func (e *Engine) trafficToday(subReddit *SubReddit) *trafficDay {
+	date := e.Clock.Now().Format(trafficDateLayout)
+	day, exists := subReddit.Traffic[date]
+	if !exists {
+		day = &trafficDay{TrafficDay: TrafficDay{Date: date}, visitors: make(map[int64]bool)}
+		for kind := range day.contributors {
+			day.contributors[kind] = make(map[int64]bool)
+		}
+		subReddit.Traffic[date] = day
+	}
+	return day
+}
+
+// GetSubRedditFeed returns a subreddit's own listing and counts the view
+// toward its traffic stats. Minors get it without NSFW posts, so an NSFW
+// subreddit's listing is empty for them.
+func (e *Engine) GetSubRedditFeed(user *User, subRedditName string) ([]*Post, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	subReddit.mu.Lock()
+	day := e.trafficToday(subReddit)
+	day.Pageviews++
+	if !day.visitors[user.ID] {
+		day.visitors[user.ID] = true
+		day.Uniques++
+	}
+	subReddit.mu.Unlock()
+	feed := make([]*Post, 0, len(subReddit.Posts))
+	for _, post := range subReddit.Posts {
+		if e.inFeeds(post) && !e.ageGated(user, post) {
+			feed = append(feed, post)
+		}
+	}
+	return feed, nil
+}
+
+// GetTrafficStats returns the subreddit's traffic per simulated day, oldest
+// first, like Reddit's moderator traffic page.
+func (e *Engine) GetTrafficStats(subRedditName string) ([]TrafficDay, error) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	if !exists {
+		return nil, ErrSubRedditNotFound
+	}
+	subReddit.mu.Lock()
+	days := make([]TrafficDay, 0, len(subReddit.Traffic))
+	for _, day := range subReddit.Traffic {
+		days = append(days, day.TrafficDay)
+	}
+	subReddit.mu.Unlock()
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
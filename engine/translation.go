@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Translation
+
+var ErrNoTranslator = errors.New("no translator configured")
+
+// Translator translates content into the language lang.
+type Translator interface {
+	Translate(content, lang string) (string, error)
+}
+
+// MockTranslator tags content with the target language instead of calling a
+// real translation service.
+type MockTranslator struct{}
+
+func (MockTranslator) Translate(content, lang string) (string, error) {
+	return fmt.Sprintf("[%s] %s", lang, content), nil
+}
+
+type translationKey struct {
+	Content string
+	Lang    string
+}
+
+// SetTranslator sets the translator TranslateContent and TranslateComment
+// use.
+func (e *Engine) SetTranslator(translator Translator) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	e.Translator = translator
+}
+
+// TranslateContent translates the post's content into lang.
+func (e *Engine) TranslateContent(post *Post, lang string) (string, error) {
+	return e.translate(post.Content, lang)
+}
+
+// TranslateComment translates the comment's content into lang.
+func (e *Engine) TranslateComment(comment *Comment, lang string) (string, error) {
+	return e.translate(comment.Content(), lang)
+}
+
+// translate consults the per-(content, lang) cache and only calls the
+// translator on a miss. The translator runs without the engine lock held.
+func (e *Engine) translate(content, lang string) (string, error) {
+	key := translationKey{Content: content, Lang: lang}
+	e.Mutex.Lock()
+	translator := e.Translator
+	if translated, cached := e.TranslationCache[key]; cached {
+		e.TranslationHits++
+		e.Mutex.Unlock()
+		return translated, nil
+	}
+	e.TranslationMisses++
+	e.Mutex.Unlock()
+
+	if translator == nil {
+		return "", ErrNoTranslator
+	}
+	translated, err := translator.Translate(content, lang)
+	if err != nil {
+		return "", err
+	}
+
+	e.Mutex.Lock()
+	e.TranslationCache[key] = translated
+	e.Mutex.Unlock()
+	return translated, nil
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// Trophies
+
+// Trophy keys. Each trophy is awarded to a user at most once.
+const (
+	TrophyFirstPost    = "first_post"
+	TrophyPopularPost  = "popular_post"
+	TrophyOneYearClub  = "one_year_club"
+	TrophyBigModerator = "big_moderator"
+)
+
+const (
+	popularPostUpvotes  = 100
+	bigCommunityMembers = 1000
+)
+
+// trophyNames are the trophies in the order profiles list them.
+var trophyNames = []struct{ key, name string }{
+	{TrophyFirstPost, "First Post"},
+	{TrophyPopularPost, fmt.Sprintf("%d Upvotes", popularPostUpvotes)},
+	{TrophyOneYearClub, "One-Year Club"},
+	{TrophyBigModerator, fmt.Sprintf("Moderator of a %d-Member Community", bigCommunityMembers)},
+}
+
+func trophyName(key string) string {
+	for _, trophy := range trophyNames {
+		if trophy.key == key {
+			return trophy.name
+		}
+	}
+	return key
+}
+
+// Trophy is an achievement shown on a user's profile: their first post, a
+// post reaching 100 upvotes, their first cake day, or moderating a
+// community of 1000 members.
+type Trophy struct {
+	Key       string
+	Name      string
+	AwardedAt time.Time
+}
+
+// awardTrophy gives user the trophy and notifies them, unless they already
+// have it. Callers must hold e.Mutex.
+func (e *Engine) awardTrophy(user *User, key string) {
+	for _, trophy := range e.Trophies[user.ID] {
+		if trophy.Key == key {
+			return
+		}
+	}
+	trophy := Trophy{Key: key, Name: trophyName(key), AwardedAt: e.Clock.Now()}
+	e.Trophies[user.ID] = append(e.Trophies[user.ID], trophy)
+	e.notify(user, "trophy", fmt.Sprintf("You earned the %s trophy!", trophy.Name))
+}
+
+// checkBigCommunity awards the big-community trophy to the subreddit's
+// moderators once it has enough members. It must be called after members
+// or moderators are added. Callers must hold e.Mutex.
+func (e *Engine) checkBigCommunity(subReddit *SubReddit) {
+	if len(subReddit.Users) < bigCommunityMembers {
+		return
+	}
+	for _, mod := range subReddit.Moderators {
+		e.awardTrophy(mod, TrophyBigModerator)
+	}
+}
+
+// GetTrophies returns the user's trophies in the order they were awarded.
+func (e *Engine) GetTrophies(user *User) []Trophy {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return append([]Trophy(nil), e.Trophies[user.ID]...)
+}
+
+// TrophyCount is how many active users hold a trophy and what share of
+// them that is.
+type TrophyCount struct {
+	Key   string
+	Name  string
+	Users int
+	Share float64
+}
+
+// GetTrophyDistribution counts the holders of every trophy, including those
+// nobody holds yet.
+func (e *Engine) GetTrophyDistribution() []TrophyCount {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	holders := make(map[string]int, len(trophyNames))
+	active := 0
+	for _, user := range e.Users {
+		if user.MergedInto != 0 {
+			continue
+		}
+		active++
+		for _, trophy := range e.Trophies[user.ID] {
+			holders[trophy.Key]++
+		}
+	}
+	counts := make([]TrophyCount, 0, len(trophyNames))
+	for _, trophy := range trophyNames {
+		count := TrophyCount{Key: trophy.key, Name: trophy.name, Users: holders[trophy.key]}
+		if active > 0 {
+			count.Share = float64(count.Users) / float64(active)
+		}
+		counts = append(counts, count)
+	}
+	return counts
+}
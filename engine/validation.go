@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Input Validation
+
+const (
+	maxTitleLength   = 300
+	maxPostLength    = 40000
+	maxCommentLength = 10000
+	maxMessageLength = 10000
+)
+
+var (
+	ErrContentTooLong = errors.New("content is too long")
+	ErrInvalidUTF8    = errors.New("content is not valid UTF-8")
+)
+
+// ValidationError is returned when user-supplied text is rejected. Field
+// names what was being submitted ("post", "comment", "message", ...) and the
+// error matches ErrEmptyContent, ErrContentTooLong or ErrInvalidUTF8 with
+// errors.Is.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Field, err.Err)
+}
+
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+// validateContent is the single gate every content-accepting method passes
+// text through. It rejects invalid UTF-8, strips control characters other
+// than newlines and tabs, and then rejects text that is blank or longer than
+// maxLength runes. Rejections are counted per field and reason. Callers must
+// hold e.Mutex.
+func (e *Engine) validateContent(field, content string, maxLength int) (string, error) {
+	var reason error
+	if !utf8.ValidString(content) {
+		reason = ErrInvalidUTF8
+	} else {
+		content = stripControlCharacters(content)
+		if strings.TrimSpace(content) == "" {
+			reason = ErrEmptyContent
+		} else if utf8.RuneCountInString(content) > maxLength {
+			reason = ErrContentTooLong
+		}
+	}
+	if reason != nil {
+		e.ValidationRejects[field+": "+reason.Error()]++
+		return "", &ValidationError{Field: field, Err: reason}
+	}
+	return content, nil
+}
+
+func stripControlCharacters(content string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, content)
+}
+
+// GetValidationRejects counts rejected content by validation reason.
+func (e *Engine) GetValidationRejects() map[string]int {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	return copyCounts(e.ValidationRejects)
+}
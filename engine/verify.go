@@ -0,0 +1,347 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Object Graph Verification
+
+// IntegrityError is returned by Verify with every violation it found.
+type IntegrityError struct {
+	Violations []string
+}
+
+func (err *IntegrityError) Error() string {
+	return fmt.Sprintf("%d integrity violations: %s", len(err.Violations), strings.Join(err.Violations, "; "))
+}
+
+// Verify checks the whole engine object graph: everything CheckInvariants
+// does, plus that every post, comment, message and membership points at
+// registered users, comment parents and post IDs agree with the trees they
+// index, post and comment scores match their voters' standing votes,
+// per-subreddit and global counters match a recount of hot and archived
+// content, and no side index refers to content that doesn't exist. It
+// returns nil or an *IntegrityError. Archived posts are loaded from cold
+// storage, so Verify is slow on large engines.
+func (e *Engine) Verify() error {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	violations := append(e.checkInvariants(), e.checkObjectGraph()...)
+	if len(violations) > 0 {
+		return &IntegrityError{Violations: violations}
+	}
+	return nil
+}
+
+// checkObjectGraph returns the violations Verify adds to checkInvariants.
+// Callers must hold e.Mutex.
+func (e *Engine) checkObjectGraph() []string {
+	var violations []string
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+	registered := func(user *User) bool {
+		return user != nil && e.Users[user.ID] == user
+	}
+
+	for id, user := range e.Users {
+		if user.ID != id {
+			fail("user %d is stored under ID %d", user.ID, id)
+		}
+		if id <= e.IDOffset || id >= e.UserID {
+			fail("user %d is outside the allocated ID range", id)
+		}
+		if user.MergedInto == id {
+			fail("user %d is merged into itself", id)
+		} else if user.MergedInto != 0 && e.Users[user.MergedInto] == nil {
+			fail("user %d is merged into unknown user %d", id, user.MergedInto)
+		}
+	}
+	for name, id := range e.Usernames {
+		if e.Users[id] == nil {
+			fail("username %q resolves to unknown user %d", name, id)
+		}
+	}
+
+	posts := make(map[int64]*Post)
+	comments := make(map[int64]*Comment)
+	hotPosts, hotComments := 0, 0
+	var walk func(post *Post, parentID int64, level []*Comment, archived bool)
+	walk = func(post *Post, parentID int64, level []*Comment, archived bool) {
+		for _, comment := range level {
+			if comments[comment.ID] != nil {
+				fail("comment %d appears twice", comment.ID)
+			}
+			comments[comment.ID] = comment
+			if comment.ID <= e.IDOffset || comment.ID >= e.CommentID {
+				fail("comment %d is outside the allocated ID range", comment.ID)
+			}
+			if !registered(comment.Author) {
+				fail("comment %d has an unregistered author", comment.ID)
+			}
+			if comment.PostID != post.ID || comment.SubReddit != post.SubReddit {
+				fail("comment %d claims post %d in %s but is on post %d in %s", comment.ID, comment.PostID, comment.SubReddit, post.ID, post.SubReddit)
+			}
+			if !archived {
+				hotComments++
+				if e.commentIndex[comment.ID] != comment {
+					fail("comment %d is missing from the comment lookup index", comment.ID)
+				}
+			}
+			if recorded, indexed := e.CommentParents[comment.ID]; !indexed || recorded != parentID {
+				fail("comment %d has indexed parent %d, want %d", comment.ID, recorded, parentID)
+			}
+			if _, removed := e.RemovedComments[comment.ID]; !archived && removed != comment.Removed {
+				fail("comment %d has Removed %v but removal index says %v", comment.ID, comment.Removed, removed)
+			}
+			if _, deleted := e.DeletedComments[comment.ID]; !archived && deleted != comment.Deleted {
+				fail("comment %d has Deleted %v but deletion index says %v", comment.ID, comment.Deleted, deleted)
+			}
+			if comment.Deleted && comment.Content() != "" {
+				fail("deleted comment %d still has content", comment.ID)
+			}
+			if root := comment.Votes < e.CollapseThreshold; root != e.CollapseRoots[comment.ID] {
+				fail("comment %d with score %d has collapse root %v", comment.ID, comment.Votes, e.CollapseRoots[comment.ID])
+			}
+			if want := e.CollapseRoots[comment.ID] || e.ancestorCollapsed(comment.ID); comment.Collapsed != want {
+				fail("comment %d has Collapsed %v, want %v", comment.ID, comment.Collapsed, want)
+			}
+			tally := 0
+			for voterID, direction := range e.CommentVotes[comment.ID] {
+				if e.Users[voterID] == nil || e.Users[voterID].MergedInto != 0 {
+					fail("comment %d holds a vote by %d, who can't vote", comment.ID, voterID)
+				}
+				tally += direction
+			}
+			if tally != comment.Votes {
+				fail("comment %d has %d votes but its voters' votes add up to %d", comment.ID, comment.Votes, tally)
+			}
+			walk(post, comment.ID, comment.Replies, archived)
+		}
+	}
+	addPost := func(name string, post *Post, archived bool) {
+		if posts[post.ID] != nil {
+			fail("post %d appears twice", post.ID)
+		}
+		posts[post.ID] = post
+		if post.ID <= e.IDOffset || post.ID >= e.PostID {
+			fail("post %d is outside the allocated ID range", post.ID)
+		}
+		if !registered(post.Author) {
+			fail("post %d has an unregistered author", post.ID)
+		}
+		if post.SubReddit != name {
+			fail("post %d in %s claims subreddit %s", post.ID, name, post.SubReddit)
+		}
+		if !archived {
+			hotPosts++
+			if e.postIndex[post.ID] != post {
+				fail("post %d is missing from the post lookup index", post.ID)
+			}
+		}
+		if _, removed := e.RemovedPosts[post.ID]; !archived && removed != post.Removed {
+			fail("post %d has Removed %v but removal index says %v", post.ID, post.Removed, removed)
+		}
+		if _, deleted := e.DeletedPosts[post.ID]; !archived && deleted != post.Deleted {
+			fail("post %d has Deleted %v but deletion index says %v", post.ID, post.Deleted, deleted)
+		}
+		tally, upvotes, downvotes := 0, 0, 0
+		for voterID, vote := range e.PostVotes[post.ID] {
+			if e.Users[voterID] == nil || e.Users[voterID].MergedInto != 0 {
+				fail("post %d holds a vote by %d, who can't vote", post.ID, voterID)
+			}
+			tally += vote.Direction
+			if vote.Direction > 0 {
+				upvotes++
+			} else {
+				downvotes++
+			}
+		}
+		if tally != post.Votes {
+			fail("post %d has %d votes but its voters' votes add up to %d", post.ID, post.Votes, tally)
+		}
+		if !archived && (upvotes != post.Upvotes || downvotes != post.Downvotes) {
+			fail("post %d counts %d up and %d down but holds %d up and %d down", post.ID, post.Upvotes, post.Downvotes, upvotes, downvotes)
+		}
+		walk(post, 0, post.Comments, archived)
+	}
+
+	subRedditPosts := make(map[string]int)
+	for name, subReddit := range e.SubReddits {
+		if subReddit.Name != name {
+			fail("subreddit %s is stored under %s", subReddit.Name, name)
+		}
+		for id, mod := range subReddit.Moderators {
+			if e.Users[id] != mod {
+				fail("%s has moderator %d that isn't a registered user", name, id)
+			}
+		}
+		for id := range subReddit.DefaultMembers {
+			if _, member := subReddit.Users[id]; !member {
+				fail("%s marks %d as a default member but they aren't a member", name, id)
+			}
+		}
+		for id := range subReddit.CommentKarma {
+			if e.Users[id] == nil {
+				fail("%s has comment karma for unknown user %d", name, id)
+			}
+		}
+		for _, post := range subReddit.Posts {
+			addPost(name, post, false)
+			subRedditPosts[name]++
+		}
+		queued := make(map[int64]bool, len(subReddit.ApprovalQueue))
+		for _, post := range subReddit.ApprovalQueue {
+			queued[post.ID] = true
+			if posts[post.ID] != post || !post.Pending {
+				fail("%s approval queue holds post %d that isn't a pending post there", name, post.ID)
+			}
+		}
+		embargoed := make(map[int64]bool, len(subReddit.Embargoed))
+		for _, post := range subReddit.Embargoed {
+			embargoed[post.ID] = true
+			if posts[post.ID] != post || !post.Embargoed {
+				fail("%s embargo holds post %d that isn't an embargoed post there", name, post.ID)
+			}
+		}
+		for _, post := range subReddit.Posts {
+			if post.Pending && !queued[post.ID] {
+				fail("pending post %d is missing from the %s approval queue", post.ID, name)
+			}
+			if post.Embargoed && !embargoed[post.ID] {
+				fail("embargoed post %d is missing from the %s embargo", post.ID, name)
+			}
+			if post.Embargoed && post.Pending {
+				fail("post %d is both embargoed and awaiting approval", post.ID)
+			}
+		}
+		if len(subReddit.Embargoed) > 0 && subReddit.UnlockAt.IsZero() {
+			fail("%s holds embargoed posts but has no unlock scheduled", name)
+		}
+	}
+	if e.ColdStore != nil {
+		for _, id := range e.ColdStore.ids() {
+			post, err := e.archivedPost(id)
+			if err != nil {
+				fail("archived post %d can't be loaded: %v", id, err)
+				continue
+			}
+			addPost(post.SubReddit, post, true)
+			subRedditPosts[post.SubReddit]++
+		}
+	}
+	for name, subReddit := range e.SubReddits {
+		if subRedditPosts[name] != subReddit.TotalPosts {
+			fail("%s counts %d posts but holds %d hot and archived", name, subReddit.TotalPosts, subRedditPosts[name])
+		}
+	}
+	if len(posts) != e.TotalPosts {
+		fail("TotalPosts %d != %d hot and archived posts", e.TotalPosts, len(posts))
+	}
+	if len(comments) != e.TotalComments {
+		fail("TotalComments %d != %d comments in post trees", e.TotalComments, len(comments))
+	}
+	if len(e.postIndex) != hotPosts || len(e.commentIndex) != hotComments {
+		fail("lookup indices hold %d posts and %d comments, want %d and %d hot", len(e.postIndex), len(e.commentIndex), hotPosts, hotComments)
+	}
+
+	for i, message := range e.Messages {
+		if !registered(message.From) || !registered(message.To) {
+			fail("message %d is between unregistered users", i)
+		}
+	}
+	for recipientID, requests := range e.DMRequests {
+		for senderID, request := range requests {
+			if !registered(request.From) || !registered(request.To) || request.From.ID != senderID || request.To.ID != recipientID {
+				fail("message request indexed from %d to %d is between other or unregistered users", senderID, recipientID)
+			}
+		}
+	}
+	for follower, followees := range e.Follows {
+		for followee := range followees {
+			if e.Users[follower] == nil || e.Users[followee] == nil || follower == followee {
+				fail("follow from %d to %d is between unknown users or a user and themselves", follower, followee)
+			}
+		}
+	}
+
+	knownComment := func(index string, id int64) {
+		if comments[id] == nil {
+			fail("%s index has unknown comment %d", index, id)
+		}
+	}
+	for id := range e.CommentParents {
+		knownComment("comment parent", id)
+	}
+	for id := range e.RemovedComments {
+		knownComment("removed comment", id)
+	}
+	for id := range e.DeletedComments {
+		knownComment("deleted comment", id)
+	}
+	for id := range e.EditedComments {
+		knownComment("edited comment", id)
+	}
+	for id := range e.CommentReactions {
+		knownComment("reaction", id)
+	}
+	for id := range e.BranchScores {
+		knownComment("branch score", id)
+	}
+	knownPost := func(index string, id int64) {
+		if posts[id] == nil {
+			fail("%s index has unknown post %d", index, id)
+		}
+	}
+	for id := range e.RemovedPosts {
+		knownPost("removed post", id)
+	}
+	for id := range e.DeletedPosts {
+		knownPost("deleted post", id)
+	}
+	for id := range e.CommentSorts {
+		knownPost("comment sort", id)
+	}
+	for id := range e.Promotions {
+		knownPost("promotion", id)
+	}
+	for id := range e.postVelocity {
+		knownPost("velocity", id)
+	}
+	for postID, commentID := range e.StickyComments {
+		comment := comments[commentID]
+		if posts[postID] == nil || comment == nil || comment.PostID != postID || e.CommentParents[commentID] != 0 || comment.Removed || comment.Deleted {
+			fail("post %d has invalid stickied comment %d", postID, commentID)
+		}
+	}
+	knownUser := func(index string, id int64) {
+		if e.Users[id] == nil {
+			fail("%s index has unknown user %d", index, id)
+		}
+	}
+	for id := range e.Notifications {
+		knownUser("notification", id)
+	}
+	backlog := 0
+	for id, pending := range e.PendingNotifications {
+		knownUser("pending notification", id)
+		backlog += len(pending)
+	}
+	if backlog != e.notificationBacklog {
+		fail("notification backlog is %d, but %d notifications are pending", e.notificationBacklog, backlog)
+	}
+	for id := range e.Interests {
+		knownUser("interest", id)
+	}
+	for id := range e.Mutes {
+		knownUser("mute", id)
+	}
+	for id := range e.Milestones {
+		knownUser("milestone", id)
+	}
+	for id := range e.Trophies {
+		knownUser("trophy", id)
+	}
+	return violations
+}
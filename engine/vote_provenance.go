@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Vote Provenance
+
+var ErrInvalidVote = errors.New("vote must be +1 or -1")
+
+const (
+	// Votes count log10(1+karma)/fullWeightKarmaDigits, so voters with
+	// 99 karma count fully, clamped below so new accounts still count a
+	// little.
+	fullWeightKarmaDigits = 2.0
+	minVoterWeight        = 0.05
+)
+
+// VoteRecord is one attributed vote. Source is where the voter came from,
+// such as the subreddit that linked them to the post; it is "" for votes
+// cast while browsing normally.
+type VoteRecord struct {
+	VoterID int64
+	PostID  int64
+	Delta   int
+	Source  string
+	At      time.Time
+}
+
+// CastPostVote sets voter's vote on post to delta, like UpvotePost and
+// DownvotePost, and records its provenance.
+func (e *Engine) CastPostVote(voter *User, post *Post, delta int, source string) error {
+	return e.CastPostVoteContext(context.Background(), voter, post, delta, source)
+}
+
+// CastPostVoteContext is CastPostVote, traced as a child of the span in ctx.
+func (e *Engine) CastPostVoteContext(ctx context.Context, voter *User, post *Post, delta int, source string) error {
+	e.Mutex.lockContext(ctx)
+	defer e.Mutex.Unlock()
+	if delta != 1 && delta != -1 {
+		return ErrInvalidVote
+	}
+	if err := e.setPostVote(voter, post, delta); err != nil {
+		return err
+	}
+	e.VoteProvenance = append(e.VoteProvenance, VoteRecord{VoterID: voter.ID, PostID: post.ID, Delta: delta, Source: source, At: e.Clock.Now()})
+	return nil
+}
+
+// GetVoteProvenance returns the attributed votes on a post, oldest first.
+func (e *Engine) GetVoteProvenance(post *Post) []VoteRecord {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	var records []VoteRecord
+	for _, record := range e.VoteProvenance {
+		if record.PostID == post.ID {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// voterWeight is how much a vote by voter counts toward KarmaWeightedVotes.
+func voterWeight(voter *User) float64 {
+	weight := math.Log10(1+math.Max(float64(voter.Karma()), 0)) / fullWeightKarmaDigits
+	return math.Min(1, math.Max(minVoterWeight, weight))
+}
@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Vote Change Feed
+
+// VoteDelta is one change to a post or comment score.
+type VoteDelta struct {
+	Seq       int64
+	Time      time.Time
+	Kind      string
+	SubReddit string
+	TargetID  int64
+	Delta     int
+}
+
+// VoteBatch is a run of consecutive deltas. Acknowledge it with its
+// LastSeq.
+type VoteBatch struct {
+	FirstSeq int64
+	LastSeq  int64
+	Deltas   []VoteDelta
+}
+
+// VoteStreamOptions configures a VoteStream.
+type VoteStreamOptions struct {
+	BatchSize      int
+	FlushInterval  time.Duration
+	RedeliverAfter time.Duration
+}
+
+var DefaultVoteStreamOptions = VoteStreamOptions{BatchSize: 64, FlushInterval: 10 * time.Millisecond, RedeliverAfter: 200 * time.Millisecond}
+
+// VoteStream delivers vote deltas in sequence-numbered batches with
+// at-least-once semantics: deltas stay pending until acknowledged, and if a
+// batch isn't acknowledged within RedeliverAfter everything pending is sent
+// again. Consumers should skip deltas whose Seq they have already applied.
+type VoteStream struct {
+	C <-chan VoteBatch
+
+	out      chan VoteBatch
+	opts     VoteStreamOptions
+	wake     chan struct{}
+	done     chan struct{}
+	finished chan struct{}
+
+	mu          sync.Mutex
+	pending     []VoteDelta
+	sent        int
+	lastSend    time.Time
+	closed      bool
+	published   int64
+	batches     int64
+	redelivered int64
+}
+
+// VoteStreamStats counts a VoteStream's deliveries.
+type VoteStreamStats struct {
+	Published   int64
+	Batches     int64
+	Redelivered int64
+	Pending     int
+}
+
+// SubscribeVotes starts a vote stream that sees every vote cast from now on.
+func (e *Engine) SubscribeVotes(opts VoteStreamOptions) *VoteStream {
+	out := make(chan VoteBatch)
+	stream := &VoteStream{
+		C:        out,
+		out:      out,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	e.Mutex.Lock()
+	e.voteStreams = append(e.voteStreams, stream)
+	e.Mutex.Unlock()
+	go stream.run()
+	return stream
+}
+
+// publishVote assigns the next vote sequence number and queues the delta on
+// every open stream. Callers must hold e.Mutex.
+func (e *Engine) publishVote(kind, subRedditName string, targetID int64, delta int) {
+	if len(e.voteStreams) == 0 {
+		return
+	}
+	e.VoteSeq++
+	vote := VoteDelta{Seq: e.VoteSeq, Time: e.Clock.Now(), Kind: kind, SubReddit: subRedditName, TargetID: targetID, Delta: delta}
+	open := e.voteStreams[:0]
+	for _, stream := range e.voteStreams {
+		if stream.enqueue(vote) {
+			open = append(open, stream)
+		}
+	}
+	clear(e.voteStreams[len(open):])
+	e.voteStreams = open
+}
+
+func (s *VoteStream) enqueue(vote VoteDelta) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.pending = append(s.pending, vote)
+	s.published++
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (s *VoteStream) run() {
+	defer close(s.finished)
+	defer close(s.out)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		flush := false
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		case <-ticker.C:
+			flush = true
+		}
+		for {
+			batch, ok := s.nextBatch(flush)
+			if !ok {
+				break
+			}
+			select {
+			case s.out <- batch:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// nextBatch returns the next unsent batch, rewinding to the oldest
+// unacknowledged delta once the redelivery deadline passes. Partial batches
+// are only sent on a flush.
+func (s *VoteStream) nextBatch(flush bool) (VoteBatch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.sent > 0 && now.Sub(s.lastSend) >= s.opts.RedeliverAfter {
+		s.sent = 0
+		s.redelivered++
+	}
+	unsent := s.pending[s.sent:]
+	if len(unsent) == 0 || (len(unsent) < s.opts.BatchSize && !flush) {
+		return VoteBatch{}, false
+	}
+	n := min(s.opts.BatchSize, len(unsent))
+	deltas := append([]VoteDelta(nil), unsent[:n]...)
+	s.sent += n
+	s.lastSend = now
+	s.batches++
+	return VoteBatch{FirstSeq: deltas[0].Seq, LastSeq: deltas[n-1].Seq, Deltas: deltas}, true
+}
+
+// Ack acknowledges every delta up to and including seq.
+func (s *VoteStream) Ack(seq int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acked := 0
+	for acked < len(s.pending) && s.pending[acked].Seq <= seq {
+		acked++
+	}
+	s.pending = append(s.pending[:0], s.pending[acked:]...)
+	s.sent = max(0, s.sent-acked)
+}
+
+// Close stops delivery and closes C. Unacknowledged deltas are discarded.
+func (s *VoteStream) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+	close(s.done)
+	<-s.finished
+}
+
+// Stats reports the stream's deliveries so far.
+func (s *VoteStream) Stats() VoteStreamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return VoteStreamStats{Published: s.published, Batches: s.batches, Redelivered: s.redelivered, Pending: len(s.pending)}
+}
+
+// WaitAcked blocks until every published delta has been acknowledged or the
+// timeout passes, reporting whether the stream drained.
+func (s *VoteStream) WaitAcked(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if s.Stats().Pending == 0 {
+			return true
+		}
+		time.Sleep(s.opts.FlushInterval)
+	}
+	return s.Stats().Pending == 0
+}
+
+// DeliverVoteWebhook POSTs each batch from stream to url as JSON until the
+// stream is closed, acknowledging batches the endpoint accepts with a 2xx.
+// Failed deliveries are left pending and retried by the stream.
+func DeliverVoteWebhook(stream *VoteStream, url string, client *http.Client) {
+	for batch := range stream.C {
+		body, err := json.Marshal(batch)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			stream.Ack(batch.LastSeq)
+		}
+	}
+}
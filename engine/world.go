@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// World Definitions
+
+var ErrEmptyWorld = errors.New("world declares no subreddits")
+
+// WorldDefinition declares the subreddits and seed users an engine starts
+// with. Subreddits are listed most popular first; Size is how many seed users
+// join each one, picked by interest in its topics.
+type WorldDefinition struct {
+	SubReddits []WorldSubReddit
+	Users      []WorldUser
+	// DefaultSubReddits are the subreddits users registering after the
+	// world is loaded are subscribed to.
+	DefaultSubReddits []string
+}
+
+// WorldSubReddit is a seed subreddit. Size is how many users join it.
+type WorldSubReddit struct {
+	Name       string
+	Topics     []string
+	Size       int
+	Settings   SubRedditSettings
+	Policy     ContentPolicy
+	Moderators []string
+}
+
+// WorldUser is a seed user. Subscriptions, a multireddit path such as
+// "r/gaming+news", subscribes them to subreddits beyond those Size picks.
+// Seed users without an Age are kept out of NSFW subreddits like minors.
+type WorldUser struct {
+	Username      string
+	Admin         bool
+	Age           int
+	Interests     map[string]float64
+	Subscriptions string
+}
+
+// LoadWorldDefinition reads a world definition from a JSON file.
+func LoadWorldDefinition(path string) (*WorldDefinition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	var world WorldDefinition
+	if err := decoder.Decode(&world); err != nil {
+		return nil, fmt.Errorf("world %s: %v", path, err)
+	}
+	return &world, nil
+}
+
+// SubRedditNames lists the world's subreddits in definition order.
+func (world *WorldDefinition) SubRedditNames() []string {
+	names := make([]string, 0, len(world.SubReddits))
+	for _, subReddit := range world.SubReddits {
+		names = append(names, subReddit.Name)
+	}
+	return names
+}
+
+// LoadWorld creates everything declared in world, which must declare at
+// least one subreddit. It runs before the simulation, so seed memberships
+// and moderator appointments are setup rather than user actions: each seed
+// user is bulk subscribed to all their subreddits at once for its
+// bookkeeping, and appointments are recorded in the audit log with the
+// system as actor.
+func (e *Engine) LoadWorld(world *WorldDefinition) error {
+	if len(world.SubReddits) == 0 {
+		return ErrEmptyWorld
+	}
+	users := make(map[string]*User, len(world.Users))
+	subscriptions := make(map[string][]string, len(world.Users))
+	for _, seed := range world.Users {
+		if _, exists := users[seed.Username]; exists {
+			return fmt.Errorf("world: duplicate user %q", seed.Username)
+		}
+		user, err := e.RegisterUser(seed.Username)
+		if err != nil {
+			return fmt.Errorf("world: user %q: %w", seed.Username, err)
+		}
+		users[seed.Username] = user
+		if seed.Admin {
+			e.MakeAdmin(user)
+		}
+		if seed.Age != 0 {
+			if err := e.SetUserAge(user, seed.Age); err != nil {
+				return fmt.Errorf("world: age of %q: %w", seed.Username, err)
+			}
+		}
+		if len(seed.Interests) > 0 {
+			e.SetInterestProfile(user, seed.Interests)
+		}
+		names, err := parseSubscriptionList(seed.Subscriptions)
+		if err != nil {
+			return fmt.Errorf("world: subscriptions of %q: %w", seed.Username, err)
+		}
+		subscriptions[seed.Username] = names
+	}
+
+	for _, declared := range world.SubReddits {
+		if _, err := e.CreateSubReddit(declared.Name); err != nil {
+			return fmt.Errorf("world: subreddit %q: %w", declared.Name, err)
+		}
+		e.SetSubRedditTopics(declared.Name, declared.Topics...)
+		e.SetSubRedditSettings(declared.Name, declared.Settings)
+		e.Mutex.Lock()
+		setContentPolicy(e.SubReddits[declared.Name], declared.Policy)
+		e.Mutex.Unlock()
+		for _, user := range seedMembers(world.Users, users, declared) {
+			subscriptions[user.Username] = append(subscriptions[user.Username], declared.Name)
+		}
+		for _, username := range declared.Moderators {
+			user, exists := users[username]
+			if !exists {
+				return fmt.Errorf("world: moderator %q of %s is not a declared user", username, declared.Name)
+			}
+			e.appointModerator(user, declared.Name)
+		}
+	}
+	for _, seed := range world.Users {
+		result, err := e.BulkSubscribe(users[seed.Username], subscriptions[seed.Username])
+		if err != nil {
+			return fmt.Errorf("world: subscribing %q: %w", seed.Username, err)
+		}
+		if len(result.Unknown) > 0 {
+			return fmt.Errorf("world: %q subscribes to undeclared subreddits %v", seed.Username, result.Unknown)
+		}
+	}
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if err := e.setDefaultSubReddits(world.DefaultSubReddits); err != nil {
+		return fmt.Errorf("world: default subreddits %v: %w", world.DefaultSubReddits, err)
+	}
+	return nil
+}
+
+// seedMembers returns the declared.Size seed users most interested in the
+// subreddit's topics, ties broken by declaration order.
+func seedMembers(seeds []WorldUser, users map[string]*User, declared WorldSubReddit) []*User {
+	ranked := make([]WorldUser, len(seeds))
+	copy(ranked, seeds)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return TopicAffinity(ranked[i].Interests, declared.Topics) > TopicAffinity(ranked[j].Interests, declared.Topics)
+	})
+	members := make([]*User, 0, declared.Size)
+	for _, seed := range ranked[:min(declared.Size, len(ranked))] {
+		members = append(members, users[seed.Username])
+	}
+	return members
+}
+
+func (e *Engine) appointModerator(user *User, subRedditName string) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	subReddit := e.SubReddits[subRedditName]
+	subReddit.Moderators[user.ID] = user
+	e.recordAudit(0, "add_moderator", user.ID, subRedditName)
+	e.checkBigCommunity(subReddit)
+}
@@ -0,0 +1,3 @@
+module github.com/sahasgundapaneni/reddit-clone
+
+go 1.24
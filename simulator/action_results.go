@@ -0,0 +1,208 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Simulator Action Results
+
+// ActionResult is the outcome of one simulated action.
+type ActionResult struct {
+	Action    string
+	UserID    int64
+	Success   bool
+	ErrorType string `json:",omitempty"`
+	Err       error  `json:"-"`
+	Latency   time.Duration
+}
+
+// ActionResults collects every ActionResult of a run, with a latency
+// histogram per action. Do, Summaries, Histogram and WriteMetrics are safe
+// for concurrent use. Each result is also logged at debug level to Logger, or to
+// slog.Default() if it is nil.
+type ActionResults struct {
+	mu         sync.Mutex
+	Records    []ActionResult
+	histograms map[string]*engine.LatencyHistogram
+	Logger     *slog.Logger
+}
+
+// ActionSummary totals the results of one kind of action.
+type ActionSummary struct {
+	Action     string
+	Count      int
+	Errors     int
+	ErrorTypes map[string]int
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+}
+
+// Do runs fn, records its outcome and latency under action, and returns its
+// error.
+func (r *ActionResults) Do(action string, user *engine.User, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	result := ActionResult{Action: action, Success: err == nil, Err: err, Latency: time.Since(start)}
+	if user != nil {
+		result.UserID = user.ID
+	}
+	if err != nil {
+		result.ErrorType = errorType(err)
+	}
+	r.log(result)
+	r.mu.Lock()
+	r.Records = append(r.Records, result)
+	if r.histograms == nil {
+		r.histograms = make(map[string]*engine.LatencyHistogram)
+	}
+	histogram := r.histograms[action]
+	if histogram == nil {
+		histogram = &engine.LatencyHistogram{}
+		r.histograms[action] = histogram
+	}
+	histogram.Record(result.Latency)
+	r.mu.Unlock()
+	return err
+}
+
+// log logs result at debug level with its action, user and latency.
+func (r *ActionResults) log(result ActionResult) {
+	logger := r.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("action", result.Action), slog.Duration("latency", result.Latency)}
+	if result.UserID != 0 {
+		attrs = append(attrs, slog.Int64("user", result.UserID))
+	}
+	if result.Err != nil {
+		attrs = append(attrs, slog.Any("err", result.Err))
+	}
+	logger.LogAttrs(context.Background(), slog.LevelDebug, "action", attrs...)
+}
+
+// errorType names an error for aggregation: the message of a sentinel error,
+// or the Go type of a structured one.
+func errorType(err error) string {
+	if name := fmt.Sprintf("%T", err); name != "*errors.errorString" {
+		return name
+	}
+	return err.Error()
+}
+
+// Summaries aggregates the records per action, sorted by action name.
+func (r *ActionResults) Summaries() []ActionSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byAction := make(map[string]*ActionSummary)
+	for _, record := range r.Records {
+		summary, exists := byAction[record.Action]
+		if !exists {
+			summary = &ActionSummary{Action: record.Action, ErrorTypes: make(map[string]int)}
+			byAction[record.Action] = summary
+		}
+		summary.Count++
+		if !record.Success {
+			summary.Errors++
+			summary.ErrorTypes[record.ErrorType]++
+		}
+	}
+	summaries := make([]ActionSummary, 0, len(byAction))
+	for action, summary := range byAction {
+		histogram := r.histograms[action]
+		summary.P50 = histogram.Percentile(0.50)
+		summary.P95 = histogram.Percentile(0.95)
+		summary.P99 = histogram.Percentile(0.99)
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Action < summaries[j].Action })
+	return summaries
+}
+
+// Histogram returns the latencies of the given actions merged into one
+// histogram.
+func (r *ActionResults) Histogram(actions ...string) *engine.LatencyHistogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	merged := &engine.LatencyHistogram{}
+	for _, action := range actions {
+		if histogram := r.histograms[action]; histogram != nil {
+			merged.Merge(histogram)
+		}
+	}
+	return merged
+}
+
+// WriteMetrics writes the latency histogram of every action in the
+// Prometheus text format, for api.MetricsHandler.
+func (r *ActionResults) WriteMetrics(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return engine.WriteHistogramMetrics(w, "action_duration_seconds", "Simulated action latency by action.", "action", r.histograms)
+}
+
+// ActionType groups the simulator's actions into one kind of engine call
+// for the latency report.
+type ActionType struct {
+	Name    string
+	Actions []string
+}
+
+// ActionTypes are the kinds of engine call PrintLatencyReport breaks
+// latency down by.
+var ActionTypes = []ActionType{
+	{"post", []string{"create_post", "link_post", "gallery_post", "repost"}},
+	{"comment", []string{"comment", "reply"}},
+	{"vote", []string{"upvote_post", "downvote_post", "upvote_comment", "downvote_comment", "visit_vote", "visit_unvote"}},
+	{"DM", []string{"message", "accept_dm_request", "decline_dm_request"}},
+	{"feed read", []string{"subreddit_feed"}},
+}
+
+// PrintActionResults prints each action's error rate, latency and most
+// common errors.
+func PrintActionResults(w io.Writer, results *ActionResults) {
+	fmt.Fprintln(w, "\nSimulator Action Results:")
+	for _, summary := range results.Summaries() {
+		fmt.Fprintf(w, "%s: %d (errors: %d, %.1f%%), p50 %v, p95 %v, p99 %v\n", summary.Action, summary.Count, summary.Errors, 100*float64(summary.Errors)/float64(summary.Count), summary.P50, summary.P95, summary.P99)
+		types := make([]string, 0, len(summary.ErrorTypes))
+		for errType := range summary.ErrorTypes {
+			types = append(types, errType)
+		}
+		sort.Strings(types)
+		for _, errType := range types {
+			fmt.Fprintf(w, "  %s: %d\n", errType, summary.ErrorTypes[errType])
+		}
+	}
+}
+
+// PrintLatencyReport prints the p50, p95 and p99 latency of each action
+// type, and of every action together.
+func PrintLatencyReport(w io.Writer, results *ActionResults) {
+	fmt.Fprintln(w, "\nLatency by Action Type (HDR histogram, within 3%):")
+	fmt.Fprintf(w, "%-10s %10s %12s %12s %12s %12s\n", "Type", "Calls", "p50", "p95", "p99", "Max")
+	row := func(name string, histogram *engine.LatencyHistogram) {
+		fmt.Fprintf(w, "%-10s %10d %12v %12v %12v %12v\n", name, histogram.Count(), histogram.Percentile(0.50), histogram.Percentile(0.95), histogram.Percentile(0.99), histogram.Max())
+	}
+	for _, actionType := range ActionTypes {
+		row(actionType.Name, results.Histogram(actionType.Actions...))
+	}
+	results.mu.Lock()
+	all := make([]string, 0, len(results.histograms))
+	for action := range results.histograms {
+		all = append(all, action)
+	}
+	results.mu.Unlock()
+	row("all", results.Histogram(all...))
+}
@@ -0,0 +1,255 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Concurrent Actors
+
+// ActorReport is the outcome of SimulateActors. Throughput is the engine
+// actions counted during the run per wall-clock second; the per-user counts
+// are the spread of simulator actions across actors. Sessions holds each
+// actor's observations when SimulateActors was asked to record them.
+type ActorReport struct {
+	Actors      int
+	Duration    time.Duration
+	Actions     int
+	Errors      int
+	Engine      int
+	Throughput  float64
+	MinPerActor int
+	MaxPerActor int
+	P50         time.Duration
+	P99         time.Duration
+	Sessions    [][]engine.Observation
+}
+
+// actorPool is the content actors have seen created, shared between them.
+type actorPool struct {
+	mu       sync.Mutex
+	posts    []*engine.Post
+	comments []*engine.Comment
+}
+
+func (p *actorPool) addPost(post *engine.Post) {
+	p.mu.Lock()
+	p.posts = append(p.posts, post)
+	p.mu.Unlock()
+}
+
+func (p *actorPool) addComment(comment *engine.Comment) {
+	p.mu.Lock()
+	p.comments = append(p.comments, comment)
+	p.mu.Unlock()
+}
+
+func (p *actorPool) randomPost(rng *rand.Rand) *engine.Post {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.posts) == 0 {
+		return nil
+	}
+	return p.posts[rng.Intn(len(p.posts))]
+}
+
+func (p *actorPool) randomComment(rng *rand.Rand) *engine.Comment {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.comments) == 0 {
+		return nil
+	}
+	return p.comments[rng.Intn(len(p.comments))]
+}
+
+// actorTally is what one actor did, kept by its own goroutine and merged
+// once every actor has stopped.
+type actorTally struct {
+	actions   int
+	errors    int
+	latencies []time.Duration
+	observed  []engine.Observation
+}
+
+// SimulateActors runs every active user as its own goroutine for duration,
+// each taking random actions against the engine as fast as it can: posting,
+// commenting, replying, voting, messaging and reading listings, in
+// subreddits drawn by popularity with activity's Zipf exponent. Actors are
+// released together and stopped together, and their outcomes are written to
+// results, so the report measures the engine under concurrent load rather
+// than one user at a time. With observe, each actor also observes the engine
+// before and after every action, for engine.CheckHappensBefore.
+//
+// Each actor draws from its own generator, seeded in turn from e.Rand, so a
+// seeded run gives every actor the same sequence of choices. How many of
+// them it gets through in duration, and how they interleave with the other
+// actors', is still up to the scheduler.
+func SimulateActors(e *engine.Engine, duration time.Duration, subRedditNames []string, activity ActivityConfig, results *ActionResults, observe bool) ActorReport {
+	report := ActorReport{Duration: duration}
+	if duration <= 0 || len(subRedditNames) == 0 {
+		return report
+	}
+	e.Mutex.RLock()
+	users := make([]*engine.User, 0, len(e.Users))
+	for _, user := range e.Users {
+		if user.MergedInto == 0 && !user.Churned {
+			users = append(users, user)
+		}
+	}
+	pool := &actorPool{}
+	for _, name := range subRedditNames {
+		posts := e.SubReddits[name].Posts
+		pool.posts = append(pool.posts, posts[max(0, len(posts)-20):]...)
+	}
+	actionsBefore := e.TotalActions
+	e.Mutex.RUnlock()
+	if len(users) == 0 {
+		return report
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	report.Actors = len(users)
+
+	start, stop := make(chan struct{}), make(chan struct{})
+	tallies := make([]actorTally, len(users))
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		go func(tally *actorTally, rng *rand.Rand) {
+			defer wg.Done()
+			popular := newPopularity(subRedditNames, activity.ZipfExponent, rng)
+			<-start
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if observe {
+					tally.observed = append(tally.observed, e.Observe())
+				}
+				began := time.Now()
+				err := actorAction(e, user, users, popular, pool, rng, results)
+				tally.latencies = append(tally.latencies, time.Since(began))
+				if observe {
+					tally.observed = append(tally.observed, e.Observe())
+				}
+				tally.actions++
+				if err != nil {
+					tally.errors++
+				}
+			}
+		}(&tallies[i], rand.New(rand.NewSource(e.Rand.Int63())))
+	}
+	began := time.Now()
+	close(start)
+	time.Sleep(duration)
+	close(stop)
+	wg.Wait()
+	elapsed := time.Since(began)
+
+	var latencies []time.Duration
+	report.MinPerActor = tallies[0].actions
+	for _, tally := range tallies {
+		report.Actions += tally.actions
+		report.Errors += tally.errors
+		report.MinPerActor = min(report.MinPerActor, tally.actions)
+		report.MaxPerActor = max(report.MaxPerActor, tally.actions)
+		latencies = append(latencies, tally.latencies...)
+		if observe {
+			report.Sessions = append(report.Sessions, tally.observed)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.P50 = percentile(latencies, 0.50)
+	report.P99 = percentile(latencies, 0.99)
+	e.Mutex.RLock()
+	report.Engine = e.TotalActions - actionsBefore
+	e.Mutex.RUnlock()
+	report.Throughput = float64(report.Engine) / elapsed.Seconds()
+	return report
+}
+
+// actorAction takes one random action as user.
+func actorAction(e *engine.Engine, user *engine.User, users []*engine.User, popular *popularity, pool *actorPool, rng *rand.Rand, results *ActionResults) error {
+	switch roll := rng.Float64(); {
+	case roll < 0.15:
+		subRedditName := popular.pick()
+		return results.Do("create_post", user, func() error {
+			post, err := e.CreatePost(user, subRedditName, simulatedContent(fmt.Sprintf("Concurrent post from %s", user.Username), rng))
+			if err == nil {
+				pool.addPost(post)
+			}
+			return err
+		})
+	case roll < 0.35:
+		post := pool.randomPost(rng)
+		if post == nil {
+			return nil
+		}
+		return results.Do("comment", user, func() error {
+			comment, err := e.CommentPost(user, post, simulatedContent(fmt.Sprintf("Concurrent comment from %s", user.Username), rng))
+			if err == nil {
+				pool.addComment(comment)
+			}
+			return err
+		})
+	case roll < 0.45:
+		parent := pool.randomComment(rng)
+		if parent == nil {
+			return nil
+		}
+		return results.Do("reply", user, func() error {
+			reply, err := e.AddReplyToComment(user, parent, simulatedContent(fmt.Sprintf("Concurrent reply from %s", user.Username), rng))
+			if err == nil {
+				pool.addComment(reply)
+			}
+			return err
+		})
+	case roll < 0.65:
+		post := pool.randomPost(rng)
+		if post == nil {
+			return nil
+		}
+		if rng.Float64() < 0.8 {
+			return results.Do("upvote_post", user, func() error { return e.UpvotePost(user, post) })
+		}
+		return results.Do("downvote_post", user, func() error { return e.DownvotePost(user, post) })
+	case roll < 0.75:
+		comment := pool.randomComment(rng)
+		if comment == nil {
+			return nil
+		}
+		if rng.Float64() < 0.8 {
+			return results.Do("upvote_comment", user, func() error { return e.UpvoteComment(user, comment) })
+		}
+		return results.Do("downvote_comment", user, func() error { return e.DownvoteComment(user, comment) })
+	case roll < 0.80:
+		to := users[rng.Intn(len(users))]
+		if to == user {
+			return nil
+		}
+		return results.Do("message", user, func() error {
+			return e.SendDirectMessage(user, to, fmt.Sprintf("Hello from %s to %s!", user.Username, to.Username))
+		})
+	default:
+		subRedditName := popular.pick()
+		return results.Do("subreddit_feed", user, func() error {
+			_, err := e.GetSubRedditFeed(user, subRedditName)
+			return err
+		})
+	}
+}
+
+// PrintActorReport prints how much the concurrent actors got done and how
+// evenly the engine served them.
+func PrintActorReport(w io.Writer, report ActorReport) {
+	fmt.Fprintf(w, "\nConcurrent Actors: %d users for %v\n", report.Actors, report.Duration)
+	fmt.Fprintf(w, "Actions: %d (%d errors), %d counted by the engine, %.2f actions/sec\n", report.Actions, report.Errors, report.Engine, report.Throughput)
+	fmt.Fprintf(w, "Per actor: min %d, max %d; latency p50 %v, p99 %v\n", report.MinPerActor, report.MaxPerActor, report.P50, report.P99)
+}
@@ -0,0 +1,67 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Age Gating
+
+// randomAge gives about one simulated user in seven an age under
+// engine.AdultAge.
+func randomAge(rng *rand.Rand) int {
+	if rng.Float64() < 0.15 {
+		return 13 + rng.Intn(engine.AdultAge-13)
+	}
+	return engine.AdultAge + rng.Intn(50)
+}
+
+// AgeGatingReport is the outcome of CheckAgeGating. Leaks lists every NSFW
+// post or subreddit a minor was offered; it should be empty.
+type AgeGatingReport struct {
+	Minors          int
+	Feeds           int
+	Recommendations int
+	Leaks           []string
+}
+
+// CheckAgeGating reads every minor's home feed and subreddit
+// recommendations through the engine's public API and reports anything NSFW
+// in them, so age gating is checked the way a client would see it rather than
+// by inspecting the filters.
+func CheckAgeGating(e *engine.Engine) AgeGatingReport {
+	var report AgeGatingReport
+	e.Mutex.RLock()
+	var minors []*engine.User
+	for _, user := range e.Users {
+		if user.Age < engine.AdultAge && user.MergedInto == 0 {
+			minors = append(minors, user)
+		}
+	}
+	nsfw := make(map[string]bool)
+	for name, subReddit := range e.SubReddits {
+		nsfw[name] = subReddit.Settings.NSFW
+	}
+	e.Mutex.RUnlock()
+	report.Minors = len(minors)
+	for _, user := range minors {
+		report.Feeds++
+		for _, post := range e.GetUserFeed(user) {
+			e.Mutex.RLock()
+			flagged := post.NSFW
+			e.Mutex.RUnlock()
+			if flagged || nsfw[post.SubReddit] {
+				report.Leaks = append(report.Leaks, fmt.Sprintf("%s was shown NSFW post %d in r/%s", user.Username, post.ID, post.SubReddit))
+			}
+		}
+		for _, recommendation := range e.RecommendSubReddits(user, len(nsfw)) {
+			report.Recommendations++
+			if nsfw[recommendation.SubReddit] {
+				report.Leaks = append(report.Leaks, fmt.Sprintf("%s was recommended NSFW r/%s", user.Username, recommendation.SubReddit))
+			}
+		}
+	}
+	return report
+}
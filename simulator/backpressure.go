@@ -0,0 +1,89 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Client Backoff
+
+const (
+	// maxBackpressureWaits bounds how many times a simulated user backs off
+	// before acting anyway, so a queue that never drains can't stall a run.
+	maxBackpressureWaits = 20
+	backpressureBuckets  = 10
+)
+
+// awaitBackpressure is how simulated users respond to backpressure: they back
+// off for the suggested interval until the engine recovers or they run out
+// of patience. It returns how many times they waited, which is also added
+// to the engine's BackpressureWaits.
+func awaitBackpressure(e *engine.Engine) int {
+	waits := 0
+	for ; waits < maxBackpressureWaits; waits++ {
+		var overloaded *engine.BackpressureError
+		if !errors.As(e.Backpressure(), &overloaded) {
+			break
+		}
+		time.Sleep(overloaded.RetryAfter)
+	}
+	e.Mutex.Lock()
+	e.BackpressureWaits += waits
+	e.Mutex.Unlock()
+	return waits
+}
+
+// PrintBackpressureChart draws activations per queue over the span of the
+// run, one column per tenth of it.
+func PrintBackpressureChart(w io.Writer, e *engine.Engine, start, end time.Time) {
+	e.Mutex.RLock()
+	activations := append([]engine.BackpressureActivation(nil), e.BackpressureActivations...)
+	signals, waits := e.BackpressureSignals, e.BackpressureWaits
+	e.Mutex.RUnlock()
+	fmt.Fprintf(w, "Backpressure: %d activations, %d signals, %d simulated back-offs\n", len(activations), signals, waits)
+	if len(activations) == 0 {
+		return
+	}
+	span := end.Sub(start)
+	for _, queue := range []string{engine.QueueVotes, engine.QueueNotifications, engine.QueueHooks} {
+		counts := make([]int, backpressureBuckets)
+		total := 0
+		for _, activation := range activations {
+			if activation.Queue != queue {
+				continue
+			}
+			bucket := backpressureBuckets - 1
+			if span > 0 {
+				bucket = min(bucket, max(0, int(activation.Time.Sub(start)*backpressureBuckets/span)))
+			}
+			counts[bucket]++
+			total++
+		}
+		if total == 0 {
+			continue
+		}
+		var chart strings.Builder
+		for _, count := range counts {
+			chart.WriteString(activationLevel(count))
+		}
+		fmt.Fprintf(w, "  %-14s |%s| %d\n", queue, chart.String(), total)
+	}
+}
+
+// activationLevel renders a bucket's count as one character.
+func activationLevel(count int) string {
+	switch {
+	case count == 0:
+		return " "
+	case count < 3:
+		return "."
+	case count < 10:
+		return "o"
+	}
+	return "#"
+}
@@ -0,0 +1,56 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Ban Appeals
+
+// SimulateBanAppeals has each user still banned from one of subRedditNames
+// appeal with probability appealProbability, then has the subreddits'
+// moderators work through their appeal queues.
+func SimulateBanAppeals(e *engine.Engine, subRedditNames []string, appealProbability float64, results *ActionResults) {
+	for _, name := range subRedditNames {
+		e.Mutex.RLock()
+		var banned []*engine.User
+		for id := range e.SubReddits[name].Banned {
+			if user := e.Users[id]; user != nil {
+				banned = append(banned, user)
+			}
+		}
+		e.Mutex.RUnlock()
+		sort.Slice(banned, func(i, j int) bool { return banned[i].ID < banned[j].ID })
+		for _, user := range banned {
+			if e.Rand.Float64() >= appealProbability {
+				continue
+			}
+			results.Do("appeal_ban", user, func() error {
+				_, err := e.FileBanAppeal(user, name, fmt.Sprintf("%s promises to follow the rules of %s from now on", user.Username, name))
+				return err
+			})
+		}
+	}
+	reviewBanAppeals(e, subRedditNames, results)
+}
+
+// reviewBanAppeals has each subreddit's moderator accept some pending ban
+// appeals and deny the rest.
+func reviewBanAppeals(e *engine.Engine, subRedditNames []string, results *ActionResults) {
+	for _, name := range subRedditNames {
+		mod := pickModerator(e, name)
+		if mod == nil {
+			continue
+		}
+		queue, _ := e.GetAppealQueue(mod, name)
+		for _, appeal := range queue {
+			if e.Rand.Float64() < 0.4 {
+				results.Do("accept_appeal", mod, func() error { return e.AcceptBanAppeal(mod, appeal) })
+			} else {
+				results.Do("deny_appeal", mod, func() error { return e.DenyBanAppeal(mod, appeal, "repeated content policy violations") })
+			}
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/api"
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Scripted Bots
+
+// Bot is a scripted account that runs outside the engine, like a real bot
+// would: it follows the event stream and acts only through the API client.
+type Bot interface {
+	// Name is the bot's username and the User-Agent it sends.
+	Name() string
+	// React handles one event by a non-bot account.
+	React(client *api.Client, event engine.Event) error
+}
+
+// AutoModBot pins the rules of the road on every new post and downvotes
+// posts that match its blocklist.
+type AutoModBot struct {
+	Blocklist []string
+}
+
+func (b *AutoModBot) Name() string { return "AutoModerator" }
+
+func (b *AutoModBot) React(client *api.Client, event engine.Event) error {
+	if event.Type != "post" {
+		return nil
+	}
+	thread, err := client.GetThread(event.TargetID)
+	if err != nil {
+		return err
+	}
+	content := strings.ToLower(thread.Post.Content)
+	for _, phrase := range b.Blocklist {
+		if strings.Contains(content, phrase) {
+			_, err := client.VotePost(b.Name(), event.TargetID, -1)
+			return err
+		}
+	}
+	_, err = client.CommentPost(b.Name(), event.TargetID, fmt.Sprintf("Reminder: please keep r/%s civil and on topic. I am a bot.", event.SubReddit))
+	return err
+}
+
+// ReminderBot answers comments containing "!remindme <duration>" and, once
+// the event stream shows that much time has passed, messages the commenter
+// a link back to their comment.
+type ReminderBot struct {
+	pending []reminder
+}
+
+type reminder struct {
+	due       time.Time
+	username  string
+	permalink string
+}
+
+func (b *ReminderBot) Name() string { return "RemindMeBot" }
+
+func (b *ReminderBot) React(client *api.Client, event engine.Event) error {
+	if err := b.deliver(client, event.Time); err != nil {
+		return err
+	}
+	if event.Type != "comment" && event.Type != "reply" {
+		return nil
+	}
+	comment, err := client.GetComment(event.TargetID)
+	if err != nil {
+		return err
+	}
+	_, after, found := strings.Cut(comment.Content, "!remindme")
+	if !found {
+		return nil
+	}
+	fields := strings.Fields(after)
+	delay := time.Hour
+	if len(fields) > 0 {
+		if parsed, err := time.ParseDuration(fields[0]); err == nil && parsed > 0 {
+			delay = parsed
+		}
+	}
+	due := comment.CreatedAt.Add(delay)
+	b.pending = append(b.pending, reminder{due: due, username: comment.Author, permalink: comment.Permalink})
+	_, err = client.Reply(b.Name(), comment.ID, fmt.Sprintf("I will message you in %v.", delay))
+	return err
+}
+
+// deliver messages every reminder due by now.
+func (b *ReminderBot) deliver(client *api.Client, now time.Time) error {
+	remaining := b.pending[:0]
+	var failed error
+	for _, pending := range b.pending {
+		if now.Before(pending.due) {
+			remaining = append(remaining, pending)
+			continue
+		}
+		if err := client.SendMessage(b.Name(), pending.username, "You asked to be reminded of "+pending.permalink); err != nil {
+			failed = err
+		}
+	}
+	b.pending = remaining
+	return failed
+}
+
+// TLDRBot summarizes long posts with their first sentence.
+type TLDRBot struct {
+	MinLength int
+}
+
+func (b *TLDRBot) Name() string { return "tldrbot" }
+
+func (b *TLDRBot) React(client *api.Client, event engine.Event) error {
+	if event.Type != "post" {
+		return nil
+	}
+	thread, err := client.GetThread(event.TargetID)
+	if err != nil {
+		return err
+	}
+	if len(thread.Post.Content) < b.MinLength {
+		return nil
+	}
+	summary, _, _ := strings.Cut(thread.Post.Content, ". ")
+	if len(summary) > 120 {
+		summary = summary[:120] + "..."
+	}
+	_, err = client.CommentPost(b.Name(), event.TargetID, "TL;DR: "+summary)
+	return err
+}
+
+// DefaultBots are the bots -bots runs.
+func DefaultBots() []Bot {
+	return []Bot{
+		&AutoModBot{Blocklist: []string{"cheap followers"}},
+		&ReminderBot{},
+		&TLDRBot{MinLength: 200},
+	}
+}
+
+// BotLoad is one bot's share of the work in a BotReport. Requests and
+// Latency are measured at the HTTP server; Actions are what the engine
+// counted for the bot's account.
+type BotLoad struct {
+	Name     string
+	Requests int
+	Errors   int
+	Actions  int
+	P50      time.Duration
+	P99      time.Duration
+}
+
+// BotReport is the outcome of SimulateBots.
+type BotReport struct {
+	Rounds         int
+	Events         int
+	OrganicActions int
+	BotActions     int
+	Bots           []BotLoad
+}
+
+// BotShare is the fraction of actions during the run that bots took.
+func (r BotReport) BotShare() float64 {
+	if total := r.OrganicActions + r.BotActions; total > 0 {
+		return float64(r.BotActions) / float64(total)
+	}
+	return 0
+}
+
+// requestLog counts the requests an HTTP handler serves per User-Agent.
+type requestLog struct {
+	mutex     sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func (l *requestLog) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+		latency := time.Since(start)
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		agent := r.UserAgent()
+		l.latencies[agent] = append(l.latencies[agent], latency)
+		if recorder.status >= 400 {
+			l.errors[agent]++
+		}
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// SimulateBots serves e's REST API on a local test server, signs bots up
+// through it and runs rounds of organic activity, written to results, after
+// each of which every bot catches up on the event stream from where it left
+// off. On a SimClock each round is half an hour, so reminders come due.
+// Bots ignore events by bot accounts, so they never answer each other.
+func SimulateBots(e *engine.Engine, bots []Bot, rounds int, subRedditNames []string, results *ActionResults) BotReport {
+	report := BotReport{Rounds: rounds}
+	if len(bots) == 0 || rounds <= 0 || len(subRedditNames) == 0 {
+		return report
+	}
+	e.Mutex.RLock()
+	users := make([]*engine.User, 0, len(e.Users))
+	for _, user := range e.Users {
+		if user.MergedInto == 0 && !user.Churned {
+			users = append(users, user)
+		}
+	}
+	e.Mutex.RUnlock()
+	if len(users) == 0 {
+		return report
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	log := &requestLog{latencies: map[string][]time.Duration{}, errors: map[string]int{}}
+	url, stop := serveLoopback(log.middleware(api.NewServer(e).Handler()))
+	defer stop()
+
+	clients := make([]*api.Client, len(bots))
+	botIDs := map[int64]bool{}
+	accounts := make([]*engine.User, len(bots))
+	for i, bot := range bots {
+		clients[i] = api.NewClient(url, bot.Name())
+		profile, err := clients[i].RegisterUser(bot.Name())
+		if err != nil {
+			e.Logger.Warn("bot could not sign up", "bot", bot.Name(), "err", err)
+			return report
+		}
+		botIDs[profile.ID] = true
+		accounts[i] = e.GetUserByUsername(bot.Name())
+	}
+	e.Mutex.RLock()
+	cursor, actionsBefore := e.EventSeq, e.TotalActions
+	e.Mutex.RUnlock()
+
+	clock, simulated := e.Clock.(*engine.SimClock)
+	cursors := make([]int, len(bots))
+	for i := range cursors {
+		cursors[i] = cursor
+	}
+	for round := 0; round < rounds; round++ {
+		simulateBotBait(e, users, subRedditNames, results)
+		for i, bot := range bots {
+			for {
+				events, err := clients[i].Events(cursors[i], 100)
+				if err != nil || len(events) == 0 {
+					break
+				}
+				for _, event := range events {
+					cursors[i] = event.Seq
+					if botIDs[event.UserID] {
+						continue
+					}
+					report.Events++
+					results.Do("bot_"+bot.Name(), accounts[i], func() error { return bot.React(clients[i], event) })
+				}
+			}
+		}
+		if simulated {
+			clock.Advance(30 * time.Minute)
+			e.RunScheduled()
+		}
+	}
+
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	for i, bot := range bots {
+		latencies := log.latencies[bot.Name()]
+		sort.Slice(latencies, func(a, b int) bool { return latencies[a] < latencies[b] })
+		report.Bots = append(report.Bots, BotLoad{
+			Name:     bot.Name(),
+			Requests: len(latencies),
+			Errors:   log.errors[bot.Name()],
+			Actions:  accounts[i].Actions,
+			P50:      percentile(latencies, 0.5),
+			P99:      percentile(latencies, 0.99),
+		})
+		report.BotActions += report.Bots[i].Actions
+	}
+	report.OrganicActions = e.TotalActions - actionsBefore - report.BotActions
+	return report
+}
+
+// simulateBotBait is one round of organic activity for SimulateBots by ten
+// of users: new posts, some long enough for a TL;DR, and comments, some asking for a
+// reminder.
+func simulateBotBait(e *engine.Engine, users []*engine.User, subRedditNames []string, results *ActionResults) {
+	for i := 0; i < 10; i++ {
+		user := users[e.Rand.Intn(len(users))]
+		subRedditName := subRedditNames[e.Rand.Intn(len(subRedditNames))]
+		switch roll := e.Rand.Float64(); {
+		case roll < 0.3:
+			content := simulatedContent(fmt.Sprintf("Thoughts on %s from %s", subRedditName, user.Username), e.Rand)
+			if e.Rand.Float64() < 0.3 {
+				content = fmt.Sprintf("Here is a long writeup about %s. ", subRedditName) + strings.Repeat("It goes on at some length with details nobody asked for. ", 5)
+			}
+			results.Do("create_post", user, func() error {
+				_, err := e.CreatePost(user, subRedditName, content)
+				return err
+			})
+		default:
+			post := randomBotBaitPost(e, subRedditName)
+			if post == nil {
+				continue
+			}
+			content := simulatedContent(fmt.Sprintf("Replying to post %d", post.ID), e.Rand)
+			if e.Rand.Float64() < 0.2 {
+				content = fmt.Sprintf("Interesting, !remindme %dh", 1+e.Rand.Intn(3))
+			}
+			results.Do("comment", user, func() error {
+				_, err := e.CommentPost(user, post, content)
+				return err
+			})
+		}
+	}
+}
+
+// randomBotBaitPost returns one of the newest posts in the subreddit, or nil
+// if it has none.
+func randomBotBaitPost(e *engine.Engine, subRedditName string) *engine.Post {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	posts := e.SubReddits[subRedditName].Posts
+	if len(posts) == 0 {
+		return nil
+	}
+	return posts[len(posts)-1-e.Rand.Intn(min(10, len(posts)))]
+}
+
+// PrintBotReport prints each bot's requests, latency and actions, and how
+// much of the site's activity came from bots.
+func PrintBotReport(w io.Writer, report BotReport) {
+	fmt.Fprintf(w, "\nScripted Bots: %d rounds, %d events handled\n", report.Rounds, report.Events)
+	for _, bot := range report.Bots {
+		fmt.Fprintf(w, "%s: %d requests (%d errors, p50 %v, p99 %v), %d actions\n", bot.Name, bot.Requests, bot.Errors, bot.P50, bot.P99, bot.Actions)
+	}
+	fmt.Fprintf(w, "Bot load: %d of %d actions (%.1f%%) on top of %d organic\n", report.BotActions, report.BotActions+report.OrganicActions, report.BotShare()*100, report.OrganicActions)
+}
+
+// serveLoopback serves handler on a free port of the loopback interface,
+// so clients in this process reach it over a real connection, and returns
+// its base URL and a function stopping it. Like net/http/httptest, which
+// isn't used so the binary doesn't link the testing package, it panics if
+// no port can be bound.
+func serveLoopback(handler http.Handler) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("simulator: serving on the loopback interface: %v", err))
+	}
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	return "http://" + listener.Addr().String(), func() { server.Close() }
+}
@@ -0,0 +1,157 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Brigade Experiment
+
+// PersonaBrigader marks the fresh accounts a simulated brigade votes with.
+const PersonaBrigader = "brigader"
+
+var brigadeSorts = []engine.FeedSort{engine.SortHot, engine.SortBest, engine.SortKarmaWeighted}
+
+// BrigadeConfig sets up RunBrigadeExperiment.
+type BrigadeConfig struct {
+	// Users is how many organic users are simulated before the attack.
+	Users int
+	// Targets is how many rising posts are attacked, each downvoted once by
+	// every one of Brigaders fresh accounts.
+	Targets   int
+	Brigaders int
+	// TopN is the front page: a target survives under a sort if it was in
+	// the top TopN before the attack and still is after.
+	TopN int
+	// Seed seeds the experiment's engine; zero seeds it from the clock.
+	Seed int64
+}
+
+var DefaultBrigadeConfig = BrigadeConfig{Users: 1000, Targets: 10, Brigaders: 40, TopN: 25}
+
+// BrigadeResult is how targets ranked under one sort before and after the
+// attack.
+type BrigadeResult struct {
+	Sort           engine.FeedSort
+	MeanRankBefore float64
+	MeanRankAfter  float64
+	OnFrontPage    int
+	Survived       int
+}
+
+// BrigadeReport is the outcome of RunBrigadeExperiment, one result per
+// sort.
+type BrigadeReport struct {
+	Config       BrigadeConfig
+	Listed       int
+	BrigadeVotes int
+	Results      []BrigadeResult
+}
+
+// RunBrigadeExperiment simulates an organic community on a fresh engine,
+// picks random rising posts (among the hottest recent, upvoted ones) and
+// has a brigade of new accounts linked from one subreddit downvote them
+// all. Each ranking is judged by how far the targets fall in the site-wide
+// listing.
+func RunBrigadeExperiment(config BrigadeConfig) BrigadeReport {
+	e := engine.New()
+	clock := engine.NewSimClock(time.Now())
+	e.Clock = clock
+	if config.Seed != 0 {
+		e.Rand = engine.NewRand(config.Seed)
+	}
+	world := GeneratedWorld(max(10, config.Users/100), e.Rand)
+	e.LoadWorld(world)
+	start := clock.Now()
+	SimulateUsers(e, config.Users, world.SubRedditNames(), DefaultActivity, &ActionResults{}, nil)
+
+	listing := e.ListedPosts()
+	report := BrigadeReport{Config: config, Listed: len(listing)}
+	risingAfter := start.Add(clock.Now().Sub(start) * 4 / 5)
+	var rising []*engine.Post
+	for _, post := range listing {
+		if post.Votes > 0 && post.CreatedAt.After(risingAfter) {
+			rising = append(rising, post)
+		}
+	}
+	engine.SortPosts(rising, engine.SortHot, nil)
+	rising = rising[:min(3*config.Targets, len(rising))]
+	e.Rand.Shuffle(len(rising), func(i, j int) { rising[i], rising[j] = rising[j], rising[i] })
+	targets := rising[:min(config.Targets, len(rising))]
+	if len(targets) == 0 {
+		return report
+	}
+	before := brigadeRanks(listing, targets)
+
+	source := "r/" + world.SubReddits[e.Rand.Intn(len(world.SubReddits))].Name
+	for i := 0; i < config.Brigaders; i++ {
+		brigader, err := e.RegisterUser(fmt.Sprintf("Brigader%d", i+1))
+		if err != nil {
+			break
+		}
+		brigader.Persona = PersonaBrigader
+		for _, target := range targets {
+			if e.CastPostVote(brigader, target, -1, source) == nil {
+				report.BrigadeVotes++
+			}
+		}
+		clock.Advance(time.Second)
+	}
+	after := brigadeRanks(listing, targets)
+
+	for _, order := range brigadeSorts {
+		result := BrigadeResult{Sort: order}
+		for i := range targets {
+			rankBefore, rankAfter := before[order][i], after[order][i]
+			result.MeanRankBefore += float64(rankBefore) / float64(len(targets))
+			result.MeanRankAfter += float64(rankAfter) / float64(len(targets))
+			if rankBefore <= config.TopN {
+				result.OnFrontPage++
+				if rankAfter <= config.TopN {
+					result.Survived++
+				}
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// brigadeRanks returns, for each sort, the 1-based rank of every target in
+// the listing.
+func brigadeRanks(listing, targets []*engine.Post) map[engine.FeedSort][]int {
+	ranks := make(map[engine.FeedSort][]int, len(brigadeSorts))
+	for _, order := range brigadeSorts {
+		sorted := append([]*engine.Post(nil), listing...)
+		engine.SortPosts(sorted, order, nil)
+		position := make(map[*engine.Post]int, len(sorted))
+		for i, post := range sorted {
+			position[post] = i + 1
+		}
+		for _, target := range targets {
+			ranks[order] = append(ranks[order], position[target])
+		}
+	}
+	return ranks
+}
+
+// PrintBrigadeReport prints how well each sort resisted the brigade.
+func PrintBrigadeReport(w io.Writer, report BrigadeReport) {
+	fmt.Fprintln(w, "Brigade Experiment:")
+	fmt.Fprintf(w, "%d organic users, %d listed posts; %d brigaders cast %d downvotes on %d rising posts\n", report.Config.Users, report.Listed, report.Config.Brigaders, report.BrigadeVotes, report.Config.Targets)
+	if len(report.Results) == 0 {
+		fmt.Fprintln(w, "No rising posts to attack")
+		return
+	}
+	fmt.Fprintf(w, "%-16s %12s %12s %s\n", "Ranking", "Rank before", "Rank after", fmt.Sprintf("Top %d survival", report.Config.TopN))
+	for _, result := range report.Results {
+		survival := "-"
+		if result.OnFrontPage > 0 {
+			survival = fmt.Sprintf("%d/%d (%.0f%%)", result.Survived, result.OnFrontPage, float64(result.Survived)/float64(result.OnFrontPage)*100)
+		}
+		fmt.Fprintf(w, "%-16s %12.1f %12.1f %s\n", feedSortNames[result.Sort], result.MeanRankBefore, result.MeanRankAfter, survival)
+	}
+}
@@ -0,0 +1,155 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Capacity Planner
+
+var CapacitySteps = []int{1000, 5000, 10000, 25000, 50000, 100000}
+
+// actionSubsystems maps simulator actions to the engine subsystem that
+// serves them, for naming bottlenecks.
+var actionSubsystems = map[string]string{
+	"create_post":      "posting",
+	"repost":           "posting",
+	"link_post":        "posting",
+	"comment":          "comments",
+	"reply":            "comments",
+	"edit_comment":     "comments",
+	"upvote_post":      "voting",
+	"upvote_comment":   "voting",
+	"react":            "reactions",
+	"join":             "subscriptions",
+	"subreddit_feed":   "feeds",
+	"engagement":       "feeds",
+	"message":          "messaging",
+	"translate":        "translation",
+	"summarize":        "summaries",
+	"remove_post":      "moderation",
+	"approve_post":     "moderation",
+	"suspend":          "moderation",
+	"appeal_ban":       "moderation",
+	"accept_appeal":    "moderation",
+	"deny_appeal":      "moderation",
+	"set_comment_sort": "comments",
+	"update_profile":   "profiles",
+	"award":            "custom actions",
+}
+
+// CapacityThresholds are the limits a step must stay within to be
+// sustainable.
+type CapacityThresholds struct {
+	P99       time.Duration
+	ErrorRate float64
+}
+
+// CapacityStep is one load level of a capacity plan.
+type CapacityStep struct {
+	Users      int
+	Actions    int
+	ErrorRate  float64
+	P99        time.Duration
+	Slowest    string
+	SlowestP99 time.Duration
+	Duration   time.Duration
+	Exceeded   bool
+}
+
+// CapacityPlan is the outcome of RunCapacityPlan.
+type CapacityPlan struct {
+	Thresholds     CapacityThresholds
+	Steps          []CapacityStep
+	MaxSustainable int
+	Bottleneck     string
+}
+
+// RunCapacityPlan simulates each load step on a fresh engine until a step
+// breaks a threshold. The bottleneck is the subsystem of the slowest action
+// by p99 in the first failing step, or of the action with the most errors if
+// only the error rate was exceeded. Every step's engine is seeded with seed,
+// or from the clock if it is zero.
+func RunCapacityPlan(thresholds CapacityThresholds, steps []int, seed int64) CapacityPlan {
+	plan := CapacityPlan{Thresholds: thresholds}
+	for _, users := range steps {
+		e := engine.New()
+		e.Clock = engine.NewSimClock(time.Now())
+		if seed != 0 {
+			e.Rand = engine.NewRand(seed)
+		}
+		world := GeneratedWorld(max(10, users/100), e.Rand)
+		e.LoadWorld(world)
+		results := &ActionResults{}
+		start := time.Now()
+		SimulateUsers(e, users, world.SubRedditNames(), DefaultActivity, results, nil)
+
+		step := CapacityStep{Users: users, Actions: len(results.Records), Duration: time.Since(start)}
+		latencies := make([]time.Duration, 0, len(results.Records))
+		errors := 0
+		for _, record := range results.Records {
+			latencies = append(latencies, record.Latency)
+			if !record.Success {
+				errors++
+			}
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		step.P99 = percentile(latencies, 0.99)
+		if step.Actions > 0 {
+			step.ErrorRate = float64(errors) / float64(step.Actions)
+		}
+		summaries := results.Summaries()
+		mostErrors, mostErrorCount := "", 0
+		for _, summary := range summaries {
+			if summary.P99 > step.SlowestP99 {
+				step.Slowest, step.SlowestP99 = summary.Action, summary.P99
+			}
+			if summary.Errors > mostErrorCount {
+				mostErrors, mostErrorCount = summary.Action, summary.Errors
+			}
+		}
+		latencyExceeded := step.P99 > thresholds.P99
+		step.Exceeded = latencyExceeded || step.ErrorRate > thresholds.ErrorRate
+		plan.Steps = append(plan.Steps, step)
+		if step.Exceeded {
+			culprit := step.Slowest
+			if !latencyExceeded {
+				culprit = mostErrors
+			}
+			plan.Bottleneck = subsystemOf(culprit)
+			break
+		}
+		plan.MaxSustainable = users
+	}
+	return plan
+}
+
+func subsystemOf(action string) string {
+	if subsystem, exists := actionSubsystems[action]; exists {
+		return subsystem
+	}
+	return action
+}
+
+// PrintCapacityPlan prints every step and the largest sustainable load.
+func PrintCapacityPlan(w io.Writer, plan CapacityPlan) {
+	fmt.Fprintln(w, "Capacity Plan:")
+	fmt.Fprintf(w, "Thresholds: p99 %v, error rate %.1f%%\n", plan.Thresholds.P99, plan.Thresholds.ErrorRate*100)
+	for _, step := range plan.Steps {
+		status := "ok"
+		if step.Exceeded {
+			status = "EXCEEDED"
+		}
+		fmt.Fprintf(w, "%d users: %d actions in %v, p99 %v, error rate %.2f%%, slowest %s (p99 %v) - %s\n", step.Users, step.Actions, step.Duration.Round(time.Millisecond), step.P99, step.ErrorRate*100, step.Slowest, step.SlowestP99, status)
+	}
+	fmt.Fprintf(w, "Max sustainable load: %d users\n", plan.MaxSustainable)
+	if plan.Bottleneck != "" {
+		fmt.Fprintf(w, "First bottleneck: %s\n", plan.Bottleneck)
+	} else {
+		fmt.Fprintln(w, "No threshold exceeded within the planned steps")
+	}
+}
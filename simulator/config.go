@@ -0,0 +1,239 @@
+package simulator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Simulation Configuration
+
+var ErrInvalidSimulationConfig = errors.New("invalid simulation configuration")
+
+// ActivityConfig tunes how often users act in SimulateUsers, and where they
+// act there and in SimulateActors.
+type ActivityConfig struct {
+	// ZipfExponent skews which subreddits users join, post in and browse:
+	// the subreddit ranked i by popularity is drawn with probability
+	// proportional to 1/i^ZipfExponent, and users join those drawn that
+	// interest them most. rand.Zipf needs it to be greater than 1.
+	ZipfExponent float64
+	// CommentProbability is the chance each comment drawn for a new post
+	// is written, and ReplyProbability the same for each reply drawn for a
+	// new comment.
+	CommentProbability float64
+	ReplyProbability   float64
+	// CommentVoteProbability and ReplyVoteProbability are the chances a new
+	// comment or reply is upvoted by a random user.
+	CommentVoteProbability float64
+	ReplyVoteProbability   float64
+	// PileOnProbability is the chance a new comment draws a pile-on of
+	// downvotes.
+	PileOnProbability float64
+	// NecroVoteProbability is the chance each new user upvotes an older
+	// post.
+	NecroVoteProbability float64
+}
+
+var DefaultActivity = ActivityConfig{
+	ZipfExponent:           1.1,
+	CommentProbability:     1,
+	ReplyProbability:       1,
+	CommentVoteProbability: 0.5,
+	ReplyVoteProbability:   0.3,
+	PileOnProbability:      0.08,
+	NecroVoteProbability:   0.3,
+}
+
+// SimulationConfig sizes a simulation run. Duration is how long the
+// concurrent actors run; a zero Seed seeds the engine's Rand from the clock.
+type SimulationConfig struct {
+	Users      int
+	SubReddits int
+	Duration   time.Duration
+	Seed       int64
+	Activity   ActivityConfig
+}
+
+var DefaultSimulationConfig = SimulationConfig{Users: 100, SubReddits: 10, Duration: time.Second, Activity: DefaultActivity}
+
+// SimulationConfigError is returned for a setting LoadSimulationConfig or
+// Validate refuses. It matches ErrInvalidSimulationConfig with errors.Is.
+type SimulationConfigError struct {
+	Setting string
+	Reason  string
+}
+
+func (err *SimulationConfigError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidSimulationConfig, err.Setting, err.Reason)
+}
+
+func (err *SimulationConfigError) Unwrap() error {
+	return ErrInvalidSimulationConfig
+}
+
+// Validate rejects sizes and probabilities a run can't use.
+func (config SimulationConfig) Validate() error {
+	if config.Users < 1 {
+		return &SimulationConfigError{"users", "must be at least 1"}
+	}
+	if config.SubReddits < 1 {
+		return &SimulationConfigError{"subreddits", "must be at least 1"}
+	}
+	if config.Duration < 0 {
+		return &SimulationConfigError{"duration", "can't be negative"}
+	}
+	if config.Activity.ZipfExponent <= 1 {
+		return &SimulationConfigError{"activity.zipf_exponent", "must be greater than 1"}
+	}
+	for _, setting := range config.Activity.probabilities() {
+		if *setting.value < 0 || *setting.value > 1 {
+			return &SimulationConfigError{"activity." + setting.key, "must be between 0 and 1"}
+		}
+	}
+	return nil
+}
+
+type activitySetting struct {
+	key   string
+	value *float64
+}
+
+// probabilities lists the activity probabilities under their config file
+// keys.
+func (activity *ActivityConfig) probabilities() []activitySetting {
+	return []activitySetting{
+		{"comment_probability", &activity.CommentProbability},
+		{"reply_probability", &activity.ReplyProbability},
+		{"comment_vote_probability", &activity.CommentVoteProbability},
+		{"reply_vote_probability", &activity.ReplyVoteProbability},
+		{"pile_on_probability", &activity.PileOnProbability},
+		{"necro_vote_probability", &activity.NecroVoteProbability},
+	}
+}
+
+// LoadSimulationConfig reads a TOML file of simulation settings over
+// DefaultSimulationConfig, so it need only list what it changes:
+//
+//	users = 50000
+//	subreddits = 500
+//	duration = "60s"
+//	seed = 42
+//
+//	[activity]
+//	zipf_exponent = 1.2
+//	comment_vote_probability = 0.4
+//
+// Only the TOML these settings need is understood: comments, one [activity]
+// table and string, integer and float values.
+func LoadSimulationConfig(path string) (SimulationConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return SimulationConfig{}, err
+	}
+	defer file.Close()
+	config, err := parseSimulationConfig(file)
+	if err != nil {
+		return SimulationConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, config.Validate()
+}
+
+func parseSimulationConfig(r io.Reader) (SimulationConfig, error) {
+	config := DefaultSimulationConfig
+	settings := map[string]func(value string) error{
+		"users":      intSetting(&config.Users),
+		"subreddits": intSetting(&config.SubReddits),
+		"duration": func(value string) error {
+			text, err := strconv.Unquote(value)
+			if err != nil {
+				return err
+			}
+			config.Duration, err = time.ParseDuration(text)
+			return err
+		},
+		"seed": func(value string) (err error) {
+			config.Seed, err = strconv.ParseInt(value, 0, 64)
+			return err
+		},
+		"activity.zipf_exponent": floatSetting(&config.Activity.ZipfExponent),
+	}
+	for _, setting := range config.Activity.probabilities() {
+		settings["activity."+setting.key] = floatSetting(setting.value)
+	}
+
+	table := ""
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return config, fmt.Errorf("line %d: malformed table header %q", line, text)
+			}
+			table = strings.TrimSpace(text[1 : len(text)-1])
+			if table != "activity" {
+				return config, fmt.Errorf("line %d: unknown table [%s]", line, table)
+			}
+			continue
+		}
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			return config, fmt.Errorf("line %d: expected key = value", line)
+		}
+		key = strings.TrimSpace(key)
+		if table != "" {
+			key = table + "." + key
+		}
+		apply, known := settings[key]
+		if !known {
+			return config, fmt.Errorf("line %d: unknown setting %s", line, key)
+		}
+		if err := apply(strings.TrimSpace(value)); err != nil {
+			return config, fmt.Errorf("line %d: %s: %w", line, key, err)
+		}
+	}
+	return config, scanner.Err()
+}
+
+// stripComment cuts a # comment off a line, leaving any inside a string.
+func stripComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func intSetting(into *int) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseInt(value, 0, 0)
+		*into = int(parsed)
+		return err
+	}
+}
+
+func floatSetting(into *float64) func(string) error {
+	return func(value string) (err error) {
+		*into, err = strconv.ParseFloat(value, 64)
+		return err
+	}
+}
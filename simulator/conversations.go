@@ -0,0 +1,174 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Simulated Conversations
+
+// ConversationStats describes the threads SimulateConversations grew.
+type ConversationStats struct {
+	Threads      int
+	Comments     int
+	Replies      int
+	MaxDepth     int
+	Participants int
+}
+
+// replyProbability is the chance that each additional reply is added at a
+// given depth. It decays with depth, so a comment has about 1.5 direct replies
+// at the top level and threads rarely go past a handful of levels.
+func replyProbability(depth int) float64 {
+	return 0.6 * math.Pow(0.65, float64(depth))
+}
+
+// SimulateConversations has subscribers of each subreddit comment on up to
+// postsPerSub of its posts and reply to each other, never to themselves.
+// Post authors answer some top-level comments, as in an AMA.
+func SimulateConversations(e *engine.Engine, subRedditNames []string, postsPerSub, maxDepth int, results *ActionResults) ConversationStats {
+	clock, simulated := e.Clock.(*engine.SimClock)
+	participants := make(map[int64]bool)
+	var stats ConversationStats
+	tick := func() {
+		if simulated {
+			clock.Advance(time.Duration(e.Rand.Intn(120)) * time.Second)
+			e.RunScheduled()
+		}
+	}
+
+	react := func(members []*engine.User, comment *engine.Comment) {
+		for e.Rand.Float64() < 0.25 {
+			reactor := pickOtherUser(members, comment.Author, e.Rand)
+			if reactor == nil {
+				return
+			}
+			emoji := engine.DefaultReactions[e.Rand.Intn(len(engine.DefaultReactions))]
+			results.Do("react", reactor, func() error { return e.React(reactor, comment, emoji) })
+		}
+	}
+
+	var grow func(members []*engine.User, comment *engine.Comment, depth int)
+	grow = func(members []*engine.User, comment *engine.Comment, depth int) {
+		react(members, comment)
+		if depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+		if depth >= maxDepth {
+			return
+		}
+		for e.Rand.Float64() < replyProbability(depth) {
+			replier := pickOtherUser(members, comment.Author, e.Rand)
+			if replier == nil {
+				return
+			}
+			tick()
+			var reply *engine.Comment
+			results.Do("reply", replier, func() (err error) {
+				reply, err = e.AddReplyToComment(replier, comment, fmt.Sprintf("%s replying to %s", replier.Username, comment.Author.Username))
+				return err
+			})
+			if reply == nil {
+				continue
+			}
+			stats.Replies++
+			participants[replier.ID] = true
+			grow(members, reply, depth+1)
+		}
+	}
+
+	for _, name := range subRedditNames {
+		subReddit := e.SubReddits[name]
+		members := make([]*engine.User, 0, len(subReddit.Users))
+		for _, user := range subReddit.Users {
+			members = append(members, user)
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+		if len(members) < 2 || len(subReddit.Posts) == 0 {
+			continue
+		}
+		// A few members tune the whole subreddit out
+		for _, member := range members {
+			if e.Rand.Float64() < 0.03 {
+				results.Do("mute", member, func() error { return e.Mute(member, engine.MuteSubRedditTarget(name)) })
+			}
+		}
+		for _, i := range e.Rand.Perm(len(subReddit.Posts))[:min(postsPerSub, len(subReddit.Posts))] {
+			post := subReddit.Posts[i]
+			stats.Threads++
+			if e.Rand.Float64() < 0.1 {
+				results.Do("mute", post.Author, func() error { return e.Mute(post.Author, engine.MutePostTarget(post)) })
+			}
+			for c := 0; c < e.Rand.Intn(4)+1; c++ {
+				commenter := pickOtherUser(members, post.Author, e.Rand)
+				if commenter == nil {
+					break
+				}
+				tick()
+				var comment *engine.Comment
+				results.Do("comment", commenter, func() (err error) {
+					comment, err = e.CommentPost(commenter, post, fmt.Sprintf("%s commenting on post %d", commenter.Username, post.ID))
+					return err
+				})
+				if comment == nil {
+					continue
+				}
+				stats.Comments++
+				participants[commenter.ID] = true
+				// Moderators pin the occasional helpful comment
+				if mod := pickModerator(e, name); mod != nil && e.Rand.Float64() < 0.05 {
+					results.Do("sticky_comment", mod, func() error { return e.StickyComment(mod, post, comment) })
+				}
+				// Drive-by commenters don't want to hear about the replies
+				if e.Rand.Float64() < 0.15 {
+					results.Do("mute", commenter, func() error { return e.Mute(commenter, engine.MuteThreadTarget(comment)) })
+				}
+				if e.Rand.Float64() < 0.3 {
+					tick()
+					var answer *engine.Comment
+					results.Do("reply", post.Author, func() (err error) {
+						answer, err = e.AddReplyToComment(post.Author, comment, fmt.Sprintf("OP answering %s", commenter.Username))
+						return err
+					})
+					if answer != nil {
+						stats.Replies++
+						participants[post.Author.ID] = true
+						grow(members, answer, 2)
+					}
+				}
+				grow(members, comment, 1)
+			}
+		}
+	}
+	stats.Participants = len(participants)
+	return stats
+}
+
+// pickOtherUser returns a random member other than exclude, or nil if there
+// is none.
+func pickOtherUser(members []*engine.User, exclude *engine.User, rng *rand.Rand) *engine.User {
+	for attempt := 0; attempt < 8; attempt++ {
+		if user := members[rng.Intn(len(members))]; user != exclude {
+			return user
+		}
+	}
+	for _, user := range members {
+		if user != exclude {
+			return user
+		}
+	}
+	return nil
+}
+
+// PrintConversationStats prints the size and depth of the simulated
+// threads.
+func PrintConversationStats(w io.Writer, stats ConversationStats) {
+	fmt.Fprintln(w, "\nSimulated Conversations:")
+	fmt.Fprintf(w, "Threads: %d, Comments: %d, Replies: %d, Max depth: %d, Participants: %d\n", stats.Threads, stats.Comments, stats.Replies, stats.MaxDepth, stats.Participants)
+}
@@ -0,0 +1,26 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Default SubReddit Report
+
+// PrintDefaultSubReddits prints the default set and how skewed
+// memberships are across subreddits.
+func PrintDefaultSubReddits(w io.Writer, e *engine.Engine) {
+	defaults := e.GetDefaultSubReddits()
+	if len(defaults) == 0 {
+		return
+	}
+	skew := e.GetMembershipSkew()
+	e.Mutex.RLock()
+	subscribed, optOuts := e.DefaultSubscriptions, e.DefaultOptOuts
+	e.Mutex.RUnlock()
+	fmt.Fprintf(w, "Default SubReddits: r/%s (%d auto-subscriptions, %d users opted out)\n", strings.Join(defaults, "+"), subscribed, optOuts)
+	fmt.Fprintf(w, "Membership Skew: Gini %.2f organic vs %.2f with defaults; defaults hold %.0f%% of organic and %.0f%% of all memberships\n", skew.OrganicGini, skew.TotalGini, skew.DefaultShareOrganic*100, skew.DefaultShareTotal*100)
+}
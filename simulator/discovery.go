@@ -0,0 +1,62 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Discovery Simulation
+
+// DiscoveryReport is the outcome of SimulateDiscovery.
+type DiscoveryReport struct {
+	Share     float64
+	FeedLoads int
+	Users     int
+	Stats     engine.DiscoveryStats
+}
+
+// SimulateDiscovery gives share of every feed's slots to hot posts from
+// subreddits the user doesn't follow and serves feedLoads such feeds per
+// user. A user reads each discovery post and joins its subreddit with a
+// probability that grows with their interest in its topics; the engine
+// tracks which shown subreddits were joined. The share is applied as a
+// configuration change, so one outside [0, 1] is refused.
+func SimulateDiscovery(e *engine.Engine, share float64, feedLoads int) (DiscoveryReport, error) {
+	config := e.GetConfig()
+	config.Discovery.Share = share
+	if _, err := e.ApplyConfig(nil, config); err != nil {
+		return DiscoveryReport{}, err
+	}
+	report := DiscoveryReport{Share: share, FeedLoads: feedLoads}
+	for id := e.IDOffset + 1; id < e.UserID; id++ {
+		user := e.Users[id]
+		if user.MergedInto != 0 || user.Churned {
+			continue
+		}
+		report.Users++
+		for load := 0; load < feedLoads; load++ {
+			feed, discovered := e.GetDiscoveryFeed(user, engine.SortHot)
+			for i, post := range feed {
+				if !discovered[i] {
+					continue
+				}
+				affinity := engine.TopicAffinity(user.InterestProfile, e.SubReddits[post.SubReddit].Topics)
+				if e.Rand.Float64() < 0.02+0.3*affinity {
+					e.JoinSubReddit(user, post.SubReddit)
+				}
+			}
+		}
+	}
+	report.Stats = e.GetDiscoveryStats()
+	return report, nil
+}
+
+// PrintDiscoveryReport prints how many unfollowed subreddits discovery
+// slots showed users and how many of them they joined.
+func PrintDiscoveryReport(w io.Writer, report DiscoveryReport) {
+	stats := report.Stats
+	fmt.Fprintf(w, "\nDiscovery Slots: %.0f%% of %d feeds for each of %d users\n", report.Share*100, report.FeedLoads, report.Users)
+	fmt.Fprintf(w, "Impressions: %d, Subreddits shown to users: %d, Joined after being shown: %d (join rate %.1f%%)\n", stats.Impressions, stats.Reached, stats.Joins, stats.JoinRate*100)
+}
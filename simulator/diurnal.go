@@ -0,0 +1,195 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Diurnal Personas
+
+// DiurnalPattern is when a persona is online. Hourly is the chance of a
+// visit in each hour of a weekday; Weekend scales it on Saturdays and
+// Sundays.
+type DiurnalPattern struct {
+	Hourly  [24]float64
+	Weekend float64
+}
+
+// DiurnalPersonas are the daily rhythms simulated users are given at sign-up.
+var DiurnalPersonas = map[string]DiurnalPattern{
+	"early_bird": {
+		Hourly:  [24]float64{0, 0, 0, 0, 0.02, 0.1, 0.3, 0.35, 0.25, 0.1, 0.05, 0.05, 0.08, 0.05, 0.03, 0.03, 0.03, 0.05, 0.05, 0.03, 0.01, 0, 0, 0},
+		Weekend: 1,
+	},
+	"office_worker": {
+		Hourly:  [24]float64{0, 0, 0, 0, 0, 0, 0.02, 0.08, 0.15, 0.1, 0.08, 0.1, 0.3, 0.2, 0.08, 0.08, 0.1, 0.2, 0.15, 0.1, 0.12, 0.08, 0.03, 0.01},
+		Weekend: 0.5,
+	},
+	"night_owl": {
+		Hourly:  [24]float64{0.3, 0.25, 0.15, 0.05, 0.01, 0, 0, 0, 0, 0, 0.01, 0.02, 0.03, 0.03, 0.03, 0.05, 0.05, 0.08, 0.1, 0.15, 0.2, 0.3, 0.35, 0.35},
+		Weekend: 1.3,
+	},
+	"weekender": {
+		Hourly:  [24]float64{0.02, 0.01, 0, 0, 0, 0, 0, 0, 0.01, 0.02, 0.02, 0.03, 0.04, 0.03, 0.02, 0.02, 0.03, 0.04, 0.05, 0.06, 0.06, 0.05, 0.04, 0.03},
+		Weekend: 6,
+	},
+}
+
+// diurnalPersonaNames lists DiurnalPersonas in a fixed order so persona
+// assignment is reproducible under a seeded rand.
+var diurnalPersonaNames = func() []string {
+	names := make([]string, 0, len(DiurnalPersonas))
+	for name := range DiurnalPersonas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}()
+
+func randomDiurnalPersona(rng *rand.Rand) string {
+	return diurnalPersonaNames[rng.Intn(len(diurnalPersonaNames))]
+}
+
+// visitChance is the chance a user with the given persona visits in the hour
+// starting at t. Personas without a diurnal pattern never visit.
+func visitChance(persona string, t time.Time) float64 {
+	pattern, exists := DiurnalPersonas[persona]
+	if !exists {
+		return 0
+	}
+	chance := pattern.Hourly[t.Hour()]
+	if day := t.Weekday(); day == time.Saturday || day == time.Sunday {
+		chance *= pattern.Weekend
+	}
+	return min(chance, 1)
+}
+
+// VisitReport is the outcome of SimulateVisits. Activity covers only the
+// visit window, so the sign-up burst before it doesn't mask the personas'
+// rhythms.
+type VisitReport struct {
+	Days     int
+	Visits   int
+	Activity engine.ActivityHeatmaps
+}
+
+// SimulateVisits has users return to the site hour by hour for days on the
+// simulated clock, each visiting with their persona's chance for that hour.
+// A visit votes on a few posts at the top of their hot feed, sometimes
+// changing or withdrawing an earlier vote, and sometimes comments on one. It does nothing unless the engine runs on a SimClock.
+func SimulateVisits(e *engine.Engine, days int, results *ActionResults) VisitReport {
+	report := VisitReport{Days: days}
+	clock, simulated := e.Clock.(*engine.SimClock)
+	if !simulated || days <= 0 {
+		return report
+	}
+	e.Mutex.RLock()
+	users := make([]*engine.User, 0, len(e.Users))
+	for _, user := range e.Users {
+		if user.MergedInto == 0 && !user.Churned {
+			users = append(users, user)
+		}
+	}
+	e.Mutex.RUnlock()
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	before := e.GetActivityHeatmaps()
+	for hour := 0; hour < days*24; hour++ {
+		now := clock.Now()
+		for _, user := range users {
+			if e.Rand.Float64() >= visitChance(user.Persona, now) {
+				continue
+			}
+			report.Visits++
+			feed := e.GetSortedFeed(user, engine.SortHot)
+			for _, post := range feed[:min(e.Rand.Intn(4), len(feed))] {
+				delta := 1
+				if e.Rand.Float64() < 0.2 {
+					delta = -1
+				}
+				// Seen before: usually leave the vote be, now and then
+				// take it back or change their mind.
+				if current := e.GetPostVote(user, post); current != 0 {
+					switch r := e.Rand.Float64(); {
+					case r < 0.1:
+						results.Do("visit_unvote", user, func() error { return e.UnvotePost(user, post) })
+						continue
+					case r < 0.2:
+						delta = -current
+					default:
+						continue
+					}
+				}
+				results.Do("visit_vote", user, func() error { return e.CastPostVote(user, post, delta, "") })
+			}
+			if len(feed) > 0 && e.Rand.Float64() < 0.2 {
+				post := feed[e.Rand.Intn(min(5, len(feed)))]
+				results.Do("comment", user, func() error {
+					_, err := e.CommentPost(user, post, simulatedContent(fmt.Sprintf("Dropping by post %d", post.ID), e.Rand))
+					return err
+				})
+			}
+		}
+		clock.Advance(time.Hour)
+		e.RunScheduled()
+	}
+	report.Activity = heatmapsSince(e.GetActivityHeatmaps(), before)
+	return report
+}
+
+// heatmapsSince is the activity in after that wasn't in before yet.
+func heatmapsSince(after, before engine.ActivityHeatmaps) engine.ActivityHeatmaps {
+	since := func(a, b engine.ActivityHeatmap) engine.ActivityHeatmap {
+		for day := range a {
+			for hour := range a[day] {
+				a[day][hour] -= b[day][hour]
+			}
+		}
+		return a
+	}
+	after.Site = since(after.Site, before.Site)
+	for persona, heatmap := range after.Personas {
+		after.Personas[persona] = since(heatmap, before.Personas[persona])
+	}
+	for username, heatmap := range after.Users {
+		after.Users[username] = since(heatmap, before.Users[username])
+	}
+	return after
+}
+
+// PrintVisitReport prints the visit window's activity as a day-by-hour grid
+// of action counts, with each persona's busiest hour.
+func PrintVisitReport(w io.Writer, report VisitReport) {
+	fmt.Fprintf(w, "\nReturn Visits: %d over %d simulated days\n", report.Visits, report.Days)
+	fmt.Fprintln(w, "Activity Heatmap (actions by day and hour):")
+	fmt.Fprint(w, "   ")
+	for hour := 0; hour < 24; hour++ {
+		fmt.Fprintf(w, "%4d", hour)
+	}
+	fmt.Fprintln(w)
+	for day, counts := range report.Activity.Site {
+		fmt.Fprintf(w, "%s", time.Weekday(day).String()[:3])
+		for _, count := range counts {
+			fmt.Fprintf(w, "%4d", count)
+		}
+		fmt.Fprintln(w)
+	}
+	personas := make([]string, 0, len(report.Activity.Personas))
+	for persona := range report.Activity.Personas {
+		personas = append(personas, persona)
+	}
+	sort.Strings(personas)
+	for _, persona := range personas {
+		heatmap := report.Activity.Personas[persona]
+		if heatmap.Total() == 0 {
+			continue
+		}
+		day, hour := heatmap.Peak()
+		fmt.Fprintf(w, "%s: %d actions, busiest %s %02d:00\n", persona, heatmap.Total(), day, hour)
+	}
+}
@@ -0,0 +1,29 @@
+package simulator
+
+import "github.com/sahasgundapaneni/reddit-clone/engine"
+
+// Follows and Message Requests
+
+// simulateFollow has user follow someone they just messaged, and that
+// person sometimes follow back, which settles the pair's message request.
+func simulateFollow(e *engine.Engine, user, target *engine.User, results *ActionResults) {
+	if e.Rand.Float64() < 0.3 && !e.IsFollowing(user, target) {
+		results.Do("follow", user, func() error { return e.FollowUser(user, target) })
+	}
+	if e.Rand.Float64() < 0.3 && !e.IsFollowing(target, user) {
+		results.Do("follow", target, func() error { return e.FollowUser(target, user) })
+	}
+}
+
+// reviewDMRequests has user work through their message requests: spammers'
+// are always declined and most others accepted.
+func reviewDMRequests(e *engine.Engine, user *engine.User, results *ActionResults) {
+	for _, request := range e.GetDMRequests(user) {
+		from := request.From
+		if from.Persona == engine.PersonaSpammer || e.Rand.Float64() < 0.2 {
+			results.Do("decline_dm_request", user, func() error { return e.DeclineDMRequest(user, from) })
+		} else {
+			results.Do("accept_dm_request", user, func() error { return e.AcceptDMRequest(user, from) })
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package simulator drives an engine.Engine with simulated users and runs
+// the experiments and load tests built on it: brigades, onboarding, capacity
+// planning, throughput targets and tenants. It uses only the engine's
+// exported API, and SimControl exposes a running simulation over HTTP.
+// SimulateUsers signs users up one at a time; SimulateActors then runs each
+// of them on its own goroutine to load the engine concurrently.
+// Scripted bots and the remote load test are the exception: they reach the
+// engine only through the api package's client, as outside clients would.
+// The Print functions write each report to any io.Writer; per-action logs go
+// to the engine's Logger at debug level.
+package simulator
@@ -0,0 +1,74 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Retention Experiment
+
+// runRetentionExperiment replays rounds of feed reads for every user under one
+// ranking and returns the fraction still active after each round. It keeps
+// its own engagement state, so several rankings can be compared on the same
+// world.
+func runRetentionExperiment(e *engine.Engine, order engine.FeedSort, rounds int) []float64 {
+	users := make([]*engine.User, 0, len(e.Users))
+	for _, user := range e.Users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	engagement := make(map[int64]float64, len(users))
+	relevance := make(map[int64]float64, len(users))
+	signals := make(map[int64]engine.EngagementSignals, len(users))
+	for _, user := range users {
+		engagement[user.ID] = engine.InitialEngagement
+		relevance[user.ID] = e.FeedRelevance(user, e.GetSortedFeed(user, order))
+		signals[user.ID] = e.EngagementSignalsFor(user, engine.SortNew)
+	}
+	active := len(users)
+	churned := make(map[int64]bool, len(users))
+	curve := make([]float64, 0, rounds)
+	for round := 0; round < rounds; round++ {
+		for _, user := range users {
+			if churned[user.ID] {
+				continue
+			}
+			s := signals[user.ID]
+			s.FeedRelevance = relevance[user.ID]
+			engagement[user.ID] = engine.NextEngagement(engagement[user.ID], s)
+			if e.Rand.Float64() < engine.ChurnProbability(engagement[user.ID]) {
+				churned[user.ID] = true
+				active--
+			}
+		}
+		curve = append(curve, float64(active)/math.Max(1, float64(len(users))))
+	}
+	return curve
+}
+
+var feedSortNames = map[engine.FeedSort]string{
+	engine.SortNew:           "new",
+	engine.SortHot:           "hot",
+	engine.SortPersonalized:  "personalized",
+	engine.SortBest:          "best",
+	engine.SortKarmaWeighted: "karma-weighted",
+	engine.SortControversial: "controversial",
+}
+
+// PrintRetentionCurves runs RunRetentionExperiment for each feed sort and
+// prints the share of users retained after each round.
+func PrintRetentionCurves(w io.Writer, e *engine.Engine, rounds int) {
+	fmt.Fprintln(w, "\nRetention by Feed Ranking:")
+	for _, order := range []engine.FeedSort{engine.SortNew, engine.SortHot, engine.SortPersonalized} {
+		curve := runRetentionExperiment(e, order, rounds)
+		fmt.Fprintf(w, "%-12s", feedSortNames[order])
+		for _, retained := range curve {
+			fmt.Fprintf(w, " %3.0f%%", retained*100)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,53 @@
+package simulator
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Personalization Experiment
+
+// simulatedEngagement estimates how many posts a user would engage with in
+// the top positions of a feed: each post is clicked with probability equal
+// to the user's affinity for its subreddit, discounted by position.
+func simulatedEngagement(affinity map[string]float64, feed []*engine.Post, positions int, rng *rand.Rand) float64 {
+	engagement := 0.0
+	for rank, post := range feed {
+		if rank >= positions {
+			break
+		}
+		p := (0.05 + 0.95*affinity[post.SubReddit]) / math.Log2(float64(rank)+2)
+		if rng.Float64() < p {
+			engagement++
+		}
+	}
+	return engagement
+}
+
+// EngagementReport compares mean engagement under the hot and personalized
+// feeds.
+type EngagementReport struct {
+	UsersSampled           int
+	HotEngagement          float64
+	PersonalizedEngagement float64
+}
+
+// EvaluatePersonalization compares average simulated engagement between the
+// hot and personalized sorts for a sample of users.
+func EvaluatePersonalization(e *engine.Engine, samples, positions int) EngagementReport {
+	report := EngagementReport{}
+	for i := 0; i < samples && len(e.Users) > 0; i++ {
+		user := e.Users[RandomUserID(e)]
+		affinity := e.GetInterestVector(user)
+		report.HotEngagement += simulatedEngagement(affinity, e.GetSortedFeed(user, engine.SortHot), positions, e.Rand)
+		report.PersonalizedEngagement += simulatedEngagement(affinity, e.GetSortedFeed(user, engine.SortPersonalized), positions, e.Rand)
+		report.UsersSampled++
+	}
+	if report.UsersSampled > 0 {
+		report.HotEngagement /= float64(report.UsersSampled)
+		report.PersonalizedEngagement /= float64(report.UsersSampled)
+	}
+	return report
+}
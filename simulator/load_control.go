@@ -0,0 +1,224 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Throughput Target Mode
+
+const (
+	paceInterval       = 10 * time.Millisecond
+	controlInterval    = 100 * time.Millisecond
+	maxTargetWorkers   = 256
+	sustainedTolerance = 0.95
+)
+
+// ThroughputTargetResult is the outcome of RunThroughputTarget.
+type ThroughputTargetResult struct {
+	TargetRate   float64
+	AchievedRate float64
+	Sustained    bool
+	Actions      int64
+	Dropped      int64
+	PeakWorkers  int
+	FinalWorkers int
+	P50Latency   time.Duration
+	P95Latency   time.Duration
+	P99Latency   time.Duration
+}
+
+type targetPostPool struct {
+	mu    sync.Mutex
+	posts []*engine.Post
+}
+
+func (p *targetPostPool) add(post *engine.Post) {
+	p.mu.Lock()
+	p.posts = append(p.posts, post)
+	p.mu.Unlock()
+}
+
+func (p *targetPostPool) random(rng *rand.Rand) *engine.Post {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.posts) == 0 {
+		return nil
+	}
+	return p.posts[rng.Intn(len(p.posts))]
+}
+
+// RunThroughputTarget drives random actions against the engine at targetRate
+// actions/sec for the given duration. A pacer releases tokens at the target
+// rate and a controller grows or shrinks the worker set based on the token
+// backlog, so the engine is pushed no harder than the target requires.
+func RunThroughputTarget(e *engine.Engine, targetRate float64, duration time.Duration) ThroughputTargetResult {
+	result := ThroughputTargetResult{TargetRate: targetRate}
+	if targetRate <= 0 || len(e.Users) == 0 || len(e.SubReddits) == 0 {
+		return result
+	}
+
+	users := make([]*engine.User, 0, len(e.Users))
+	for _, user := range e.Users {
+		users = append(users, user)
+	}
+	subNames := make([]string, 0, len(e.SubReddits))
+	for name := range e.SubReddits {
+		subNames = append(subNames, name)
+	}
+
+	tokens := make(chan struct{}, int(targetRate)+1)
+	var actions, dropped int64
+	var latMu sync.Mutex
+	var latencies []time.Duration
+	pool := &targetPostPool{}
+
+	var wg sync.WaitGroup
+	var quits []chan struct{}
+	startWorker := func() {
+		quit := make(chan struct{})
+		quits = append(quits, quit)
+		wg.Add(1)
+		rng := rand.New(rand.NewSource(e.Rand.Int63()))
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-quit:
+					return
+				case _, ok := <-tokens:
+					if !ok {
+						return
+					}
+					start := time.Now()
+					performTargetAction(e, users, subNames, pool, rng)
+					elapsed := time.Since(start)
+					atomic.AddInt64(&actions, 1)
+					latMu.Lock()
+					latencies = append(latencies, elapsed)
+					latMu.Unlock()
+				}
+			}
+		}()
+	}
+	stopWorker := func() {
+		last := len(quits) - 1
+		close(quits[last])
+		quits = quits[:last]
+	}
+
+	startWorker()
+	result.PeakWorkers = 1
+
+	pace := time.NewTicker(paceInterval)
+	control := time.NewTicker(controlInterval)
+	defer pace.Stop()
+	defer control.Stop()
+
+	perTick := targetRate * paceInterval.Seconds()
+	credit := 0.0
+	idleTicks := 0
+	start := time.Now()
+	deadline := start.Add(duration)
+	for time.Now().Before(deadline) {
+		select {
+		case <-pace.C:
+			credit += perTick
+			for credit >= 1 {
+				credit--
+				select {
+				case tokens <- struct{}{}:
+				default:
+					dropped++
+				}
+			}
+		case <-control.C:
+			backlog := len(tokens)
+			switch {
+			case float64(backlog) > perTick && len(quits) < maxTargetWorkers:
+				startWorker()
+				idleTicks = 0
+			case backlog == 0 && len(quits) > 1:
+				idleTicks++
+				if idleTicks >= 3 {
+					stopWorker()
+					idleTicks = 0
+				}
+			default:
+				idleTicks = 0
+			}
+			if len(quits) > result.PeakWorkers {
+				result.PeakWorkers = len(quits)
+			}
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+	result.FinalWorkers = len(quits)
+	close(tokens)
+	wg.Wait()
+
+	result.Actions = atomic.LoadInt64(&actions)
+	result.Dropped = dropped
+	result.AchievedRate = float64(result.Actions) / elapsed
+	result.Sustained = result.AchievedRate >= targetRate*sustainedTolerance
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50Latency = percentile(latencies, 0.50)
+	result.P95Latency = percentile(latencies, 0.95)
+	result.P99Latency = percentile(latencies, 0.99)
+	return result
+}
+
+func performTargetAction(e *engine.Engine, users []*engine.User, subNames []string, pool *targetPostPool, rng *rand.Rand) {
+	user := users[rng.Intn(len(users))]
+	switch r := rng.Float64(); {
+	case r < 0.25:
+		if post, err := e.CreatePost(user, subNames[rng.Intn(len(subNames))], fmt.Sprintf("Target post from %s", user.Username)); err == nil {
+			pool.add(post)
+		}
+	case r < 0.70:
+		if post := pool.random(rng); post != nil {
+			if rng.Float64() < 0.8 {
+				e.UpvotePost(user, post)
+			} else {
+				e.DownvotePost(user, post)
+			}
+		}
+	case r < 0.90:
+		if post := pool.random(rng); post != nil {
+			e.CommentPost(user, post, fmt.Sprintf("Target comment from %s", user.Username))
+		}
+	default:
+		to := users[rng.Intn(len(users))]
+		if to != user {
+			e.SendDirectMessage(user, to, fmt.Sprintf("Target message from %s", user.Username))
+		}
+	}
+}
+
+// percentile expects latencies sorted in ascending order.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
+
+// PrintThroughputTargetResult prints the rate achieved against the target.
+func PrintThroughputTargetResult(w io.Writer, result ThroughputTargetResult) {
+	fmt.Fprintln(w, "\nThroughput Target Mode:")
+	fmt.Fprintf(w, "Target Rate (actions/sec): %.2f\n", result.TargetRate)
+	fmt.Fprintf(w, "Achieved Rate (actions/sec): %.2f\n", result.AchievedRate)
+	fmt.Fprintf(w, "Sustained: %t\n", result.Sustained)
+	fmt.Fprintf(w, "Actions: %d (dropped tokens: %d)\n", result.Actions, result.Dropped)
+	fmt.Fprintf(w, "Workers: peak %d, final %d\n", result.PeakWorkers, result.FinalWorkers)
+	fmt.Fprintf(w, "Latency p50: %v, p95: %v, p99: %v\n", result.P50Latency, result.P95Latency, result.P99Latency)
+}
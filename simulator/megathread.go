@@ -0,0 +1,213 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Live Megathreads
+
+// ErrNoHost is returned when a subreddit has no member to host a megathread.
+var ErrNoHost = errors.New("no member to host the megathread")
+
+// MegathreadReport is the outcome of SimulateMegathread. Comments and Errors
+// count the commenters' attempts; Received is what the watchers read off
+// their streams, which Delivered should match once they have drained, and
+// Reordered counts comments a watcher received after a later one.
+type MegathreadReport struct {
+	SubReddit  string
+	PostID     int64
+	Duration   time.Duration
+	Commenters int
+	Watchers   int
+	Comments   int
+	Errors     int
+	Throughput float64
+	P50        time.Duration
+	P99        time.Duration
+	Stats      engine.LiveThreadStats
+	Received   int64
+	Reordered  int64
+}
+
+// SimulateMegathread models a game-day megathread: a moderator of the
+// subreddit (or, failing that, a member) posts a thread and takes it live,
+// watchers subscribe to it, and every active member comments and replies on
+// it concurrently for duration. The commenters hammer a single post's comment
+// path, so the report shows how that write path holds up under a pile-on and
+// whether the watchers kept up with it.
+func SimulateMegathread(e *engine.Engine, subRedditName string, watchers int, duration time.Duration, results *ActionResults) (MegathreadReport, error) {
+	report := MegathreadReport{SubReddit: subRedditName, Duration: duration}
+	e.Mutex.RLock()
+	subReddit, exists := e.SubReddits[subRedditName]
+	var host *engine.User
+	var commenters []*engine.User
+	if exists {
+		for _, moderator := range subReddit.Moderators {
+			if host == nil || moderator.ID < host.ID {
+				host = moderator
+			}
+		}
+		for _, user := range subReddit.Users {
+			if user.MergedInto == 0 && !user.Churned {
+				commenters = append(commenters, user)
+			}
+		}
+		sort.Slice(commenters, func(i, j int) bool { return commenters[i].ID < commenters[j].ID })
+	}
+	e.Mutex.RUnlock()
+	if !exists {
+		return report, engine.ErrSubRedditNotFound
+	}
+	sort.Slice(commenters, func(i, j int) bool { return commenters[i].ID < commenters[j].ID })
+	if host == nil {
+		if len(commenters) == 0 {
+			return report, ErrNoHost
+		}
+		host = commenters[0]
+	}
+
+	var post *engine.Post
+	err := results.Do("create_post", host, func() (err error) {
+		post, err = e.CreatePost(host, subRedditName, simulatedContent("Game day megathread", e.Rand))
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+	report.PostID = post.ID
+	if err := results.Do("start_live_thread", host, func() error { return e.StartLiveThread(host, post) }); err != nil {
+		return report, err
+	}
+
+	report.Watchers = max(watchers, 0)
+	received := make([]int64, report.Watchers)
+	reordered := make([]int64, report.Watchers)
+	var watching sync.WaitGroup
+	for i := 0; i < report.Watchers; i++ {
+		stream, err := e.SubscribeLiveThread(post, 64)
+		if err != nil {
+			return report, err
+		}
+		watching.Add(1)
+		go func(received, reordered *int64) {
+			defer watching.Done()
+			var last int64
+			for comment := range stream.C {
+				*received++
+				if comment.Seq < last {
+					*reordered++
+				}
+				last = comment.Seq
+			}
+		}(&received[i], &reordered[i])
+	}
+
+	report.Commenters = len(commenters)
+	pool := &actorPool{posts: []*engine.Post{post}}
+	start, stop := make(chan struct{}), make(chan struct{})
+	tallies := make([]actorTally, len(commenters))
+	var commenting sync.WaitGroup
+	for i, user := range commenters {
+		commenting.Add(1)
+		go func(tally *actorTally, rng *rand.Rand) {
+			defer commenting.Done()
+			<-start
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				began := time.Now()
+				err := megathreadComment(e, user, post, pool, rng, results)
+				tally.latencies = append(tally.latencies, time.Since(began))
+				tally.actions++
+				if err != nil {
+					tally.errors++
+				}
+			}
+		}(&tallies[i], rand.New(rand.NewSource(e.Rand.Int63())))
+	}
+	began := time.Now()
+	close(start)
+	time.Sleep(duration)
+	close(stop)
+	commenting.Wait()
+	elapsed := time.Since(began)
+
+	if report.Stats, err = e.GetLiveThreadStats(post); err != nil {
+		return report, err
+	}
+	if err := results.Do("end_live_thread", host, func() error { return e.EndLiveThread(host, post) }); err != nil {
+		return report, err
+	}
+	watching.Wait()
+
+	var latencies []time.Duration
+	for _, tally := range tallies {
+		report.Comments += tally.actions
+		report.Errors += tally.errors
+		latencies = append(latencies, tally.latencies...)
+	}
+	for i := range received {
+		report.Received += received[i]
+		report.Reordered += reordered[i]
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.P50 = percentile(latencies, 0.50)
+	report.P99 = percentile(latencies, 0.99)
+	report.Throughput = float64(report.Stats.Comments) / elapsed.Seconds()
+	return report, nil
+}
+
+// LargestSubReddit returns the subreddit with the most members, the first
+// by name among ties, or "" if there are none.
+func LargestSubReddit(e *engine.Engine) string {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	largest, members := "", -1
+	for name, subReddit := range e.SubReddits {
+		if n := len(subReddit.Users); n > members || (n == members && name < largest) {
+			largest, members = name, n
+		}
+	}
+	return largest
+}
+
+// megathreadComment posts one comment on the megathread as user, replying
+// to an earlier comment about a third of the time.
+func megathreadComment(e *engine.Engine, user *engine.User, post *engine.Post, pool *actorPool, rng *rand.Rand, results *ActionResults) error {
+	if parent := pool.randomComment(rng); parent != nil && rng.Float64() < 0.3 {
+		return results.Do("reply", user, func() error {
+			reply, err := e.AddReplyToComment(user, parent, simulatedContent(fmt.Sprintf("Live reply from %s", user.Username), rng))
+			if err == nil {
+				pool.addComment(reply)
+			}
+			return err
+		})
+	}
+	return results.Do("comment", user, func() error {
+		comment, err := e.CommentPost(user, post, simulatedContent(fmt.Sprintf("Live comment from %s", user.Username), rng))
+		if err == nil {
+			pool.addComment(comment)
+		}
+		return err
+	})
+}
+
+// PrintMegathreadReport prints how fast the megathread took comments and
+// how well its watchers kept up.
+func PrintMegathreadReport(w io.Writer, report MegathreadReport) {
+	fmt.Fprintf(w, "\nLive Megathread: post %d in %s, %d commenters and %d watchers for %v\n", report.PostID, report.SubReddit, report.Commenters, report.Watchers, report.Duration)
+	fmt.Fprintf(w, "Comments: %d attempted (%d errors), %d posted by %d participants, %.2f comments/sec\n", report.Comments, report.Errors, report.Stats.Comments, report.Stats.Participants, report.Throughput)
+	fmt.Fprintf(w, "Comment latency p50 %v, p99 %v\n", report.P50, report.P99)
+	fmt.Fprintf(w, "Streamed: %d delivered, %d dropped for slow watchers, %d received (%d out of order)\n", report.Stats.Delivered, report.Stats.Dropped, report.Received, report.Reordered)
+}
@@ -0,0 +1,160 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Onboarding Experiment
+
+// onboardingSlate returns count subreddits to show a new user. quality in
+// [0, 1] is the share of slots filled from RecommendSubReddits; the rest are
+// random subreddits, as a site without recommendations would show.
+func onboardingSlate(e *engine.Engine, user *engine.User, count int, quality float64) []engine.SubRedditRecommendation {
+	recommended := e.RecommendSubReddits(user, count)
+	candidates := e.RecommendSubReddits(user, math.MaxInt)
+	e.Rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	slate := make([]engine.SubRedditRecommendation, 0, count)
+	shown := make(map[string]bool, count)
+	for _, recommendation := range recommended {
+		if float64(len(slate)) >= quality*float64(count) {
+			break
+		}
+		slate = append(slate, recommendation)
+		shown[recommendation.SubReddit] = true
+	}
+	for _, candidate := range candidates {
+		if len(slate) >= count {
+			break
+		}
+		if !shown[candidate.SubReddit] {
+			slate = append(slate, candidate)
+		}
+	}
+	return slate
+}
+
+// OnboardingConfig sets up RunOnboardingExperiment.
+type OnboardingConfig struct {
+	// Users is how many organic users are simulated before any cohort
+	// arrives.
+	Users int
+	// Each quality level gets a cohort of CohortSize new users, each shown
+	// Shown subreddits and then followed for Rounds feed visits.
+	CohortSize int
+	Shown      int
+	Rounds     int
+	Qualities  []float64
+	// Seed seeds the experiment's engine; zero seeds it from the clock.
+	Seed int64
+}
+
+var DefaultOnboardingConfig = OnboardingConfig{Users: 1000, CohortSize: 200, Shown: 5, Rounds: 10, Qualities: []float64{0, 0.5, 1}}
+
+// OnboardingCohort is how one cohort of new users fared. Subscriptions and
+// Relevance are means over the cohort.
+type OnboardingCohort struct {
+	Quality       float64
+	Subscriptions float64
+	Relevance     float64
+	// Retention is the share of the cohort still active after each round
+	// and Activity the mean posts engaged with per user per round, counting
+	// churned users as zero.
+	Retention []float64
+	Activity  []float64
+}
+
+// RunOnboardingExperiment simulates an organic community on a fresh engine,
+// then brings in one cohort of new users per quality level. Each user is
+// onboarded with a slate of that quality and then repeatedly reads their hot
+// feed: engagement and churn evolve as in ApplyEngagement, and activity is
+// estimated by simulatedEngagement with the user's topic affinities.
+func RunOnboardingExperiment(config OnboardingConfig) []OnboardingCohort {
+	e := engine.New()
+	clock := engine.NewSimClock(time.Now())
+	e.Clock = clock
+	if config.Seed != 0 {
+		e.Rand = engine.NewRand(config.Seed)
+	}
+	world := GeneratedWorld(max(10, config.Users/100), e.Rand)
+	e.LoadWorld(world)
+	SimulateUsers(e, config.Users, world.SubRedditNames(), DefaultActivity, &ActionResults{}, nil)
+	affinity := make(map[int64]map[string]float64)
+
+	var cohorts []OnboardingCohort
+	for q, quality := range config.Qualities {
+		cohort := OnboardingCohort{Quality: quality}
+		var users []*engine.User
+		for i := 0; i < config.CohortSize; i++ {
+			user, err := e.RegisterUser(fmt.Sprintf("Newcomer%d_%d", q+1, i+1))
+			if err != nil {
+				break
+			}
+			e.SetInterestProfile(user, randomInterestProfile(e.Rand))
+			outcome := e.OnboardUser(user, onboardingSlate(e, user, config.Shown, quality))
+			cohort.Subscriptions += float64(len(outcome.Joined))
+			cohort.Relevance += outcome.Relevance
+			users = append(users, user)
+			affinity[user.ID] = subRedditAffinity(e, user)
+		}
+		if len(users) == 0 {
+			break
+		}
+		cohort.Subscriptions /= float64(len(users))
+		cohort.Relevance /= float64(len(users))
+
+		for round := 0; round < config.Rounds; round++ {
+			active, engaged := 0, 0.0
+			for _, user := range users {
+				if user.Churned {
+					continue
+				}
+				engaged += simulatedEngagement(affinity[user.ID], e.GetSortedFeed(user, engine.SortHot), engine.FeedRelevanceDepth, e.Rand)
+				if !e.ApplyEngagement(user, e.EngagementSignalsFor(user, engine.SortHot)) {
+					active++
+				}
+			}
+			cohort.Retention = append(cohort.Retention, float64(active)/float64(len(users)))
+			cohort.Activity = append(cohort.Activity, engaged/float64(len(users)))
+			clock.Advance(time.Hour)
+		}
+		cohorts = append(cohorts, cohort)
+	}
+	return cohorts
+}
+
+// subRedditAffinity is the user's declared topic affinity for every
+// subreddit.
+func subRedditAffinity(e *engine.Engine, user *engine.User) map[string]float64 {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	affinity := make(map[string]float64, len(e.SubReddits))
+	for name, subReddit := range e.SubReddits {
+		affinity[name] = engine.TopicAffinity(user.InterestProfile, subReddit.Topics)
+	}
+	return affinity
+}
+
+// PrintOnboardingReport prints each cohort's retention and activity by
+// round.
+func PrintOnboardingReport(w io.Writer, config OnboardingConfig, cohorts []OnboardingCohort) {
+	fmt.Fprintln(w, "Onboarding Experiment:")
+	fmt.Fprintf(w, "%d organic users; cohorts of %d new users shown %d subreddits, followed for %d rounds\n", config.Users, config.CohortSize, config.Shown, config.Rounds)
+	fmt.Fprintf(w, "%-8s %8s %10s %10s %10s %10s\n", "Quality", "Joined", "Relevance", "Activity", "Halfway", "Final")
+	for _, cohort := range cohorts {
+		activity := 0.0
+		for _, engaged := range cohort.Activity {
+			activity += engaged / float64(len(cohort.Activity))
+		}
+		halfway, final := 0.0, 0.0
+		if n := len(cohort.Retention); n > 0 {
+			halfway, final = cohort.Retention[(n-1)/2], cohort.Retention[n-1]
+		}
+		fmt.Fprintf(w, "%-8s %8.2f %10.2f %10.2f %9.0f%% %9.0f%%\n", fmt.Sprintf("%.0f%%", cohort.Quality*100), cohort.Subscriptions, cohort.Relevance, activity, halfway*100, final*100)
+	}
+}
@@ -0,0 +1,49 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Promoted Post Simulation
+
+// SimulatePromotions has some authors promote their posts, then serves
+// feedLoads promoted feeds per user. Users click promoted posts with a
+// probability that grows with their interest in the post's subreddit.
+func SimulatePromotions(e *engine.Engine, feedLoads int) engine.PromotionStats {
+	names := make([]string, 0, len(e.SubReddits))
+	for name := range e.SubReddits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, post := range e.SubReddits[name].Posts {
+			if e.Rand.Float64() < 0.05 {
+				e.PromotePost(post.Author, post, 20+e.Rand.Intn(80), 1+e.Rand.Float64()*9)
+			}
+		}
+	}
+	for id := e.IDOffset + 1; id < e.UserID; id++ {
+		user := e.Users[id]
+		affinity := e.GetInterestVector(user)
+		for load := 0; load < feedLoads; load++ {
+			feed, promoted := e.GetPromotedFeed(user, engine.SortHot)
+			for i, post := range feed {
+				if promoted[i] && e.Rand.Float64() < 0.01+0.2*affinity[post.SubReddit] {
+					e.RecordPromotionClick(post)
+				}
+			}
+		}
+	}
+	return e.GetPromotionStats()
+}
+
+// PrintPromotionStats prints promoted post delivery and spend.
+func PrintPromotionStats(w io.Writer, stats engine.PromotionStats) {
+	fmt.Fprintln(w, "\nPromoted Posts:")
+	fmt.Fprintf(w, "Promotions: %d (active: %d), Impressions: %d, Clicks: %d, CTR: %.2f%%\n", stats.Promotions, stats.Active, stats.Impressions, stats.Clicks, stats.CTR*100)
+	fmt.Fprintf(w, "Slots offered: %d, filled: %d (fill rate %.1f%%), Spend: $%.2f, eCPM: $%.2f, eCPC: $%.2f\n", stats.SlotsOffered, stats.SlotsFilled, stats.FillRate*100, stats.Spend, stats.ECPM, stats.ECPC)
+}
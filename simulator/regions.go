@@ -0,0 +1,91 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Regional Latency Simulation
+
+// RegionalLatencyReport compares latency of actions in the user's own
+// region with cross-region ones.
+type RegionalLatencyReport struct {
+	LocalActions int
+	CrossActions int
+	LocalP99     time.Duration
+	CrossP99     time.Duration
+	OverallP99   time.Duration
+	PerRegionP99 map[string]time.Duration
+}
+
+// SimulateRegionalLatency performs samples posts from random users into random
+// subreddits and combines the measured engine latency with the modeled network
+// latency, splitting the results by whether the action crossed regions.
+func SimulateRegionalLatency(e *engine.Engine, model *engine.LatencyModel, samples int) RegionalLatencyReport {
+	report := RegionalLatencyReport{PerRegionP99: make(map[string]time.Duration)}
+	if len(e.Users) == 0 || len(e.SubReddits) == 0 {
+		return report
+	}
+	users := make([]*engine.User, 0, len(e.Users))
+	for _, user := range e.Users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	subReddits := make([]*engine.SubReddit, 0, len(e.SubReddits))
+	for _, subReddit := range e.SubReddits {
+		subReddits = append(subReddits, subReddit)
+	}
+	sort.Slice(subReddits, func(i, j int) bool { return subReddits[i].Name < subReddits[j].Name })
+
+	var local, cross, all []time.Duration
+	perRegion := make(map[string][]time.Duration)
+	for i := 0; i < samples; i++ {
+		user := users[e.Rand.Intn(len(users))]
+		subReddit := subReddits[e.Rand.Intn(len(subReddits))]
+		start := time.Now()
+		e.CreatePost(user, subReddit.Name, fmt.Sprintf("Regional post from %s", user.Username))
+		latency := time.Since(start) + model.Sample(user.Region, subReddit.HomeRegion)
+		if user.Region == subReddit.HomeRegion {
+			local = append(local, latency)
+		} else {
+			cross = append(cross, latency)
+		}
+		all = append(all, latency)
+		perRegion[user.Region] = append(perRegion[user.Region], latency)
+	}
+
+	for _, latencies := range [][]time.Duration{local, cross, all} {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	}
+	report.LocalActions = len(local)
+	report.CrossActions = len(cross)
+	report.LocalP99 = percentile(local, 0.99)
+	report.CrossP99 = percentile(cross, 0.99)
+	report.OverallP99 = percentile(all, 0.99)
+	for region, latencies := range perRegion {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.PerRegionP99[region] = percentile(latencies, 0.99)
+	}
+	return report
+}
+
+// PrintRegionalLatencyReport prints the local, cross-region and
+// per-region p99 latencies.
+func PrintRegionalLatencyReport(w io.Writer, report RegionalLatencyReport) {
+	fmt.Fprintln(w, "\nRegional Latency:")
+	fmt.Fprintf(w, "Same-region actions: %d, p99: %v\n", report.LocalActions, report.LocalP99)
+	fmt.Fprintf(w, "Cross-region actions: %d, p99: %v\n", report.CrossActions, report.CrossP99)
+	fmt.Fprintf(w, "Overall p99: %v\n", report.OverallP99)
+	regions := make([]string, 0, len(report.PerRegionP99))
+	for region := range report.PerRegionP99 {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		fmt.Fprintf(w, "  %s p99: %v\n", region, report.PerRegionP99[region])
+	}
+}
@@ -0,0 +1,211 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/api"
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Remote Load Test
+
+// RemoteLoadConfig sets up RunRemoteLoadTest.
+type RemoteLoadConfig struct {
+	// Clients is how many simulated users act at once through the API, each
+	// as fast as its calls come back, for Duration per mode.
+	Clients  int
+	Duration time.Duration
+	Batch    api.BatchOptions
+	// Latency is added to every HTTP round trip, standing in for the
+	// network between the remote simulator and the engine.
+	Latency time.Duration
+}
+
+var DefaultRemoteLoadConfig = RemoteLoadConfig{Clients: 64, Duration: time.Second, Batch: api.DefaultBatchOptions, Latency: 2 * time.Millisecond}
+
+// RemoteLoadRun is how one mode of RunRemoteLoadTest fared. Calls are the
+// API calls the clients made and RPCs the HTTP requests the server got for
+// them; Latency is per call, as a client saw it.
+type RemoteLoadRun struct {
+	Batched    bool
+	Calls      int
+	Errors     int
+	RPCs       int
+	Throughput float64
+	P50        time.Duration
+	P99        time.Duration
+}
+
+// CallsPerRPC is the mean number of calls each HTTP request carried.
+func (r RemoteLoadRun) CallsPerRPC() float64 {
+	if r.RPCs == 0 {
+		return 0
+	}
+	return float64(r.Calls) / float64(r.RPCs)
+}
+
+// RemoteLoadReport is the outcome of RunRemoteLoadTest.
+type RemoteLoadReport struct {
+	Config    RemoteLoadConfig
+	Unbatched RemoteLoadRun
+	Batched   RemoteLoadRun
+}
+
+// Speedup is batched throughput over unbatched throughput.
+func (r RemoteLoadReport) Speedup() float64 {
+	if r.Unbatched.Throughput == 0 {
+		return 0
+	}
+	return r.Batched.Throughput / r.Unbatched.Throughput
+}
+
+// RunRemoteLoadTest serves e's REST API on a local test server and drives it
+// the way a remote load generator would: Clients simulated users share one
+// api.Client and vote, comment, post and read threads as fast as their calls
+// return. It runs once sending every call on its own and once with the
+// client batching them, so the two throughputs can be compared.
+func RunRemoteLoadTest(e *engine.Engine, subRedditNames []string, config RemoteLoadConfig) RemoteLoadReport {
+	report := RemoteLoadReport{Config: config}
+	e.Mutex.RLock()
+	users := make([]*engine.User, 0, len(e.Users))
+	for _, user := range e.Users {
+		if user.MergedInto == 0 && !user.Churned {
+			users = append(users, user)
+		}
+	}
+	var postIDs []int64
+	for _, name := range subRedditNames {
+		posts := e.SubReddits[name].Posts
+		for _, post := range posts[max(0, len(posts)-20):] {
+			postIDs = append(postIDs, post.ID)
+		}
+	}
+	e.Mutex.RUnlock()
+	if config.Clients <= 0 || config.Duration <= 0 || len(users) == 0 || len(postIDs) == 0 {
+		return report
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	var rpcs int64
+	handler := api.NewServer(e).Handler()
+	url, stop := serveLoopback(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&rpcs, 1)
+		time.Sleep(config.Latency)
+		handler.ServeHTTP(w, r)
+	}))
+	defer stop()
+
+	for _, batched := range []bool{false, true} {
+		atomic.StoreInt64(&rpcs, 0)
+		run := runRemoteLoad(e, url, users, subRedditNames, postIDs, config, batched)
+		run.RPCs = int(atomic.LoadInt64(&rpcs))
+		if batched {
+			report.Batched = run
+		} else {
+			report.Unbatched = run
+		}
+	}
+	return report
+}
+
+// runRemoteLoad is one mode of RunRemoteLoadTest.
+func runRemoteLoad(e *engine.Engine, baseURL string, users []*engine.User, subRedditNames []string, postIDs []int64, config RemoteLoadConfig, batched bool) RemoteLoadRun {
+	transport := &http.Transport{MaxIdleConnsPerHost: config.Clients}
+	defer transport.CloseIdleConnections()
+	client := api.NewClient(baseURL, "remote-load")
+	client.HTTP = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	// Count every failure rather than hiding it behind a retry.
+	client.Retry.Attempts = 1
+	if batched {
+		client.EnableBatching(config.Batch)
+	}
+
+	run := RemoteLoadRun{Batched: batched}
+	stop := make(chan struct{})
+	tallies := make([]actorTally, config.Clients)
+	var wg sync.WaitGroup
+	for i := range tallies {
+		wg.Add(1)
+		go func(user *engine.User, tally *actorTally, rng *rand.Rand) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				began := time.Now()
+				err := remoteAction(client, user.Username, subRedditNames, postIDs, rng)
+				tally.latencies = append(tally.latencies, time.Since(began))
+				tally.actions++
+				if err != nil {
+					tally.errors++
+				}
+			}
+		}(users[i%len(users)], &tallies[i], rand.New(rand.NewSource(e.Rand.Int63())))
+	}
+	began := time.Now()
+	time.Sleep(config.Duration)
+	close(stop)
+	wg.Wait()
+	client.Close()
+	elapsed := time.Since(began)
+
+	var latencies []time.Duration
+	for _, tally := range tallies {
+		run.Calls += tally.actions
+		run.Errors += tally.errors
+		latencies = append(latencies, tally.latencies...)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	run.Throughput = float64(run.Calls) / elapsed.Seconds()
+	run.P50 = percentile(latencies, 0.5)
+	run.P99 = percentile(latencies, 0.99)
+	return run
+}
+
+// remoteAction is one random API call as user: mostly votes and comments on
+// recent posts, with some new posts and thread reads.
+func remoteAction(client *api.Client, user string, subRedditNames []string, postIDs []int64, rng *rand.Rand) error {
+	postID := postIDs[rng.Intn(len(postIDs))]
+	switch r := rng.Float64(); {
+	case r < 0.45:
+		direction := 1
+		if rng.Float64() < 0.2 {
+			direction = -1
+		}
+		_, err := client.VotePost(user, postID, direction)
+		return err
+	case r < 0.7:
+		_, err := client.CommentPost(user, postID, fmt.Sprintf("Remote comment from %s", user))
+		return err
+	case r < 0.8:
+		_, err := client.CreatePost(user, subRedditNames[rng.Intn(len(subRedditNames))], fmt.Sprintf("Remote post from %s", user))
+		return err
+	default:
+		_, err := client.GetThread(postID)
+		return err
+	}
+}
+
+// PrintRemoteLoadReport prints both modes side by side.
+func PrintRemoteLoadReport(w io.Writer, report RemoteLoadReport) {
+	config := report.Config
+	fmt.Fprintf(w, "\nRemote Load Test: %d clients for %v per mode, %v added per round trip, batches of up to %d flushed every %v\n", config.Clients, config.Duration, config.Latency, config.Batch.Size, config.Batch.FlushInterval)
+	fmt.Fprintf(w, "%-10s %8s %8s %8s %10s %12s %12s %12s\n", "Mode", "Calls", "Errors", "RPCs", "Calls/RPC", "Calls/sec", "p50", "p99")
+	for _, run := range []RemoteLoadRun{report.Unbatched, report.Batched} {
+		mode := "unbatched"
+		if run.Batched {
+			mode = "batched"
+		}
+		fmt.Fprintf(w, "%-10s %8d %8d %8d %10.1f %12.1f %12v %12v\n", mode, run.Calls, run.Errors, run.RPCs, run.CallsPerRPC(), run.Throughput, run.P50, run.P99)
+	}
+	fmt.Fprintf(w, "Batching speedup: %.2fx\n", report.Speedup())
+}
@@ -0,0 +1,369 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/api"
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Simulator Live Control
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidRate      = errors.New("rate must not be negative")
+	ErrInvalidUserCount = errors.New("user count must be positive")
+)
+
+// SimControl steers a running simulation. The simulator calls checkpoint
+// before each new user; operators change what checkpoint does through the
+// admin HTTP handler. Every control action is recorded in the engine's event
+// log so a replay shows when and how the run was steered, traced as a child
+// of the span in the context it is given.
+type SimControl struct {
+	engine  *engine.Engine
+	mu      sync.Mutex
+	resumed *sync.Cond
+	paused  bool
+	// usersPerSecond caps how fast new users arrive in wall-clock time. Zero
+	// leaves the simulation unthrottled.
+	usersPerSecond float64
+	extraUsers     int
+	lastUser       time.Time
+	injected       int
+	idempotency    *api.IdempotencyStore
+	// configFile is the engine configuration POST /config/reload rereads.
+	configFile string
+}
+
+// SimControlStatus is a snapshot of a running simulation.
+type SimControlStatus struct {
+	Paused         bool
+	UsersPerSecond float64
+	PendingUsers   int
+	Injected       int
+	Users          int
+	TotalActions   int
+	// Replays is how many retried requests were answered from the
+	// idempotency store.
+	Replays int
+}
+
+// InjectRequest triggers one action outside the simulator's own schedule.
+// Type is post, comment, upvote, downvote or the name of a registered custom
+// action. A zero UserID picks a random user.
+type InjectRequest struct {
+	Type      string
+	UserID    int64
+	SubReddit string
+	TargetID  int64
+	Content   string
+}
+
+// InjectReceipt reports what an injected action did. PostID and CommentID
+// identify what was created or voted on, and Votes is the post's score after
+// a vote.
+type InjectReceipt struct {
+	Type      string
+	UserID    int64
+	PostID    int64 `json:",omitempty"`
+	CommentID int64 `json:",omitempty"`
+	Votes     int   `json:",omitempty"`
+}
+
+// NewSimControl returns a running control for a simulation on e.
+func NewSimControl(e *engine.Engine) *SimControl {
+	control := &SimControl{engine: e, idempotency: api.NewIdempotencyStore(api.DefaultIdempotencyTTL)}
+	control.resumed = sync.NewCond(&control.mu)
+	return control
+}
+
+// SetConfigFile names the engine configuration file POST /config/reload
+// rereads.
+func (c *SimControl) SetConfigFile(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configFile = path
+}
+
+// Pause stops new users from being simulated until Resume.
+func (c *SimControl) Pause(ctx context.Context) {
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+	c.engine.RecordControlContext(ctx, "control_pause", 0)
+}
+
+// Resume restarts a paused simulation.
+func (c *SimControl) Resume(ctx context.Context) {
+	c.mu.Lock()
+	c.paused = false
+	c.resumed.Broadcast()
+	c.mu.Unlock()
+	c.engine.RecordControlContext(ctx, "control_resume", 0)
+}
+
+// SetRate limits how many users are simulated per second. Zero removes
+// the limit.
+func (c *SimControl) SetRate(ctx context.Context, usersPerSecond float64) error {
+	if usersPerSecond < 0 {
+		return ErrInvalidRate
+	}
+	c.mu.Lock()
+	c.usersPerSecond = usersPerSecond
+	c.mu.Unlock()
+	c.engine.RecordControlContext(ctx, "control_rate", int64(usersPerSecond))
+	return nil
+}
+
+// AddUsers extends the run by count users beyond the planned total.
+func (c *SimControl) AddUsers(ctx context.Context, count int) error {
+	if count <= 0 {
+		return ErrInvalidUserCount
+	}
+	c.mu.Lock()
+	c.extraUsers += count
+	c.mu.Unlock()
+	c.engine.RecordControlContext(ctx, "control_add_users", int64(count))
+	return nil
+}
+
+// Inject performs the request's action now, as the request's user.
+func (c *SimControl) Inject(ctx context.Context, request InjectRequest) (InjectReceipt, error) {
+	e := c.engine
+	e.Mutex.RLock()
+	userID := request.UserID
+	if userID == 0 && len(e.Users) > 0 {
+		userID = RandomUserID(e)
+	}
+	user := e.Users[userID]
+	post := e.FindPost(request.TargetID)
+	e.Mutex.RUnlock()
+	if user == nil {
+		return InjectReceipt{}, ErrUserNotFound
+	}
+	receipt := InjectReceipt{Type: request.Type, UserID: user.ID}
+	content := request.Content
+	if content == "" {
+		content = fmt.Sprintf("Injected %s from %s", request.Type, user.Username)
+	}
+
+	var err error
+	switch request.Type {
+	case "post":
+		var created *engine.Post
+		if created, err = e.CreatePostContext(ctx, user, request.SubReddit, content); err == nil {
+			receipt.PostID = created.ID
+		}
+	case "comment", "upvote", "downvote":
+		if post == nil {
+			return InjectReceipt{}, engine.ErrPostNotFound
+		}
+		receipt.PostID = post.ID
+		switch request.Type {
+		case "comment":
+			var comment *engine.Comment
+			if comment, err = e.CommentPostContext(ctx, user, post, content); err == nil {
+				receipt.CommentID = comment.ID
+			}
+		case "upvote":
+			err = e.UpvotePostContext(ctx, user, post)
+		case "downvote":
+			err = e.DownvotePostContext(ctx, user, post)
+		}
+		if request.Type != "comment" {
+			e.Mutex.RLock()
+			receipt.Votes = post.Votes
+			e.Mutex.RUnlock()
+		}
+	default:
+		err = e.PerformActionContext(ctx, user, request.Type, map[string]interface{}{"post": post})
+	}
+	if err != nil {
+		return InjectReceipt{}, err
+	}
+	c.mu.Lock()
+	c.injected++
+	c.mu.Unlock()
+	e.RecordControlContext(ctx, "control_inject", request.TargetID)
+	return receipt, nil
+}
+
+// Status reports the simulation's progress.
+func (c *SimControl) Status() SimControlStatus {
+	c.mu.Lock()
+	status := SimControlStatus{Paused: c.paused, UsersPerSecond: c.usersPerSecond, PendingUsers: c.extraUsers, Injected: c.injected}
+	c.mu.Unlock()
+	status.Replays = c.idempotency.Replays()
+	c.engine.Mutex.RLock()
+	status.Users = len(c.engine.Users)
+	status.TotalActions = c.engine.TotalActions
+	c.engine.Mutex.RUnlock()
+	return status
+}
+
+// checkpoint blocks while the simulation is paused and paces new users to
+// the configured rate. It returns the number of users added since the last
+// call. A nil control never blocks.
+func (c *SimControl) checkpoint() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	for c.paused {
+		c.resumed.Wait()
+	}
+	var wait time.Duration
+	if c.usersPerSecond > 0 {
+		next := c.lastUser.Add(time.Duration(float64(time.Second) / c.usersPerSecond))
+		wait = time.Until(next)
+	}
+	extra := c.extraUsers
+	c.extraUsers = 0
+	c.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	c.mu.Lock()
+	c.lastUser = time.Now()
+	c.mu.Unlock()
+	return extra
+}
+
+// Handler serves the admin API:
+//
+//	GET  /status
+//	POST /pause
+//	POST /resume
+//	POST /rate?users_per_second=N   (0 removes the limit)
+//	POST /users?count=N
+//	POST /inject                    (JSON InjectRequest body, InjectReceipt reply)
+//	GET  /config                    (the engine's engine.Config)
+//	POST /config                    (JSON engine.Config body; settings left out are kept)
+//	POST /config/reload             (reread the file given to SetConfigFile)
+//
+// The /config POSTs reply with the settings they changed, which are also
+// written to the engine's audit log.
+//
+// /inject answers 503 with a Retry-After header while the engine signals
+// backpressure.
+//
+// POSTs may carry an Idempotency-Key header; a retry with the same key and
+// request within a day gets the original response, marked with an
+// Idempotent-Replayed header, rather than being applied again.
+func (c *SimControl) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.Status())
+	})
+	mux.HandleFunc("/pause", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		c.Pause(r.Context())
+		writeJSON(w, c.Status())
+	}))
+	mux.HandleFunc("/resume", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		c.Resume(r.Context())
+		writeJSON(w, c.Status())
+	}))
+	mux.HandleFunc("/rate", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		rate, err := strconv.ParseFloat(r.URL.Query().Get("users_per_second"), 64)
+		if err == nil {
+			err = c.SetRate(r.Context(), rate)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, c.Status())
+	}))
+	mux.HandleFunc("/users", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		count, err := strconv.Atoi(r.URL.Query().Get("count"))
+		if err == nil {
+			err = c.AddUsers(r.Context(), count)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, c.Status())
+	}))
+	mux.HandleFunc("/inject", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		var overloaded *engine.BackpressureError
+		if errors.As(c.engine.Backpressure(), &overloaded) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(overloaded.RetryAfter.Seconds()))))
+			http.Error(w, overloaded.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		var request InjectRequest
+		var receipt InjectReceipt
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err == nil {
+			receipt, err = c.Inject(r.Context(), request)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, receipt)
+	}))
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, c.engine.GetConfig())
+		case http.MethodPost:
+			writeConfigChanges(w, func() ([]engine.ConfigChange, error) { return c.engine.PatchConfig(nil, r.Body) })
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/config/reload", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		path := c.configFile
+		c.mu.Unlock()
+		if path == "" {
+			http.Error(w, "no config file to reload", http.StatusNotFound)
+			return
+		}
+		writeConfigChanges(w, func() ([]engine.ConfigChange, error) { return c.engine.ReloadConfig(path) })
+	}))
+	return c.idempotency.Middleware(mux)
+}
+
+// writeConfigChanges replies with the settings apply changed.
+func writeConfigChanges(w http.ResponseWriter, apply func() ([]engine.ConfigChange, error)) {
+	changes, err := apply()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if changes == nil {
+		changes = []engine.ConfigChange{}
+	}
+	writeJSON(w, changes)
+}
+
+// postOnly rejects requests that would change the simulation unless they
+// are POSTs.
+func postOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,443 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Simulator Functions
+
+var translationLangs = []string{"es", "de", "fr", "ja"}
+
+var simulatedLinks = []string{
+	"https://example.com/news/launch",
+	"https://www.example.com/news/launch/",
+	"https://example.org/science/discovery",
+	"https://example.net/gaming/patch-notes",
+	"http://Example.net/gaming/patch-notes#comments",
+}
+
+var DefaultRules = []engine.Rule{
+	{Title: "Be civil", Description: "No personal attacks or harassment."},
+	{Title: "No spam", Description: "No self-promotion or repetitive content."},
+	{Title: "Stay on topic", Description: "Posts must be relevant to the community."},
+}
+
+// pickModerator returns the subreddit's longest-standing moderator by ID,
+// or the first admin if it has none.
+func pickModerator(e *engine.Engine, subRedditName string) *engine.User {
+	var moderator *engine.User
+	for _, mod := range e.SubReddits[subRedditName].Moderators {
+		if moderator == nil || mod.ID < moderator.ID {
+			moderator = mod
+		}
+	}
+	if moderator == nil {
+		return firstAdmin(e)
+	}
+	return moderator
+}
+
+// simulatedContent occasionally turns content into spam so content policies
+// have something to catch.
+func simulatedContent(content string, rng *rand.Rand) string {
+	switch roll := rng.Float64(); {
+	case roll < 0.03:
+		return content + " - cheap followers, not a scam"
+	case roll < 0.04:
+		// Pasted text with stray control characters, which get stripped
+		return content + "\r\x00"
+	case roll < 0.045:
+		// An accidental submit with nothing typed
+		return "   "
+	}
+	return content
+}
+
+// firstAdmin returns the admin with the lowest ID, or nil if there is none.
+func firstAdmin(e *engine.Engine) *engine.User {
+	for id := e.IDOffset + 1; id < e.UserID; id++ {
+		if user := e.Users[id]; user != nil && user.IsAdmin {
+			return user
+		}
+	}
+	return nil
+}
+
+func addDefaultRules(e *engine.Engine, admin *engine.User) {
+	for name := range e.SubReddits {
+		for _, rule := range DefaultRules {
+			e.AddRule(admin, name, rule.Title, rule.Description)
+		}
+	}
+}
+
+// SimulateUsers drives numUsers simulated users through a world already
+// loaded into the engine, as often as activity says. subRedditNames lists its
+// subreddits most popular first. Every user action's outcome is recorded in
+// results.
+func SimulateUsers(e *engine.Engine, numUsers int, subRedditNames []string, activity ActivityConfig, results *ActionResults, control *SimControl) {
+	e.RegisterAction("award", engine.AwardAction)
+	clock, simulated := e.Clock.(*engine.SimClock)
+	var recentComments []*engine.Comment
+	numSubReddits := len(subRedditNames)
+	popular := newPopularity(subRedditNames, activity.ZipfExponent, e.Rand)
+	admin := firstAdmin(e)
+	if admin != nil {
+		addDefaultRules(e, admin)
+	}
+	cakeDay := -1
+	defer e.CheckCakeDays()
+
+	for i := 0; i < numUsers; i++ {
+		numUsers += control.checkpoint()
+		awaitBackpressure(e)
+		username := fmt.Sprintf("User%d", i+1)
+		user, err := e.RegisterUser(username)
+		if err != nil {
+			break
+		}
+		if simulated {
+			clock.Advance(time.Minute)
+			e.RunScheduled()
+		}
+		if admin == nil {
+			admin = user
+			e.MakeAdmin(admin)
+			addDefaultRules(e, admin)
+		}
+		if simulated && i == 0 {
+			scheduleEventThread(e, admin, subRedditNames[numSubReddits-1], results)
+		}
+		// Simulated accounts predate the simulation by up to three years,
+		// except spammers, who use fresh throwaways
+		if e.Rand.Float64() < 0.03 {
+			user.Persona = engine.PersonaSpammer
+		} else {
+			user.Persona = randomDiurnalPersona(e.Rand)
+			user.CreatedAt = user.CreatedAt.AddDate(0, 0, -e.Rand.Intn(3*365))
+		}
+		if today := e.Clock.Now().YearDay(); today != cakeDay {
+			e.CheckCakeDays()
+			cakeDay = today
+		}
+		e.SetInterestProfile(user, randomInterestProfile(e.Rand))
+		e.SetUserAge(user, randomAge(e.Rand))
+		// Some newcomers decline the default subreddits at sign-up
+		if e.Rand.Float64() < 0.15 {
+			e.OptOutOfDefaults(user)
+		}
+		if e.Rand.Float64() < 0.3 {
+			name, avatar := fmt.Sprintf("Simulated Person %d", i+1), fmt.Sprintf("https://avatars.example.com/%s.png", username)
+			results.Do("update_profile", user, func() error {
+				return e.UpdateProfile(user, engine.ProfileUpdate{DisplayName: &name, AvatarURL: &avatar})
+			})
+		}
+		subCount := int(float64(numSubReddits)*math.Pow(e.Rand.Float64(), 1.2)) + 1
+		for _, subRedditName := range chooseSubReddits(e, popular, user.InterestProfile, subCount) {
+			err := results.Do("join", user, func() error { return e.JoinSubReddit(user, subRedditName) })
+			if err == nil && len(e.SubReddits[subRedditName].Moderators) == 0 {
+				e.AddModerator(admin, user, subRedditName)
+			}
+		}
+
+		// Randomly disconnect/connect users
+		if e.Rand.Float64() > 0.2 {
+			user.Connected = true
+		} else {
+			user.Connected = false
+			e.DisconnectedUsers++
+		}
+
+		// Create posts and comments
+		for j := 0; j < e.Rand.Intn(3)+1; j++ {
+			if user.Connected {
+				var post *engine.Post
+				subRedditName := popular.pick()
+				results.Do("create_post", user, func() (err error) {
+					post, err = e.CreatePost(user, subRedditName, simulatedContent(fmt.Sprintf("Post content %d from %s", j+1, username), e.Rand))
+					return err
+				})
+				if post != nil {
+					// Some authors run their posts as AMAs
+					if e.Rand.Float64() < 0.05 {
+						results.Do("set_comment_sort", user, func() error { return e.SetCommentSort(user, post, engine.CommentSortQA) })
+					}
+					if e.Rand.Float64() < 0.05 {
+						results.Do("mark_nsfw", user, func() error { return e.SetPostNSFW(user, post, true) })
+					}
+					for k := 0; k < e.Rand.Intn(3)+1; k++ {
+						voter := e.Users[RandomUserID(e)]
+						results.Do("upvote_post", voter, func() error { return e.UpvotePost(voter, post) })
+					}
+					// Simulate comments on posts
+					for l := 0; l < e.Rand.Intn(2)+1; l++ {
+						if e.Rand.Float64() >= activity.CommentProbability {
+							continue
+						}
+						var comment *engine.Comment
+						results.Do("comment", user, func() (err error) {
+							comment, err = e.CommentPost(user, post, simulatedContent(fmt.Sprintf("Comment %d on post %d", l+1, post.ID), e.Rand))
+							return err
+						})
+						if comment == nil {
+							break
+						}
+						recentComments = append(recentComments, comment)
+						for m := 0; m < e.Rand.Intn(2)+1; m++ {
+							if e.Rand.Float64() >= activity.ReplyProbability {
+								continue
+							}
+							var reply *engine.Comment
+							results.Do("reply", user, func() (err error) {
+								reply, err = e.AddReplyToComment(user, comment, simulatedContent(fmt.Sprintf("Reply %d to comment %d", m+1, comment.ID), e.Rand))
+								return err
+							})
+							if reply != nil && e.Rand.Float64() < activity.ReplyVoteProbability {
+								voter := e.Users[RandomUserID(e)]
+								results.Do("upvote_comment", voter, func() error { return e.UpvoteComment(voter, reply) })
+							}
+						}
+						if e.Rand.Float64() < activity.CommentVoteProbability {
+							voter := e.Users[RandomUserID(e)]
+							results.Do("upvote_comment", voter, func() error { return e.UpvoteComment(voter, comment) })
+						}
+						// Some comments draw a pile-on of downvotes
+						if e.Rand.Float64() < activity.PileOnProbability {
+							for n := e.Rand.Intn(6) + 2; n > 0; n-- {
+								voter := e.Users[RandomUserID(e)]
+								results.Do("downvote_comment", voter, func() error { return e.DownvoteComment(voter, comment) })
+							}
+						}
+					}
+					// Simulate reposts
+					if e.Rand.Float64() < 0.1 {
+						results.Do("repost", user, func() error {
+							_, err := e.CreateRepost(user, post, popular.pick())
+							return err
+						})
+					}
+					// Simulate readers requesting translations
+					if e.Rand.Float64() < 0.1 {
+						results.Do("translate", user, func() error {
+							_, err := e.TranslateContent(post, translationLangs[e.Rand.Intn(len(translationLangs))])
+							return err
+						})
+					}
+					// Simulate readers skimming thread summaries
+					if e.Rand.Float64() < 0.05 {
+						results.Do("summarize", user, func() error {
+							_, err := e.SummarizeThread(post.ID)
+							return err
+						})
+					}
+					// Simulate moderators removing rule-breaking posts
+					if e.Rand.Float64() < 0.03 {
+						mod := pickModerator(e, post.SubReddit)
+						results.Do("remove_post", mod, func() error { return e.RemovePost(mod, post, e.Rand.Intn(len(DefaultRules))+1) })
+					}
+					// Simulate occasional viral posts
+					if e.Rand.Float64() < 0.01 {
+						injectViralEvent(e, post, 50+e.Rand.Intn(50))
+					}
+					// Simulate awards from other users
+					if e.Rand.Float64() < 0.05 && len(e.Users) > 1 {
+						giver := e.Users[RandomUserID(e)]
+						results.Do("award", giver, func() error { return e.PerformAction(giver, "award", map[string]interface{}{"post": post}) })
+					}
+				}
+			}
+		}
+
+		// Simulate authors going back to edit earlier comments
+		if e.Rand.Float64() < 0.1 && len(recentComments) > 0 {
+			comment := recentComments[e.Rand.Intn(len(recentComments))]
+			results.Do("edit_comment", comment.Author, func() error {
+				return e.EditComment(comment.Author, comment, comment.Content()+" (edit: typo)")
+			})
+		}
+
+		// Simulate authors deleting posts and comments they regret
+		if e.Rand.Float64() < 0.03 && len(recentComments) > 0 {
+			comment := recentComments[e.Rand.Intn(len(recentComments))]
+			results.Do("delete_comment", comment.Author, func() error { return e.DeleteComment(comment.Author, comment) })
+		}
+		if e.Rand.Float64() < 0.02 {
+			if post := randomPost(e, popular); post != nil {
+				results.Do("delete_post", post.Author, func() error { return e.DeletePost(post.Author, post) })
+			}
+		}
+
+		// Simulate admins suspending earlier users
+		if e.Rand.Float64() < 0.02 && user.ID > e.IDOffset+1 {
+			target := earlierUser(e, user)
+			results.Do("suspend", admin, func() error { return e.SuspendUser(admin, target, time.Duration(e.Rand.Intn(60)+1)*time.Minute) })
+		}
+
+		// Let an earlier user's engagement evolve, possibly churning them
+		if reader := earlierUser(e, user); reader != nil {
+			results.Do("engagement", reader, func() error {
+				e.ApplyEngagement(reader, e.EngagementSignalsFor(reader, engine.SortHot))
+				return nil
+			})
+		}
+
+		// Simulate necro-votes on posts from earlier users
+		if e.Rand.Float64() < activity.NecroVoteProbability && user.ID > e.IDOffset+1 {
+			if post := randomPost(e, popular); post != nil {
+				results.Do("upvote_post", user, func() error { return e.UpvotePost(user, post) })
+			}
+		}
+
+		// Simulate browsing subreddit listings
+		for v := 0; v < e.Rand.Intn(4); v++ {
+			results.Do("subreddit_feed", user, func() error {
+				_, err := e.GetSubRedditFeed(user, popular.pick())
+				return err
+			})
+		}
+
+		// Simulate link submissions, some of them reposting popular URLs
+		if user.Connected && e.Rand.Float64() < 0.3 {
+			link := simulatedLinks[e.Rand.Intn(len(simulatedLinks))]
+			results.Do("link_post", user, func() error {
+				_, err := e.CreateLinkPost(user, popular.pick(), "Check this out", link)
+				return err
+			})
+		}
+
+		// Simulate gallery and video posts
+		if e.Rand.Float64() < 0.05 {
+			results.Do("gallery_post", user, func() error {
+				_, err := e.CreateAttachmentPost(user, popular.pick(), fmt.Sprintf("Some shots from %s", user.Username), simulatedAttachments(user.Username, i, e.Rand))
+				return err
+			})
+		}
+
+		// Moderators work through approval queues every few users
+		if i%10 == 9 {
+			reviewApprovalQueues(e, subRedditNames, results)
+		}
+
+		// Simulate direct messages
+		if user.Persona == engine.PersonaSpammer && len(e.Users) > 1 {
+			simulateSpamCampaign(e, user, results)
+		} else if e.Rand.Float64() < 0.2 && len(e.Users) > 1 {
+			targetUserID := RandomUserID(e)
+			if targetUserID != user.ID {
+				targetUser := e.Users[targetUserID]
+				results.Do("message", user, func() error {
+					return e.SendDirectMessage(user, targetUser, fmt.Sprintf("Hello from %s to %s!", user.Username, targetUser.Username))
+				})
+				simulateFollow(e, user, targetUser, results)
+			}
+		}
+		// Someone checks their message requests
+		if e.Rand.Float64() < 0.5 {
+			reviewDMRequests(e, e.Users[RandomUserID(e)], results)
+		}
+
+		// Simulate users discovering they signed up twice
+		if e.Rand.Float64() < 0.01 {
+			if primary := earlierUser(e, user); primary != nil {
+				results.Do("merge_accounts", primary, func() error {
+					_, err := e.MergeAccounts(primary, user)
+					return err
+				})
+			}
+		}
+	}
+}
+
+// eventThreadLead is how long before an event the simulated admin closes its
+// subreddit and stages the event thread.
+const eventThreadLead = 30 * time.Minute
+
+// scheduleEventThread closes subRedditName until an upcoming event and posts
+// its thread in advance, so the thread and every early submission go up
+// together when the subreddit unlocks.
+func scheduleEventThread(e *engine.Engine, admin *engine.User, subRedditName string, results *ActionResults) {
+	err := results.Do("schedule_unlock", admin, func() error {
+		return e.ScheduleUnlock(admin, subRedditName, e.Clock.Now().Add(eventThreadLead))
+	})
+	if err != nil {
+		return
+	}
+	results.Do("create_post", admin, func() error {
+		_, err := e.CreatePost(admin, subRedditName, "Event thread: live discussion")
+		return err
+	})
+}
+
+// reviewApprovalQueues has each subreddit's moderator approve most pending
+// posts and reject the rest.
+func reviewApprovalQueues(e *engine.Engine, subRedditNames []string, results *ActionResults) {
+	for _, name := range subRedditNames {
+		mod := pickModerator(e, name)
+		if mod == nil {
+			continue
+		}
+		queue, _ := e.GetApprovalQueue(mod, name)
+		for _, post := range queue {
+			if e.Rand.Float64() < 0.9 {
+				results.Do("approve_post", mod, func() error { return e.ApprovePost(mod, post) })
+			} else {
+				results.Do("remove_post", mod, func() error { return e.RemovePost(mod, post, 1) })
+			}
+		}
+	}
+}
+
+// randomPost picks a random post from a subreddit drawn by popularity, or
+// nil if that subreddit has none.
+func randomPost(e *engine.Engine, popular *popularity) *engine.Post {
+	posts := e.SubReddits[popular.pick()].Posts
+	if len(posts) == 0 {
+		return nil
+	}
+	return posts[e.Rand.Intn(len(posts))]
+}
+
+// injectViralEvent piles upvotes from random users onto a post within a
+// single velocity window. Users drawn twice only count once.
+func injectViralEvent(e *engine.Engine, post *engine.Post, votes int) {
+	for i := 0; i < votes; i++ {
+		e.UpvotePost(e.Users[RandomUserID(e)], post)
+	}
+}
+
+// RandomUserID picks an existing user ID. User IDs are allocated without
+// gaps, so the engine's users are exactly IDOffset+1..IDOffset+len(Users).
+func RandomUserID(e *engine.Engine) int64 {
+	return e.IDOffset + 1 + e.Rand.Int63n(int64(len(e.Users)))
+}
+
+// earlierUser picks a user who registered before user, or nil if user was
+// the first.
+func earlierUser(e *engine.Engine, user *engine.User) *engine.User {
+	earlier := user.ID - e.IDOffset - 1
+	if earlier <= 0 {
+		return nil
+	}
+	return e.Users[e.IDOffset+1+e.Rand.Int63n(earlier)]
+}
+
+// simulatedAttachments returns a gallery of two to five images, or now and
+// then a single video.
+func simulatedAttachments(username string, n int, rng *rand.Rand) []engine.Attachment {
+	if rng.Float64() < 0.3 {
+		return []engine.Attachment{{Type: engine.AttachmentVideo, Reference: fmt.Sprintf("https://media.example.com/%s/%d.mp4", username, n)}}
+	}
+	attachments := make([]engine.Attachment, rng.Intn(4)+2)
+	for i := range attachments {
+		attachments[i] = engine.Attachment{Type: engine.AttachmentImage, Reference: fmt.Sprintf("https://media.example.com/%s/%d-%d.jpg", username, n, i+1)}
+		if rng.Float64() < 0.5 {
+			attachments[i].Caption = fmt.Sprintf("Photo %d of %d", i+1, len(attachments))
+		}
+	}
+	return attachments
+}
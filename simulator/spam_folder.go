@@ -0,0 +1,28 @@
+package simulator
+
+import "github.com/sahasgundapaneni/reddit-clone/engine"
+
+// Spam Campaigns
+
+// simulateSpamCampaign has a spammer send the same pitch to a handful of
+// random users in quick succession.
+func simulateSpamCampaign(e *engine.Engine, spammer *engine.User, results *ActionResults) {
+	pitch, recipients := spamPitches[e.Rand.Intn(len(spamPitches))], e.Rand.Intn(6)+3
+	for i := 0; i < recipients; i++ {
+		targetUserID := RandomUserID(e)
+		if targetUserID == spammer.ID {
+			continue
+		}
+		target := e.Users[targetUserID]
+		results.Do("message", spammer, func() error {
+			e.SendDirectMessage(spammer, target, pitch)
+			return nil
+		})
+	}
+}
+
+var spamPitches = []string{
+	"Earn $500 a day from home, DM me for details!",
+	"Cheap followers and upvotes at https://boost.example.com",
+	"You have been selected for a $100 gift card, claim it now",
+}
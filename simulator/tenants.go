@@ -0,0 +1,52 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Tenant Simulation
+
+// RunTenants simulates count tenants in parallel, giving every other tenant
+// a quota of half the simulated users and posts so rejections show up. Each
+// tenant's engine is seeded from seed, or from the clock if it is zero.
+func RunTenants(count, numUsers, numSubReddits int, seed int64) []engine.TenantMetrics {
+	host := engine.NewHost()
+	seeds := rand.New(rand.NewSource(seed))
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		quota := engine.TenantQuota{}
+		if i%2 == 1 {
+			quota = engine.TenantQuota{MaxUsers: numUsers / 2, MaxSubReddits: numSubReddits, MaxPosts: numUsers / 2}
+		}
+		tenant, _ := host.CreateTenant(fmt.Sprintf("tenant%d", i+1), quota)
+		tenant.Engine.Clock = engine.NewSimClock(time.Now())
+		if seed != 0 {
+			tenant.Engine.Rand = engine.NewRand(seeds.Int63())
+		}
+		wg.Add(1)
+		go func(e *engine.Engine) {
+			defer wg.Done()
+			world := GeneratedWorld(numSubReddits, e.Rand)
+			if err := e.LoadWorld(world); err != nil {
+				return
+			}
+			SimulateUsers(e, numUsers, world.SubRedditNames(), DefaultActivity, &ActionResults{}, nil)
+		}(tenant.Engine)
+	}
+	wg.Wait()
+	return host.Metrics()
+}
+
+// PrintTenantMetrics prints a line per tenant.
+func PrintTenantMetrics(w io.Writer, metrics []engine.TenantMetrics) {
+	fmt.Fprintln(w, "\nTenants:")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "%s - Users: %d, SubReddits: %d, Posts: %d, Comments: %d, Votes: %d, Actions: %d, Quota rejections: %d\n", m.ID, m.Users, m.SubReddits, m.TotalPosts, m.TotalComments, m.TotalVotes, m.TotalActions, m.QuotaRejections)
+	}
+}
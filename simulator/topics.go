@@ -0,0 +1,94 @@
+package simulator
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Interest Profiles
+
+var simulatedTopics = []string{"gaming", "news", "science", "sports", "music", "technology", "movies", "food"}
+
+// dominantTopic returns the user's highest-weighted topic, or "" if none.
+func dominantTopic(profile map[string]float64) string {
+	best, bestWeight := "", 0.0
+	for topic, weight := range profile {
+		if weight > bestWeight || (weight == bestWeight && topic < best) {
+			best, bestWeight = topic, weight
+		}
+	}
+	return best
+}
+
+// randomInterestProfile gives a simulated user one to three topics with
+// weights summing to 1.
+func randomInterestProfile(rng *rand.Rand) map[string]float64 {
+	count := rng.Intn(3) + 1
+	profile := make(map[string]float64, count)
+	total := 0.0
+	for _, i := range rng.Perm(len(simulatedTopics))[:count] {
+		weight := rng.Float64() + 0.1
+		profile[simulatedTopics[i]] = weight
+		total += weight
+	}
+	for topic := range profile {
+		profile[topic] /= total
+	}
+	return profile
+}
+
+// subRedditCandidates is how many subreddits chooseSubReddits draws by
+// popularity for each one it picks by interest.
+const subRedditCandidates = 3
+
+// chooseSubReddits picks up to count subreddits: for each, it draws
+// candidates by popularity and keeps one of them, weighted by the user's
+// interest in its topics. Popular subreddits can be picked more than once,
+// so the result, in name order, may hold fewer than count.
+func chooseSubReddits(e *engine.Engine, popular *popularity, profile map[string]float64, count int) []string {
+	picked := make(map[string]bool, count)
+	var candidates [subRedditCandidates]string
+	var weights [subRedditCandidates]float64
+	for i := 0; i < count; i++ {
+		total := 0.0
+		for j := range candidates {
+			candidates[j] = popular.pick()
+			weights[j] = 0.05 + engine.TopicAffinity(profile, e.SubReddits[candidates[j]].Topics)
+			total += weights[j]
+		}
+		r := e.Rand.Float64() * total
+		for j, weight := range weights {
+			if r -= weight; r <= 0 || j == len(weights)-1 {
+				picked[candidates[j]] = true
+				break
+			}
+		}
+	}
+	chosen := make([]string, 0, len(picked))
+	for name := range picked {
+		chosen = append(chosen, name)
+	}
+	sort.Strings(chosen)
+	return chosen
+}
+
+// OnTopicShare is the fraction of a subreddit's members whose dominant
+// interest is one of its topics.
+func OnTopicShare(subReddit *engine.SubReddit) float64 {
+	if len(subReddit.Users) == 0 {
+		return 0
+	}
+	onTopic := 0
+	for _, user := range subReddit.Users {
+		dominant := dominantTopic(user.InterestProfile)
+		for _, topic := range subReddit.Topics {
+			if topic == dominant {
+				onTopic++
+				break
+			}
+		}
+	}
+	return float64(onTopic) / float64(len(subReddit.Users))
+}
@@ -0,0 +1,68 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Vote Stream Consumer
+
+// ExternalScores is a minimal out-of-process style consumer that rebuilds
+// post and comment scores from the vote stream, skipping redelivered deltas.
+type ExternalScores struct {
+	applied  int64
+	posts    map[int64]int
+	comments map[int64]int
+	finished chan struct{}
+}
+
+// ConsumeVoteScores applies every batch on stream until it closes,
+// acknowledging each one.
+func ConsumeVoteScores(stream *engine.VoteStream) *ExternalScores {
+	scores := &ExternalScores{posts: make(map[int64]int), comments: make(map[int64]int), finished: make(chan struct{})}
+	go func() {
+		defer close(scores.finished)
+		for batch := range stream.C {
+			for _, vote := range batch.Deltas {
+				if vote.Seq <= scores.applied {
+					continue
+				}
+				scores.applied = vote.Seq
+				if vote.Kind == "comment" {
+					scores.comments[vote.TargetID] += vote.Delta
+				} else {
+					scores.posts[vote.TargetID] += vote.Delta
+				}
+			}
+			stream.Ack(batch.LastSeq)
+		}
+	}()
+	return scores
+}
+
+// MismatchedPostScores counts hot posts whose vote total differs from the
+// external consumer's. The stream must already be closed.
+func MismatchedPostScores(e *engine.Engine, scores *ExternalScores) int {
+	<-scores.finished
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	mismatched := 0
+	for _, subReddit := range e.SubReddits {
+		for _, post := range subReddit.Posts {
+			if scores.posts[post.ID] != post.Votes {
+				mismatched++
+			}
+		}
+	}
+	return mismatched
+}
+
+// PrintVoteStreamReport prints the stream's deliveries and whether the
+// consumer ended up with the engine's scores.
+func PrintVoteStreamReport(w io.Writer, stats engine.VoteStreamStats, drained bool, mismatched int) {
+	fmt.Fprintln(w, "\nVote Change Feed:")
+	fmt.Fprintf(w, "Deltas: %d, Batches: %d, Redeliveries: %d, Drained: %t\n", stats.Published, stats.Batches, stats.Redelivered, drained)
+	fmt.Fprintf(w, "Posts with mismatched external scores: %d\n", mismatched)
+}
@@ -0,0 +1,55 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Generated World
+
+var simulatedPolicy = engine.ContentPolicy{
+	BannedWords:       []string{"spam", "scam"},
+	MaxLength:         200,
+	WarningsBeforeBan: 2,
+	BanDuration:       24 * time.Hour,
+}
+
+// GeneratedWorld is the world the simulator uses when no definition file is
+// given: numSubReddits subreddits cycling through the simulated topics, a few
+// with restrictive crosspost settings, a content policy, pre-moderation or
+// a comment karma requirement, and no seed users. The three most popular
+// subreddits are the defaults; a few of the others are NSFW. The settings
+// are drawn from rng.
+func GeneratedWorld(numSubReddits int, rng *rand.Rand) *engine.WorldDefinition {
+	world := &engine.WorldDefinition{}
+	for i := 0; i < numSubReddits; i++ {
+		subReddit := engine.WorldSubReddit{
+			Name:   fmt.Sprintf("SubReddit%d", i+1),
+			Topics: []string{simulatedTopics[i%len(simulatedTopics)]},
+		}
+		if rng.Float64() < 0.2 {
+			subReddit.Settings = engine.SubRedditSettings{
+				DisallowCrosspostsIn:  rng.Float64() < 0.5,
+				DisallowCrosspostsOut: rng.Float64() < 0.5,
+			}
+		}
+		if rng.Float64() < 0.3 {
+			subReddit.Policy = simulatedPolicy
+		}
+		subReddit.Settings.RequireApproval = rng.Float64() < 0.2
+		if rng.Float64() < 0.1 {
+			subReddit.Settings.MinCommentKarmaToPost = rng.Intn(3) + 1
+		}
+		world.SubReddits = append(world.SubReddits, subReddit)
+	}
+	for _, subReddit := range world.SubReddits[:min(3, len(world.SubReddits))] {
+		world.DefaultSubReddits = append(world.DefaultSubReddits, subReddit.Name)
+	}
+	for i := len(world.DefaultSubReddits); i < len(world.SubReddits); i++ {
+		world.SubReddits[i].Settings.NSFW = rng.Float64() < 0.1
+	}
+	return world
+}
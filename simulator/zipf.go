@@ -0,0 +1,75 @@
+package simulator
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sahasgundapaneni/reddit-clone/engine"
+)
+
+// Zipf Popularity
+
+// popularity draws subreddits by popularity rank from a Zipf law: the i-th
+// of names, counting from one, with probability proportional to
+// 1/i^exponent. It draws from rng, so each goroutine needs its own.
+type popularity struct {
+	names []string
+	zipf  *rand.Zipf
+}
+
+// newPopularity returns the Zipf law over names, which are most popular
+// first and must not be empty, as LoadWorld ensures. The exponent must be
+// greater than 1, as ActivityConfig.Validate ensures.
+func newPopularity(names []string, exponent float64, rng *rand.Rand) *popularity {
+	return &popularity{names: names, zipf: rand.NewZipf(rng, exponent, 1, uint64(len(names)-1))}
+}
+
+func (p *popularity) pick() string {
+	return p.names[p.zipf.Uint64()]
+}
+
+// ZipfFit is the Zipf exponent fitted to how subreddits' organic
+// memberships and posts fall off with their rank.
+type ZipfFit struct {
+	Members float64
+	Posts   float64
+}
+
+// FitSubRedditPopularity fits a Zipf exponent to the subreddits' organic
+// memberships, leaving out default subscriptions, and to their posts.
+func FitSubRedditPopularity(e *engine.Engine) ZipfFit {
+	e.Mutex.RLock()
+	var members, posts []int
+	for _, subReddit := range e.SubReddits {
+		members = append(members, len(subReddit.Users)-len(subReddit.DefaultMembers))
+		posts = append(posts, len(subReddit.Posts))
+	}
+	e.Mutex.RUnlock()
+	return ZipfFit{Members: FitZipfExponent(members), Posts: FitZipfExponent(posts)}
+}
+
+// FitZipfExponent ranks counts from largest to smallest and returns the
+// exponent s of the Zipf law count ∝ 1/rank^s that fits them best by least
+// squares on a log-log scale. Zero counts are left out, and fewer than two
+// nonzero counts give no fit, which is reported as 0.
+func FitZipfExponent(counts []int) float64 {
+	sorted := append([]int(nil), counts...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	var n, sumX, sumY, sumXX, sumXY float64
+	for i, count := range sorted {
+		if count <= 0 {
+			break
+		}
+		x, y := math.Log(float64(i+1)), math.Log(float64(count))
+		n++
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	if n < 2 {
+		return 0
+	}
+	return -(n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+}